@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// airdropTimeout bounds the wait for an airdrop to be confirmed.
+const airdropTimeout = time.Minute
+
+func init() {
+	commands["airdrop"] = runAirdrop
+}
+
+func runAirdrop(ctx context.Context, args []string) error {
+	fs := newFlagSet("airdrop")
+	solFlag := fs.String("sol", "1", "Amount of SOL to request, e.g. 2")
+	toFlag := fs.String("to", "", "Account to fund (default: the signer)")
+	parseFlags(fs, args)
+
+	cluster, err := currentCluster()
+	if err != nil {
+		return err
+	}
+	if cluster.Cluster == "mainnet" {
+		return fmt.Errorf("%w: there is no faucet on mainnet", ErrInvalidArgument)
+	}
+	lamports, err := parseUIAmount(*solFlag, solDecimals)
+	if err != nil {
+		return err
+	}
+	if lamports == 0 {
+		return fmt.Errorf("%w: --sol must be positive", ErrInvalidArgument)
+	}
+	var to solanago.PublicKey
+	if *toFlag != "" {
+		if to, err = solanago.PublicKeyFromBase58(*toFlag); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	} else {
+		signer, err := loadSigner()
+		if err != nil {
+			return err
+		}
+		to = signer.PublicKey()
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeoutCause(ctx, airdropTimeout, fmt.Errorf("not confirmed within %s", airdropTimeout))
+	defer cancel()
+	slog.Info("requesting airdrop", "network", network, "to", to, "amount", formatUIAmount(lamports, solDecimals))
+	sig, err := client.RequestAirdrop(ctx, to, lamports, rpc.CommitmentConfirmed)
+	if err != nil {
+		// Public faucets limit how much and how often each address and IP can request.
+		return fmt.Errorf("airdrop refused, the faucet may be rate limiting (try less SOL, later, or https://faucet.solana.com): %w", classifyRPCError(err))
+	}
+	// The faucet signs the airdrop, so its blockhash and expiry aren't known.
+	if err := WaitForConfirmation(ctx, client, sig, 0, rpc.CommitmentConfirmed); err != nil {
+		return fmt.Errorf("airdrop: %w", err)
+	}
+	if balance, err := client.GetBalance(ctx, to, rpc.CommitmentConfirmed); err == nil {
+		slog.Info("airdrop confirmed", "balance", formatUIAmount(balance.Value, solDecimals))
+	}
+	fmt.Println(sig)
+	return nil
+}
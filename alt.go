@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// lookupTablesFile lists the address lookup tables created with `alt create` in the data directory. batch uses the
+// ones of the selected --network automatically.
+const lookupTablesFile = "lookup-tables.json"
+
+const (
+	// Address lookup table program instructions, encoded as a u32 index.
+	lookupTableCreate     = 0
+	lookupTableExtend     = 2
+	lookupTableDeactivate = 3
+	lookupTableClose      = 4
+	// maxLookupTableAddresses is how many addresses a lookup table holds at most.
+	maxLookupTableAddresses = 256
+	// addressesPerExtend is how many addresses one extend transaction adds, staying well within the transaction
+	// size limit.
+	addressesPerExtend = 20
+	// lookupTableCooldownSlots is how long a deactivated table has to wait before it can be closed: until its
+	// deactivation slot has left the SlotHashes sysvar.
+	lookupTableCooldownSlots = 513
+)
+
+func init() {
+	commands["alt"] = runALT
+}
+
+// configuredLookupTable is a lookup table created with `alt create`, on the network profile it was created on.
+type configuredLookupTable struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+// loadConfiguredLookupTables reads the lookup tables configured in dir. A missing file means there are none.
+func loadConfiguredLookupTables(dir string) ([]configuredLookupTable, error) {
+	path := filepath.Join(dir, lookupTablesFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read lookup tables: %v", err)
+	}
+	var tables []configuredLookupTable
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	return tables, nil
+}
+
+// saveConfiguredLookupTables writes tables to dir.
+func saveConfiguredLookupTables(dir string, tables []configuredLookupTable) error {
+	data, err := json.MarshalIndent(tables, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("can't create data directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lookupTablesFile), append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("can't save lookup tables: %v", err)
+	}
+	return nil
+}
+
+// configuredLookupTableOptions adds the lookup tables configured for --network to opts, so batches pack more
+// transfers per transaction without --lookup-table.
+func configuredLookupTableOptions(ctx context.Context, client RPCClient, opts *TransferOptions) error {
+	configured, err := loadConfiguredLookupTables(dataDir)
+	if err != nil {
+		return err
+	}
+	var addresses []solanago.PublicKey
+	for _, t := range configured {
+		address, err := solanago.PublicKeyFromBase58(t.Address)
+		if err != nil {
+			return fmt.Errorf("%w: invalid lookup table %q in %s: %v", ErrInvalidArgument, t.Address, lookupTablesFile, err)
+		}
+		if _, ok := opts.AddressTables[address]; t.Network == network && !ok {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	tables, err := LoadLookupTables(ctx, client, addresses)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	slog.Info("using configured lookup tables", "tables", len(tables))
+	if opts.AddressTables == nil {
+		opts.AddressTables = map[solanago.PublicKey]solanago.PublicKeySlice{}
+	}
+	maps.Copy(opts.AddressTables, tables)
+	opts.Versioned = true
+	return nil
+}
+
+func runALT(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "create" && args[0] != "extend" && args[0] != "close") {
+		return fmt.Errorf("%w: usage: alt create|extend|close [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("alt " + args[0])
+	tableFlag := fs.String("table", "", "Lookup table to extend or close (required for extend and close)")
+	addressesFile := fs.String("addresses", "", "File of addresses to add, one per line")
+	batchFile := fs.String("batch", "", "Batch file whose receivers' token accounts for --token are added, with the accounts every batch transfer uses")
+	parseFlags(fs, args[1:])
+
+	var table solanago.PublicKey
+	if args[0] == "create" {
+		if *tableFlag != "" {
+			return fmt.Errorf("%w: alt create makes a new table, --table doesn't apply", ErrInvalidArgument)
+		}
+	} else {
+		if *tableFlag == "" {
+			return fmt.Errorf("%w: --table flag is required", ErrInvalidArgument)
+		}
+		var err error
+		if table, err = solanago.PublicKeyFromBase58(*tableFlag); err != nil {
+			return fmt.Errorf("%w: invalid --table: %v", ErrInvalidArgument, err)
+		}
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	if args[0] == "close" {
+		return closeLookupTable(ctx, clients, signer, table)
+	}
+	addresses, err := lookupTableAddresses(ctx, clients.Read, signer.PublicKey(), *addressesFile, *batchFile)
+	if err != nil {
+		return err
+	}
+	if args[0] == "create" {
+		return createLookupTable(ctx, clients, signer, addresses)
+	}
+	return extendLookupTable(ctx, clients, signer, table, addresses)
+}
+
+// lookupTableAddresses collects the addresses to put in a lookup table: those listed in addressesFile, and for
+// batchFile the associated token accounts of its receivers for the selected token along with the mint, its token
+// program and the sender's token account, which every transfer of a batch uses.
+func lookupTableAddresses(ctx context.Context, client RPCClient, sender solanago.PublicKey, addressesFile, batchFile string) ([]solanago.PublicKey, error) {
+	if addressesFile == "" && batchFile == "" {
+		return nil, fmt.Errorf("%w: --addresses or --batch is required", ErrInvalidArgument)
+	}
+	set := map[solanago.PublicKey]bool{}
+	if addressesFile != "" {
+		listed, err := readAddressList(addressesFile)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(set, listed)
+	}
+	if batchFile != "" {
+		mintAddress, _, err := resolveMint(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		tm, err := loadTransferMint(ctx, client, mintAddress)
+		if err != nil {
+			return nil, err
+		}
+		senderAta, err := tm.tokenAccount(sender)
+		if err != nil {
+			return nil, fmt.Errorf("can't get ATA for sender %s: %v", sender, err)
+		}
+		set[mintAddress], set[tm.Program], set[senderAta] = true, true, true
+		records, err := readBatchRecords(batchFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			receiver, issue := parseBatchReceiver(record)
+			if issue != nil {
+				return nil, fmt.Errorf("%w: %s %s", ErrInvalidArgument, batchFile, issue)
+			}
+			receiverAta, err := tm.tokenAccount(receiver)
+			if err != nil {
+				return nil, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, receiver, err)
+			}
+			set[receiverAta] = true
+		}
+	}
+	addresses := slices.SortedFunc(maps.Keys(set), func(a, b solanago.PublicKey) int { return strings.Compare(a.String(), b.String()) })
+	return addresses, nil
+}
+
+// createLookupTable creates a lookup table with the signer as its authority, fills it with addresses and records
+// it in lookupTablesFile for the selected network.
+func createLookupTable(ctx context.Context, clients Clients, signer Signer, addresses []solanago.PublicKey) error {
+	if len(addresses) > maxLookupTableAddresses {
+		return fmt.Errorf("%w: %d addresses don't fit in a lookup table, which holds %d", ErrInvalidArgument, len(addresses), maxLookupTableAddresses)
+	}
+	// The table's address is derived from a recent slot, which the program checks against SlotHashes.
+	slot, err := clients.Read.GetSlot(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return fmt.Errorf("can't get slot: %w", classifyRPCError(err))
+	}
+	authority := signer.PublicKey()
+	table, bump, err := solanago.FindProgramAddress([][]byte{authority.Bytes(), binary.LittleEndian.AppendUint64(nil, slot)}, solanago.AddressLookupTableProgramID)
+	if err != nil {
+		return fmt.Errorf("can't derive lookup table address: %v", err)
+	}
+	data := binary.LittleEndian.AppendUint32(nil, lookupTableCreate)
+	data = binary.LittleEndian.AppendUint64(data, slot)
+	data = append(data, bump)
+	create := solanago.NewInstruction(solanago.AddressLookupTableProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(table, true, false),
+		solanago.NewAccountMeta(authority, false, true),
+		solanago.NewAccountMeta(authority, true, true), // payer
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+	}, data)
+
+	first := addresses[:min(addressesPerExtend, len(addresses))]
+	sig, err := sendInstructions(ctx, clients, signer, create, extendLookupTableInstruction(table, authority, first))
+	if err != nil {
+		return fmt.Errorf("can't create lookup table: %w", err)
+	}
+	slog.Info("created lookup table", "table", table, "addresses", len(first), "signature", sig)
+
+	configured, err := loadConfiguredLookupTables(dataDir)
+	if err != nil {
+		return err
+	}
+	configured = append(configured, configuredLookupTable{Network: network, Address: table.String()})
+	if err := saveConfiguredLookupTables(dataDir, configured); err != nil {
+		return err
+	}
+	if err := extendInChunks(ctx, clients, signer, table, addresses[len(first):]); err != nil {
+		return err
+	}
+	fmt.Println(table)
+	return nil
+}
+
+// extendLookupTable adds the addresses the table doesn't hold yet.
+func extendLookupTable(ctx context.Context, clients Clients, signer Signer, table solanago.PublicKey, addresses []solanago.PublicKey) error {
+	state, err := loadLookupTableState(ctx, clients.Read, table, signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	if state.DeactivationSlot != math.MaxUint64 {
+		return fmt.Errorf("%w: lookup table %s is deactivated", ErrInvalidArgument, table)
+	}
+	addresses = slices.DeleteFunc(addresses, func(a solanago.PublicKey) bool { return slices.Contains(state.Addresses, a) })
+	if len(addresses) == 0 {
+		slog.Info("lookup table already holds every address", "table", table)
+		return nil
+	}
+	if len(state.Addresses)+len(addresses) > maxLookupTableAddresses {
+		return fmt.Errorf("%w: lookup table %s holds %d addresses, %d more don't fit in %d", ErrInvalidArgument, table, len(state.Addresses), len(addresses), maxLookupTableAddresses)
+	}
+	return extendInChunks(ctx, clients, signer, table, addresses)
+}
+
+// extendInChunks adds addresses to the table, addressesPerExtend per transaction.
+func extendInChunks(ctx context.Context, clients Clients, signer Signer, table solanago.PublicKey, addresses []solanago.PublicKey) error {
+	for chunk := range slices.Chunk(addresses, addressesPerExtend) {
+		sig, err := sendInstructions(ctx, clients, signer, extendLookupTableInstruction(table, signer.PublicKey(), chunk))
+		if err != nil {
+			return fmt.Errorf("can't extend lookup table: %w", err)
+		}
+		slog.Info("extended lookup table", "table", table, "addresses", len(chunk), "signature", sig)
+	}
+	return nil
+}
+
+// extendLookupTableInstruction adds addresses to table, paid for by its authority.
+func extendLookupTableInstruction(table, authority solanago.PublicKey, addresses []solanago.PublicKey) solanago.Instruction {
+	data := binary.LittleEndian.AppendUint32(nil, lookupTableExtend)
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(addresses)))
+	for _, address := range addresses {
+		data = append(data, address.Bytes()...)
+	}
+	return solanago.NewInstruction(solanago.AddressLookupTableProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(table, true, false),
+		solanago.NewAccountMeta(authority, false, true),
+		solanago.NewAccountMeta(authority, true, true), // payer
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+	}, data)
+}
+
+// closeLookupTable closes the table and returns its rent to the signer. A table has to be deactivated first and
+// then cool down, so the first run deactivates an active table, and a run after the cool-down closes it.
+func closeLookupTable(ctx context.Context, clients Clients, signer Signer, table solanago.PublicKey) error {
+	state, err := loadLookupTableState(ctx, clients.Read, table, signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	authority := signer.PublicKey()
+	if state.DeactivationSlot == math.MaxUint64 {
+		deactivate := solanago.NewInstruction(solanago.AddressLookupTableProgramID, solanago.AccountMetaSlice{
+			solanago.NewAccountMeta(table, true, false),
+			solanago.NewAccountMeta(authority, false, true),
+		}, binary.LittleEndian.AppendUint32(nil, lookupTableDeactivate))
+		sig, err := sendInstructions(ctx, clients, signer, deactivate)
+		if err != nil {
+			return fmt.Errorf("can't deactivate lookup table: %w", err)
+		}
+		slog.Info(fmt.Sprintf("deactivated lookup table; run alt close again in about %d slots (~4 minutes) to close it", lookupTableCooldownSlots), "table", table, "signature", sig)
+		fmt.Println(sig)
+		return nil
+	}
+
+	slot, err := clients.Read.GetSlot(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return fmt.Errorf("can't get slot: %w", classifyRPCError(err))
+	}
+	if ready := state.DeactivationSlot + lookupTableCooldownSlots; slot < ready {
+		return fmt.Errorf("%w: lookup table %s is cooling down after deactivation, it can be closed in %d slots", ErrInvalidArgument, table, ready-slot)
+	}
+	closeTable := solanago.NewInstruction(solanago.AddressLookupTableProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(table, true, false),
+		solanago.NewAccountMeta(authority, false, true),
+		solanago.NewAccountMeta(authority, true, false), // rent recipient
+	}, binary.LittleEndian.AppendUint32(nil, lookupTableClose))
+	sig, err := sendInstructions(ctx, clients, signer, closeTable)
+	if err != nil {
+		return fmt.Errorf("can't close lookup table: %w", err)
+	}
+	slog.Info("closed lookup table", "table", table, "signature", sig)
+
+	configured, err := loadConfiguredLookupTables(dataDir)
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(configured, func(t configuredLookupTable) bool { return t.Address == table.String() })
+	if len(kept) != len(configured) {
+		if err := saveConfiguredLookupTables(dataDir, kept); err != nil {
+			return err
+		}
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// loadLookupTableState fetches and decodes the lookup table, which must have authority as its authority.
+func loadLookupTableState(ctx context.Context, client RPCClient, table, authority solanago.PublicKey) (*addresslookuptable.AddressLookupTableState, error) {
+	res, err := GetAccountInfo(ctx, client, table, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return nil, fmt.Errorf("%w: lookup table %s doesn't exist", ErrInvalidArgument, table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't get lookup table %s: %w", table, classifyRPCError(err))
+	}
+	state, err := addresslookuptable.DecodeAddressLookupTableState(res.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s isn't a lookup table: %v", ErrInvalidArgument, table, err)
+	}
+	if state.Authority == nil || !state.Authority.Equals(authority) {
+		return nil, fmt.Errorf("%w: the signer isn't the authority of lookup table %s", ErrSignerUnavailable, table)
+	}
+	return state, nil
+}
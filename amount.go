@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// amountPercent is --amount given as a percentage of the sender's balance, or nil if it's a whole number of
+// tokens.
+var amountPercent *big.Rat
+
+// setAmount parses --amount: a whole number of tokens, or a percentage of the sender's balance such as 50% or
+// 12.5%.
+func setAmount(value string) error {
+	if pct, ok := strings.CutSuffix(strings.TrimSpace(value), "%"); ok {
+		p, ok := new(big.Rat).SetString(pct)
+		if !ok || strings.ContainsAny(pct, "/eE") || p.Sign() <= 0 || p.Cmp(big.NewRat(100, 1)) > 0 {
+			return fmt.Errorf("invalid percentage %q, use more than 0%% and at most 100%%", value)
+		}
+		amountPercent, amount = p, 0
+		return nil
+	}
+	whole, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q, use a whole number of tokens or a percentage such as 50%%", value)
+	}
+	amountPercent, amount = nil, whole
+	return nil
+}
+
+// percentOfBalance returns percent of the balance of the token account, in raw base units rounded down. An
+// account that doesn't exist holds nothing.
+func percentOfBalance(ctx context.Context, client RPCClient, account solanago.PublicKey, percent *big.Rat) (uint64, error) {
+	ta, err := getTokenAccount(ctx, client, account)
+	if err != nil {
+		return 0, err
+	}
+	if ta == nil || ta.Amount == 0 {
+		return 0, fmt.Errorf("%w: %s holds no tokens", ErrInsufficientFunds, account)
+	}
+	share := new(big.Rat).Mul(new(big.Rat).SetInt(new(big.Int).SetUint64(ta.Amount)), percent)
+	share.Quo(share, big.NewRat(100, 1))
+	raw := new(big.Int).Quo(share.Num(), share.Denom())
+	if raw.Sign() == 0 {
+		return 0, fmt.Errorf("%w: %s%% of the balance of %s rounds down to nothing", ErrInvalidArgument, percent.FloatString(2), account)
+	}
+	return raw.Uint64(), nil
+}
+
+// parseUIAmount converts a decimal token amount such as "12.5" into raw base units of a mint with the given
+// decimals. Negative values, exponents and more fractional digits than the mint supports are rejected.
+func parseUIAmount(s string, decimals uint8) (uint64, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%w: empty amount", ErrInvalidArgument)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: invalid amount %q", ErrInvalidArgument, s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > int(decimals) {
+		return 0, fmt.Errorf("%w: amount %q has more than %d decimal places", ErrInvalidArgument, s, decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	raw, ok := new(big.Int).SetString(digits, 10)
+	if !ok || !raw.IsUint64() {
+		return 0, fmt.Errorf("%w: amount %q is out of range", ErrInvalidArgument, s)
+	}
+	return raw.Uint64(), nil
+}
+
+// scaleAmount converts a whole number of tokens into raw base units, failing if the result overflows.
+func scaleAmount(whole uint64, decimals uint8) (uint64, error) {
+	raw := new(big.Int).Mul(new(big.Int).SetUint64(whole), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	if !raw.IsUint64() {
+		return 0, fmt.Errorf("%w: amount %d is out of range", ErrInvalidArgument, whole)
+	}
+	return raw.Uint64(), nil
+}
+
+// formatUIAmount renders raw base units as a decimal amount, without trailing zeros.
+func formatUIAmount(raw uint64, decimals uint8) string {
+	s := fmt.Sprintf("%0*d", int(decimals)+1, raw)
+	whole, frac := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func init() {
+	commands["approvals"] = runApprovals
+}
+
+// queueForApproval records a transfer that needs a second operator's approval instead of sending it, and returns
+// the ErrAborted error telling the operator how to proceed.
+func queueForApproval(store *Store, record transferRecord) error {
+	record.Status = transferAwaitingApproval
+	if err := store.PutTransfer(record); err != nil {
+		return fmt.Errorf("can't record transfer: %v", err)
+	}
+	slog.Info("transfer queued for approval", "id", record.ID, "receiver", record.Receiver, "amount", record.Amount)
+	fmt.Println(record.ID)
+	return fmt.Errorf("%w: transfer %s needs approval: another operator must run `token-transfer approve %s --approver-key <keypair>`", ErrAborted, record.ID, record.ID)
+}
+
+// awaitingApproval returns the transfers held for approval, oldest first.
+func awaitingApproval(store *Store) []transferRecord {
+	var records []transferRecord
+	for _, record := range store.TransfersSince(time.Time{}) {
+		if record.Status == transferAwaitingApproval {
+			records = append(records, record)
+		}
+	}
+	slices.SortFunc(records, func(a, b transferRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return records
+}
+
+func runApprovals(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%w: usage: approvals list [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("approvals list")
+	parseFlags(fs, args[1:])
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tRECEIVER\tAMOUNT\tMINT")
+	for _, record := range awaitingApproval(store) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", record.ID, record.CreatedAt.Format(time.RFC3339), record.Receiver, record.Amount, record.Mint)
+	}
+	return w.Flush()
+}
+
+// runApproveTransfer approves and sends the transfer id held for approval. The approver's key co-signs the
+// transaction through a memo instruction naming the transfer, so the approval is recorded on-chain next to the
+// sender's signature.
+func runApproveTransfer(ctx context.Context, id string, args []string) error {
+	fs := newFlagSet("approve")
+	approverKeyPath := fs.String("approver-key", "", "Keypair file of the approving operator; must be listed in the policy's approvers and differ from the sender's (required)")
+	yes := fs.Bool("yes", false, "Approve without the confirmation prompt")
+	parseFlags(fs, args)
+
+	if *approverKeyPath == "" {
+		return fmt.Errorf("%w: --approver-key flag is required", ErrInvalidArgument)
+	}
+	approver, err := loadKeypair(*approverKeyPath)
+	if err != nil {
+		return fmt.Errorf("%w: can't load approver key: %v", ErrSignerUnavailable, err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+
+	record, ok := store.Transfer(id)
+	if !ok {
+		return fmt.Errorf("%w: transfer %s not found", ErrInvalidArgument, id)
+	}
+	if record.Status != transferAwaitingApproval {
+		return fmt.Errorf("%w: transfer %s is %s, not awaiting approval", ErrInvalidArgument, id, record.Status)
+	}
+	// Sent from the wallet the transfer was requested from, not whichever one the approver has configured.
+	if signer.PublicKey().String() != record.Sender {
+		return fmt.Errorf("%w: transfer %s is from %s, but the signer is %s", ErrInvalidArgument, id, record.Sender, signer.PublicKey())
+	}
+	if approver.PublicKey().Equals(signer.PublicKey()) {
+		return fmt.Errorf("%w: the approver must use a different keypair than the one that requested the transfer", ErrInvalidArgument)
+	}
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := policy.CheckApprover(approver.PublicKey()); err != nil {
+		return err
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(record.Receiver)
+	if err != nil {
+		return fmt.Errorf("corrupt receiver in transfer %s: %v", id, err)
+	}
+	mintAddress, err := solanago.PublicKeyFromBase58(record.Mint)
+	if err != nil {
+		return fmt.Errorf("corrupt mint in transfer %s: %v", id, err)
+	}
+
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mint, err := GetMint(ctx, clients.Read, mintAddress, rpc.CommitmentFinalized)
+	if err != nil {
+		return fmt.Errorf("error getting mint: %w", classifyRPCError(err))
+	}
+	rawAmount, err := parseUIAmount(record.Amount, mint.Decimals)
+	if err != nil {
+		return fmt.Errorf("corrupt amount in transfer %s: %w", id, err)
+	}
+
+	// The policy may have changed, and today's total grown, since the transfer was requested.
+	if err := policy.CheckApproved(store, id, mintAddress, mint.Decimals, receiverKey, rawAmount); err != nil {
+		return err
+	}
+
+	if !*yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%w: no terminal to confirm the approval, pass --yes", ErrAborted)
+		}
+		ok, err := promptYesNo(fmt.Sprintf("Approve transfer %s of %s %s to %s, requested %s?", id, record.Amount, tokenLabel(ctx, clients.Read, mintAddress), record.Receiver, record.CreatedAt.Format(time.RFC3339)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: transfer not approved", ErrAborted)
+		}
+	}
+
+	err = store.UpdateTransfer(id, func(r *transferRecord) {
+		r.Status = transferPending
+		r.ApprovedBy = approver.PublicKey().String()
+	})
+	if err != nil {
+		return fmt.Errorf("can't record approval: %v", err)
+	}
+	if err := writeAuditLog(dataDir, map[string]any{"action": "approve", "transfer": id, "approver": approver.PublicKey().String()}); err != nil {
+		return err
+	}
+	slog.Info("transfer approved", "id", id, "approver", approver.PublicKey())
+
+	opts := TransferOptions{
+		Mint:             mintAddress,
+		PostInstructions: []solanago.Instruction{approvalMemo(id, approver.PublicKey())},
+		CoSigners:        []Signer{approver},
+	}
+	sig, err := sendRecorded(ctx, store, clients, signer, id, receiverKey, rawAmount, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// approvalMemo returns a memo instruction, signed by approver, recording the approval of transfer id.
+func approvalMemo(id string, approver solanago.PublicKey) solanago.Instruction {
+	return solanago.NewInstruction(
+		solanago.MemoProgramID,
+		solanago.AccountMetaSlice{solanago.Meta(approver).SIGNER()},
+		[]byte("approved transfer "+id),
+	)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+func init() {
+	commands["asset"] = runAsset
+}
+
+// runAsset looks up an asset, an NFT compressed or not or a fungible token, with the DAS provider's getAsset.
+func runAsset(ctx context.Context, args []string) error {
+	fs := newFlagSet("asset")
+	idFlag := fs.String("id", "", "Asset ID to look up: an NFT's mint, a compressed NFT's asset ID or a token mint (required)")
+	jsonOutput := fs.Bool("json", false, "Print the asset as JSON")
+	parseFlags(fs, args)
+
+	if *idFlag == "" {
+		return fmt.Errorf("%w: --id flag is required", ErrInvalidArgument)
+	}
+	id, err := solanago.PublicKeyFromBase58(*idFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --id: %v", ErrInvalidArgument, err)
+	}
+	das, err := newDASClient()
+	if err != nil {
+		return err
+	}
+	asset, err := das.GetAsset(ctx, id)
+	if err != nil {
+		return fmt.Errorf("can't get asset %s: %w", id, err)
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(asset)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", asset.ID)
+	fmt.Fprintf(w, "Interface\t%s\n", asset.Interface)
+	fmt.Fprintf(w, "Name\t%s\n", asset.Content.Metadata.Name)
+	fmt.Fprintf(w, "Symbol\t%s\n", asset.Content.Metadata.Symbol)
+	fmt.Fprintf(w, "URI\t%s\n", asset.Content.JSONURI)
+	fmt.Fprintf(w, "Owner\t%s\n", asset.Ownership.Owner)
+	if asset.Ownership.Delegate != "" {
+		fmt.Fprintf(w, "Delegate\t%s\n", asset.Ownership.Delegate)
+	}
+	fmt.Fprintf(w, "Frozen\t%t\n", asset.Ownership.Frozen)
+	fmt.Fprintf(w, "Mutable\t%t\n", asset.Mutable)
+	fmt.Fprintf(w, "Burnt\t%t\n", asset.Burnt)
+	if asset.Compression.Compressed {
+		fmt.Fprintf(w, "Tree\t%s\n", asset.Compression.Tree)
+		fmt.Fprintf(w, "Leaf\t%d\n", asset.Compression.LeafID)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func init() {
+	commands["authorize"] = runAuthorize
+}
+
+// runAuthorize hands one of the signer's authorities over a mint or token account to another key, or gives it up.
+// Neither can be undone by the signer, so it's confirmed by typing the change back.
+func runAuthorize(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: usage: authorize mint|freeze|owner|close (--new-authority ADDRESS | --none) [--account ADDRESS] [flags]", ErrInvalidArgument)
+	}
+	name := args[0]
+	typ, ok := authorityTypes[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown authority %q, want mint, freeze, owner or close", ErrInvalidArgument, name)
+	}
+	ofMint := typ == token.AuthorityMintTokens || typ == token.AuthorityFreezeAccount
+	fs := newFlagSet("authorize " + name)
+	accountFlag := fs.String("account", "", "Mint or token account whose authority changes (default: the selected token's mint for mint and freeze, the signer's token account for owner and close)")
+	newAuthorityFlag := fs.String("new-authority", "", "Address that gets the authority")
+	none := fs.Bool("none", false, "Remove the authority for good instead of handing it over (not for owner)")
+	yes := fs.Bool("yes", false, "Change the authority without the confirmation prompt")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the change without sending it")
+	parseFlags(fs, args[1:])
+
+	if (*newAuthorityFlag == "") == !*none {
+		return fmt.Errorf("%w: exactly one of --new-authority and --none is required", ErrInvalidArgument)
+	}
+	if *none && typ == token.AuthorityAccountOwner {
+		return fmt.Errorf("%w: a token account always has an owner; close the account instead", ErrInvalidArgument)
+	}
+	var newAuthority *solanago.PublicKey
+	if !*none {
+		key, err := solanago.PublicKeyFromBase58(*newAuthorityFlag)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --new-authority: %v", ErrInvalidArgument, err)
+		}
+		newAuthority = &key
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	var subject solanago.PublicKey
+	if *accountFlag != "" {
+		if subject, err = solanago.PublicKeyFromBase58(*accountFlag); err != nil {
+			return fmt.Errorf("%w: invalid --account: %v", ErrInvalidArgument, err)
+		}
+	} else if subject, err = defaultAuthoritySubject(ctx, clients.Read, signer.PublicKey(), ofMint); err != nil {
+		return err
+	}
+
+	res, err := GetAccountInfo(ctx, clients.Read, subject, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return fmt.Errorf("%w: account %s doesn't exist", ErrInvalidArgument, subject)
+	}
+	if err != nil {
+		return fmt.Errorf("can't get account %s: %w", subject, classifyRPCError(err))
+	}
+	program := res.Value.Owner
+	if !program.Equals(solanago.TokenProgramID) && !program.Equals(solanago.Token2022ProgramID) {
+		return fmt.Errorf("%w: %s isn't a mint or token account", ErrInvalidArgument, subject)
+	}
+	data := res.Value.Data.GetBinary()
+	if isMintAccount(data) != ofMint {
+		kind := "token account"
+		if !ofMint {
+			kind = "mint"
+		}
+		return fmt.Errorf("%w: %s is a %s, which has no %s authority", ErrInvalidArgument, subject, kind, name)
+	}
+	current, err := currentAuthority(data, typ)
+	if err != nil {
+		return fmt.Errorf("can't decode %s: %v", subject, err)
+	}
+	switch {
+	case current == nil:
+		return fmt.Errorf("%w: %s has no %s authority; once removed it can't be set again", ErrInvalidArgument, subject, name)
+	case !current.Equals(signer.PublicKey()):
+		return fmt.Errorf("%w: the %s authority of %s is %s, not the signer", ErrSignerUnavailable, name, subject, current)
+	case newAuthority != nil && newAuthority.Equals(*current):
+		return fmt.Errorf("%w: %s already has the %s authority of %s", ErrInvalidArgument, current, name, subject)
+	}
+
+	instruction, err := setAuthorityInstruction(program, subject, signer.PublicKey(), newAuthority, typ)
+	if err != nil {
+		return err
+	}
+	if *dryRunFlag {
+		return simulateInstructions(ctx, clients, signer, append(priorityFeeInstructions(), instruction)...)
+	}
+
+	expected := "transfer " + name + " authority"
+	if newAuthority == nil {
+		expected = "remove " + name + " authority"
+		fmt.Fprintf(os.Stderr, "The %s authority of %s is removed for good. %s\n", name, subject, authorityRemovalEffect(typ))
+	} else {
+		fmt.Fprintf(os.Stderr, "The %s authority of %s moves from %s to %s.\n", name, subject, current, newAuthority)
+		if !newAuthority.IsOnCurve() {
+			fmt.Fprintf(os.Stderr, "%s is a program derived address: only its program can use the authority.\n", newAuthority)
+		}
+	}
+	if !*yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%w: changing an authority needs confirmation, pass --yes to do it non-interactively", ErrAborted)
+		}
+		ok, err := promptTyped("The signer can't undo this.", expected)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: authority not changed", ErrAborted)
+		}
+	}
+	sig, err := sendInstructions(ctx, clients, signer, append(priorityFeeInstructions(), instruction)...)
+	if err != nil {
+		return fmt.Errorf("can't set authority: %w", err)
+	}
+	if newAuthority == nil {
+		slog.Info("removed authority", "account", subject, "authority", name, "signature", sig)
+	} else {
+		slog.Info("transferred authority", "account", subject, "authority", name, "to", newAuthority, "signature", sig)
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// defaultAuthoritySubject returns the selected token's mint if ofMint is set, and signer's token account for it
+// otherwise.
+func defaultAuthoritySubject(ctx context.Context, client RPCClient, signer solanago.PublicKey, ofMint bool) (solanago.PublicKey, error) {
+	mintAddress, _, err := resolveMint(ctx, client)
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	if ofMint {
+		return mintAddress, nil
+	}
+	tm, err := loadTransferMint(ctx, client, mintAddress)
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	account, err := tm.tokenAccount(signer)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't get ATA for signer %s: %v", signer, err)
+	}
+	return account, nil
+}
+
+// currentAuthority returns the holder of the authority of type typ recorded in data, a mint for the mint and
+// freeze authorities and a token account otherwise, or nil if there is none. A token account without a close
+// authority is closed by its owner.
+func currentAuthority(data []byte, typ token.AuthorityType) (*solanago.PublicKey, error) {
+	switch typ {
+	case token.AuthorityMintTokens, token.AuthorityFreezeAccount:
+		var mint token.Mint
+		if err := bin.NewBorshDecoder(data).Decode(&mint); err != nil {
+			return nil, err
+		}
+		if typ == token.AuthorityMintTokens {
+			return mint.MintAuthority, nil
+		}
+		return mint.FreezeAuthority, nil
+	default:
+		var account token.Account
+		if err := bin.NewBorshDecoder(data).Decode(&account); err != nil {
+			return nil, err
+		}
+		if typ == token.AuthorityCloseAccount && account.CloseAuthority != nil {
+			return account.CloseAuthority, nil
+		}
+		return &account.Owner, nil
+	}
+}
+
+// authorityRemovalEffect says what removing an authority of type typ means for the token.
+func authorityRemovalEffect(typ token.AuthorityType) string {
+	switch typ {
+	case token.AuthorityMintTokens:
+		return "No more tokens can ever be minted; the supply is fixed."
+	case token.AuthorityFreezeAccount:
+		return "No token account of the mint can ever be frozen or thawed again."
+	default:
+		return "Only the owner can close the account."
+	}
+}
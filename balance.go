@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["balance"] = runBalance
+}
+
+func runBalance(ctx context.Context, args []string) error {
+	fs := newFlagSet("balance")
+	ownerFlag := fs.String("owner", "", "Base58 wallet whose balance is shown (defaults to the signer's public key)")
+	fs.StringVar(&tokenName, "mint", "", "Mint address or token symbol; same as --token")
+	all := fs.Bool("all", false, "List the balance of every token account the owner holds")
+	wallets := fs.Bool("wallets", false, "List the balance of every token account of every named wallet (see wallets list)")
+	parseFlags(fs, args)
+
+	var owners []solanago.PublicKey
+	if *wallets {
+		if *ownerFlag != "" {
+			return fmt.Errorf("%w: --owner and --wallets are mutually exclusive", ErrInvalidArgument)
+		}
+		configured, err := loadWallets(dataDir)
+		if err != nil {
+			return err
+		}
+		for _, w := range configured {
+			address, err := walletAddress(w)
+			if err != nil {
+				return fmt.Errorf("can't get address of wallet %q: %v", w.Name, err)
+			}
+			owners = append(owners, address)
+		}
+	} else {
+		owner, err := ownerKey(*ownerFlag)
+		if err != nil {
+			return err
+		}
+		owners = append(owners, owner)
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var balances []tokenBalance
+	for _, owner := range owners {
+		var owned []tokenBalance
+		if *all || *wallets {
+			owned, err = allBalances(ctx, client, owner)
+		} else {
+			var b tokenBalance
+			b, err = mintBalance(ctx, client, owner)
+			owned = append(owned, b)
+		}
+		if err != nil {
+			return err
+		}
+		balances = append(balances, owned...)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OWNER\tACCOUNT\tMINT\tTOKEN\tBALANCE\tRAW")
+	for _, b := range balances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", b.Owner, b.Account, b.Mint, tokenLabel(ctx, client, b.Mint), formatUIAmount(b.Amount, b.Decimals), b.Amount)
+	}
+	return w.Flush()
+}
+
+// ownerKey returns the wallet given by --owner, or the signer's public key if it is empty.
+func ownerKey(owner string) (solanago.PublicKey, error) {
+	if owner != "" {
+		key, err := solanago.PublicKeyFromBase58(owner)
+		if err != nil {
+			return solanago.PublicKey{}, fmt.Errorf("%w: invalid --owner: %v", ErrInvalidArgument, err)
+		}
+		return key, nil
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	return signer.PublicKey(), nil
+}
+
+// tokenBalance is the balance of one token account, in raw base units.
+type tokenBalance struct {
+	Owner    solanago.PublicKey
+	Account  solanago.PublicKey
+	Mint     solanago.PublicKey
+	Amount   uint64
+	Decimals uint8
+}
+
+// mintBalance returns the balance of owner's associated token account for the selected mint. An account that
+// doesn't exist yet holds nothing.
+func mintBalance(ctx context.Context, client RPCClient, owner solanago.PublicKey) (tokenBalance, error) {
+	mintAddress, mint, err := resolveMint(ctx, client)
+	if err != nil {
+		return tokenBalance{}, err
+	}
+	ata, _, err := solanago.FindAssociatedTokenAddress(owner, mintAddress)
+	if err != nil {
+		return tokenBalance{}, fmt.Errorf("%w: can't get ATA for owner %s: %v", ErrInvalidArgument, owner, err)
+	}
+	b := tokenBalance{Owner: owner, Account: ata, Mint: mintAddress, Decimals: mint.Decimals}
+	account, err := getTokenAccount(ctx, client, ata)
+	if err != nil {
+		return tokenBalance{}, err
+	}
+	if account == nil {
+		slog.Info("owner has no token account for this mint yet", "owner", owner, "ata", ata)
+		return b, nil
+	}
+	b.Amount = account.Amount
+	return b, nil
+}
+
+// allBalances returns the balances of every token account owned by owner. With --das-url the accounts are listed
+// by the DAS provider, which includes Token-2022 accounts.
+func allBalances(ctx context.Context, client RPCClient, owner solanago.PublicKey) ([]tokenBalance, error) {
+	list := listTokenAccounts
+	if useDAS() {
+		list = dasOwnedTokenAccounts
+	}
+	owned, err := list(ctx, client, owner)
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := mintDecimals(ctx, client, owned)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]tokenBalance, 0, len(owned))
+	for _, ta := range owned {
+		balances = append(balances, tokenBalance{
+			Owner:    owner,
+			Account:  ta.Address,
+			Mint:     ta.Account.Mint,
+			Amount:   ta.Account.Amount,
+			Decimals: decimals[ta.Account.Mint],
+		})
+	}
+	return balances, nil
+}
+
+// dasOwnedTokenAccounts lists owner's token accounts of both token programs with the DAS provider's
+// getTokenAccounts. The accounts' mint, owner and amount are filled in.
+func dasOwnedTokenAccounts(ctx context.Context, _ RPCClient, owner solanago.PublicKey) ([]ownedTokenAccount, error) {
+	das, err := newDASClient()
+	if err != nil {
+		return nil, err
+	}
+	listed, err := das.TokenAccounts(ctx, map[string]any{"owner": owner.String()})
+	if err != nil {
+		return nil, fmt.Errorf("can't list token accounts: %w", err)
+	}
+	accounts := make([]ownedTokenAccount, 0, len(listed))
+	for _, ta := range listed {
+		address, err := solanago.PublicKeyFromBase58(ta.Address)
+		if err != nil {
+			return nil, fmt.Errorf("das listed an invalid token account %q: %v", ta.Address, err)
+		}
+		mint, err := solanago.PublicKeyFromBase58(ta.Mint)
+		if err != nil {
+			return nil, fmt.Errorf("das listed token account %s with an invalid mint %q: %v", ta.Address, ta.Mint, err)
+		}
+		accounts = append(accounts, ownedTokenAccount{Address: address, Account: token.Account{Mint: mint, Owner: owner, Amount: ta.Amount}})
+	}
+	return accounts, nil
+}
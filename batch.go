@@ -0,0 +1,578 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+func init() {
+	commands["batch"] = runBatch
+}
+
+// batchRow is one recipient of a batch file.
+type batchRow struct {
+	Line      int
+	Receiver  solanago.PublicKey
+	Amount    string
+	RawAmount uint64
+}
+
+func runBatch(ctx context.Context, args []string) error {
+	fs := newFlagSet("batch")
+	file := fs.String("file", "", "CSV file of receiver,amount rows; amounts are decimal token amounts (required)")
+	split := fs.String("split", "", "Share --total among the file's receivers instead of reading amounts: equal, or proportional to the weight in each row's second column")
+	totalFlag := fs.String("total", "", "Decimal token amount to share among the receivers with --split")
+	estimateOnly := fs.Bool("estimate", false, "Print the cost and duration estimate and exit without sending")
+	closeEmpty := fs.Bool("close-if-empty", false, "After a fully successful run, close the sender's token account if it is empty and reclaim its rent")
+	dedupe := fs.Bool("dedupe", false, "Drop rows paying a receiver already paid by an earlier row, instead of reporting them as issues")
+	acceptIssues := fs.Bool("accept-issues", false, "Send even if validation found issues, skipping invalid rows; without it a terminal prompts and anything else refuses")
+	maxTotalFlag := fs.String("max-total", "", "Refuse to start if the rows total more than this decimal token amount")
+	yes := fs.Bool("yes", false, "Send without asking to confirm the recipient count and total")
+	registerTxFlags(fs)
+	report := fs.String("report", "", "After the run, write every row's outcome (status, signature, error, fee, ATA created) to this file, as JSON if it ends in .json and CSV otherwise")
+	resume := fs.Bool("resume", false, "Continue an interrupted run of the same file, skipping recipients that were already paid")
+	maxPerTx := fs.Int("max-per-tx", 0, "Maximum transfers packed into one transaction (0 packs as many as fit, 1 sends one transaction per recipient)")
+	concurrency := fs.Int("concurrency", 1, "Number of transactions submitted and confirmed in parallel")
+	fs.BoolVar(&policyOverride, "override", false, "Send even if rows violate the spending policy; each override is recorded in the audit log")
+	registerNotifyFlags(fs)
+	registerShutdownFlags(fs)
+	parseFlags(fs, args)
+	if err := setupNotifications(); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("%w: --file flag is required", ErrInvalidArgument)
+	}
+	switch {
+	case *split != "" && *split != splitEqual && *split != splitProportional:
+		return fmt.Errorf("%w: invalid --split %q, use equal or proportional", ErrInvalidArgument, *split)
+	case *split != "" && *totalFlag == "":
+		return fmt.Errorf("%w: --split needs --total", ErrInvalidArgument)
+	case *split == "" && *totalFlag != "":
+		return fmt.Errorf("%w: --total is only used with --split", ErrInvalidArgument)
+	}
+	if *maxPerTx < 0 {
+		return fmt.Errorf("%w: --max-per-tx can't be negative", ErrInvalidArgument)
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("%w: --concurrency must be at least 1", ErrInvalidArgument)
+	}
+
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+	if err != nil {
+		return err
+	}
+	if tm.isToken2022() {
+		// Packs are built offline from legacy token accounts and Transfer instructions.
+		return fmt.Errorf("%w: batch only supports SPL Token mints, send Token-2022 transfers individually", ErrInvalidArgument)
+	}
+	if tm.Programmable {
+		return fmt.Errorf("%w: %s is a programmable NFT, send it with a plain transfer", ErrInvalidArgument, mintAddress)
+	}
+	var maxTotal uint64
+	if *maxTotalFlag != "" {
+		if maxTotal, err = parseUIAmount(*maxTotalFlag, mint.Decimals); err != nil {
+			return fmt.Errorf("invalid --max-total: %w", err)
+		}
+	}
+	label := tokenLabel(ctx, clients.Read, mintAddress)
+	var (
+		rows      []batchRow
+		issues    []batchIssue
+		weights   map[int]*big.Rat
+		total     uint64
+		splitArgs []string // identify the run along with the file, since they decide the amounts
+	)
+	if *split != "" {
+		if total, err = parseUIAmount(*totalFlag, mint.Decimals); err != nil {
+			return fmt.Errorf("invalid --total: %w", err)
+		}
+		splitArgs = []string{*split, formatUIAmount(total, mint.Decimals)}
+		rows, weights, issues, err = readSplitFile(*file, *split)
+	} else {
+		rows, issues, err = readBatchFile(*file, mint.Decimals)
+	}
+	if err != nil {
+		return err
+	}
+	rows, duplicates := duplicateRows(rows, *dedupe)
+	issues = append(issues, duplicates...)
+	if *split != "" && len(rows) > 0 {
+		leftover := splitTotal(rows, weights, total, mint.Decimals)
+		printSplit(os.Stderr, *split, rows, total, mint.Decimals, label, leftover)
+		rows = slices.DeleteFunc(rows, func(row batchRow) bool {
+			if row.RawAmount == 0 {
+				issues = append(issues, batchIssue{Line: row.Line, Problem: "share of the total rounds to zero"})
+			}
+			return row.RawAmount == 0
+		})
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	// Frozen receiver accounts are left to pack simulation, which fails just their rows.
+	if err := checkTokenTransfer(ctx, clients.Read, tm, signer.PublicKey(), signer.PublicKey()); err != nil {
+		return err
+	}
+	// A resumed run has already paid some rows, so the balance only has to cover the rest.
+	if !*resume {
+		balance, err := mintBalance(ctx, clients.Read, signer.PublicKey())
+		if err != nil {
+			return err
+		}
+		if issue := balanceIssue(rows, balance.Amount, mint.Decimals, label); issue != nil {
+			issues = append(issues, *issue)
+		}
+	}
+	if *estimateOnly && len(issues) > 0 {
+		printBatchIssues(os.Stderr, *file, issues)
+	} else if err := acknowledgeBatchIssues(os.Stderr, *file, issues, *acceptIssues); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("%w: batch file %s has no valid recipients", ErrInvalidArgument, *file)
+	}
+	opts := TransferOptions{Mint: mintAddress, PreInstructions: priorityFeeInstructions()}
+	if err := txFormatOptions(ctx, clients.Read, signer.PublicKey(), &opts); err != nil {
+		return err
+	}
+	if err := configuredLookupTableOptions(ctx, clients.Read, &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+	// Packs are sized with the tip included, so it is fixed for the whole run.
+	opts.PostInstructions, err = clients.Sender.TipInstructions(ctx, opts.payer(signer.PublicKey()))
+	if err != nil {
+		return err
+	}
+
+	if noFundRecipient {
+		missing, err := missingATAs(ctx, clients.Read, mintAddress, rows)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %d receivers have no token account for the mint, and creating them isn't allowed (--no-fund-recipient)", ErrInvalidRecipient, len(missing))
+		}
+	}
+
+	// Estimate the pack size assuming no accounts have to be created; packs creating them hold fewer transfers.
+	queue := make([]queuedTransfer, len(rows))
+	for i, row := range rows {
+		queue[i] = queuedTransfer{batchRow: row}
+	}
+	perTx, err := packSize(signer.PublicKey(), mintAddress, queue, nil, opts, *maxPerTx)
+	if err != nil {
+		return err
+	}
+	estimate, err := EstimateBatch(ctx, clients.Read, signer.PublicKey(), mintAddress, rows, opts, EstimateParams{Concurrency: *concurrency, RPS: rpcRPS, RecipientsPerTransaction: perTx})
+	if err != nil {
+		return err
+	}
+	estimate.Print(os.Stderr, mint.Decimals, label)
+	if *maxTotalFlag != "" && estimate.TotalAmount > maxTotal {
+		return fmt.Errorf("%w: the batch totals %s %s, more than --max-total %s", ErrPolicyViolation, formatUIAmount(estimate.TotalAmount, mint.Decimals), label, formatUIAmount(maxTotal, mint.Decimals))
+	}
+	if *estimateOnly {
+		return nil
+	}
+	if err := confirmBatch(estimate, mint.Decimals, label, *yes); err != nil {
+		return err
+	}
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+
+	batchID, err := batchFileID(*file, splitArgs...)
+	if err != nil {
+		return err
+	}
+	if !*resume {
+		for _, row := range rows {
+			if _, ok := store.TransferByIdempotencyKey(batchRowKey(batchID, row)); ok {
+				return fmt.Errorf("%w: %s was already run, pass --resume to continue it", ErrInvalidArgument, *file)
+			}
+		}
+	}
+
+	// Check the whole file against the policy before anything is sent.
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	var planned uint64
+	for _, row := range rows {
+		if _, ok := store.TransferByIdempotencyKey(batchRowKey(batchID, row)); ok {
+			continue
+		}
+		if err := enforcePolicy(policy, store, mintAddress, mint.Decimals, row.Receiver, row.RawAmount, planned); err != nil {
+			return fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		planned += row.RawAmount
+	}
+
+	var failed, skipped, awaiting int
+	queue = queue[:0]
+	for _, row := range rows {
+		key := batchRowKey(batchID, row)
+		record, ok := store.TransferByIdempotencyKey(key)
+		if ok && record.Status == transferAwaitingApproval {
+			awaiting++
+			continue
+		}
+		if ok {
+			send, err := resumeBatchRow(ctx, clients.Read, store, record)
+			if err != nil {
+				failed++
+				slog.Error("can't resume transfer", "line", row.Line, "receiver", row.Receiver, "id", record.ID, "error", err)
+				continue
+			}
+			if !send {
+				skipped++
+				continue
+			}
+		} else {
+			record = newTransferRecord(key, signer.PublicKey(), row.Receiver, mintAddress, row.Amount)
+			needs, err := policy.NeedsApproval(mintAddress, mint.Decimals, row.RawAmount)
+			if err != nil {
+				return err
+			}
+			if needs {
+				// Held for a second operator, who approves and sends it on its own.
+				record.Status = transferAwaitingApproval
+				awaiting++
+				slog.Info("transfer queued for approval", "line", row.Line, "id", record.ID, "receiver", row.Receiver, "amount", row.Amount)
+			}
+			if err := store.PutTransfer(record); err != nil {
+				return fmt.Errorf("can't record transfer: %v", err)
+			}
+			if needs {
+				continue
+			}
+		}
+		queue = append(queue, queuedTransfer{batchRow: row, ID: record.ID})
+	}
+
+	// While the progress is shown, logs go through it so they don't garble its status line.
+	progress := newBatchProgress(len(queue))
+	setupLogging(progress.Writer(os.Stderr), verbose, quiet)
+	packFailed, err := sendQueue(ctx, store, clients, signer, mintAddress, queue, opts, *maxPerTx, *concurrency, progress)
+	progress.Close()
+	setupLogging(os.Stderr, verbose, quiet)
+	failed += packFailed
+	if *report != "" {
+		// Written whether or not the run finished; rows it didn't reach are reported as not sent.
+		results := batchResults(ctx, clients.Read, store, batchID, rows)
+		if reportErr := writeBatchReport(*report, results); reportErr != nil {
+			if err == nil {
+				return reportErr
+			}
+			slog.Error("batch report not written", "error", reportErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("batch finished", "recipients", len(rows), "skipped", skipped, "failed", failed, "awaiting approval", awaiting)
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d transfers failed, rerun with --resume to retry them", ErrTransactionFailed, failed, len(rows))
+	}
+	if awaiting > 0 {
+		return fmt.Errorf("%w: %d transfers are awaiting approval, see `token-transfer approvals list`", ErrAborted, awaiting)
+	}
+	if *closeEmpty {
+		return closeIfEmpty(ctx, clients, signer, mintAddress)
+	}
+	return nil
+}
+
+// confirmBatch asks the operator to confirm sending the batch summarized by estimate, by typing its total, unless
+// yes (--yes) is set.
+func confirmBatch(estimate BatchEstimate, decimals uint8, label string, yes bool) error {
+	if yes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w: batches need confirmation, pass --yes to send non-interactively", ErrAborted)
+	}
+	total := formatUIAmount(estimate.TotalAmount, decimals)
+	question := fmt.Sprintf("Send %s %s to %d recipients on %s? Transfers can't be reversed.", total, label, estimate.Recipients, network)
+	ok, err := promptTyped(question, total)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: batch not confirmed", ErrAborted)
+	}
+	return nil
+}
+
+// sendQueue packs the queued transfers into transactions and sends them, up to concurrency at a time, printing the
+// signature of each one that confirms and counting its transfers in progress. It returns the number of transfers
+// that failed; an error means packing itself failed or the batch was interrupted, and the remaining transfers
+// weren't sent. Once ctx is cancelled no more transactions are sent, and the ones in flight get --shutdown-grace to
+// confirm.
+func sendQueue(ctx context.Context, store *Store, clients Clients, signer Signer, mint solanago.PublicKey, queue []queuedTransfer, opts TransferOptions, maxPerTx, concurrency int, progress *batchProgress) (int, error) {
+	drain, stop := drainContext(ctx)
+	stdout := progress.Writer(os.Stdout)
+	defer stop()
+	var (
+		mu          sync.Mutex
+		failed      int
+		unconfirmed int
+		wg          sync.WaitGroup
+		sem         = make(chan struct{}, concurrency)
+		creating    = map[solanago.PublicKey]bool{} // receiver ATAs created by packs in flight
+	)
+	fail := func(pack []queuedTransfer, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if interrupted(err) {
+			// Left pending in the store; --resume checks whether it landed.
+			unconfirmed += len(pack)
+			for _, t := range pack {
+				slog.Warn("transfer unconfirmed at exit", "line", t.Line, "receiver", t.Receiver, "amount", t.Amount, "id", t.ID)
+			}
+			return
+		}
+		failed += len(pack)
+		progress.Add(0, len(pack))
+		for _, t := range pack {
+			slog.Error("transfer failed", "line", t.Line, "receiver", t.Receiver, "amount", t.Amount, "error", err)
+		}
+	}
+	for len(queue) > 0 && ctx.Err() == nil {
+		pack, missing, err := nextPack(ctx, clients.Read, signer, mint, queue, opts, maxPerTx)
+		if ctx.Err() != nil {
+			break
+		}
+		if len(pack) == 0 {
+			wg.Wait()
+			return failed, err
+		}
+		if err == nil && createsPending(missing, creating, &mu) {
+			// A pack still in flight creates one of these accounts; creating it twice would fail, so let it land
+			// and pack again.
+			wg.Wait()
+			continue
+		}
+		queue = queue[len(pack):]
+		if err != nil {
+			for _, t := range pack {
+				recordOutcome(store, t.ID, err)
+			}
+			fail(pack, err)
+			continue
+		}
+
+		sem <- struct{}{}
+		mu.Lock()
+		for account := range missing {
+			creating[account] = true
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer func() {
+				mu.Lock()
+				for account := range missing {
+					delete(creating, account)
+				}
+				mu.Unlock()
+				<-sem
+				wg.Done()
+			}()
+			sig, err := sendPack(drain, store, clients, signer, mint, pack, missing, opts)
+			if err != nil {
+				fail(pack, err)
+				return
+			}
+			slog.Debug("pack sent", "transfers", len(pack), "signature", sig)
+			progress.Add(len(pack), 0)
+			fmt.Fprintln(stdout, sig)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return failed, fmt.Errorf("%w: %v: %d transfers not sent, %d sent but unconfirmed; rerun with --resume to finish the batch", ErrAborted, context.Cause(ctx), len(queue), unconfirmed)
+	}
+	return failed, nil
+}
+
+// createsPending reports whether any of accounts is being created by a pack in flight.
+func createsPending(accounts, creating map[solanago.PublicKey]bool, mu *sync.Mutex) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for account := range accounts {
+		if creating[account] {
+			return true
+		}
+	}
+	return false
+}
+
+// batchRecord is a row of a batch file before its fields are parsed.
+type batchRecord struct {
+	Line   int
+	Fields []string
+}
+
+// readBatchRecords reads the rows of a CSV batch file. A header row (starting with "receiver") and lines starting
+// with # are skipped.
+func readBatchRecords(path string) ([]batchRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't open batch file: %v", ErrInvalidArgument, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var records []batchRecord
+	for {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(records) == 0 && strings.EqualFold(strings.TrimSpace(fields[0]), "receiver") {
+			continue
+		}
+		records = append(records, batchRecord{Line: line, Fields: fields})
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: batch file %s has no recipients", ErrInvalidArgument, path)
+	}
+	return records, nil
+}
+
+// parseBatchReceiver parses the receiver in the first field of record.
+func parseBatchReceiver(record batchRecord) (solanago.PublicKey, *batchIssue) {
+	receiver, err := solanago.PublicKeyFromBase58(strings.TrimSpace(record.Fields[0]))
+	if err != nil {
+		return solanago.PublicKey{}, &batchIssue{Line: record.Line, Problem: fmt.Sprintf("invalid receiver %q: %v", record.Fields[0], err)}
+	}
+	if err := checkReceiverOwner(receiver); err != nil {
+		return solanago.PublicKey{}, &batchIssue{Line: record.Line, Problem: err.Error()}
+	}
+	return receiver, nil
+}
+
+// readBatchFile parses a CSV batch file of receiver,amount rows. Rows with an invalid receiver or amount are left
+// out and returned as issues.
+func readBatchFile(path string, decimals uint8) ([]batchRow, []batchIssue, error) {
+	records, err := readBatchRecords(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows []batchRow
+	var issues []batchIssue
+	for _, record := range records {
+		if len(record.Fields) < 2 {
+			issues = append(issues, batchIssue{Line: record.Line, Problem: "expected receiver,amount"})
+			continue
+		}
+		receiver, issue := parseBatchReceiver(record)
+		if issue != nil {
+			issues = append(issues, *issue)
+			continue
+		}
+		amount := strings.TrimSpace(record.Fields[1])
+		if strings.HasPrefix(amount, "-") {
+			issues = append(issues, batchIssue{Line: record.Line, Problem: fmt.Sprintf("negative amount %s", amount)})
+			continue
+		}
+		rawAmount, err := parseUIAmount(amount, decimals)
+		if err != nil {
+			issues = append(issues, batchIssue{Line: record.Line, Problem: err.Error()})
+			continue
+		}
+		if rawAmount == 0 {
+			issues = append(issues, batchIssue{Line: record.Line, Problem: "zero amount"})
+			continue
+		}
+		rows = append(rows, batchRow{
+			Line:      record.Line,
+			Receiver:  receiver,
+			Amount:    formatUIAmount(rawAmount, decimals),
+			RawAmount: rawAmount,
+		})
+	}
+	return rows, issues, nil
+}
+
+// batchFileID identifies a batch file by its contents, and params by the flags that decide the amounts if the
+// file doesn't, so progress recorded for it is only reused while they are unchanged.
+func batchFileID(path string, params ...string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: can't read batch file: %v", ErrInvalidArgument, err)
+	}
+	for _, param := range params {
+		data = append(data, '\n')
+		data = append(data, param...)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// batchRowKey is the idempotency key under which the transfer for row is recorded.
+func batchRowKey(batchID string, row batchRow) string {
+	return fmt.Sprintf("batch:%s:%d", batchID, row.Line)
+}
+
+// resumeBatchRow decides whether the row recorded as record must be sent (again). Confirmed rows are skipped;
+// pending and failed ones are checked on-chain first so a transfer that landed isn't paid twice.
+func resumeBatchRow(ctx context.Context, client RPCClient, store *Store, record transferRecord) (bool, error) {
+	if record.Status == transferFailed {
+		// A failed send may still have landed, e.g. when confirmation timed out; check like a pending one.
+		record.Status = transferPending
+	}
+	done, err := resumeTransfer(ctx, client, store, record)
+	switch {
+	case errors.Is(err, ErrTransactionFailed):
+		// Failed on-chain, so nothing was transferred.
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+	return !done, nil
+}
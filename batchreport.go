@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// transferNotSent is the report status of batch rows that have no transfer record, because the run stopped before
+// reaching them.
+const transferNotSent = "not-sent"
+
+// batchReportColumns are the columns of a CSV batch report: the input row followed by its outcome.
+var batchReportColumns = []string{"line", "receiver", "amount", "status", "signature", "error", "fee", "ata_created"}
+
+// batchResult is the outcome of one batch row, as written to the --report file.
+type batchResult struct {
+	Line      int    `json:"line"`
+	Receiver  string `json:"receiver"`
+	Amount    string `json:"amount"`
+	Status    string `json:"status"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Fee is the row's share of its transaction's fee in SOL; rows packed into one transaction split it evenly.
+	Fee        string `json:"fee,omitempty"`
+	ATACreated bool   `json:"ataCreated"`
+}
+
+// batchResults returns the outcome of every row of the batch file batchID from the store, with the fees of the
+// confirmed transactions looked up on-chain.
+func batchResults(ctx context.Context, client RPCClient, store *Store, batchID string, rows []batchRow) []batchResult {
+	results := make([]batchResult, len(rows))
+	shares := map[string]int{} // rows per transaction signature
+	for i, row := range rows {
+		results[i] = batchResult{Line: row.Line, Receiver: row.Receiver.String(), Amount: row.Amount, Status: transferNotSent}
+		record, ok := store.TransferByIdempotencyKey(batchRowKey(batchID, row))
+		if !ok {
+			continue
+		}
+		results[i].Status, results[i].Signature, results[i].Error = record.Status, record.Signature, record.Error
+		results[i].ATACreated = record.CreatedAccount
+		if record.Status == transferConfirmed {
+			shares[record.Signature]++
+		}
+	}
+
+	fees := map[string]uint64{}
+	for signature := range shares {
+		fee, err := transactionFee(ctx, client, signature)
+		if err != nil {
+			slog.Warn("can't get transaction fee for the report", "signature", signature, "error", err)
+			continue
+		}
+		fees[signature] = fee
+	}
+	// The first row of each transaction takes the remainder of the split.
+	split := map[string]bool{}
+	for i := range results {
+		fee, ok := fees[results[i].Signature]
+		if !ok || results[i].Status != transferConfirmed {
+			continue
+		}
+		n := uint64(shares[results[i].Signature])
+		share := fee / n
+		if !split[results[i].Signature] {
+			share += fee % n
+			split[results[i].Signature] = true
+		}
+		results[i].Fee = formatUIAmount(share, solDecimals)
+	}
+	return results
+}
+
+// transactionFee returns the fee paid by the confirmed transaction with the given signature, in lamports.
+func transactionFee(ctx context.Context, client RPCClient, signature string) (uint64, error) {
+	sig, err := solanago.SignatureFromBase58(signature)
+	if err != nil {
+		return 0, err
+	}
+	maxVersion := uint64(0)
+	res, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return 0, classifyRPCError(err)
+	}
+	if res.Meta == nil {
+		return 0, fmt.Errorf("transaction %s has no metadata", signature)
+	}
+	return res.Meta.Fee, nil
+}
+
+// writeBatchReport writes results to path, as JSON if it ends in .json and as CSV otherwise.
+func writeBatchReport(path string, results []batchResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("can't create report: %v", err)
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		cw := csv.NewWriter(f)
+		cw.Write(batchReportColumns)
+		for _, r := range results {
+			cw.Write([]string{strconv.Itoa(r.Line), r.Receiver, r.Amount, r.Status, r.Signature, r.Error, r.Fee, strconv.FormatBool(r.ATACreated)})
+		}
+		cw.Flush()
+		err = cw.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("can't write report: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("can't write report: %v", err)
+	}
+	slog.Info("batch report written", "rows", len(results), "path", path)
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// batchIssue is a problem found in a batch file before anything is sent. Line is 0 for problems with the file as
+// a whole.
+type batchIssue struct {
+	Line    int
+	Problem string
+}
+
+func (i batchIssue) String() string {
+	if i.Line == 0 {
+		return i.Problem
+	}
+	return fmt.Sprintf("line %d: %s", i.Line, i.Problem)
+}
+
+// duplicateRows returns an issue for every row paying a receiver already paid by an earlier row. With dedupe the
+// later rows are dropped instead, and the rows kept are returned.
+func duplicateRows(rows []batchRow, dedupe bool) ([]batchRow, []batchIssue) {
+	first := map[solanago.PublicKey]int{}
+	var kept []batchRow
+	var issues []batchIssue
+	for _, row := range rows {
+		line, seen := first[row.Receiver]
+		switch {
+		case !seen:
+			first[row.Receiver] = row.Line
+		case dedupe:
+			slog.Info("dropping duplicate receiver", "line", row.Line, "receiver", row.Receiver, "first", line)
+			continue
+		default:
+			issues = append(issues, batchIssue{Line: row.Line, Problem: fmt.Sprintf("duplicate receiver %s, also paid on line %d", row.Receiver, line)})
+		}
+		kept = append(kept, row)
+	}
+	return kept, issues
+}
+
+// balanceIssue returns an issue if the rows pay out more than balance, or nil.
+func balanceIssue(rows []batchRow, balance uint64, decimals uint8, label string) *batchIssue {
+	var total uint64
+	for _, row := range rows {
+		total += row.RawAmount
+	}
+	if total <= balance {
+		return nil
+	}
+	return &batchIssue{Problem: fmt.Sprintf("the rows total %s %s, more than the sender's balance of %s", formatUIAmount(total, decimals), label, formatUIAmount(balance, decimals))}
+}
+
+// printBatchIssues writes the validation report for the batch file path.
+func printBatchIssues(w io.Writer, path string, issues []batchIssue) {
+	fmt.Fprintf(w, "Validation found %d issues in %s:\n", len(issues), path)
+	for _, issue := range issues {
+		fmt.Fprintf(w, "  %s\n", issue)
+	}
+}
+
+// acknowledgeBatchIssues reports the issues found in the batch file path and returns nil only if the operator
+// accepts them, with accept (--accept-issues) or at a prompt. Invalid rows are then skipped and the rest sent.
+func acknowledgeBatchIssues(w io.Writer, path string, issues []batchIssue, accept bool) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	printBatchIssues(w, path, issues)
+	if accept {
+		slog.Warn("sending despite validation issues", "issues", len(issues))
+		return nil
+	}
+	refused := fmt.Errorf("%w: %d issues in %s; fix them, or pass --accept-issues to skip invalid rows and send the rest", ErrInvalidArgument, len(issues), path)
+	if !stdinIsTerminal() {
+		return refused
+	}
+	ok, err := promptYesNo("Skip the invalid rows and send the rest anyway?")
+	if err != nil || !ok {
+		return refused
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenBuildParams returns the parameters of a transfer of 1.5 tokens of a 6-decimal mint of program between
+// fixed keys, with a fixed blockhash.
+func goldenBuildParams(t *testing.T, program solanago.PublicKey) BuildParams {
+	t.Helper()
+	sender, receiver := testKey(1).PublicKey(), testKey(2).PublicKey()
+	mint := transferMint{Address: testKey(3).PublicKey(), Program: program, Decimals: 6}
+	senderAccount, err := mint.tokenAccount(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverAccount, err := mint.tokenAccount(receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blockhash solanago.Hash
+	for i := range blockhash {
+		blockhash[i] = byte(i + 1)
+	}
+	return BuildParams{
+		Sender:                sender,
+		Receiver:              receiver,
+		Amount:                1_500_000,
+		Blockhash:             blockhash,
+		Mint:                  mint,
+		SenderTokenAccount:    senderAccount,
+		ReceiverTokenAccount:  receiverAccount,
+		ReceiverAccountExists: true,
+		Options:               TransferOptions{Mint: mint.Address},
+	}
+}
+
+func TestBuildTokenTransferTransactionGolden(t *testing.T) {
+	fee := uint64(10_000)
+	for _, tc := range []struct {
+		name    string
+		program solanago.PublicKey
+		modify  func(*BuildParams)
+	}{
+		{"plain", solanago.TokenProgramID, func(p *BuildParams) {}},
+		{"create-ata", solanago.TokenProgramID, func(p *BuildParams) { p.ReceiverAccountExists = false }},
+		{"memo", solanago.TokenProgramID, func(p *BuildParams) { p.Options.Memo = "invoice 42" }},
+		{"token-2022", solanago.Token2022ProgramID, func(p *BuildParams) {}},
+		{"priority-fee", solanago.TokenProgramID, func(p *BuildParams) {
+			defer func(saved *uint64) { priorityFeeFlag = saved }(priorityFeeFlag)
+			priorityFeeFlag = &fee
+			p.Options.PreInstructions = priorityFeeInstructions()
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := goldenBuildParams(t, tc.program)
+			tc.modify(&p)
+			tx, err := BuildTokenTransferTransaction(p)
+			if err != nil {
+				t.Fatalf("BuildTokenTransferTransaction: %v", err)
+			}
+			var got bytes.Buffer
+			if err := printDecodedTransaction(&got, tx, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("transaction differs from %s (run with -update if the change is intended):\ngot:\n%s\nwant:\n%s", golden, got.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["burn"] = runBurn
+}
+
+func runBurn(ctx context.Context, args []string) error {
+	fs := newFlagSet("burn")
+	amountFlag := fs.String("amount", "", "Decimal token amount to burn from the signer's token account (required)")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the burn without sending it")
+	parseFlags(fs, args)
+
+	if *amountFlag == "" {
+		return fmt.Errorf("%w: --amount flag is required", ErrInvalidArgument)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	rawAmount, err := parseUIAmount(*amountFlag, mint.Decimals)
+	if err != nil {
+		return err
+	}
+	if rawAmount == 0 {
+		return fmt.Errorf("%w: --amount must be positive", ErrInvalidArgument)
+	}
+
+	instruction, err := burnInstruction(signer.PublicKey(), mintAddress, mint.Decimals, rawAmount)
+	if err != nil {
+		return err
+	}
+	if *dryRunFlag {
+		return simulateInstructions(ctx, clients, signer, instruction)
+	}
+	slog.Info("burning tokens", "amount", formatUIAmount(rawAmount, mint.Decimals), "mint", mintAddress)
+	sig, err := sendInstructions(ctx, clients, signer, instruction)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// burnInstruction returns a BurnChecked instruction destroying amount raw base units from owner's token account
+// for mint. The token program rejects it if decimals doesn't match the mint.
+func burnInstruction(owner, mint solanago.PublicKey, decimals uint8, amount uint64) (solanago.Instruction, error) {
+	source, _, err := solanago.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return nil, fmt.Errorf("can't get ATA for sender %s: %v", owner, err)
+	}
+	return token.NewBurnCheckedInstruction(amount, decimals, source, mint, owner, nil).Build(), nil
+}
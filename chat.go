@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	slackWebhookURL   string
+	discordWebhookURL string
+	chatFailuresOnly  bool
+)
+
+// chatNotifier posts a one-line summary of each transfer to a Slack or Discord incoming webhook.
+type chatNotifier struct {
+	name         string
+	url          string
+	field        string // JSON field holding the message: "text" for Slack, "content" for Discord
+	failuresOnly bool
+	client       *http.Client
+}
+
+func newChatNotifier(name, rawURL, field string, failuresOnly bool) (*chatNotifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid %s webhook URL", ErrInvalidArgument, name)
+	}
+	return &chatNotifier{
+		name:         name,
+		url:          rawURL,
+		field:        field,
+		failuresOnly: failuresOnly,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (n *chatNotifier) Name() string { return n.name }
+
+func (n *chatNotifier) Notify(ctx context.Context, event transferEvent) error {
+	if n.failuresOnly && event.Status != transferFailed {
+		return nil
+	}
+	body, err := json.Marshal(map[string]string{n.field: chatMessage(event)})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return postStatusError(resp.StatusCode)
+}
+
+// chatMessage summarizes event for a chat channel.
+func chatMessage(event transferEvent) string {
+	if event.Status == transferFailed {
+		return fmt.Sprintf("Transfer %s of %s (mint %s) to %s failed: %s", event.ID, event.Amount, event.Mint, event.Receiver, event.Error)
+	}
+	return fmt.Sprintf("Transfer %s of %s (mint %s) to %s confirmed: %s", event.ID, event.Amount, event.Mint, event.Receiver, event.Signature)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+)
+
+// Clients bundles the connections used to send a transfer. Reads (account state, blockhashes, signature
+// statuses) and writes (sendTransaction) may go to different endpoints, e.g. a cheap read provider plus a
+// premium or staked send path.
+type Clients struct {
+	Read  RPCClient
+	Write RPCClient
+	// Sender submits transactions as selected by --send-strategy.
+	Sender Sender
+}
+
+// readEndpoint returns the endpoint used for reads: --rpc-read, or the network's default endpoint.
+func readEndpoint() (string, error) {
+	rpcEndpoint, _, err := rpcEndpoints()
+	if err != nil {
+		return "", err
+	}
+	return cmp.Or(rpcReadEndpoint, rpcEndpoint), nil
+}
+
+// wsEndpoint returns the websocket endpoint for subscriptions: the one matching --rpc-read, or the network's
+// default.
+func wsEndpoint() (string, error) {
+	_, ws, err := rpcEndpoints()
+	if err != nil {
+		return "", err
+	}
+	if rpcReadEndpoint == "" {
+		return ws, nil
+	}
+	// Providers serve websockets on the same URL as HTTP.
+	return websocketURL(rpcReadEndpoint), nil
+}
+
+// newReadClient returns a client for the read endpoint, for commands that never send transactions.
+func newReadClient() (RPCClient, error) {
+	endpoint, err := readEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return newRPCClient(endpoint), nil
+}
+
+// connect returns the read and write clients. Writes go to --rpc-write, falling back to the read endpoint. Call
+// Close when done.
+func connect() (Clients, error) {
+	read, err := readEndpoint()
+	if err != nil {
+		return Clients{}, err
+	}
+	write := cmp.Or(rpcWriteEndpoint, read)
+
+	c := Clients{Read: newRPCClient(read)}
+	c.Write = c.Read
+	if write != read {
+		c.Write = newRPCClient(write)
+	}
+	c.Sender, err = newSender(c)
+	if err != nil {
+		c.Close()
+		return Clients{}, err
+	}
+	slog.Debug("rpc endpoints", "read", read, "write", write, "send strategy", sendStrategy)
+	return c, nil
+}
+
+// Close closes the clients' connections.
+func (c Clients) Close() {
+	if c.Read != nil {
+		c.Read.Close()
+	}
+	if c.Write != nil && c.Write != c.Read {
+		c.Write.Close()
+	}
+}
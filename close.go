@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// closesPerTransaction is how many CloseAccount instructions are packed into one transaction. Each one adds a
+// 32 byte account key, which keeps 20 of them well within the transaction size limit.
+const closesPerTransaction = 20
+
+func init() {
+	commands["close-ata"] = runCloseATA
+}
+
+// reclaimableAccount is an empty token account owned by the signer that can be closed.
+type reclaimableAccount struct {
+	Address  solanago.PublicKey
+	Mint     solanago.PublicKey
+	Lamports uint64
+}
+
+func runCloseATA(ctx context.Context, args []string) error {
+	fs := newFlagSet("close-ata")
+	scan := fs.Bool("scan", false, "List the signer's empty token accounts and the rent they hold, without closing them")
+	accountFlag := fs.String("account", "", "Close only this token account (default: all empty token accounts)")
+	parseFlags(fs, args)
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	accounts, err := findReclaimableAccounts(ctx, clients.Read, signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	if *accountFlag != "" {
+		address, err := solanago.PublicKeyFromBase58(*accountFlag)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --account: %v", ErrInvalidArgument, err)
+		}
+		accounts = filterAccounts(accounts, address)
+		if len(accounts) == 0 {
+			return fmt.Errorf("%w: %s is not an empty token account the signer can close", ErrInvalidArgument, address)
+		}
+	}
+
+	if *scan {
+		printReclaimable(accounts)
+		return nil
+	}
+	if len(accounts) == 0 {
+		slog.Info("no empty token accounts to close")
+		return nil
+	}
+
+	var reclaimed uint64
+	for start := 0; start < len(accounts); start += closesPerTransaction {
+		chunk := accounts[start:min(start+closesPerTransaction, len(accounts))]
+		sig, err := CloseTokenAccounts(ctx, clients, signer, chunk)
+		if err != nil {
+			return err
+		}
+		for _, account := range chunk {
+			reclaimed += account.Lamports
+		}
+		fmt.Println(sig)
+	}
+	slog.Info("closed token accounts", "count", len(accounts), "reclaimed", formatUIAmount(reclaimed, solDecimals))
+	return nil
+}
+
+func filterAccounts(accounts []reclaimableAccount, address solanago.PublicKey) []reclaimableAccount {
+	for _, account := range accounts {
+		if account.Address.Equals(address) {
+			return []reclaimableAccount{account}
+		}
+	}
+	return nil
+}
+
+func printReclaimable(accounts []reclaimableAccount) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tMINT\tRENT (SOL)")
+	var total uint64
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", account.Address, account.Mint, formatUIAmount(account.Lamports, solDecimals))
+		total += account.Lamports
+	}
+	w.Flush()
+	slog.Info("reclaimable rent", "accounts", len(accounts), "total", formatUIAmount(total, solDecimals))
+}
+
+// ownedTokenAccount is a decoded token account together with the lamports (rent) it holds.
+type ownedTokenAccount struct {
+	Address  solanago.PublicKey
+	Account  token.Account
+	Lamports uint64
+}
+
+// listTokenAccounts returns every SPL token account owned by owner.
+func listTokenAccounts(ctx context.Context, client RPCClient, owner solanago.PublicKey) ([]ownedTokenAccount, error) {
+	res, err := client.GetTokenAccountsByOwner(ctx, owner,
+		&rpc.GetTokenAccountsConfig{ProgramId: solanago.TokenProgramID.ToPointer()},
+		&rpc.GetTokenAccountsOpts{Commitment: rpc.CommitmentConfirmed, Encoding: solanago.EncodingBase64},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't list token accounts: %w", classifyRPCError(err))
+	}
+
+	accounts := make([]ownedTokenAccount, 0, len(res.Value))
+	for _, ta := range res.Value {
+		var account token.Account
+		if err := bin.NewBorshDecoder(ta.Account.Data.GetBinary()).Decode(&account); err != nil {
+			slog.Warn("can't decode token account", "account", ta.Pubkey, "error", err)
+			continue
+		}
+		accounts = append(accounts, ownedTokenAccount{Address: ta.Pubkey, Account: account, Lamports: ta.Account.Lamports})
+	}
+	return accounts, nil
+}
+
+// findReclaimableAccounts returns owner's token accounts that hold no tokens and can be closed by owner: not
+// frozen, and without a close authority other than owner.
+func findReclaimableAccounts(ctx context.Context, client RPCClient, owner solanago.PublicKey) ([]reclaimableAccount, error) {
+	owned, err := listTokenAccounts(ctx, client, owner)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []reclaimableAccount
+	for _, ta := range owned {
+		if isReclaimable(ta.Account, owner) {
+			accounts = append(accounts, reclaimableAccount{Address: ta.Address, Mint: ta.Account.Mint, Lamports: ta.Lamports})
+		}
+	}
+	return accounts, nil
+}
+
+func isReclaimable(account token.Account, owner solanago.PublicKey) bool {
+	return account.Amount == 0 && canClose(account, owner)
+}
+
+// canClose reports whether owner may close account once it is empty.
+func canClose(account token.Account, owner solanago.PublicKey) bool {
+	return account.State != token.Frozen && (account.CloseAuthority == nil || account.CloseAuthority.Equals(owner))
+}
+
+// CloseTokenAccounts closes accounts in one transaction, returning their rent lamports to the signer.
+func CloseTokenAccounts(ctx context.Context, clients Clients, signer Signer, accounts []reclaimableAccount) (solanago.Signature, error) {
+	owner := signer.PublicKey()
+	instructions := make([]solanago.Instruction, 0, len(accounts))
+	for _, account := range accounts {
+		slog.Info("closing token account", "account", account.Address, "mint", account.Mint, "rent", formatUIAmount(account.Lamports, solDecimals))
+		instructions = append(instructions, token.NewCloseAccountInstruction(account.Address, owner, owner, nil).Build())
+	}
+	return sendInstructions(ctx, clients, signer, instructions...)
+}
+
+// closeIfEmpty closes owner's token account for mint if it holds no tokens, e.g. after a batch distributed the
+// whole balance.
+func closeIfEmpty(ctx context.Context, clients Clients, signer Signer, mint solanago.PublicKey) error {
+	address, _, err := solanago.FindAssociatedTokenAddress(signer.PublicKey(), mint)
+	if err != nil {
+		return fmt.Errorf("can't get ATA for sender %s: %v", signer.PublicKey(), err)
+	}
+	res, err := GetAccountInfo(ctx, clients.Read, address, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get token account %s: %w", address, classifyRPCError(err))
+	}
+	var account token.Account
+	if err := bin.NewBorshDecoder(res.Value.Data.GetBinary()).Decode(&account); err != nil {
+		return fmt.Errorf("can't decode token account %s: %v", address, err)
+	}
+	if !isReclaimable(account, signer.PublicKey()) {
+		slog.Info("sender token account not closed: it still holds tokens or can't be closed", "account", address, "balance", account.Amount)
+		return nil
+	}
+	sig, err := CloseTokenAccounts(ctx, clients, signer, []reclaimableAccount{{Address: address, Mint: mint, Lamports: res.Value.Lamports}})
+	if err != nil {
+		return err
+	}
+	slog.Info("closed sender token account", "account", address, "signature", sig)
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// clustersFile holds the user's cluster profiles in the data directory.
+const clustersFile = "clusters.json"
+
+// setComputeUnitPrice is the compute budget program's SetComputeUnitPrice instruction.
+const setComputeUnitPrice = 3
+
+// clusterProfile bundles what's needed to work with a cluster through a particular provider. --network selects
+// one by name.
+type clusterProfile struct {
+	Name string `json:"name"`
+	// Cluster is the Solana cluster the profile's endpoints serve: mainnet, devnet, testnet or localnet. It selects
+	// the well-known tokens, the explorer links and whether transfers need confirmation.
+	Cluster string `json:"cluster"`
+	RPC     string `json:"rpc"`
+	// WS is the websocket endpoint. If empty it's the RPC URL with a ws(s) scheme.
+	WS string `json:"ws,omitempty"`
+	// Commitment a sent transaction must reach to count as confirmed: confirmed (the default) or finalized.
+	Commitment string `json:"commitment,omitempty"`
+	// PriorityFee is the default --priority-fee, in micro-lamports per compute unit.
+	PriorityFee uint64 `json:"priorityFee,omitempty"`
+	// Explorer is the base URL of a block explorer serving /tx/<signature> and /account/<address> for this cluster.
+	// It overrides --explorer.
+	Explorer string `json:"explorer,omitempty"`
+	// MintSeeds are the seeds of the program's mint on this cluster, in --mint-seeds syntax, for deployments that
+	// don't use the default.
+	MintSeeds []string `json:"mintSeeds,omitempty"`
+}
+
+// builtinClusters are the profiles available without configuration, using the public endpoints.
+var builtinClusters = []clusterProfile{
+	{Name: "localnet", Cluster: "localnet", RPC: "http://127.0.0.1:8899", WS: "ws://127.0.0.1:8900"},
+	{Name: "devnet", Cluster: "devnet", RPC: "https://api.devnet.solana.com"},
+	{Name: "testnet", Cluster: "testnet", RPC: "https://api.testnet.solana.com"},
+	{Name: "mainnet", Cluster: "mainnet", RPC: "https://api.mainnet-beta.solana.com"},
+}
+
+// priorityFeeFlag is --priority-fee, nil if it wasn't given.
+var priorityFeeFlag *uint64
+
+// commitment is --commitment, overriding the profile's commitment if set.
+var commitment string
+
+func init() {
+	commands["clusters"] = runClusters
+}
+
+// loadClusters returns the built-in profiles, overridden and extended by the ones in dir.
+func loadClusters(dir string) ([]clusterProfile, error) {
+	clusters := slices.Clone(builtinClusters)
+	path := filepath.Join(dir, clustersFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return clusters, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read cluster profiles: %v", err)
+	}
+	var user []clusterProfile
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	for _, p := range user {
+		if p.Name == "" || p.RPC == "" {
+			return nil, fmt.Errorf("%w: %s: every profile needs a name and an rpc URL", ErrInvalidArgument, path)
+		}
+		if !slices.ContainsFunc(builtinClusters, func(b clusterProfile) bool { return b.Cluster == p.Cluster }) {
+			return nil, fmt.Errorf("%w: %s: profile %q: cluster must be mainnet, devnet, testnet or localnet", ErrInvalidArgument, path, p.Name)
+		}
+		if p.Commitment != "" && p.Commitment != "confirmed" && p.Commitment != "finalized" {
+			return nil, fmt.Errorf("%w: %s: profile %q: commitment must be confirmed or finalized", ErrInvalidArgument, path, p.Name)
+		}
+		// A symbol seed is only resolved once --mint-symbol is known.
+		if _, err := parseSeeds(p.MintSeeds, "-"); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", path, p.Name, err)
+		}
+		if i := slices.IndexFunc(clusters, func(c clusterProfile) bool { return c.Name == p.Name }); i >= 0 {
+			clusters[i] = p
+		} else {
+			clusters = append(clusters, p)
+		}
+	}
+	return clusters, nil
+}
+
+// selectedCluster caches the profile currentCluster found for a data directory and network, since it's consulted
+// on every confirmation poll.
+var selectedCluster struct {
+	sync.Mutex
+	dir, network string
+	profile      clusterProfile
+}
+
+// currentCluster returns the profile selected by --network.
+func currentCluster() (clusterProfile, error) {
+	selectedCluster.Lock()
+	defer selectedCluster.Unlock()
+	if selectedCluster.dir == dataDir && selectedCluster.network == network {
+		return selectedCluster.profile, nil
+	}
+	clusters, err := loadClusters(dataDir)
+	if err != nil {
+		return clusterProfile{}, err
+	}
+	for _, c := range clusters {
+		if c.Name == network {
+			selectedCluster.dir, selectedCluster.network, selectedCluster.profile = dataDir, network, c
+			return c, nil
+		}
+	}
+	return clusterProfile{}, fmt.Errorf("%w: unknown network %q, use localnet, devnet, testnet, mainnet or a profile from %s (see `clusters list`)", ErrInvalidArgument, network, clustersFile)
+}
+
+// clusterKind returns the cluster the selected profile connects to, or "" if --network is invalid.
+func clusterKind() string {
+	c, err := currentCluster()
+	if err != nil {
+		return ""
+	}
+	return c.Cluster
+}
+
+// rpcEndpoints returns the selected profile's RPC and websocket endpoints.
+func rpcEndpoints() (string, string, error) {
+	c, err := currentCluster()
+	if err != nil {
+		return "", "", err
+	}
+	if c.WS != "" {
+		return c.RPC, c.WS, nil
+	}
+	return c.RPC, websocketURL(c.RPC), nil
+}
+
+// websocketURL returns the websocket URL providers serve next to an HTTP RPC endpoint.
+func websocketURL(endpoint string) string {
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		return "wss://" + rest
+	}
+	if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		return "ws://" + rest
+	}
+	return endpoint
+}
+
+// finalizedOnly reports whether sent transactions only count as confirmed once finalized.
+func finalizedOnly() bool {
+	if commitment != "" {
+		return commitment == "finalized"
+	}
+	c, err := currentCluster()
+	return err == nil && c.Commitment == "finalized"
+}
+
+// setCommitment parses --commitment.
+func setCommitment(value string) error {
+	if value != "confirmed" && value != "finalized" {
+		return fmt.Errorf("invalid commitment %q, want confirmed or finalized", value)
+	}
+	commitment = value
+	return nil
+}
+
+// setPriorityFee parses --priority-fee.
+func setPriorityFee(value string) error {
+	fee, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid priority fee %q: %v", value, errors.Unwrap(err))
+	}
+	priorityFeeFlag = &fee
+	return nil
+}
+
+// priorityFee returns the compute unit price to pay: --priority-fee, or the profile's default.
+func priorityFee() uint64 {
+	if priorityFeeFlag != nil {
+		return *priorityFeeFlag
+	}
+	c, err := currentCluster()
+	if err != nil {
+		return 0
+	}
+	return c.PriorityFee
+}
+
+// priorityFeeInstructions returns the compute budget instruction setting the priority fee, if there is one.
+func priorityFeeInstructions() []solanago.Instruction {
+	fee := priorityFee()
+	if fee == 0 {
+		return nil
+	}
+	data := binary.LittleEndian.AppendUint64([]byte{setComputeUnitPrice}, fee)
+	return []solanago.Instruction{solanago.NewInstruction(solanago.ComputeBudget, solanago.AccountMetaSlice{}, data)}
+}
+
+func runClusters(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%w: usage: clusters list [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("clusters list")
+	parseFlags(fs, args[1:])
+
+	clusters, err := loadClusters(dataDir)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCLUSTER\tRPC\tCOMMITMENT\tPRIORITY FEE")
+	for _, c := range clusters {
+		commitment := c.Commitment
+		if commitment == "" {
+			commitment = "confirmed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", c.Name, c.Cluster, c.RPC, commitment, c.PriorityFee)
+	}
+	return w.Flush()
+}
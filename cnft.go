@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"slices"
+
+	txsender "github.com/csknk/token-transfer/pkg/sender"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	// bubblegumProgramID is Metaplex Bubblegum, which owns compressed NFTs.
+	bubblegumProgramID = solanago.MustPublicKeyFromBase58("BGUMAp9Gq7iTEuizy4pqaxsTyUCBK68MDfK752saRPUY")
+	// accountCompressionProgramID is SPL Account Compression, which keeps the Merkle trees compressed NFTs are
+	// leaves of, and noopProgramID the SPL Noop program it logs tree changes through.
+	accountCompressionProgramID = solanago.MustPublicKeyFromBase58("cmtDvXumGCrqC1Age74AVPhSRVXJMd8PJS91L8KbNCK")
+	noopProgramID               = solanago.MustPublicKeyFromBase58("noopb9bkMVfRPU8AsbpTUg8AQkHtKwMYZiFUjNRtMmV")
+)
+
+// bubblegumTransferDiscriminator identifies Bubblegum's Anchor transfer instruction.
+var bubblegumTransferDiscriminator = anchorDiscriminator("transfer")
+
+// merkleTreeHeaderSize is the size of a concurrent Merkle tree account's header: account type, header version,
+// max_buffer_size, max_depth, authority, creation_slot and padding.
+const merkleTreeHeaderSize = 2 + 4 + 4 + 32 + 8 + 6
+
+// assetID is the compressed NFT sent by --asset-id instead of a token.
+var assetID string
+
+// compressedTransfer is what the Bubblegum transfer of a compressed NFT needs: the leaf's current state as the
+// DAS API indexed it, and the proof nodes the tree's canopy doesn't already hold.
+type compressedTransfer struct {
+	Asset       solanago.PublicKey
+	Name        string
+	Tree        solanago.PublicKey
+	Owner       solanago.PublicKey
+	Delegate    solanago.PublicKey
+	Root        solanago.Hash
+	DataHash    solanago.Hash
+	CreatorHash solanago.Hash
+	Nonce       uint64
+	Index       uint32
+	Proof       []solanago.PublicKey
+}
+
+// prepareCompressedTransfer looks up the compressed NFT id and its proof, and trims the proof to the nodes the
+// tree's canopy doesn't cache, which keeps the transaction small enough for deep trees.
+func prepareCompressedTransfer(ctx context.Context, client RPCClient, das *dasClient, id solanago.PublicKey) (compressedTransfer, error) {
+	asset, err := das.GetAsset(ctx, id)
+	if err != nil {
+		return compressedTransfer{}, fmt.Errorf("can't get asset %s: %w", id, err)
+	}
+	if !asset.Compression.Compressed {
+		return compressedTransfer{}, fmt.Errorf("%w: asset %s isn't compressed, send it with --token", ErrInvalidArgument, id)
+	}
+	if asset.Burnt {
+		return compressedTransfer{}, fmt.Errorf("%w: asset %s was burnt", ErrInvalidArgument, id)
+	}
+	proof, err := das.GetAssetProof(ctx, id)
+	if err != nil {
+		return compressedTransfer{}, fmt.Errorf("can't get proof of asset %s: %w", id, err)
+	}
+
+	t := compressedTransfer{Asset: id, Name: asset.Content.Metadata.Name, Nonce: asset.Compression.LeafID}
+	if t.Tree, err = solanago.PublicKeyFromBase58(asset.Compression.Tree); err != nil {
+		return compressedTransfer{}, fmt.Errorf("invalid tree of asset %s: %v", id, err)
+	}
+	if t.Owner, err = solanago.PublicKeyFromBase58(asset.Ownership.Owner); err != nil {
+		return compressedTransfer{}, fmt.Errorf("invalid owner of asset %s: %v", id, err)
+	}
+	// Without a delegate, the owner is the leaf's delegate.
+	t.Delegate = t.Owner
+	if asset.Ownership.Delegate != "" {
+		if t.Delegate, err = solanago.PublicKeyFromBase58(asset.Ownership.Delegate); err != nil {
+			return compressedTransfer{}, fmt.Errorf("invalid delegate of asset %s: %v", id, err)
+		}
+	}
+	hashes := []struct {
+		name  string
+		value string
+		out   *solanago.Hash
+	}{{"root", proof.Root, &t.Root}, {"data hash", asset.Compression.DataHash, &t.DataHash}, {"creator hash", asset.Compression.CreatorHash, &t.CreatorHash}}
+	for _, h := range hashes {
+		if *h.out, err = solanago.HashFromBase58(h.value); err != nil {
+			return compressedTransfer{}, fmt.Errorf("invalid %s of asset %s: %v", h.name, id, err)
+		}
+	}
+
+	maxDepth, canopyDepth, err := merkleTreeDepths(ctx, client, t.Tree)
+	if err != nil {
+		return compressedTransfer{}, err
+	}
+	if proof.NodeIndex < 1<<maxDepth || len(proof.Proof) < int(maxDepth) {
+		return compressedTransfer{}, fmt.Errorf("das returned a proof of asset %s that doesn't fit its tree of depth %d", id, maxDepth)
+	}
+	t.Index = uint32(proof.NodeIndex - 1<<maxDepth)
+	for _, node := range proof.Proof[:maxDepth-canopyDepth] {
+		key, err := solanago.PublicKeyFromBase58(node)
+		if err != nil {
+			return compressedTransfer{}, fmt.Errorf("invalid proof node of asset %s: %v", id, err)
+		}
+		t.Proof = append(t.Proof, key)
+	}
+	slog.Debug("prepared compressed NFT transfer", "asset", id, "tree", t.Tree, "leaf", t.Index, "maxDepth", maxDepth, "canopyDepth", canopyDepth, "proof", len(t.Proof))
+	return t, nil
+}
+
+// merkleTreeDepths reads a concurrent Merkle tree account's depth and the depth of its canopy, the top levels
+// of the tree the account caches so proofs can leave them out.
+func merkleTreeDepths(ctx context.Context, client RPCClient, tree solanago.PublicKey) (uint32, uint32, error) {
+	res, err := GetAccountInfo(ctx, client, tree, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("can't get merkle tree %s: %w", tree, classifyRPCError(err))
+	}
+	if res == nil || res.Value == nil {
+		return 0, 0, fmt.Errorf("merkle tree %s doesn't exist", tree)
+	}
+	data := res.Value.Data.GetBinary()
+	if !res.Value.Owner.Equals(accountCompressionProgramID) || len(data) < merkleTreeHeaderSize {
+		return 0, 0, fmt.Errorf("%s isn't a concurrent merkle tree", tree)
+	}
+	maxBufferSize := uint64(binary.LittleEndian.Uint32(data[2:6]))
+	maxDepth := binary.LittleEndian.Uint32(data[6:10])
+	// sequence_number, active_index and buffer_size, the change log buffer, then the rightmost path. A change
+	// log and a path are each 32 bytes per level plus a root or leaf and an index.
+	path := uint64(40 + 32*maxDepth)
+	size := uint64(merkleTreeHeaderSize) + 24 + maxBufferSize*path + path
+	if maxDepth == 0 || maxDepth > 30 || uint64(len(data)) < size {
+		return 0, 0, fmt.Errorf("%s isn't a concurrent merkle tree", tree)
+	}
+	// A canopy of depth n holds the 2^(n+1)-2 nodes of the top n levels below the root.
+	nodes := (uint64(len(data)) - size) / 32
+	canopyDepth := uint32(bits.Len64(nodes+2)) - 2
+	return maxDepth, min(canopyDepth, maxDepth), nil
+}
+
+// bubblegumTransferInstruction moves the compressed NFT t to receiver, signed by its owner or delegate, signer.
+func bubblegumTransferInstruction(t compressedTransfer, signer, receiver solanago.PublicKey) (solanago.Instruction, error) {
+	treeConfig, _, err := solanago.FindProgramAddress([][]byte{t.Tree.Bytes()}, bubblegumProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("can't derive tree config of %s: %v", t.Tree, err)
+	}
+	data := slices.Clone(bubblegumTransferDiscriminator)
+	data = append(data, t.Root[:]...)
+	data = append(data, t.DataHash[:]...)
+	data = append(data, t.CreatorHash[:]...)
+	data = binary.LittleEndian.AppendUint64(data, t.Nonce)
+	data = binary.LittleEndian.AppendUint32(data, t.Index)
+
+	accounts := solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(treeConfig, false, false),
+		solanago.NewAccountMeta(t.Owner, false, t.Owner.Equals(signer)),
+		solanago.NewAccountMeta(t.Delegate, false, !t.Owner.Equals(signer)),
+		solanago.NewAccountMeta(receiver, false, false),
+		solanago.NewAccountMeta(t.Tree, true, false),
+		solanago.NewAccountMeta(noopProgramID, false, false),
+		solanago.NewAccountMeta(accountCompressionProgramID, false, false),
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+	}
+	for _, node := range t.Proof {
+		accounts = append(accounts, solanago.NewAccountMeta(node, false, false))
+	}
+	return solanago.NewInstruction(bubblegumProgramID, accounts, data), nil
+}
+
+// compressedTransferSigner returns a sign function building and signing the compressed NFT transfer against
+// the blockhash the send loop provides. The proof stays valid while the tree's change log still holds its root,
+// so it isn't fetched again when the blockhash expires.
+func compressedTransferSigner(clients Clients, signer Signer, receiver solanago.PublicKey, t compressedTransfer, opts TransferOptions) txsender.SignFunc {
+	return func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		payer := opts.payer(signer.PublicKey())
+		tip, err := clients.Sender.TipInstructions(ctx, payer)
+		if err != nil {
+			return nil, err
+		}
+		transfer, err := bubblegumTransferInstruction(t, signer.PublicKey(), receiver)
+		if err != nil {
+			return nil, err
+		}
+		instructions := slices.Clone(opts.PreInstructions)
+		if opts.Memo != "" {
+			instructions = append(instructions, memoInstruction(opts.Memo))
+		}
+		instructions = append(instructions, transfer)
+		instructions = append(instructions, opts.PostInstructions...)
+		instructions = append(instructions, tip...)
+
+		txOpts := []solanago.TransactionOption{solanago.TransactionPayer(payer)}
+		if len(opts.AddressTables) > 0 {
+			txOpts = append(txOpts, solanago.TransactionAddressTables(opts.AddressTables))
+		}
+		tx, err := solanago.NewTransaction(instructions, blockhash, txOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("can't build transaction: %v", err)
+		}
+		if opts.Versioned {
+			tx.Message.SetVersion(solanago.MessageVersionV0)
+		}
+		if err := signTransaction(tx, signer, opts.signers()...); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+}
+
+// runCompressedTransfer sends the compressed NFT --asset-id to --receiver with Bubblegum's transfer.
+func runCompressedTransfer(ctx context.Context) error {
+	switch {
+	case receiver == "":
+		return fmt.Errorf("%w: --receiver flag is required", ErrInvalidArgument)
+	case amount != 0 || amountPercent != nil || tokenName != "":
+		return fmt.Errorf("%w: --asset-id sends one compressed NFT, --amount and --token can't be combined with it", ErrInvalidArgument)
+	case receiverAccount != "" || testSend != "":
+		return fmt.Errorf("%w: --receiver-token-account and --test-send can't be combined with --asset-id", ErrInvalidArgument)
+	}
+	asset, err := solanago.PublicKeyFromBase58(assetID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --asset-id: %v", ErrInvalidArgument, err)
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(receiver)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if err := checkReceiverOwner(receiverKey); err != nil {
+		return err
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	das, err := newDASClient()
+	if err != nil {
+		return err
+	}
+
+	opts := TransferOptions{
+		PreInstructions:  append(priorityFeeInstructions(), preInstructions...),
+		PostInstructions: postInstructions,
+		Memo:             transferMemo,
+	}
+	if err := txFormatOptions(ctx, clients.Read, signer.PublicKey(), &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+	t, err := prepareCompressedTransfer(ctx, clients.Read, das, asset)
+	if err != nil {
+		return err
+	}
+	if !t.Owner.Equals(signer.PublicKey()) && !t.Delegate.Equals(signer.PublicKey()) {
+		return fmt.Errorf("%w: asset %s is owned by %s, not the signer", ErrInvalidArgument, asset, t.Owner)
+	}
+	if t.Owner.Equals(receiverKey) {
+		return fmt.Errorf("%w: %s already owns asset %s", ErrInvalidRecipient, receiverKey, asset)
+	}
+
+	sign := compressedTransferSigner(clients, signer, receiverKey, t, opts)
+	if dryRun {
+		latest, err := clients.Read.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+		if err != nil {
+			return fmt.Errorf("can't get recent block hash: %w", classifyRPCError(err))
+		}
+		tx, err := sign(ctx, latest.Value.Blockhash)
+		if err != nil {
+			return err
+		}
+		return clients.Sender.Simulate(ctx, tx)
+	}
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+
+	var record transferRecord
+	if idempotencyKey != "" {
+		if existing, ok := store.TransferByIdempotencyKey(idempotencyKey); ok {
+			if existing.Receiver != receiverKey.String() || existing.Mint != asset.String() {
+				return fmt.Errorf("%w: idempotency key %q was used for another transfer", ErrInvalidArgument, idempotencyKey)
+			}
+			done, err := resumeTransfer(ctx, clients.Read, store, existing)
+			if done {
+				if err != nil {
+					return err
+				}
+				slog.Info("transfer already confirmed, not sending again", "id", existing.ID, "signature", existing.Signature)
+				fmt.Println(existing.Signature)
+				return nil
+			}
+			record = existing
+		}
+	}
+	if record.ID == "" {
+		if err := confirmCompressedTransfer(t, receiverKey); err != nil {
+			return err
+		}
+		record = newTransferRecord(idempotencyKey, signer.PublicKey(), receiverKey, asset, "1")
+		if err := store.PutTransfer(record); err != nil {
+			return fmt.Errorf("can't record transfer: %v", err)
+		}
+	}
+
+	sig, err := clients.Sender.SendAndConfirm(ctx, sign, journalSignature(store, record.ID))
+	if err != nil {
+		err = fmt.Errorf("can't send transaction: %w", classifySendError(err))
+	}
+	recordOutcome(store, record.ID, err)
+	if err != nil {
+		return err
+	}
+	slog.Info("sent compressed NFT", "asset", asset, "name", t.Name, "receiver", receiverKey, "signature", sig)
+	if link := explorerTxURL(sig.String()); link != "" {
+		slog.Info("view on explorer", "transaction", link)
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// confirmCompressedTransfer asks the operator to confirm a mainnet compressed NFT transfer, unless --yes is set.
+func confirmCompressedTransfer(t compressedTransfer, receiver solanago.PublicKey) error {
+	if clusterKind() != "mainnet" || assumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w: mainnet transfers need confirmation, pass --yes to send non-interactively", ErrAborted)
+	}
+	ok, err := promptYesNo(fmt.Sprintf("Send compressed NFT %q (%s) to %s? Transfers can't be reversed.", t.Name, t.Asset, receiver))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: transfer not confirmed", ErrAborted)
+	}
+	return nil
+}
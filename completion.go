@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+func init() {
+	commands["completion"] = runCompletion
+}
+
+// completionScripts are the shell completion scripts. All of them ask `completion __complete` for the candidates
+// of the word being completed, given the words before it, and fall back to file names when it has none.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for token-transfer. Load it with:
+#   source <(token-transfer completion bash)
+_token_transfer() {
+	local IFS=$'\n'
+	COMPREPLY=($(token-transfer completion __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _token_transfer token-transfer
+`,
+	"zsh": `#compdef token-transfer
+# zsh completion for token-transfer. Load it with:
+#   source <(token-transfer completion zsh)
+_token_transfer() {
+	local -a candidates
+	candidates=("${(@f)$(token-transfer completion __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${candidates[1]}" ]]; then
+		compadd -Q -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+compdef _token_transfer token-transfer
+`,
+	"fish": `# fish completion for token-transfer. Load it with:
+#   token-transfer completion fish | source
+function __token_transfer_complete
+	set -l words (commandline -opc)
+	set -e words[1]
+	token-transfer completion __complete $words (commandline -ct) 2>/dev/null
+end
+complete -c token-transfer -a '(__token_transfer_complete)'
+`,
+}
+
+// valueCompletions lists the candidates for the values of flags that name configured things.
+var valueCompletions = map[string]func() []string{
+	"from":    walletNames,
+	"token":   tokenSymbols,
+	"mint":    tokenSymbols,
+	"network": networkNames,
+	"send": func() []string {
+		symbols := tokenSymbols()
+		for i, symbol := range symbols {
+			symbols[i] = symbol + ":"
+		}
+		return symbols
+	},
+}
+
+// flagName matches the flags in a flag set's usage output.
+var flagName = regexp.MustCompile(`(?m)^  -(\S+)`)
+
+func runCompletion(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "__complete" {
+		for _, candidate := range completeWords(ctx, args[1:]) {
+			fmt.Println(candidate)
+		}
+		return nil
+	}
+	fs := newFlagSet("completion")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token-transfer completion bash|zsh|fish")
+	}
+	parseFlags(fs, args)
+	script, ok := completionScripts[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return fmt.Errorf("%w: expected the shell: bash, zsh or fish", ErrInvalidArgument)
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completeWords returns the candidates for the last of words, the command line after the program name. bash
+// splits --flag=value into three words, "--flag", "=" and "value", the others keep it as one.
+func completeWords(ctx context.Context, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	previous := words[:len(words)-1]
+	command := ""
+	if len(previous) > 0 && commands[previous[0]] != nil {
+		command = previous[0]
+	}
+	// The values listed depend on the network and data directory given earlier on the line.
+	for i, word := range previous {
+		name, value, found := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if !found && i+1 < len(previous) {
+			value = previous[i+1]
+			if value == "=" && i+2 < len(previous) {
+				value = previous[i+2]
+			}
+		}
+		switch {
+		case !strings.HasPrefix(word, "-"):
+		case name == "network":
+			network = value
+		case name == "data-dir":
+			dataDir = value
+		}
+	}
+
+	// The flag whose value is being completed, if any.
+	valueOf := ""
+	if n := len(previous); n > 0 && previous[n-1] == "=" && n > 1 {
+		valueOf = previous[n-2]
+	} else if n > 0 {
+		valueOf = previous[n-1]
+	}
+	var candidates []string
+	switch values := valueCompletions[strings.TrimLeft(valueOf, "-")]; {
+	case strings.HasPrefix(valueOf, "-") && values != nil:
+		candidates = values()
+	case strings.HasPrefix(current, "-"):
+		if name, _, found := strings.Cut(strings.TrimLeft(current, "-"), "="); found {
+			if values := valueCompletions[name]; values != nil {
+				for _, value := range values() {
+					candidates = append(candidates, "--"+name+"="+value)
+				}
+			}
+			break
+		}
+		for _, name := range commandFlags(ctx, command) {
+			candidates = append(candidates, "--"+name)
+		}
+	case len(previous) == 0:
+		candidates = slices.Sorted(maps.Keys(commands))
+	}
+	return slices.DeleteFunc(candidates, func(c string) bool { return !strings.HasPrefix(c, current) })
+}
+
+// commandFlags returns the flags of command, or of a plain transfer if command is "". Subcommands register their
+// flags when they run, so they're read from the usage the subcommand prints for -h.
+func commandFlags(ctx context.Context, command string) []string {
+	var names []string
+	if command == "" {
+		flag.CommandLine.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		return names
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	// -h exits before the command does anything; the exit status doesn't matter.
+	usage, _ := exec.CommandContext(ctx, self, command, "-h").CombinedOutput()
+	for _, match := range flagName.FindAllSubmatch(usage, -1) {
+		names = append(names, string(match[1]))
+	}
+	return names
+}
+
+// walletNames returns the names of the configured wallets.
+func walletNames() []string {
+	wallets, err := loadWallets(dataDir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(wallets))
+	for i, w := range wallets {
+		names[i] = w.Name
+	}
+	return names
+}
+
+// tokenSymbols returns the symbols in the token registry of the --network cluster.
+func tokenSymbols() []string {
+	registry, err := loadTokenRegistry(dataDir, clusterKind())
+	if err != nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// networkNames returns the names of the cluster profiles.
+func networkNames() []string {
+	clusters, err := loadClusters(dataDir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(clusters))
+	for i, c := range clusters {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// confirmPollInterval is how often WaitForConfirmation checks a signature's status.
+const confirmPollInterval = time.Second
+
+// WaitForConfirmation polls the status of sig until it reaches commitment, fails on-chain, or can no longer land
+// because the finalized block height has passed lastValidBlockHeight, the last valid block height of the
+// blockhash it was signed with. A lastValidBlockHeight of 0 waits until ctx is done.
+//
+// It returns nil once the transaction is confirmed, an ErrTransactionFailed error if it failed, an
+// ErrBlockhashExpired error if it definitely expired without landing, and an ErrAborted error if ctx is done
+// while it could still land.
+func WaitForConfirmation(ctx context.Context, client RPCClient, sig solanago.Signature, lastValidBlockHeight uint64, commitment rpc.CommitmentType) error {
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+	for {
+		if done, err := signatureDone(ctx, client, sig, commitment); done {
+			return err
+		}
+		if lastValidBlockHeight > 0 {
+			// Lookup errors are retried on the next tick; ctx bounds how long that can go on.
+			height, err := client.GetBlockHeight(ctx, rpc.CommitmentFinalized)
+			if err == nil && height > lastValidBlockHeight {
+				// It may have landed between the status check and now.
+				if done, err := signatureDone(ctx, client, sig, commitment); done {
+					return err
+				}
+				return fmt.Errorf("%w: %s wasn't confirmed by block height %d", ErrBlockhashExpired, sig, lastValidBlockHeight)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s is still pending: %v", ErrAborted, sig, context.Cause(ctx))
+		case <-ticker.C:
+		}
+	}
+}
+
+// signatureDone reports whether sig reached commitment or failed, and the error to return if it failed. Status
+// lookup errors count as not known yet.
+func signatureDone(ctx context.Context, client RPCClient, sig solanago.Signature, commitment rpc.CommitmentType) (bool, error) {
+	res, err := client.GetSignatureStatuses(ctx, false, sig)
+	if err != nil || len(res.Value) == 0 || res.Value[0] == nil {
+		return false, nil
+	}
+	status := res.Value[0]
+	if status.Err != nil {
+		return true, fmt.Errorf("%w: %s: %v", ErrTransactionFailed, sig, status.Err)
+	}
+	return commitmentReached(status.ConfirmationStatus, commitment), nil
+}
+
+// commitmentReached reports whether a transaction with the given confirmation status has reached commitment.
+func commitmentReached(status rpc.ConfirmationStatusType, commitment rpc.CommitmentType) bool {
+	switch status {
+	case rpc.ConfirmationStatusFinalized:
+		return true
+	case rpc.ConfirmationStatusConfirmed:
+		return commitment != rpc.CommitmentFinalized
+	case rpc.ConfirmationStatusProcessed:
+		return commitment == rpc.CommitmentProcessed
+	}
+	return false
+}
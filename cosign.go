@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+func init() {
+	commands["cosign"] = runCosign
+}
+
+// CosignTransaction adds signer's signature to tx, which other parties may have signed already or sign later. It
+// fails with ErrSignerUnavailable if signer isn't one of tx's required signers. Existing signatures are kept.
+func CosignTransaction(tx *solanago.Transaction, signer Signer) error {
+	required := tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures]
+	i := slices.IndexFunc(required, signer.PublicKey().Equals)
+	if i < 0 {
+		return fmt.Errorf("%w: %s isn't a required signer of the transaction", ErrSignerUnavailable, signer.PublicKey())
+	}
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("can't encode transaction message: %v", err)
+	}
+	sig, err := signer.Sign(message)
+	if err != nil {
+		return fmt.Errorf("%w: can't sign transaction: %v", ErrSignerUnavailable, err)
+	}
+	if len(tx.Signatures) < len(required) {
+		tx.Signatures = append(tx.Signatures, make([]solanago.Signature, len(required)-len(tx.Signatures))...)
+	}
+	tx.Signatures[i] = sig
+	return nil
+}
+
+func runCosign(ctx context.Context, args []string) error {
+	fs := newFlagSet("cosign")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token-transfer cosign [flags] TRANSACTION|-")
+		fs.PrintDefaults()
+	}
+	encoding := fs.String("encoding", "base64", "Encoding of the signed transaction written to stdout: base64 or base58")
+	yes := fs.Bool("yes", false, "Sign without showing the transaction and asking for confirmation")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("%w: expected one base64 or base58 transaction, or - to read stdin", ErrInvalidArgument)
+	}
+	if *encoding != "base64" && *encoding != "base58" {
+		return fmt.Errorf("%w: --encoding must be base64 or base58, got %q", ErrInvalidArgument, *encoding)
+	}
+
+	tx, err := readSerializedTransaction(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+
+	// The transaction was built by someone else: whatever it does is signed for, so show it first.
+	if !*yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%w: cosigning needs confirmation, pass --yes to sign non-interactively", ErrAborted)
+		}
+		if err := printDecodedTransaction(os.Stderr, tx, nil); err != nil {
+			return err
+		}
+		ok, err := promptYesNo(fmt.Sprintf("Sign this transaction as %s?", signer.PublicKey()))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: transaction not signed", ErrAborted)
+		}
+	}
+
+	if err := CosignTransaction(tx, signer); err != nil {
+		return err
+	}
+	var missing []solanago.PublicKey
+	for i, key := range tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures] {
+		if tx.Signatures[i].IsZero() {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		slog.Info("transaction still needs signatures", "signers", missing)
+	} else {
+		slog.Info("transaction is fully signed")
+	}
+
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("can't encode transaction: %v", err)
+	}
+	if *encoding == "base58" {
+		fmt.Println(base58.Encode(raw))
+	} else {
+		fmt.Println(base64.StdEncoding.EncodeToString(raw))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"os"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// dasURL is the Digital Asset Standard (DAS) API endpoint. DAS is served by RPC providers that index assets, such
+// as Helius or Triton, usually on their regular RPC URL. The provider's API key is read from DAS_API_KEY.
+var dasURL string
+
+// dasPageLimit is the most items a DAS provider returns in one page.
+const dasPageLimit = 1000
+
+func registerDASFlags(fs *flag.FlagSet) {
+	fs.StringVar(&dasURL, "das-url", "", "DAS API endpoint (e.g. Helius) used for asset lookups and compressed NFTs, and when set for snapshots and balances instead of getProgramAccounts; key from DAS_API_KEY")
+}
+
+// useDAS reports whether --das-url selects the DAS backend for queries plain RPC can also answer, more slowly.
+func useDAS() bool {
+	return dasURL != ""
+}
+
+// dasClient talks to a DAS API provider, which indexes assets and token accounts. Compressed NFTs can only be
+// found this way, since their data only lives in ledger history and the Merkle tree's root; holders and balances
+// come back much faster than from getProgramAccounts.
+type dasClient struct {
+	url    string
+	client *http.Client
+}
+
+// newDASClient returns a client for --das-url, or for the read endpoint if it isn't set.
+func newDASClient() (*dasClient, error) {
+	url := dasURL
+	if url == "" {
+		var err error
+		if url, err = readEndpoint(); err != nil {
+			return nil, err
+		}
+	}
+	if key := os.Getenv("DAS_API_KEY"); key != "" {
+		// Helius and most other providers take the key as the api-key query parameter.
+		u, err := neturl.Parse(url)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid --das-url: %v", ErrInvalidArgument, err)
+		}
+		query := u.Query()
+		query.Set("api-key", key)
+		u.RawQuery = query.Encode()
+		url = u.String()
+	}
+	transport := newRateLimitTransport(rpcRPS, rpcBurst, &tracingTransport{next: http.DefaultTransport})
+	return &dasClient{url: url, client: &http.Client{Transport: transport, Timeout: rpcTimeout}}, nil
+}
+
+// dasAsset is the part of a getAsset result describing what an asset is, who owns it and, for a compressed NFT,
+// where its leaf is.
+type dasAsset struct {
+	ID        string `json:"id"`
+	Interface string `json:"interface"`
+	Content   struct {
+		JSONURI  string `json:"json_uri"`
+		Metadata struct {
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		} `json:"metadata"`
+	} `json:"content"`
+	Compression struct {
+		Compressed  bool   `json:"compressed"`
+		Tree        string `json:"tree"`
+		LeafID      uint64 `json:"leaf_id"`
+		DataHash    string `json:"data_hash"`
+		CreatorHash string `json:"creator_hash"`
+	} `json:"compression"`
+	Ownership struct {
+		Owner    string `json:"owner"`
+		Delegate string `json:"delegate"`
+		Frozen   bool   `json:"frozen"`
+	} `json:"ownership"`
+	Mutable bool `json:"mutable"`
+	Burnt   bool `json:"burnt"`
+}
+
+// dasTokenAccount is a token account as listed by getTokenAccounts, an extension of DAS that Helius and others
+// serve. The amount is in raw base units.
+type dasTokenAccount struct {
+	Address string `json:"address"`
+	Mint    string `json:"mint"`
+	Owner   string `json:"owner"`
+	Amount  uint64 `json:"amount"`
+	Frozen  bool   `json:"frozen"`
+}
+
+// dasAssetProof is a getAssetProof result: the current root of the asset's tree and the path from its leaf to it.
+type dasAssetProof struct {
+	Root      string   `json:"root"`
+	Proof     []string `json:"proof"`
+	NodeIndex uint64   `json:"node_index"`
+	TreeID    string   `json:"tree_id"`
+}
+
+// GetAsset fetches the asset id.
+func (d *dasClient) GetAsset(ctx context.Context, id solanago.PublicKey) (dasAsset, error) {
+	var asset dasAsset
+	err := d.call(ctx, "getAsset", map[string]any{"id": id.String()}, &asset)
+	return asset, err
+}
+
+// GetAssetProof fetches the Merkle proof of the compressed asset id against its tree's current root.
+func (d *dasClient) GetAssetProof(ctx context.Context, id solanago.PublicKey) (dasAssetProof, error) {
+	var proof dasAssetProof
+	err := d.call(ctx, "getAssetProof", map[string]any{"id": id.String()}, &proof)
+	return proof, err
+}
+
+// TokenAccounts lists the token accounts matching filter, e.g. {"mint": ...} or {"owner": ...}, page by page.
+// Accounts of both token programs are listed.
+func (d *dasClient) TokenAccounts(ctx context.Context, filter map[string]any) ([]dasTokenAccount, error) {
+	var accounts []dasTokenAccount
+	for page := 1; ; page++ {
+		params := map[string]any{"page": page, "limit": dasPageLimit}
+		for k, v := range filter {
+			params[k] = v
+		}
+		var res struct {
+			TokenAccounts []dasTokenAccount `json:"token_accounts"`
+		}
+		if err := d.call(ctx, "getTokenAccounts", params, &res); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, res.TokenAccounts...)
+		if len(res.TokenAccounts) < dasPageLimit {
+			return accounts, nil
+		}
+		if page%10 == 0 {
+			slog.Info("listing token accounts", "pages", page, "accounts", len(accounts))
+		}
+	}
+}
+
+// call makes a JSON-RPC request to the DAS provider. Errors are classified like those of the cluster's RPC.
+func (d *dasClient) call(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: invalid --das-url: %v", ErrInvalidArgument, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("das %s: %w", method, classifyRPCError(err))
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *jsonrpc.RPCError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("%w: das %s: HTTP %d", ErrRPCUnavailable, method, resp.StatusCode)
+		}
+		return fmt.Errorf("das %s: HTTP %d: can't decode response: %v", method, resp.StatusCode, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("das %s: %w", method, classifyRPCError(reply.Error))
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return fmt.Errorf("das %s: can't decode result: %v", method, err)
+	}
+	return nil
+}
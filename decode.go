@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/mr-tron/base58"
+)
+
+func init() {
+	commands["decode"] = runDecode
+}
+
+// programNames are the programs decode names instead of printing their address.
+var programNames = map[solanago.PublicKey]string{
+	solanago.SystemProgramID:                    "System",
+	solanago.TokenProgramID:                     "Token",
+	solanago.Token2022ProgramID:                 "Token-2022",
+	solanago.SPLAssociatedTokenAccountProgramID: "Associated Token Account",
+	solanago.MemoProgramID:                      "Memo",
+	solanago.ComputeBudget:                      "Compute Budget",
+	solanago.AddressLookupTableProgramID:        "Address Lookup Table",
+	solanago.TokenMetadataProgramID:             "Token Metadata",
+}
+
+// tokenInstructionNames are the SPL Token instructions by their first data byte. Token-2022 shares them.
+var tokenInstructionNames = map[byte]string{
+	0: "InitializeMint", 1: "InitializeAccount", 2: "InitializeMultisig", 3: "Transfer", 4: "Approve", 5: "Revoke",
+	6: "SetAuthority", 7: "MintTo", 8: "Burn", 9: "CloseAccount", 10: "FreezeAccount", 11: "ThawAccount",
+	12: "TransferChecked", 13: "ApproveChecked", 14: "MintToChecked", 15: "BurnChecked", 16: "InitializeAccount2",
+	17: "SyncNative", 18: "InitializeAccount3", 19: "InitializeMultisig2", 20: "InitializeMint2",
+}
+
+// readSerializedTransaction decodes a wire-format transaction given as base64 or base58, or read from stdin if
+// value is "-".
+func readSerializedTransaction(value string) (*solanago.Transaction, error) {
+	if value == "-" {
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("can't read transaction from stdin: %v", err)
+		}
+		value = string(raw)
+	}
+	value = strings.TrimSpace(value)
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		if raw, err = base58.Decode(value); err != nil {
+			return nil, fmt.Errorf("%w: the transaction is neither base64 nor base58", ErrInvalidArgument)
+		}
+	}
+	tx, err := solanago.TransactionFromDecoder(bin.NewBinDecoder(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't decode transaction: %v", ErrInvalidArgument, err)
+	}
+	return tx, nil
+}
+
+func runDecode(ctx context.Context, args []string) error {
+	fs := newFlagSet("decode")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token-transfer decode [flags] TRANSACTION|SIGNATURE|-")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("%w: expected one base64 or base58 transaction, a signature, or - to read stdin", ErrInvalidArgument)
+	}
+	value := fs.Arg(0)
+
+	// A bare signature is the only input that decodes to exactly 64 bytes: the smallest transaction is larger.
+	if raw, err := base58.Decode(value); err == nil && len(raw) == 64 {
+		return decodeLandedTransaction(ctx, solanago.SignatureFromBytes(raw))
+	}
+	tx, err := readSerializedTransaction(value)
+	if err != nil {
+		return err
+	}
+	return printDecodedTransaction(os.Stdout, tx, nil)
+}
+
+// decodeLandedTransaction fetches the confirmed transaction sig and prints it along with its status and fee.
+func decodeLandedTransaction(ctx context.Context, sig solanago.Signature) error {
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	maxVersion := uint64(0)
+	res, err := clients.Read.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return fmt.Errorf("%w: transaction %s not found, or not confirmed yet", ErrInvalidArgument, sig)
+	}
+	if err != nil {
+		return fmt.Errorf("can't get transaction %s: %w", sig, classifyRPCError(err))
+	}
+	if res == nil || res.Transaction == nil {
+		return fmt.Errorf("transaction %s has no data", sig)
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return fmt.Errorf("can't decode transaction %s: %v", sig, err)
+	}
+	status := &landedStatus{res: res}
+	if res.Meta != nil {
+		status.writable = res.Meta.LoadedAddresses.Writable
+		status.loaded = append(append(status.loaded, res.Meta.LoadedAddresses.Writable...), res.Meta.LoadedAddresses.ReadOnly...)
+	}
+	return printDecodedTransaction(os.Stdout, tx, status)
+}
+
+// landedStatus is what the cluster reports about a landed transaction.
+type landedStatus struct {
+	res *rpc.GetTransactionResult
+	// loaded are the accounts the transaction loaded from address lookup tables, the writable ones first.
+	loaded   solanago.PublicKeySlice
+	writable solanago.PublicKeySlice
+}
+
+// printDecodedTransaction writes a readable view of tx to w. status is set for landed transactions.
+func printDecodedTransaction(w io.Writer, tx *solanago.Transaction, status *landedStatus) error {
+	msg := tx.Message
+	keys := msg.AccountKeys
+	if status != nil {
+		keys = append(append(solanago.PublicKeySlice{}, keys...), status.loaded...)
+	}
+
+	version := "legacy"
+	if msg.IsVersioned() {
+		version = "v0"
+	}
+	fmt.Fprintf(w, "Version:    %s\n", version)
+	fmt.Fprintf(w, "Blockhash:  %s\n", msg.RecentBlockhash)
+	if status != nil {
+		fmt.Fprintf(w, "Slot:       %d\n", status.res.Slot)
+		if status.res.Meta != nil {
+			result := "success"
+			if status.res.Meta.Err != nil {
+				result = fmt.Sprintf("failed: %v", status.res.Meta.Err)
+			}
+			fmt.Fprintf(w, "Status:     %s\n", result)
+			fmt.Fprintf(w, "Fee:        %s SOL\n", formatUIAmount(status.res.Meta.Fee, 9))
+		}
+	}
+
+	fmt.Fprintln(w, "\nSignatures:")
+	message, err := msg.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("can't encode transaction message: %v", err)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i := range int(msg.Header.NumRequiredSignatures) {
+		if i >= len(keys) {
+			break
+		}
+		state := "missing"
+		if i < len(tx.Signatures) && !tx.Signatures[i].IsZero() {
+			state = "valid"
+			if !tx.Signatures[i].Verify(keys[i], message) {
+				state = "INVALID"
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", keys[i], state, signatureText(tx, i))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nAccounts:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, key := range keys {
+		fmt.Fprintf(tw, "  %d\t%s\t%s\n", i, key, strings.Join(accountRoles(msg, i, len(msg.AccountKeys), status), ", "))
+	}
+	if status == nil {
+		for _, lookup := range msg.AddressTableLookups {
+			fmt.Fprintf(tw, "  -\tlookup table %s\t%d writable, %d readonly (not resolved)\n", lookup.AccountKey, len(lookup.WritableIndexes), len(lookup.ReadonlyIndexes))
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nInstructions:")
+	for i, ix := range msg.Instructions {
+		program := accountAt(keys, ix.ProgramIDIndex)
+		fmt.Fprintf(w, "  #%d %s\n", i, programLabel(program))
+		for _, detail := range describeInstruction(program, ix.Data) {
+			fmt.Fprintf(w, "     %s\n", detail)
+		}
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, index := range ix.Accounts {
+			fmt.Fprintf(tw, "     %d\t%s\n", index, accountAt(keys, index))
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+// signatureText returns the i-th signature of tx, or "" if it's missing.
+func signatureText(tx *solanago.Transaction, i int) string {
+	if i >= len(tx.Signatures) || tx.Signatures[i].IsZero() {
+		return ""
+	}
+	return tx.Signatures[i].String()
+}
+
+// accountRoles returns the roles of the account at index in msg, whose static account list has static entries.
+// Accounts after those were loaded from lookup tables.
+func accountRoles(msg solanago.Message, index, static int, status *landedStatus) []string {
+	var roles []string
+	if index == 0 {
+		roles = append(roles, "fee payer")
+	}
+	signers := int(msg.Header.NumRequiredSignatures)
+	var writable bool
+	switch {
+	case index >= static:
+		writable = status != nil && index-static < len(status.writable)
+		roles = append(roles, "from lookup table")
+	case index < signers:
+		roles = append(roles, "signer")
+		writable = index < signers-int(msg.Header.NumReadonlySignedAccounts)
+	default:
+		writable = index < static-int(msg.Header.NumReadonlyUnsignedAccounts)
+	}
+	if writable {
+		roles = append(roles, "writable")
+	} else {
+		roles = append(roles, "readonly")
+	}
+	for _, ix := range msg.Instructions {
+		if int(ix.ProgramIDIndex) == index {
+			roles = append(roles, "program")
+			break
+		}
+	}
+	return roles
+}
+
+// accountAt returns the account at index, or the zero key if it's in an unresolved lookup table.
+func accountAt(keys solanago.PublicKeySlice, index uint16) solanago.PublicKey {
+	if int(index) >= len(keys) {
+		return solanago.PublicKey{}
+	}
+	return keys[index]
+}
+
+// programLabel names program if it's well known.
+func programLabel(program solanago.PublicKey) string {
+	if program.IsZero() {
+		return "(program in unresolved lookup table)"
+	}
+	if name, ok := programNames[program]; ok {
+		return fmt.Sprintf("%s (%s)", name, program)
+	}
+	if program.String() == programIDBase58 {
+		return fmt.Sprintf("token-transfer program (%s)", program)
+	}
+	return program.String()
+}
+
+// describeInstruction decodes the data of instructions of the programs it knows, or else shows it as hex.
+func describeInstruction(program solanago.PublicKey, data []byte) []string {
+	switch {
+	case program.Equals(solanago.TokenProgramID) || program.Equals(solanago.Token2022ProgramID):
+		return describeTokenInstruction(data)
+	case program.Equals(solanago.SPLAssociatedTokenAccountProgramID):
+		if len(data) == 0 || data[0] == 0 {
+			return []string{"Create"}
+		}
+		if data[0] == 1 {
+			return []string{"CreateIdempotent"}
+		}
+	case program.Equals(solanago.ComputeBudget):
+		switch {
+		case len(data) == 5 && data[0] == setComputeUnitLimit:
+			return []string{fmt.Sprintf("SetComputeUnitLimit %d", binary.LittleEndian.Uint32(data[1:]))}
+		case len(data) == 9 && data[0] == setComputeUnitPrice:
+			return []string{fmt.Sprintf("SetComputeUnitPrice %d micro-lamports", binary.LittleEndian.Uint64(data[1:]))}
+		}
+	case program.Equals(solanago.SystemProgramID):
+		// System instructions are tagged with a u32; 2 is Transfer.
+		if len(data) == 12 && binary.LittleEndian.Uint32(data) == 2 {
+			return []string{fmt.Sprintf("Transfer %s SOL", formatUIAmount(binary.LittleEndian.Uint64(data[4:]), 9))}
+		}
+	case program.Equals(solanago.MemoProgramID):
+		if utf8.Valid(data) {
+			return []string{fmt.Sprintf("Memo %q", data)}
+		}
+	}
+	return []string{fmt.Sprintf("data: %x", data)}
+}
+
+// describeTokenInstruction decodes SPL Token instruction data: its name and, for those that move tokens, the
+// amount.
+func describeTokenInstruction(data []byte) []string {
+	if len(data) == 0 {
+		return []string{"data: (empty)"}
+	}
+	name, ok := tokenInstructionNames[data[0]]
+	if !ok {
+		return []string{fmt.Sprintf("token instruction %d, data: %x", data[0], data[1:])}
+	}
+	switch data[0] {
+	case 3, 4, 7, 8:
+		if len(data) == 9 {
+			return []string{name, fmt.Sprintf("amount: %d (raw)", binary.LittleEndian.Uint64(data[1:]))}
+		}
+	case 12, 13, 14, 15:
+		if len(data) == 10 {
+			return []string{name, "amount: " + formatUIAmount(binary.LittleEndian.Uint64(data[1:9]), data[9])}
+		}
+	}
+	if len(data) > 1 {
+		return []string{name, fmt.Sprintf("data: %x", data[1:])}
+	}
+	return []string{name}
+}
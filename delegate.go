@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["approve"] = runApprove
+	commands["revoke"] = runRevoke
+}
+
+func runApprove(ctx context.Context, args []string) error {
+	// "approve <id>" approves a transfer held for a second operator; with flags only it approves a delegate.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return runApproveTransfer(ctx, args[0], args[1:])
+	}
+	fs := newFlagSet("approve")
+	delegateFlag := fs.String("delegate", "", "Base58 public key allowed to spend from the signer's token account (required)")
+	amountFlag := fs.String("amount", "", "Decimal token amount the delegate may spend (required)")
+	unchecked := fs.Bool("unchecked", false, "Use Approve instead of ApproveChecked, skipping the on-chain mint and decimals check")
+	fs.BoolVar(&policyOverride, "override", false, "Approve even though the spending policy can't check what the delegate spends; the override is recorded in the audit log")
+	fs.BoolVar(&assumeYes, "yes", false, "Approve on mainnet without the confirmation prompt")
+	parseFlags(fs, args)
+
+	if *delegateFlag == "" || *amountFlag == "" {
+		return fmt.Errorf("%w: --delegate and --amount flags are required", ErrInvalidArgument)
+	}
+	delegate, err := solanago.PublicKeyFromBase58(*delegateFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --delegate: %v", ErrInvalidArgument, err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	rawAmount, err := parseUIAmount(*amountFlag, mint.Decimals)
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	details := map[string]any{"delegate": delegate.String(), "mint": mintAddress.String(), "amount": formatUIAmount(rawAmount, mint.Decimals)}
+	if err := enforceUncheckedPolicy(policy, "what a delegate spends", details); err != nil {
+		return err
+	}
+	amount := formatUIAmount(rawAmount, mint.Decimals)
+	summary := fmt.Sprintf("Approve:   %s may spend up to %s %s from the signer's token account\n", delegate, amount, tokenLabel(ctx, clients.Read, mintAddress))
+	if err := confirmMainnetOperation(summary, amount); err != nil {
+		return err
+	}
+
+	sig, err := Approve(ctx, clients, signer, mintAddress, mint.Decimals, delegate, rawAmount, !*unchecked)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+func runRevoke(ctx context.Context, args []string) error {
+	fs := newFlagSet("revoke")
+	parseFlags(fs, args)
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, _, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	sig, err := Revoke(ctx, clients, signer, mintAddress)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// Approve lets delegate transfer up to amount raw base units out of the signer's token account for mint. With
+// checked set it uses ApproveChecked, so the token program verifies mint and decimals match.
+func Approve(ctx context.Context, clients Clients, signer Signer, mint solanago.PublicKey, decimals uint8, delegate solanago.PublicKey, amount uint64, checked bool) (solanago.Signature, error) {
+	owner := signer.PublicKey()
+	source, _, err := solanago.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't get ATA for sender %s: %v", owner, err)
+	}
+
+	var instruction solanago.Instruction
+	if checked {
+		instruction = token.NewApproveCheckedInstruction(amount, decimals, source, mint, delegate, owner, nil).Build()
+	} else {
+		instruction = token.NewApproveInstruction(amount, source, delegate, owner, nil).Build()
+	}
+	slog.Info("approving delegate", "delegate", delegate, "amount", formatUIAmount(amount, decimals), "account", source)
+	return sendInstructions(ctx, clients, signer, instruction)
+}
+
+// Revoke removes any delegate from the signer's token account for mint.
+func Revoke(ctx context.Context, clients Clients, signer Signer, mint solanago.PublicKey) (solanago.Signature, error) {
+	owner := signer.PublicKey()
+	source, _, err := solanago.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't get ATA for sender %s: %v", owner, err)
+	}
+	slog.Info("revoking delegate", "account", source)
+	return sendInstructions(ctx, clients, signer, token.NewRevokeInstruction(source, owner, nil).Build())
+}
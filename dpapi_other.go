@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// dpapiSecret is only available on Windows.
+func dpapiSecret(string) ([]byte, error) {
+	return nil, errors.New("the dpapi scheme is only supported on Windows")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procCryptUnprotectData = syscall.NewLazyDLL("crypt32.dll").NewProc("CryptUnprotectData")
+
+// cryptprotectUIForbidden makes CryptUnprotectData fail rather than show a prompt.
+const cryptprotectUIForbidden = 0x1
+
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+// dpapiSecret decrypts the file at path with the Windows Data Protection API, for the current user.
+func dpapiSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty file")
+	}
+	in := dataBlob{size: uint32(len(data)), data: &data[0]}
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(&in)), 0, 0, 0, 0, cryptprotectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, err
+	}
+	defer syscall.LocalFree(syscall.Handle(unsafe.Pointer(out.data)))
+	return bytes.Clone(unsafe.Slice(out.data, out.size)), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+var (
+	minTransfer   string
+	dustThreshold string
+	forceDust     bool
+)
+
+// registerDustFlags registers the flags guarding against tiny transfers and leftover dust.
+func registerDustFlags(fs *flag.FlagSet) {
+	fs.StringVar(&minTransfer, "min-transfer", "", "Refuse transfers of less than this decimal amount (default: the policy's minTransfer for the token)")
+	fs.StringVar(&dustThreshold, "dust-threshold", "", "Refuse transfers leaving the sender with some, but less than this decimal amount of the token (default: the policy's dustThreshold for the token)")
+	fs.BoolVar(&forceDust, "force", false, "Send even if the amount is below --min-transfer or leaves dust behind")
+}
+
+// dustLimits returns the minimum transfer and dust threshold configured for mint, as decimal amounts; empty if
+// not set.
+func (p *Policy) dustLimits(mint solanago.PublicKey) (string, string) {
+	if p == nil {
+		return "", ""
+	}
+	limits := p.tokens[mint]
+	return limits.MinTransfer, limits.DustThreshold
+}
+
+// checkDust refuses, unless --force is set, a transfer of amount raw base units that is below the minimum
+// transfer, or that would leave the sender's token account with a balance above zero but below the dust
+// threshold. The flags take precedence over the policy.
+func checkDust(ctx context.Context, client RPCClient, policy *Policy, mint transferMint, senderAta solanago.PublicKey, amount uint64) error {
+	minimum, dust := policy.dustLimits(mint.Address)
+	if minTransfer != "" {
+		minimum = minTransfer
+	}
+	if dustThreshold != "" {
+		dust = dustThreshold
+	}
+	if minimum == "" && dust == "" {
+		return nil
+	}
+
+	var violation error
+	if minimum != "" {
+		least, err := parseUIAmount(minimum, mint.Decimals)
+		if err != nil {
+			return fmt.Errorf("invalid minimum transfer: %w", err)
+		}
+		if amount < least {
+			violation = fmt.Errorf("%w: %s is below the minimum transfer of %s", ErrPolicyViolation, formatUIAmount(amount, mint.Decimals), minimum)
+		}
+	}
+	if violation == nil && dust != "" {
+		threshold, err := parseUIAmount(dust, mint.Decimals)
+		if err != nil {
+			return fmt.Errorf("invalid dust threshold: %w", err)
+		}
+		account, err := getTokenAccount(ctx, client, senderAta)
+		if err != nil {
+			return err
+		}
+		// Overdrafts are left to the token program to reject.
+		if account != nil && account.Amount > amount {
+			left := account.Amount - amount
+			if left < threshold {
+				violation = fmt.Errorf("%w: sending %s would leave %s behind, below the dust threshold of %s; send all of it (see `sweep`) or less",
+					ErrPolicyViolation, formatUIAmount(amount, mint.Decimals), formatUIAmount(left, mint.Decimals), dust)
+			}
+		}
+	}
+	if violation != nil && forceDust {
+		slog.Warn("sending anyway (--force)", "violation", violation)
+		return nil
+	}
+	if violation != nil {
+		return fmt.Errorf("%w (pass --force to send anyway)", violation)
+	}
+	return nil
+}
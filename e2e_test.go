@@ -0,0 +1,87 @@
+//go:build e2e
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	"github.com/csknk/token-transfer/pkg/localnet"
+)
+
+// TestTransferOnLocalnet sends transfers of an SPL Token and a Token-2022 mint through the send pipeline against a
+// solana-test-validator, creating the receiver's token account, and checks both balances. Run it with
+// `go test -tags e2e -run Localnet`; it is skipped if the validator isn't installed.
+func TestTransferOnLocalnet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	v, err := localnet.Start(ctx, localnet.Options{})
+	if errors.Is(err, localnet.ErrNotInstalled) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("can't start validator: %v", err)
+	}
+	defer v.Stop()
+
+	authority, err := v.NewWallet(ctx, 10*solanago.LAMPORTS_PER_SOL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := Clients{Read: v.Client, Write: v.Client}
+	clients.Sender = newRPCSender(clients)
+
+	const minted, sent = 10_000_000, 1_500_000
+	for name, program := range map[string]solanago.PublicKey{"token": solanago.TokenProgramID, "token-2022": solanago.Token2022ProgramID} {
+		t.Run(name, func(t *testing.T) {
+			mint, err := v.CreateMint(ctx, authority, program, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			senderAccount, err := v.MintTo(ctx, authority, program, mint, authority.PublicKey(), minted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			receiver, err := solanago.NewRandomPrivateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			receiverAccount, err := localnet.TokenAccount(program, mint, receiver.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store, err := OpenStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			record := newTransferRecord("", authority.PublicKey(), receiver.PublicKey(), mint, "1.5")
+			if err := store.PutTransfer(record); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := sendRecorded(ctx, store, clients, authority, record.ID, receiver.PublicKey(), sent, TransferOptions{Mint: mint}); err != nil {
+				t.Fatalf("transfer failed: %v", err)
+			}
+			if record, _ := store.Transfer(record.ID); record.Status != transferConfirmed || record.Received != "1.5" {
+				t.Errorf("transfer recorded %s with %q received, want %s with 1.5", record.Status, record.Received, transferConfirmed)
+			}
+			for account, want := range map[solanago.PublicKey]string{senderAccount: "8500000", receiverAccount: "1500000"} {
+				balance, err := v.Client.GetTokenAccountBalance(ctx, account, rpc.CommitmentConfirmed)
+				if err != nil {
+					t.Fatalf("can't get balance of %s: %v", account, err)
+				}
+				if balance.Value.Amount != want {
+					t.Errorf("balance of %s = %s, want %s", account, balance.Value.Amount, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// envPrefix prefixes the environment variables flags are taken from, e.g. TOKEN_TRANSFER_RPC_READ for
+	// --rpc-read.
+	envPrefix = "TOKEN_TRANSFER_"
+	// envKeypair holds the signer's key, in any format a keypair file accepts, when neither --signer nor --from
+	// is given.
+	envKeypair = envPrefix + "KEYPAIR"
+)
+
+// safetyFlags confirm or override a check that guards against sending funds by mistake. They can't be set from the
+// environment, where they would apply to every run rather than the one the user meant.
+var safetyFlags = map[string]bool{
+	"override":              true,
+	"yes":                   true,
+	"force":                 true,
+	"unchecked":             true,
+	"allow-owner-off-curve": true,
+	"skip-preflight":        true,
+}
+
+// isSafetyFlag reports whether name is a safety flag: one of safetyFlags or an --accept-* flag.
+func isSafetyFlag(name string) bool {
+	return safetyFlags[name] || strings.HasPrefix(name, "accept-")
+}
+
+// envFlags are the flags last set from the environment rather than the command line.
+var envFlags = map[string]bool{}
+
+// envName returns the environment variable the flag name is taken from.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvFlags sets each flag of fs that wasn't given on the command line from its environment variable, if that
+// is set, so a deployment can be configured without arguments. Flags on the command line take precedence. Safety
+// flags have to be given on the command line; setting one in the environment is an error.
+func applyEnvFlags(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if isSafetyFlag(f.Name) {
+			err = fmt.Errorf("%s can't set --%s, pass it on the command line", envName(f.Name), f.Name)
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), setErr)
+			return
+		}
+		envFlags[f.Name] = true
+	})
+	return err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestApplyEnvFlagsRefusesSafetyFlags(t *testing.T) {
+	for _, name := range []string{"yes", "override", "force", "unchecked", "allow-owner-off-curve", "skip-preflight", "accept-issues"} {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			set := fs.Bool(name, false, "")
+			t.Setenv(envName(name), "true")
+			if err := applyEnvFlags(fs); err == nil || *set {
+				t.Errorf("applyEnvFlags = %v with --%s %t, want an error leaving it unset", err, name, *set)
+			}
+		})
+	}
+}
+
+func TestApplyEnvFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	rpcRead, commitment := fs.String("rpc-read", "", ""), fs.String("commitment", "confirmed", "")
+	if err := fs.Parse([]string{"--commitment", "finalized"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { delete(envFlags, "rpc-read") })
+	t.Setenv(envName("rpc-read"), "http://localhost:8899")
+	t.Setenv(envName("commitment"), "processed")
+	if err := applyEnvFlags(fs); err != nil {
+		t.Fatalf("applyEnvFlags: %v", err)
+	}
+	if *rpcRead != "http://localhost:8899" || *commitment != "finalized" {
+		t.Errorf("--rpc-read %q, --commitment %q; want the environment's and the command line's", *rpcRead, *commitment)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// Error classes. Functions wrap these with %w so callers (and the process exit code) can branch on the failure
+// class with errors.Is instead of matching on message text.
+var (
+	ErrInvalidArgument   = errors.New("invalid argument")
+	ErrInvalidRecipient  = errors.New("invalid recipient")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrRPCUnavailable    = errors.New("rpc unavailable")
+	ErrBlockhashExpired  = errors.New("blockhash expired")
+	ErrSimulationFailed  = errors.New("simulation failed")
+	ErrSignerUnavailable = errors.New("signer unavailable")
+	ErrTransactionFailed = errors.New("transaction failed")
+	ErrAborted           = errors.New("aborted")
+	ErrPolicyViolation   = errors.New("policy violation")
+)
+
+// Process exit codes, one per error class. Anything unclassified exits with exitUnknown.
+const (
+	exitOK                = 0
+	exitUnknown           = 1
+	exitInvalidArgument   = 2 // same as the flag package uses for bad flags
+	exitInvalidRecipient  = 3
+	exitInsufficientFunds = 4
+	exitRPCUnavailable    = 5
+	exitBlockhashExpired  = 6
+	exitSimulationFailed  = 7
+	exitSignerUnavailable = 8
+	exitTransactionFailed = 9
+	exitAborted           = 10 // declined at a prompt, or stopped waiting for an operator
+	exitPolicyViolation   = 11
+)
+
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	// Most specific classes first: a failed simulation caused by missing funds exits as insufficient funds.
+	{ErrInvalidArgument, exitInvalidArgument},
+	{ErrInvalidRecipient, exitInvalidRecipient},
+	{ErrInsufficientFunds, exitInsufficientFunds},
+	{ErrBlockhashExpired, exitBlockhashExpired},
+	{ErrSimulationFailed, exitSimulationFailed},
+	{ErrSignerUnavailable, exitSignerUnavailable},
+	{ErrTransactionFailed, exitTransactionFailed},
+	{ErrAborted, exitAborted},
+	{ErrPolicyViolation, exitPolicyViolation},
+	{ErrRPCUnavailable, exitRPCUnavailable},
+}
+
+// exitCode maps err to the process exit code of its error class.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return exitUnknown
+}
+
+// classifiedError carries several error classes at once, e.g. a simulation failure caused by insufficient funds.
+type classifiedError struct {
+	classes []error
+	err     error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() []error {
+	return append([]error{e.err}, e.classes...)
+}
+
+// SPL token program error 1 is InsufficientFunds.
+var tokenInsufficientFunds = regexp.MustCompile(`custom program error: 0x1\b`)
+
+// classifyRPCError wraps an error returned by the RPC client with the matching error classes. Errors that can't
+// be classified are returned unchanged.
+func classifyRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classes []error
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		// -32002 is returned by sendTransaction when preflight simulation fails.
+		if rpcErr.Code == -32002 {
+			classes = append(classes, ErrSimulationFailed)
+			if e, ok := decodeTokenError(preflightLogs(rpcErr.Data)); ok {
+				if e.class != nil {
+					classes = append(classes, e.class)
+				}
+				err = fmt.Errorf("%w: %s", err, e)
+			}
+		}
+		classes = append(classes, messageClasses(rpcErr.Message+" "+fmt.Sprint(rpcErr.Data))...)
+		if rpcErr.Code == 429 || rpcErr.Code == -32005 {
+			classes = append(classes, ErrRPCUnavailable)
+		}
+	} else if isUnavailable(err) {
+		classes = append(classes, ErrRPCUnavailable)
+	}
+
+	if len(classes) == 0 {
+		return err
+	}
+	return &classifiedError{classes: classes, err: err}
+}
+
+// messageClasses returns the error classes recognisable from an RPC error message or transaction logs.
+func messageClasses(msg string) []error {
+	msg = strings.ToLower(msg)
+	switch {
+	case strings.Contains(msg, "blockhash not found"), strings.Contains(msg, "blockhashnotfound"):
+		return []error{ErrBlockhashExpired}
+	case strings.Contains(msg, "insufficient funds"),
+		strings.Contains(msg, "insufficient lamports"),
+		strings.Contains(msg, "no record of a prior credit"),
+		tokenInsufficientFunds.MatchString(msg):
+		return []error{ErrInsufficientFunds}
+	}
+	return nil
+}
+
+// preflightLogs returns the program logs in the data of a failed preflight check.
+func preflightLogs(data any) []string {
+	m, ok := data.(map[string]any)
+	if !ok {
+		return nil
+	}
+	raw, _ := m["logs"].([]any)
+	logs := make([]string, 0, len(raw))
+	for _, line := range raw {
+		if s, ok := line.(string); ok {
+			logs = append(logs, s)
+		}
+	}
+	return logs
+}
+
+func isUnavailable(err error) bool {
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == 429 || httpErr.Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
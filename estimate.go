@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// lamportsPerSignature is the base fee charged per transaction signature.
+	lamportsPerSignature = 5000
+	// tokenAccountSize is the size of an SPL token account, which determines the rent of a new ATA.
+	tokenAccountSize = 165
+	// rpcCallsPerTransfer approximates the HTTP RPC calls needed per transaction (blockhash, ATA lookup, send).
+	rpcCallsPerTransfer = 3
+	// typicalConfirmationTime approximates send-to-confirmation latency of one transaction.
+	typicalConfirmationTime = 2 * time.Second
+	// setComputeUnitLimit is the compute budget program's SetComputeUnitLimit instruction.
+	setComputeUnitLimit = 2
+	// defaultInstructionComputeUnits is the compute unit limit per instruction of a transaction without
+	// SetComputeUnitLimit, up to maxTransactionComputeUnits.
+	defaultInstructionComputeUnits = 200_000
+	maxTransactionComputeUnits     = 1_400_000
+)
+
+// CostBreakdown is what a transfer costs its fee payer, in lamports.
+type CostBreakdown struct {
+	// BaseFee is the fee charged per signature.
+	BaseFee uint64
+	// PriorityFee is the compute unit price times the transaction's compute unit limit. It's charged in full
+	// whether or not the transaction uses all of its compute units.
+	PriorityFee uint64
+	// Tip is what the fee payer sends in system transfers alongside the transfer, such as a Jito tip.
+	Tip uint64
+	// Rent is the rent exemption deposit of the token accounts the transfer creates.
+	Rent uint64
+	// NewAccounts is the number of token accounts the transfer creates.
+	NewAccounts int
+}
+
+// Total returns the sum of the fees, tip and rent.
+func (c CostBreakdown) Total() uint64 {
+	return c.BaseFee + c.PriorityFee + c.Tip + c.Rent
+}
+
+// EstimateTransferCost returns what sending the transfer p describes would cost its fee payer, without building
+// or signing it. p is usually the result of PrepareTransfer; client is only queried for the rent exemption
+// minimum, if the transfer creates the receiver's token account.
+func EstimateTransferCost(ctx context.Context, client RPCClient, p BuildParams) (CostBreakdown, error) {
+	payer := p.Options.payer(p.Sender)
+	leg, err := transferLegInstructions(p, payer)
+	if err != nil {
+		return CostBreakdown{}, err
+	}
+	cost, err := transactionFees(payer, p.Options, leg)
+	if err != nil {
+		return CostBreakdown{}, err
+	}
+	if !p.ReceiverAccountExists {
+		rent, err := client.GetMinimumBalanceForRentExemption(ctx, p.Mint.accountSize(), rpc.CommitmentFinalized)
+		if err != nil {
+			return CostBreakdown{}, fmt.Errorf("can't get rent exemption minimum: %w", classifyRPCError(err))
+		}
+		cost.Rent, cost.NewAccounts = rent, 1
+	}
+	return cost, nil
+}
+
+// transactionFees returns the fees and tip of a transaction paid by payer holding instructions between
+// opts.PreInstructions and opts.PostInstructions.
+func transactionFees(payer solanago.PublicKey, opts TransferOptions, instructions []solanago.Instruction) (CostBreakdown, error) {
+	instructions = append(append(append([]solanago.Instruction{}, opts.PreInstructions...), instructions...), opts.PostInstructions...)
+
+	signers := map[solanago.PublicKey]bool{payer: true}
+	var cost CostBreakdown
+	var price, limit uint64
+	var limitSet bool
+	var programInstructions uint64
+	for _, instruction := range instructions {
+		for _, account := range instruction.Accounts() {
+			if account.IsSigner {
+				signers[account.PublicKey] = true
+			}
+		}
+		switch program := instruction.ProgramID(); {
+		case program.Equals(solanago.ComputeBudget):
+			data, err := instruction.Data()
+			if err != nil {
+				return CostBreakdown{}, fmt.Errorf("can't read compute budget instruction: %v", err)
+			}
+			switch {
+			case len(data) == 9 && data[0] == setComputeUnitPrice:
+				price = binary.LittleEndian.Uint64(data[1:])
+			case len(data) == 5 && data[0] == setComputeUnitLimit:
+				limit, limitSet = uint64(binary.LittleEndian.Uint32(data[1:])), true
+			}
+		case program.Equals(solanago.SystemProgramID):
+			programInstructions++
+			lamports, err := transferredFrom(payer, instruction)
+			if err != nil {
+				return CostBreakdown{}, err
+			}
+			cost.Tip += lamports
+		default:
+			programInstructions++
+		}
+	}
+	if !limitSet {
+		limit = min(programInstructions*defaultInstructionComputeUnits, maxTransactionComputeUnits)
+	}
+
+	cost.BaseFee = uint64(len(signers)) * lamportsPerSignature
+	// The price is in micro-lamports per compute unit; the validator rounds the fee up.
+	cost.PriorityFee = (price*limit + 999_999) / 1_000_000
+	return cost, nil
+}
+
+// transferredFrom returns the lamports a system program instruction transfers out of payer's account.
+func transferredFrom(payer solanago.PublicKey, instruction solanago.Instruction) (uint64, error) {
+	data, err := instruction.Data()
+	if err != nil {
+		return 0, fmt.Errorf("can't read system instruction: %v", err)
+	}
+	// System instructions are tagged with a u32; 2 is Transfer, whose first account is the sender.
+	accounts := instruction.Accounts()
+	if len(data) != 12 || binary.LittleEndian.Uint32(data) != 2 || len(accounts) == 0 || !accounts[0].PublicKey.Equals(payer) {
+		return 0, nil
+	}
+	return binary.LittleEndian.Uint64(data[4:]), nil
+}
+
+// EstimateParams describes how a batch will be executed.
+type EstimateParams struct {
+	// Concurrency is the number of transactions in flight at once; values below 1 mean 1.
+	Concurrency int
+	// RPS caps RPC requests per second; 0 means unlimited.
+	RPS float64
+	// RecipientsPerTransaction is how many transfers are packed into one transaction; values below 1 mean 1.
+	RecipientsPerTransaction int
+}
+
+// BatchEstimate is the projected cost and duration of a batch run.
+type BatchEstimate struct {
+	Recipients   int
+	Transactions int
+	TotalAmount  uint64 // raw base units
+	// NewAccounts is the number of recipients without a token account, which the sender pays rent for.
+	NewAccounts int
+	// FeeLamports are the base and priority fees of every transaction.
+	FeeLamports uint64
+	// TipLamports are the tips paid with every transaction, e.g. to Jito.
+	TipLamports  uint64
+	RentLamports uint64
+	Duration     time.Duration
+}
+
+// EstimateBatch projects the fees, rent, transaction count and wall-clock duration of sender sending rows of
+// mint with opts, as transactionFees prices each transaction.
+func EstimateBatch(ctx context.Context, client RPCClient, sender, mint solanago.PublicKey, rows []batchRow, opts TransferOptions, params EstimateParams) (BatchEstimate, error) {
+	concurrency := max(params.Concurrency, 1)
+	perTx := max(params.RecipientsPerTransaction, 1)
+
+	estimate := BatchEstimate{
+		Recipients:   len(rows),
+		Transactions: (len(rows) + perTx - 1) / perTx,
+	}
+	for _, row := range rows {
+		estimate.TotalAmount += row.RawAmount
+	}
+
+	missing, err := missingATAs(ctx, client, mint, rows)
+	if err != nil {
+		return BatchEstimate{}, err
+	}
+	estimate.NewAccounts = len(missing)
+	if len(missing) > 0 {
+		rent, err := client.GetMinimumBalanceForRentExemption(ctx, tokenAccountSize, rpc.CommitmentFinalized)
+		if err != nil {
+			return BatchEstimate{}, fmt.Errorf("can't get rent exemption minimum: %w", classifyRPCError(err))
+		}
+		estimate.RentLamports = uint64(len(missing)) * rent
+	}
+
+	// Price each pack as it will be sent; the first pack paying a receiver creates its account.
+	payer := opts.payer(sender)
+	uncreated := maps.Clone(missing)
+	for start := 0; start < len(rows); start += perTx {
+		pack := make([]queuedTransfer, 0, perTx)
+		for _, row := range rows[start:min(start+perTx, len(rows))] {
+			pack = append(pack, queuedTransfer{batchRow: row})
+		}
+		instructions, err := batchTransferInstructions(sender, payer, mint, pack, uncreated)
+		if err != nil {
+			return BatchEstimate{}, err
+		}
+		cost, err := transactionFees(payer, opts, instructions)
+		if err != nil {
+			return BatchEstimate{}, err
+		}
+		estimate.FeeLamports += cost.BaseFee + cost.PriorityFee
+		estimate.TipLamports += cost.Tip
+		for _, t := range pack {
+			ata, _, _ := solanago.FindAssociatedTokenAddress(t.Receiver, mint)
+			delete(uncreated, ata)
+		}
+	}
+
+	// Limited by whichever is slower: confirmations in flight, or the RPC request budget.
+	waves := math.Ceil(float64(estimate.Transactions) / float64(concurrency))
+	estimate.Duration = time.Duration(waves) * typicalConfirmationTime
+	if params.RPS > 0 {
+		byRPS := time.Duration(float64(estimate.Transactions*rpcCallsPerTransfer) / params.RPS * float64(time.Second))
+		estimate.Duration = max(estimate.Duration, byRPS)
+	}
+	return estimate, nil
+}
+
+// missingATAs returns the associated token accounts for mint of the recipients in rows that don't exist yet.
+func missingATAs(ctx context.Context, client RPCClient, mint solanago.PublicKey, rows []batchRow) (map[solanago.PublicKey]bool, error) {
+	// Recipients may repeat; each ATA is only created once.
+	seen := map[solanago.PublicKey]bool{}
+	var atas []solanago.PublicKey
+	for _, row := range rows {
+		ata, _, err := solanago.FindAssociatedTokenAddress(row.Receiver, mint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, row.Receiver, err)
+		}
+		if !seen[ata] {
+			seen[ata] = true
+			atas = append(atas, ata)
+		}
+	}
+
+	missing := map[solanago.PublicKey]bool{}
+	// getMultipleAccounts accepts at most 100 accounts per request.
+	for start := 0; start < len(atas); start += 100 {
+		chunk := atas[start:min(start+100, len(atas))]
+		res, err := client.GetMultipleAccounts(ctx, chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("can't look up token accounts: %w", classifyRPCError(err))
+		}
+		for i, account := range res.Value {
+			if account == nil {
+				missing[chunk[i]] = true
+			}
+		}
+	}
+	return missing, nil
+}
+
+// Print writes a human readable summary of the estimate.
+func (e BatchEstimate) Print(w io.Writer, decimals uint8, symbol string) {
+	fmt.Fprintf(w, "Recipients:          %d\n", e.Recipients)
+	fmt.Fprintf(w, "Total amount:        %s %s\n", formatUIAmount(e.TotalAmount, decimals), symbol)
+	fmt.Fprintf(w, "Transactions:        %d\n", e.Transactions)
+	fmt.Fprintf(w, "Estimated fees:      %s SOL\n", formatUIAmount(e.FeeLamports, 9))
+	if e.TipLamports > 0 {
+		fmt.Fprintf(w, "Tips:                %s SOL\n", formatUIAmount(e.TipLamports, 9))
+	}
+	fmt.Fprintf(w, "Accounts to create:  %d (rent %s SOL)\n", e.NewAccounts, formatUIAmount(e.RentLamports, 9))
+	fmt.Fprintf(w, "Total SOL cost:      %s SOL\n", formatUIAmount(e.FeeLamports+e.TipLamports+e.RentLamports, 9))
+	fmt.Fprintf(w, "Estimated duration:  %s\n", e.Duration.Round(time.Second))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+
+	"github.com/csknk/token-transfer/pkg/rpcfake"
+)
+
+func TestEstimateBatchPricesEachTransaction(t *testing.T) {
+	sender, feePayer, mint := testKey(1), testKey(2), testKey(3).PublicKey()
+	rows := []batchRow{
+		{Line: 1, Receiver: testKey(4).PublicKey(), RawAmount: 1},
+		{Line: 2, Receiver: testKey(5).PublicKey(), RawAmount: 2},
+		{Line: 3, Receiver: testKey(6).PublicKey(), RawAmount: 3},
+	}
+	defer func(saved *uint64) { priorityFeeFlag = saved }(priorityFeeFlag)
+	price := uint64(1000)
+	priorityFeeFlag = &price
+	opts := TransferOptions{
+		Mint:             mint,
+		FeePayer:         feePayer,
+		PreInstructions:  priorityFeeInstructions(),
+		PostInstructions: []solanago.Instruction{system.NewTransferInstruction(10_000, feePayer.PublicKey(), testKey(7).PublicKey()).Build()},
+	}
+
+	// No receiver has a token account yet.
+	estimate, err := EstimateBatch(context.Background(), rpcfake.New(), sender.PublicKey(), mint, rows, opts, EstimateParams{RecipientsPerTransaction: 2})
+	if err != nil {
+		t.Fatalf("EstimateBatch: %v", err)
+	}
+	if estimate.Transactions != 2 || estimate.NewAccounts != 3 {
+		t.Errorf("estimated %d transactions creating %d accounts, want 2 creating 3", estimate.Transactions, estimate.NewAccounts)
+	}
+	// Two signatures per transaction, and 1000 micro-lamports for 200,000 compute units per instruction: five
+	// (two creates, two transfers, the tip) in the first transaction and three in the second.
+	if want := uint64(2*2*lamportsPerSignature + 1000 + 600); estimate.FeeLamports != want {
+		t.Errorf("fees = %d, want %d", estimate.FeeLamports, want)
+	}
+	if estimate.TipLamports != 20_000 {
+		t.Errorf("tips = %d, want 20000", estimate.TipLamports)
+	}
+}
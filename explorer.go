@@ -0,0 +1,67 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// explorers maps the --explorer names to their base URLs.
+var explorers = map[string]string{
+	"solana":  "https://explorer.solana.com",
+	"solscan": "https://solscan.io",
+	"xray":    "https://xray.helius.xyz",
+}
+
+// explorerName is the block explorer links point to.
+var explorerName = "solana"
+
+func setExplorer(name string) error {
+	if explorers[name] == "" {
+		return fmt.Errorf("unknown explorer %q, use solana, solscan or xray", name)
+	}
+	explorerName = name
+	return nil
+}
+
+// explorerTxURL returns the explorer page of a transaction on the --network cluster, or "" if the explorer doesn't
+// show that cluster.
+func explorerTxURL(sig string) string {
+	return explorerURL("tx", sig)
+}
+
+// explorerAccountURL returns the explorer page of an account on the --network cluster, or "" if the explorer
+// doesn't show that cluster.
+func explorerAccountURL(address string) string {
+	return explorerURL("account", address)
+}
+
+func explorerURL(kind, value string) string {
+	profile, err := currentCluster()
+	if err != nil {
+		return ""
+	}
+	if profile.Explorer != "" {
+		return strings.TrimSuffix(profile.Explorer, "/") + "/" + kind + "/" + value
+	}
+	query := url.Values{}
+	switch cluster := profile.Cluster; {
+	case cluster == "mainnet":
+	case explorerName == "solana" && cluster == "localnet":
+		// Solana Explorer reads a local validator through the browser.
+		query.Set("cluster", "custom")
+		query.Set("customUrl", cmp.Or(rpcReadEndpoint, profile.RPC))
+	case explorerName == "xray" && cluster == "devnet":
+		query.Set("network", cluster)
+	case explorerName != "xray" && (cluster == "devnet" || cluster == "testnet"):
+		query.Set("cluster", cluster)
+	default:
+		return ""
+	}
+	link := explorers[explorerName] + "/" + kind + "/" + value
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// exportColumns are the columns export can write, in their default order.
+var exportColumns = []string{"time", "source", "id", "status", "signature", "slot", "sender", "receiver", "mint", "amount", "value", "received", "error", "explorer"}
+
+// exportPageSize is how many signatures are requested per getSignaturesForAddress call when exporting on-chain
+// history.
+const exportPageSize = 1000
+
+func init() {
+	commands["export"] = runExport
+}
+
+// exportRow is one transfer in an export, from the local journal or the chain.
+type exportRow struct {
+	Time      time.Time
+	Source    string
+	ID        string
+	Status    string
+	Signature string
+	Slot      uint64
+	Sender    string
+	Receiver  string
+	Mint      string
+	Amount    string
+	Value     string
+	Received  string
+	Error     string
+	Explorer  string
+}
+
+// field returns the value of column.
+func (r exportRow) field(column string) string {
+	switch column {
+	case "time":
+		return r.Time.UTC().Format(time.RFC3339)
+	case "source":
+		return r.Source
+	case "id":
+		return r.ID
+	case "status":
+		return r.Status
+	case "signature":
+		return r.Signature
+	case "slot":
+		if r.Slot == 0 {
+			return ""
+		}
+		return strconv.FormatUint(r.Slot, 10)
+	case "sender":
+		return r.Sender
+	case "receiver":
+		return r.Receiver
+	case "mint":
+		return r.Mint
+	case "amount":
+		return r.Amount
+	case "value":
+		return r.Value
+	case "received":
+		return r.Received
+	case "error":
+		return r.Error
+	case "explorer":
+		return r.Explorer
+	}
+	return ""
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("export")
+	source := fs.String("source", "journal", "What to export: journal (local transfer records)|chain (the signer's on-chain history of --token)|all")
+	format := fs.String("format", "csv", "Output format: csv|parquet")
+	columnsFlag := fs.String("columns", strings.Join(exportColumns, ","), "Comma-separated columns to export")
+	sinceFlag := fs.String("since", "", "Only export transfers at or after this date (YYYY-MM-DD or RFC 3339)")
+	untilFlag := fs.String("until", "", "Only export transfers before this date (YYYY-MM-DD or RFC 3339)")
+	out := fs.String("out", "", "Write the export to this file instead of stdout")
+	parseFlags(fs, args)
+
+	if *source != "journal" && *source != "chain" && *source != "all" {
+		return fmt.Errorf("%w: invalid --source %q, use journal, chain or all", ErrInvalidArgument, *source)
+	}
+	if *format != "csv" && *format != "parquet" {
+		return fmt.Errorf("%w: invalid --format %q, use csv or parquet", ErrInvalidArgument, *format)
+	}
+	if *format == "parquet" && *out == "" {
+		return fmt.Errorf("%w: --format parquet needs --out", ErrInvalidArgument)
+	}
+	var columns []string
+	for _, column := range strings.Split(*columnsFlag, ",") {
+		column = strings.TrimSpace(column)
+		if !slices.Contains(exportColumns, column) {
+			return fmt.Errorf("%w: unknown column %q, use any of %s", ErrInvalidArgument, column, strings.Join(exportColumns, ","))
+		}
+		columns = append(columns, column)
+	}
+	since, err := parseExportTime("--since", *sinceFlag)
+	if err != nil {
+		return err
+	}
+	until, err := parseExportTime("--until", *untilFlag)
+	if err != nil {
+		return err
+	}
+
+	var rows []exportRow
+	if *source != "chain" {
+		store, err := OpenStore(dataDir)
+		if err != nil {
+			return fmt.Errorf("can't open store: %w", err)
+		}
+		rows = journalRows(store, since, until)
+		store.Close()
+	}
+	if *source != "journal" {
+		chain, err := chainRows(ctx, since, until)
+		if err != nil {
+			return err
+		}
+		// Transfers sent by this tool are in both; keep the journal's record with the chain's slot.
+		bySignature := map[string]*exportRow{}
+		for i := range rows {
+			if rows[i].Signature != "" {
+				bySignature[rows[i].Signature] = &rows[i]
+			}
+		}
+		for _, row := range chain {
+			if journaled := bySignature[row.Signature]; journaled != nil {
+				journaled.Slot = row.Slot
+				continue
+			}
+			rows = append(rows, row)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b exportRow) int { return a.Time.Compare(b.Time) })
+
+	table := make([][]string, len(rows))
+	for i, row := range rows {
+		for _, column := range columns {
+			table[i] = append(table[i], row.field(column))
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("can't create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "parquet" {
+		err = writeParquet(w, columns, table)
+	} else {
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		cw.WriteAll(table)
+		err = cw.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("can't write export: %v", err)
+	}
+	if *out != "" {
+		slog.Info("exported transfers", "rows", len(rows), "path", *out)
+	}
+	return nil
+}
+
+// parseExportTime parses a date filter. The zero time means no filter.
+func parseExportTime(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid %s %q, use YYYY-MM-DD or RFC 3339", ErrInvalidArgument, flag, value)
+	}
+	return t, nil
+}
+
+// inRange reports whether t is within [since, until), where zero bounds are open.
+func inRange(t, since, until time.Time) bool {
+	return !t.Before(since) && (until.IsZero() || t.Before(until))
+}
+
+// journalRows returns the transfer records in store created within the date range.
+func journalRows(store *Store, since, until time.Time) []exportRow {
+	var rows []exportRow
+	for _, record := range store.TransfersSince(since) {
+		if !inRange(record.CreatedAt, since, until) {
+			continue
+		}
+		rows = append(rows, exportRow{
+			Time:      record.CreatedAt,
+			Source:    "journal",
+			ID:        record.ID,
+			Status:    record.Status,
+			Signature: record.Signature,
+			Sender:    record.Sender,
+			Receiver:  record.Receiver,
+			Mint:      record.Mint,
+			Amount:    record.Amount,
+			Value:     record.Value,
+			Received:  record.Received,
+			Error:     record.Error,
+			Explorer:  record.Explorer,
+		})
+	}
+	return rows
+}
+
+// chainRows returns the transfers of the --token mint in or out of the signer's token account within the date
+// range, newest first, by paging through the account's signatures until they're older than since.
+func chainRows(ctx context.Context, since, until time.Time) ([]exportRow, error) {
+	signer, err := loadSigner()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	mintAddress, _, err := resolveMint(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	tm, err := loadTransferMint(ctx, client, mintAddress)
+	if err != nil {
+		return nil, err
+	}
+	account, err := tm.tokenAccount(signer.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []exportRow
+	var before solanago.Signature
+	for {
+		limit := exportPageSize
+		opts := &rpc.GetSignaturesForAddressOpts{Limit: &limit, Before: before, Commitment: rpc.CommitmentConfirmed}
+		sigs, err := client.GetSignaturesForAddressWithOpts(ctx, account, opts)
+		if errors.Is(err, rpc.ErrNotFound) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't get history of %s: %w", account, classifyRPCError(err))
+		}
+		for _, sig := range sigs {
+			var t time.Time
+			if sig.BlockTime != nil {
+				t = sig.BlockTime.Time()
+			}
+			if t.Before(since) {
+				return rows, nil
+			}
+			if !inRange(t, since, until) {
+				continue
+			}
+			row, ok, err := chainRow(ctx, client, sig.Signature, signer.PublicKey(), tm)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				row.Time = t
+				rows = append(rows, row)
+			}
+		}
+		if len(sigs) < exportPageSize {
+			return rows, nil
+		}
+		before = sigs[len(sigs)-1].Signature
+	}
+}
+
+// chainRow describes transaction sig as a transfer of mint to or from owner. ok is false if owner's balance didn't
+// change, e.g. for a transaction that only touched the account's authority.
+func chainRow(ctx context.Context, client RPCClient, sig solanago.Signature, owner solanago.PublicKey, mint transferMint) (exportRow, bool, error) {
+	maxVersion := uint64(0)
+	tx, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return exportRow{}, false, fmt.Errorf("can't get transaction %s: %w", sig, classifyRPCError(err))
+	}
+	if tx == nil || tx.Meta == nil {
+		return exportRow{}, false, nil
+	}
+	row := exportRow{
+		Source:    "chain",
+		Status:    transferConfirmed,
+		Signature: sig.String(),
+		Slot:      tx.Slot,
+		Mint:      mint.Address.String(),
+		Explorer:  explorerTxURL(sig.String()),
+	}
+	if tx.Meta.Err != nil {
+		row.Status, row.Error = transferFailed, fmt.Sprint(tx.Meta.Err)
+	}
+
+	// Every owner's change in balance; the counterparty is whoever moved the most the other way.
+	changes := map[solanago.PublicKey]int64{}
+	for _, balances := range [][]rpc.TokenBalance{tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances} {
+		for _, balance := range balances {
+			if balance.Owner != nil && balance.Mint.Equals(mint.Address) {
+				pre := ownerTokenBalance(tx.Meta.PreTokenBalances, *balance.Owner, mint.Address)
+				post := ownerTokenBalance(tx.Meta.PostTokenBalances, *balance.Owner, mint.Address)
+				changes[*balance.Owner] = int64(post) - int64(pre)
+			}
+		}
+	}
+	change := changes[owner]
+	if change == 0 && tx.Meta.Err == nil {
+		return exportRow{}, false, nil
+	}
+	var counterparty solanago.PublicKey
+	var largest int64
+	for key, c := range changes {
+		if !key.Equals(owner) && (c > 0) != (change > 0) && abs(c) > largest {
+			counterparty, largest = key, abs(c)
+		}
+	}
+	row.Sender, row.Receiver = owner.String(), counterparty.String()
+	if change > 0 {
+		row.Sender, row.Receiver = counterparty.String(), owner.String()
+	}
+	if counterparty.IsZero() {
+		// Minted, burned, or a failed transaction that moved nothing.
+		if change > 0 {
+			row.Sender = ""
+		} else {
+			row.Receiver = ""
+		}
+	}
+	row.Amount = formatUIAmount(uint64(abs(change)), mint.Decimals)
+	return row, true, nil
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
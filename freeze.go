@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+// freezesPerTransaction is how many token accounts one freeze or thaw transaction handles.
+const freezesPerTransaction = 10
+
+func init() {
+	commands["freeze"] = func(ctx context.Context, args []string) error { return runFreeze(ctx, args, true) }
+	commands["thaw"] = func(ctx context.Context, args []string) error { return runFreeze(ctx, args, false) }
+}
+
+// runFreeze freezes token accounts of the selected token, or thaws them if freeze isn't set. The signer must be
+// the mint's freeze authority.
+func runFreeze(ctx context.Context, args []string, freeze bool) error {
+	name, done, state := "thaw", "thawed", token.Initialized
+	if freeze {
+		name, done, state = "freeze", "frozen", token.Frozen
+	}
+	fs := newFlagSet(name)
+	var owners, accounts publicKeyList
+	fs.Var(&owners, "owner", "Wallet whose associated token account to "+name+"; repeat for several")
+	fs.Var(&accounts, "account", "Token account to "+name+"; repeat for several")
+	reason := fs.String("reason", "", "Memo recorded with the transaction, e.g. a case reference")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate without sending")
+	parseFlags(fs, args)
+
+	if len(owners) == 0 && len(accounts) == 0 {
+		return fmt.Errorf("%w: --owner or --account is required", ErrInvalidArgument)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, _, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+	if err != nil {
+		return err
+	}
+	switch {
+	case tm.FreezeAuthority.IsZero():
+		return fmt.Errorf("%w: mint %s has no freeze authority", ErrInvalidArgument, mintAddress)
+	case !tm.FreezeAuthority.Equals(signer.PublicKey()):
+		return fmt.Errorf("%w: the freeze authority of mint %s is %s, not the signer", ErrSignerUnavailable, mintAddress, tm.FreezeAuthority)
+	}
+	for _, owner := range owners {
+		account, err := tm.tokenAccount(owner)
+		if err != nil {
+			return fmt.Errorf("can't get ATA for owner %s: %v", owner, err)
+		}
+		if !slices.Contains(accounts, account) {
+			accounts = append(accounts, account)
+		}
+	}
+	targets, err := freezeTargets(ctx, clients.Read, mintAddress, accounts, state, done)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		slog.Info("nothing to " + name)
+		return nil
+	}
+
+	for chunk := range slices.Chunk(targets, freezesPerTransaction) {
+		var instructions []solanago.Instruction
+		if *reason != "" {
+			instructions = append(instructions, memoInstruction(*reason))
+		}
+		for _, account := range chunk {
+			instruction, err := freezeInstruction(tm, account, signer.PublicKey(), freeze)
+			if err != nil {
+				return err
+			}
+			instructions = append(instructions, instruction)
+		}
+		instructions = append(priorityFeeInstructions(), instructions...)
+		if *dryRunFlag {
+			if err := simulateInstructions(ctx, clients, signer, instructions...); err != nil {
+				return err
+			}
+			continue
+		}
+		sig, err := sendInstructions(ctx, clients, signer, instructions...)
+		if err != nil {
+			return fmt.Errorf("can't %s token accounts: %w", name, err)
+		}
+		slog.Info(done+" token accounts", "mint", mintAddress, "accounts", len(chunk), "signature", sig)
+		fmt.Println(sig)
+	}
+	return nil
+}
+
+// freezeTargets returns the accounts, token accounts of mint, that aren't in state yet. Accounts already in it,
+// described as done, are logged and skipped; a missing account or one of another mint is an error.
+func freezeTargets(ctx context.Context, client RPCClient, mint solanago.PublicKey, accounts []solanago.PublicKey, state token.AccountState, done string) ([]solanago.PublicKey, error) {
+	var targets []solanago.PublicKey
+	for start := 0; start < len(accounts); start += 100 {
+		chunk := accounts[start:min(start+100, len(accounts))]
+		res, err := client.GetMultipleAccounts(ctx, chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("can't get token accounts: %w", classifyRPCError(err))
+		}
+		for i, info := range res.Value {
+			if info == nil {
+				return nil, fmt.Errorf("%w: token account %s doesn't exist", ErrInvalidArgument, chunk[i])
+			}
+			var account token.Account
+			if err := bin.NewBorshDecoder(info.Data.GetBinary()).Decode(&account); err != nil {
+				return nil, fmt.Errorf("can't decode token account %s: %v", chunk[i], err)
+			}
+			if !account.Mint.Equals(mint) {
+				return nil, fmt.Errorf("%w: %s is a token account of mint %s, not %s", ErrInvalidArgument, chunk[i], account.Mint, mint)
+			}
+			if account.State == state {
+				slog.Info("token account already "+done, "account", chunk[i], "owner", account.Owner)
+				continue
+			}
+			targets = append(targets, chunk[i])
+		}
+	}
+	return targets, nil
+}
+
+// freezeInstruction freezes account, a token account of the mint, with authority, or thaws it if freeze isn't set.
+func freezeInstruction(tm transferMint, account, authority solanago.PublicKey, freeze bool) (solanago.Instruction, error) {
+	instruction := token.NewThawAccountInstruction(account, tm.Address, authority, nil).Build()
+	if freeze {
+		instruction = token.NewFreezeAccountInstruction(account, tm.Address, authority, nil).Build()
+	}
+	if tm.isToken2022() {
+		return token2022Instruction(instruction)
+	}
+	return instruction, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["gc"] = runGC
+}
+
+// gcCandidate is a token account gc can close. Dust balances are burned first.
+type gcCandidate struct {
+	ownedTokenAccount
+	Decimals uint8
+}
+
+func runGC(ctx context.Context, args []string) error {
+	fs := newFlagSet("gc")
+	dust := fs.String("dust", "", "Also collect accounts holding at most this many tokens (decimal, per mint); their balance is burned")
+	closeFlag := fs.Bool("close", false, "Close the collectable accounts after confirmation, instead of only reporting them")
+	yes := fs.Bool("yes", false, "Don't ask for confirmation before closing")
+	parseFlags(fs, args)
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	owned, err := listTokenAccounts(ctx, clients.Read, signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	candidates, err := gcCandidates(ctx, clients.Read, signer.PublicKey(), owned, *dust)
+	if err != nil {
+		return err
+	}
+	rent := printGCReport(owned, candidates)
+
+	if !*closeFlag || len(candidates) == 0 {
+		return nil
+	}
+	if !*yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%w: pass --yes to close accounts non-interactively", ErrAborted)
+		}
+		ok, err := promptYesNo(fmt.Sprintf("Close %d token accounts and reclaim %s SOL?", len(candidates), formatUIAmount(rent, solDecimals)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: no accounts closed", ErrAborted)
+		}
+	}
+
+	// Dust accounts take two instructions (burn and close), so pack by instruction count.
+	var instructions []solanago.Instruction
+	flush := func() error {
+		if len(instructions) == 0 {
+			return nil
+		}
+		sig, err := sendInstructions(ctx, clients, signer, instructions...)
+		if err != nil {
+			return err
+		}
+		fmt.Println(sig)
+		instructions = nil
+		return nil
+	}
+	for _, c := range candidates {
+		next := gcInstructions(signer.PublicKey(), c)
+		if len(instructions)+len(next) > closesPerTransaction {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		instructions = append(instructions, next...)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	slog.Info("garbage collected token accounts", "count", len(candidates), "reclaimed", formatUIAmount(rent, solDecimals))
+	return nil
+}
+
+// gcCandidates returns the accounts in owned that owner can close: empty ones, plus those holding at most dust
+// tokens if dust is set.
+func gcCandidates(ctx context.Context, client RPCClient, owner solanago.PublicKey, owned []ownedTokenAccount, dust string) ([]gcCandidate, error) {
+	var decimals map[solanago.PublicKey]uint8
+	if dust != "" {
+		var err error
+		if decimals, err = mintDecimals(ctx, client, owned); err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []gcCandidate
+	for _, ta := range owned {
+		if !canClose(ta.Account, owner) {
+			continue
+		}
+		c := gcCandidate{ownedTokenAccount: ta, Decimals: decimals[ta.Account.Mint]}
+		switch {
+		case ta.Account.Amount == 0:
+		case dust == "" || ta.Account.IsNative != nil:
+			// Never burn wrapped SOL: unwrap it instead.
+			continue
+		default:
+			threshold, err := parseUIAmount(dust, c.Decimals)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --dust: %w", err)
+			}
+			if ta.Account.Amount > threshold {
+				continue
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// mintDecimals fetches the decimals of every mint in accounts.
+func mintDecimals(ctx context.Context, client RPCClient, accounts []ownedTokenAccount) (map[solanago.PublicKey]uint8, error) {
+	decimals := map[solanago.PublicKey]uint8{}
+	var mints []solanago.PublicKey
+	for _, ta := range accounts {
+		if _, ok := decimals[ta.Account.Mint]; !ok {
+			decimals[ta.Account.Mint] = 0
+			mints = append(mints, ta.Account.Mint)
+		}
+	}
+	for start := 0; start < len(mints); start += 100 {
+		chunk := mints[start:min(start+100, len(mints))]
+		res, err := client.GetMultipleAccounts(ctx, chunk...)
+		if err != nil {
+			return nil, fmt.Errorf("can't get mints: %w", classifyRPCError(err))
+		}
+		for i, account := range res.Value {
+			if account == nil {
+				continue
+			}
+			var mint token.Mint
+			if err := bin.NewBorshDecoder(account.Data.GetBinary()).Decode(&mint); err != nil {
+				return nil, fmt.Errorf("can't decode mint %s: %v", chunk[i], err)
+			}
+			decimals[chunk[i]] = mint.Decimals
+		}
+	}
+	return decimals, nil
+}
+
+// gcInstructions returns the instructions closing c, burning its dust balance first.
+func gcInstructions(owner solanago.PublicKey, c gcCandidate) []solanago.Instruction {
+	var instructions []solanago.Instruction
+	if c.Account.Amount > 0 {
+		instructions = append(instructions, token.NewBurnCheckedInstruction(c.Account.Amount, c.Decimals, c.Address, c.Account.Mint, owner, nil).Build())
+	}
+	return append(instructions, token.NewCloseAccountInstruction(c.Address, owner, owner, nil).Build())
+}
+
+// printGCReport lists every owned token account and whether gc collects it, and returns the reclaimable rent.
+func printGCReport(owned []ownedTokenAccount, candidates []gcCandidate) uint64 {
+	collect := map[solanago.PublicKey]bool{}
+	var rent uint64
+	for _, c := range candidates {
+		collect[c.Address] = true
+		rent += c.Lamports
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tMINT\tBALANCE (RAW)\tRENT (SOL)\tSTATUS")
+	for _, ta := range owned {
+		status := "keep"
+		switch {
+		case collect[ta.Address] && ta.Account.Amount == 0:
+			status = "empty"
+		case collect[ta.Address]:
+			status = "dust"
+		case ta.Account.State == token.Frozen:
+			status = "frozen"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", ta.Address, ta.Account.Mint, ta.Account.Amount, formatUIAmount(ta.Lamports, solDecimals), status)
+	}
+	w.Flush()
+	slog.Info("token accounts scanned", "total", len(owned), "collectable", len(candidates), "reclaimable", formatUIAmount(rent, solDecimals))
+	return rent
+}
@@ -0,0 +1,212 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tokentransfer/v1/transfer.proto
+
+// Programmatic access to the token-transfer daemon. Mirrors the HTTP API served by `token-transfer serve`:
+// transfers are accepted asynchronously and identified by an opaque id.
+
+package tokentransferv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TransferService_SubmitTransfer_FullMethodName      = "/tokentransfer.v1.TransferService/SubmitTransfer"
+	TransferService_GetTransferStatus_FullMethodName   = "/tokentransfer.v1.TransferService/GetTransferStatus"
+	TransferService_StreamConfirmations_FullMethodName = "/tokentransfer.v1.TransferService/StreamConfirmations"
+)
+
+// TransferServiceClient is the client API for TransferService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TransferServiceClient interface {
+	// SubmitTransfer queues a transfer signed by the daemon's key and returns immediately.
+	SubmitTransfer(ctx context.Context, in *SubmitTransferRequest, opts ...grpc.CallOption) (*SubmitTransferResponse, error)
+	// GetTransferStatus returns the current state of a previously submitted transfer.
+	GetTransferStatus(ctx context.Context, in *GetTransferStatusRequest, opts ...grpc.CallOption) (*Transfer, error)
+	// StreamConfirmations streams state changes of transfers as they happen, starting with the current state of the
+	// transfers whose ids are set. With no ids set, changes of every transfer the API key may see are streamed.
+	StreamConfirmations(ctx context.Context, in *StreamConfirmationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransferEvent], error)
+}
+
+type transferServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransferServiceClient(cc grpc.ClientConnInterface) TransferServiceClient {
+	return &transferServiceClient{cc}
+}
+
+func (c *transferServiceClient) SubmitTransfer(ctx context.Context, in *SubmitTransferRequest, opts ...grpc.CallOption) (*SubmitTransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitTransferResponse)
+	err := c.cc.Invoke(ctx, TransferService_SubmitTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) GetTransferStatus(ctx context.Context, in *GetTransferStatusRequest, opts ...grpc.CallOption) (*Transfer, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transfer)
+	err := c.cc.Invoke(ctx, TransferService_GetTransferStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transferServiceClient) StreamConfirmations(ctx context.Context, in *StreamConfirmationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransferEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TransferService_ServiceDesc.Streams[0], TransferService_StreamConfirmations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamConfirmationsRequest, TransferEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TransferService_StreamConfirmationsClient = grpc.ServerStreamingClient[TransferEvent]
+
+// TransferServiceServer is the server API for TransferService service.
+// All implementations must embed UnimplementedTransferServiceServer
+// for forward compatibility.
+type TransferServiceServer interface {
+	// SubmitTransfer queues a transfer signed by the daemon's key and returns immediately.
+	SubmitTransfer(context.Context, *SubmitTransferRequest) (*SubmitTransferResponse, error)
+	// GetTransferStatus returns the current state of a previously submitted transfer.
+	GetTransferStatus(context.Context, *GetTransferStatusRequest) (*Transfer, error)
+	// StreamConfirmations streams state changes of transfers as they happen, starting with the current state of the
+	// transfers whose ids are set. With no ids set, changes of every transfer the API key may see are streamed.
+	StreamConfirmations(*StreamConfirmationsRequest, grpc.ServerStreamingServer[TransferEvent]) error
+	mustEmbedUnimplementedTransferServiceServer()
+}
+
+// UnimplementedTransferServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTransferServiceServer struct{}
+
+func (UnimplementedTransferServiceServer) SubmitTransfer(context.Context, *SubmitTransferRequest) (*SubmitTransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTransfer not implemented")
+}
+func (UnimplementedTransferServiceServer) GetTransferStatus(context.Context, *GetTransferStatusRequest) (*Transfer, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransferStatus not implemented")
+}
+func (UnimplementedTransferServiceServer) StreamConfirmations(*StreamConfirmationsRequest, grpc.ServerStreamingServer[TransferEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamConfirmations not implemented")
+}
+func (UnimplementedTransferServiceServer) mustEmbedUnimplementedTransferServiceServer() {}
+func (UnimplementedTransferServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeTransferServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransferServiceServer will
+// result in compilation errors.
+type UnsafeTransferServiceServer interface {
+	mustEmbedUnimplementedTransferServiceServer()
+}
+
+func RegisterTransferServiceServer(s grpc.ServiceRegistrar, srv TransferServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTransferServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TransferService_ServiceDesc, srv)
+}
+
+func _TransferService_SubmitTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).SubmitTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_SubmitTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).SubmitTransfer(ctx, req.(*SubmitTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_GetTransferStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransferStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransferServiceServer).GetTransferStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransferService_GetTransferStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransferServiceServer).GetTransferStatus(ctx, req.(*GetTransferStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransferService_StreamConfirmations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamConfirmationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransferServiceServer).StreamConfirmations(m, &grpc.GenericServerStream[StreamConfirmationsRequest, TransferEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TransferService_StreamConfirmationsServer = grpc.ServerStreamingServer[TransferEvent]
+
+// TransferService_ServiceDesc is the grpc.ServiceDesc for TransferService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TransferService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tokentransfer.v1.TransferService",
+	HandlerType: (*TransferServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitTransfer",
+			Handler:    _TransferService_SubmitTransfer_Handler,
+		},
+		{
+			MethodName: "GetTransferStatus",
+			Handler:    _TransferService_GetTransferStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamConfirmations",
+			Handler:       _TransferService_StreamConfirmations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tokentransfer/v1/transfer.proto",
+}
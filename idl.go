@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+func init() {
+	commands["invoke"] = runInvoke
+}
+
+// anchorIDL is the subset of an Anchor IDL needed to build instructions. Both the legacy format (Anchor < 0.30,
+// with metadata.address and isMut/isSigner accounts) and the current one (with address, discriminators and
+// writable/signer accounts) are accepted.
+type anchorIDL struct {
+	Address  string `json:"address"`
+	Metadata struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+	} `json:"metadata"`
+	Name         string           `json:"name"`
+	Instructions []idlInstruction `json:"instructions"`
+}
+
+type idlInstruction struct {
+	Name          string       `json:"name"`
+	Discriminator []byte       `json:"-"`
+	RawDisc       []int        `json:"discriminator"`
+	Accounts      []idlAccount `json:"accounts"`
+	Args          []idlField   `json:"args"`
+}
+
+type idlAccount struct {
+	Name     string `json:"name"`
+	IsMut    bool   `json:"isMut"`
+	IsSigner bool   `json:"isSigner"`
+	Writable bool   `json:"writable"`
+	Signer   bool   `json:"signer"`
+	Optional bool   `json:"optional"`
+	// IsOptional is the legacy spelling of Optional.
+	IsOptional bool `json:"isOptional"`
+	// Address is set for accounts whose address is fixed, e.g. a program.
+	Address string `json:"address"`
+	// Accounts is set instead of the other fields for a nested group of accounts.
+	Accounts []idlAccount `json:"accounts"`
+}
+
+type idlField struct {
+	Name string          `json:"name"`
+	Type json.RawMessage `json:"type"`
+}
+
+func (a idlAccount) writable() bool { return a.IsMut || a.Writable }
+func (a idlAccount) signer() bool   { return a.IsSigner || a.Signer }
+func (a idlAccount) optional() bool { return a.Optional || a.IsOptional }
+
+// loadIDL reads an Anchor IDL file.
+func loadIDL(path string) (anchorIDL, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return anchorIDL{}, fmt.Errorf("can't read IDL: %v", err)
+	}
+	var idl anchorIDL
+	if err := json.Unmarshal(raw, &idl); err != nil {
+		return anchorIDL{}, fmt.Errorf("%w: can't parse IDL %s: %v", ErrInvalidArgument, path, err)
+	}
+	for i := range idl.Instructions {
+		ix := &idl.Instructions[i]
+		if len(ix.RawDisc) > 0 {
+			for _, b := range ix.RawDisc {
+				ix.Discriminator = append(ix.Discriminator, byte(b))
+			}
+		} else {
+			// Legacy IDLs leave the discriminator implicit: the first 8 bytes of sha256("global:<snake_case name>").
+			sum := sha256.Sum256([]byte("global:" + snakeCase(ix.Name)))
+			ix.Discriminator = sum[:8]
+		}
+	}
+	return idl, nil
+}
+
+// programID returns the program the IDL describes, or the zero key if the IDL doesn't say.
+func (idl anchorIDL) programID() (solanago.PublicKey, error) {
+	address := idl.Address
+	if address == "" {
+		address = idl.Metadata.Address
+	}
+	if address == "" {
+		return solanago.PublicKey{}, nil
+	}
+	return solanago.PublicKeyFromBase58(address)
+}
+
+// instruction returns the instruction called name, in either snake_case or camelCase.
+func (idl anchorIDL) instruction(name string) (idlInstruction, bool) {
+	i := slices.IndexFunc(idl.Instructions, func(ix idlInstruction) bool { return snakeCase(ix.Name) == snakeCase(name) })
+	if i < 0 {
+		return idlInstruction{}, false
+	}
+	return idl.Instructions[i], true
+}
+
+// snakeCase converts a camelCase IDL name to the snake_case of the Rust function it was generated from.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// flatAccounts returns the instruction's accounts in order, with nested groups expanded.
+func flatAccounts(accounts []idlAccount) []idlAccount {
+	var flat []idlAccount
+	for _, a := range accounts {
+		if len(a.Accounts) > 0 {
+			flat = append(flat, flatAccounts(a.Accounts)...)
+			continue
+		}
+		flat = append(flat, a)
+	}
+	return flat
+}
+
+// wellKnownAccounts are filled in when an instruction's account of that name isn't given.
+var wellKnownAccounts = map[string]solanago.PublicKey{
+	"system_program":           solanago.SystemProgramID,
+	"token_program":            solanago.TokenProgramID,
+	"token_2022_program":       solanago.Token2022ProgramID,
+	"associated_token_program": solanago.SPLAssociatedTokenAccountProgramID,
+	"rent":                     solanago.SysVarRentPubkey,
+	"clock":                    solanago.SysVarClockPubkey,
+}
+
+// buildIDLInstruction encodes a call of ix on programID. args and accounts map IDL names to command line values.
+// Signer accounts that aren't given are the signer, and accounts with a fixed or well-known address are filled in;
+// every other account must be given.
+func buildIDLInstruction(programID solanago.PublicKey, ix idlInstruction, args, accounts map[string]string, signer solanago.PublicKey) (solanago.Instruction, error) {
+	data := slices.Clone(ix.Discriminator)
+	for _, field := range ix.Args {
+		value, ok := lookupName(args, field.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: missing --arg %s", ErrInvalidArgument, field.Name)
+		}
+		var err error
+		if data, err = encodeIDLValue(data, field.Type, value); err != nil {
+			return nil, fmt.Errorf("%w: --arg %s: %v", ErrInvalidArgument, field.Name, err)
+		}
+	}
+	for name := range args {
+		if !slices.ContainsFunc(ix.Args, func(f idlField) bool { return snakeCase(f.Name) == snakeCase(name) }) {
+			return nil, fmt.Errorf("%w: %s takes no argument %q", ErrInvalidArgument, ix.Name, name)
+		}
+	}
+
+	flat := flatAccounts(ix.Accounts)
+	metas := make(solanago.AccountMetaSlice, 0, len(flat))
+	for _, a := range flat {
+		var key solanago.PublicKey
+		value, given := lookupName(accounts, a.Name)
+		known, isKnown := wellKnownAccounts[snakeCase(a.Name)]
+		switch {
+		case given:
+			var err error
+			if key, err = solanago.PublicKeyFromBase58(value); err != nil {
+				return nil, fmt.Errorf("%w: --account %s: %v", ErrInvalidArgument, a.Name, err)
+			}
+		case a.Address != "":
+			var err error
+			if key, err = solanago.PublicKeyFromBase58(a.Address); err != nil {
+				return nil, fmt.Errorf("%w: IDL address of account %s: %v", ErrInvalidArgument, a.Name, err)
+			}
+		case a.signer():
+			key = signer
+		case isKnown:
+			key = known
+		case a.optional():
+			// Anchor reads an omitted optional account as the program's own ID.
+			metas = append(metas, solanago.NewAccountMeta(programID, false, false))
+			continue
+		default:
+			return nil, fmt.Errorf("%w: missing --account %s", ErrInvalidArgument, a.Name)
+		}
+		if a.signer() && !key.Equals(signer) {
+			return nil, fmt.Errorf("%w: account %s must sign, but only %s can", ErrSignerUnavailable, a.Name, signer)
+		}
+		metas = append(metas, solanago.NewAccountMeta(key, a.writable(), a.signer()))
+	}
+	for name := range accounts {
+		if !slices.ContainsFunc(flat, func(a idlAccount) bool { return snakeCase(a.Name) == snakeCase(name) }) {
+			return nil, fmt.Errorf("%w: %s takes no account %q", ErrInvalidArgument, ix.Name, name)
+		}
+	}
+	return solanago.NewInstruction(programID, metas, data), nil
+}
+
+// lookupName returns the value given for an IDL name, in either snake_case or camelCase.
+func lookupName(values map[string]string, name string) (string, bool) {
+	for k, v := range values {
+		if snakeCase(k) == snakeCase(name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// encodeIDLValue appends the Borsh encoding of value, given on the command line, as an IDL type. Elements of vecs
+// and arrays are comma separated; bytes are hex.
+func encodeIDLValue(data []byte, typ json.RawMessage, value string) ([]byte, error) {
+	var name string
+	if json.Unmarshal(typ, &name) == nil {
+		return encodeIDLPrimitive(data, name, value)
+	}
+	var composite struct {
+		Option  json.RawMessage   `json:"option"`
+		Vec     json.RawMessage   `json:"vec"`
+		Array   []json.RawMessage `json:"array"`
+		Defined json.RawMessage   `json:"defined"`
+	}
+	if err := json.Unmarshal(typ, &composite); err != nil {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	switch {
+	case composite.Option != nil:
+		if value == "" || value == "null" {
+			return append(data, 0), nil
+		}
+		return encodeIDLValue(append(data, 1), composite.Option, value)
+	case composite.Vec != nil:
+		elements := splitList(value)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(elements)))
+		return encodeIDLElements(data, composite.Vec, elements)
+	case len(composite.Array) == 2:
+		var n int
+		if err := json.Unmarshal(composite.Array[1], &n); err != nil {
+			return nil, fmt.Errorf("unknown type %s", typ)
+		}
+		elements := splitList(value)
+		if len(elements) != n {
+			return nil, fmt.Errorf("expected %d comma separated values, got %d", n, len(elements))
+		}
+		return encodeIDLElements(data, composite.Array[0], elements)
+	case composite.Defined != nil:
+		return nil, fmt.Errorf("user-defined type %s isn't supported, build the instruction with --pre-ix instead", composite.Defined)
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func encodeIDLElements(data []byte, typ json.RawMessage, elements []string) ([]byte, error) {
+	for i, element := range elements {
+		var err error
+		if data, err = encodeIDLValue(data, typ, element); err != nil {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+	}
+	return data, nil
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	elements := strings.Split(value, ",")
+	for i := range elements {
+		elements[i] = strings.TrimSpace(elements[i])
+	}
+	return elements
+}
+
+func encodeIDLPrimitive(data []byte, typ, value string) ([]byte, error) {
+	switch typ {
+	case "bool":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", value)
+		}
+		if v {
+			return append(data, 1), nil
+		}
+		return append(data, 0), nil
+	case "u8", "u16", "u32", "u64":
+		bits, _ := strconv.Atoi(typ[1:])
+		v, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", typ, value)
+		}
+		return appendLittleEndian(data, v, bits/8), nil
+	case "i8", "i16", "i32", "i64":
+		bits, _ := strconv.Atoi(typ[1:])
+		v, err := strconv.ParseInt(value, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", typ, value)
+		}
+		return appendLittleEndian(data, uint64(v), bits/8), nil
+	case "u128", "i128":
+		v, ok := new(big.Int).SetString(value, 10)
+		limit := new(big.Int).Lsh(big.NewInt(1), 128)
+		if typ == "i128" {
+			limit.Rsh(limit, 1)
+		}
+		if !ok || v.CmpAbs(limit) >= 0 || (typ == "u128" && v.Sign() < 0) || (typ == "i128" && v.Cmp(new(big.Int).Neg(limit)) < 0) {
+			return nil, fmt.Errorf("invalid %s %q", typ, value)
+		}
+		if v.Sign() < 0 {
+			// Two's complement.
+			v.Add(v, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		var be [16]byte
+		v.FillBytes(be[:])
+		slices.Reverse(be[:])
+		return append(data, be[:]...), nil
+	case "f32":
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid f32 %q", value)
+		}
+		return binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(v))), nil
+	case "f64":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid f64 %q", value)
+		}
+		return binary.LittleEndian.AppendUint64(data, math.Float64bits(v)), nil
+	case "string":
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+		return append(data, value...), nil
+	case "bytes":
+		b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex bytes %q", value)
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(b)))
+		return append(data, b...), nil
+	case "publicKey", "pubkey":
+		key, err := solanago.PublicKeyFromBase58(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %v", value, err)
+		}
+		return append(data, key[:]...), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", typ)
+}
+
+func appendLittleEndian(data []byte, v uint64, size int) []byte {
+	for i := 0; i < size; i++ {
+		data = append(data, byte(v>>(8*i)))
+	}
+	return data
+}
+
+// nameValues collects NAME=VALUE pairs from a repeatable flag.
+type nameValues map[string]string
+
+func (m nameValues) String() string {
+	return fmt.Sprintf("%d value(s)", len(m))
+}
+
+func (m nameValues) Set(value string) error {
+	name, v, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	m[name] = v
+	return nil
+}
+
+func runInvoke(ctx context.Context, args []string) error {
+	fs := newFlagSet("invoke")
+	idlFlag := fs.String("idl", "", "Anchor IDL of the program (required)")
+	programFlag := fs.String("program", "", "Program to invoke (default: the IDL's address, or the configured program)")
+	listFlag := fs.Bool("list", false, "List the IDL's instructions with their arguments and accounts instead of invoking one")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the instruction without sending it")
+	ixArgs, ixAccounts := nameValues{}, nameValues{}
+	fs.Var(ixArgs, "arg", "Instruction argument as NAME=VALUE; vec and array elements are comma separated, bytes hex (repeatable)")
+	fs.Var(ixAccounts, "account", "Instruction account as NAME=ADDRESS (repeatable)")
+	fs.BoolVar(&policyOverride, "override", false, "Invoke even though the spending policy can't check program instructions; the override is recorded in the audit log")
+	fs.BoolVar(&assumeYes, "yes", false, "Invoke on mainnet without the confirmation prompt")
+	parseFlags(fs, args)
+
+	if *idlFlag == "" {
+		return fmt.Errorf("%w: --idl flag is required", ErrInvalidArgument)
+	}
+	idl, err := loadIDL(*idlFlag)
+	if err != nil {
+		return err
+	}
+	if *listFlag {
+		return printIDLInstructions(idl)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: usage: invoke --idl <file> [flags] <instruction>", ErrInvalidArgument)
+	}
+	ix, ok := idl.instruction(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("%w: the IDL has no instruction %q (see invoke --list)", ErrInvalidArgument, fs.Arg(0))
+	}
+
+	programID, err := idl.programID()
+	if err != nil {
+		return fmt.Errorf("%w: IDL address: %v", ErrInvalidArgument, err)
+	}
+	switch {
+	case *programFlag != "":
+		if programID, err = solanago.PublicKeyFromBase58(*programFlag); err != nil {
+			return fmt.Errorf("%w: --program: %v", ErrInvalidArgument, err)
+		}
+	case programID.IsZero():
+		programID = solanago.MustPublicKeyFromBase58(programIDBase58)
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	instruction, err := buildIDLInstruction(programID, ix, ixArgs, ixAccounts, signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	if *dryRunFlag {
+		return simulateInstructions(ctx, clients, signer, instruction)
+	}
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := enforceUncheckedPolicy(policy, "program instructions", map[string]any{"program": programID.String(), "instruction": ix.Name}); err != nil {
+		return err
+	}
+	if err := confirmMainnetOperation(invokeSummary(programID, ix.Name, instruction), ix.Name); err != nil {
+		return err
+	}
+	slog.Info("invoking program", "program", programID, "instruction", ix.Name)
+	sig, err := sendInstructions(ctx, clients, signer, instruction)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// invokeSummary describes the instruction ix of program for confirmMainnetOperation, with its accounts.
+func invokeSummary(program solanago.PublicKey, ix string, instruction solanago.Instruction) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Program:   %s\nInvoke:    %s\n", program, ix)
+	for i, account := range instruction.Accounts() {
+		label := ""
+		if i == 0 {
+			label = "Accounts:"
+		}
+		var flags []string
+		if account.IsWritable {
+			flags = append(flags, "writable")
+		}
+		if account.IsSigner {
+			flags = append(flags, "signer")
+		}
+		line := fmt.Sprintf("%-10s %s", label, account.PublicKey)
+		if len(flags) > 0 {
+			line += " (" + strings.Join(flags, ", ") + ")"
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// printIDLInstructions lists the IDL's instructions, their arguments with types and their accounts.
+func printIDLInstructions(idl anchorIDL) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTRUCTION\tARGS\tACCOUNTS")
+	for _, ix := range idl.Instructions {
+		args := make([]string, len(ix.Args))
+		for i, f := range ix.Args {
+			args[i] = fmt.Sprintf("%s:%s", f.Name, strings.Trim(string(f.Type), `"`))
+		}
+		var accounts []string
+		for _, a := range flatAccounts(ix.Accounts) {
+			name := a.Name
+			if a.writable() {
+				name += "(w)"
+			}
+			if a.signer() {
+				name += "(s)"
+			}
+			accounts = append(accounts, name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ix.Name, strings.Join(args, " "), strings.Join(accounts, " "))
+	}
+	return w.Flush()
+}
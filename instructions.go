@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+// instructionSpec is the JSON schema accepted by --pre-ix and --post-ix. Data is base64 encoded unless Encoding says
+// otherwise (base64|base58|hex|utf8).
+//
+//	{"programId": "...", "accounts": [{"pubkey": "...", "isSigner": false, "isWritable": true}], "data": "..."}
+type instructionSpec struct {
+	ProgramID string            `json:"programId"`
+	Accounts  []accountMetaSpec `json:"accounts"`
+	Data      string            `json:"data"`
+	Encoding  string            `json:"encoding"`
+}
+
+type accountMetaSpec struct {
+	Pubkey     string `json:"pubkey"`
+	IsSigner   bool   `json:"isSigner"`
+	IsWritable bool   `json:"isWritable"`
+}
+
+// instructionList collects extra instructions from repeated command line flags. Each value is one of:
+//
+//	{...} or [{...}, ...]        an inline JSON instruction spec (or list of them)
+//	@path/to/file.json           a file containing the same JSON
+//	PROGRAM_ID:BASE64_DATA       an instruction without accounts, e.g. a memo or logging instruction
+type instructionList []solanago.Instruction
+
+func (l *instructionList) String() string {
+	return fmt.Sprintf("%d instruction(s)", len(*l))
+}
+
+func (l *instructionList) Set(value string) error {
+	instructions, err := ParseInstructions(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, instructions...)
+	return nil
+}
+
+// ParseInstructions decodes one command line instruction value into instructions. See instructionList for the
+// accepted formats.
+func ParseInstructions(value string) ([]solanago.Instruction, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "@") {
+		raw, err := os.ReadFile(value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("can't read instruction file: %v", err)
+		}
+		value = strings.TrimSpace(string(raw))
+	}
+
+	switch {
+	case strings.HasPrefix(value, "["):
+		var specs []instructionSpec
+		if err := json.Unmarshal([]byte(value), &specs); err != nil {
+			return nil, fmt.Errorf("invalid instruction list: %v", err)
+		}
+		instructions := make([]solanago.Instruction, 0, len(specs))
+		for i, spec := range specs {
+			instruction, err := spec.build()
+			if err != nil {
+				return nil, fmt.Errorf("instruction %d: %v", i, err)
+			}
+			instructions = append(instructions, instruction)
+		}
+		return instructions, nil
+	case strings.HasPrefix(value, "{"):
+		var spec instructionSpec
+		if err := json.Unmarshal([]byte(value), &spec); err != nil {
+			return nil, fmt.Errorf("invalid instruction: %v", err)
+		}
+		instruction, err := spec.build()
+		if err != nil {
+			return nil, err
+		}
+		return []solanago.Instruction{instruction}, nil
+	}
+
+	programID, data, found := strings.Cut(value, ":")
+	if !found {
+		return nil, fmt.Errorf("invalid instruction %q: expected JSON, @file or PROGRAM_ID:BASE64_DATA", value)
+	}
+	instruction, err := instructionSpec{ProgramID: programID, Data: data}.build()
+	if err != nil {
+		return nil, err
+	}
+	return []solanago.Instruction{instruction}, nil
+}
+
+func (s instructionSpec) build() (solanago.Instruction, error) {
+	programID, err := solanago.PublicKeyFromBase58(s.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid program ID %q: %v", s.ProgramID, err)
+	}
+
+	accounts := make(solanago.AccountMetaSlice, 0, len(s.Accounts))
+	for _, account := range s.Accounts {
+		pubkey, err := solanago.PublicKeyFromBase58(account.Pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %q: %v", account.Pubkey, err)
+		}
+		accounts = append(accounts, solanago.NewAccountMeta(pubkey, account.IsWritable, account.IsSigner))
+	}
+
+	data, err := decodeInstructionData(s.Data, s.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instruction data: %v", err)
+	}
+	return solanago.NewInstruction(programID, accounts, data), nil
+}
+
+func decodeInstructionData(data, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "base64":
+		return base64.StdEncoding.DecodeString(data)
+	case "base58":
+		return base58.Decode(data)
+	case "hex":
+		return hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	case "utf8":
+		return []byte(data), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
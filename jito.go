@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// defaultJitoURL is the mainnet block engine; regional endpoints can be set with --jito-url.
+const defaultJitoURL = "https://mainnet.block-engine.jito.wtf"
+
+// jitoClient talks to a Jito block engine, which forwards bundles of transactions to Jito validators. Bundles
+// are only considered when they tip one of the block engine's tip accounts.
+type jitoClient struct {
+	url    string
+	tip    uint64
+	client *http.Client
+
+	mu          sync.Mutex
+	tipAccounts []solanago.PublicKey
+}
+
+func newJitoClient(url string, tip uint64) *jitoClient {
+	transport := newRateLimitTransport(rpcRPS, rpcBurst, &tracingTransport{next: http.DefaultTransport})
+	return &jitoClient{
+		url:    strings.TrimSuffix(url, "/") + "/api/v1/bundles",
+		tip:    tip,
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// SendBundle submits tx as a single-transaction bundle and returns its signature.
+func (j *jitoClient) SendBundle(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't encode transaction: %v", err)
+	}
+	var bundleID string
+	params := []any{[]string{base64.StdEncoding.EncodeToString(raw)}, map[string]string{"encoding": "base64"}}
+	if err := j.call(ctx, "sendBundle", params, &bundleID); err != nil {
+		return solanago.Signature{}, err
+	}
+	spanFromContext(ctx).SetAttrs("jito.bundle_id", bundleID)
+	return tx.Signatures[0], nil
+}
+
+// TipInstructions returns a transfer of the tip to one of the block engine's tip accounts, picked at random so
+// concurrent transactions don't all write-lock the same account.
+func (j *jitoClient) TipInstructions(ctx context.Context, payer solanago.PublicKey) ([]solanago.Instruction, error) {
+	accounts, err := j.getTipAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tipAccount := accounts[rand.N(len(accounts))]
+	return []solanago.Instruction{system.NewTransferInstruction(j.tip, payer, tipAccount).Build()}, nil
+}
+
+// getTipAccounts fetches the block engine's tip accounts once.
+func (j *jitoClient) getTipAccounts(ctx context.Context) ([]solanago.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.tipAccounts != nil {
+		return j.tipAccounts, nil
+	}
+	var accounts []string
+	if err := j.call(ctx, "getTipAccounts", []any{}, &accounts); err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		key, err := solanago.PublicKeyFromBase58(account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jito tip account %q: %v", account, err)
+		}
+		j.tipAccounts = append(j.tipAccounts, key)
+	}
+	if len(j.tipAccounts) == 0 {
+		return nil, fmt.Errorf("jito block engine returned no tip accounts")
+	}
+	return j.tipAccounts, nil
+}
+
+// call makes a JSON-RPC request to the block engine. Errors are classified like those of the cluster's RPC.
+func (j *jitoClient) call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: invalid --jito-url: %v", ErrInvalidArgument, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jito %s: %w", method, classifyRPCError(err))
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *jsonrpc.RPCError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return fmt.Errorf("%w: jito %s: HTTP %d", ErrRPCUnavailable, method, resp.StatusCode)
+		}
+		return fmt.Errorf("jito %s: HTTP %d: can't decode response: %v", method, resp.StatusCode, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("jito %s: %w", method, classifyRPCError(reply.Error))
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return fmt.Errorf("jito %s: can't decode result: %v", method, err)
+	}
+	return nil
+}
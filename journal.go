@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	txsender "github.com/csknk/token-transfer/pkg/sender"
+)
+
+// newTransferRecord returns a pending record for a transfer that is about to be sent.
+func newTransferRecord(idempotencyKey string, sender, receiver, mint solanago.PublicKey, amount string) transferRecord {
+	now := time.Now().UTC()
+	return transferRecord{
+		ID:             newRequestID(),
+		IdempotencyKey: idempotencyKey,
+		Sender:         sender.String(),
+		Receiver:       receiver.String(),
+		Mint:           mint.String(),
+		Amount:         amount,
+		Status:         transferPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// sendRecorded sends the transfer for the stored record id, journaling each attempt's signature before it is
+// broadcast and the outcome afterwards.
+func sendRecorded(ctx context.Context, store *Store, clients Clients, signer Signer, id string, receiver solanago.PublicKey, amount uint64, opts TransferOptions) (solanago.Signature, error) {
+	ctx, sp := startSpan(ctx, "transfer", spanKindInternal, "transfer.id", id, "transfer.receiver", receiver.String(), "transfer.amount", amount)
+	sig, err := clients.Sender.SendAndConfirm(ctx, transferSigner(clients, signer, receiver, amount, opts), journalSignature(store, id))
+	if err != nil {
+		err = fmt.Errorf("can't send transaction: %w", classifySendError(err))
+	} else if !opts.Mint.IsZero() {
+		verifyReceived(ctx, clients.Read, store, id, sig, receiver, opts.Mint, amount)
+	}
+	recordOutcome(store, id, err)
+	sp.End(err)
+	return sig, err
+}
+
+// journalSignature returns a send loop hook recording each attempt's signature and blockhash on the transfer
+// records ids before the transaction is broadcast.
+func journalSignature(store *Store, ids ...string) func(*solanago.Transaction, txsender.Blockhash) error {
+	return func(tx *solanago.Transaction, blockhash txsender.Blockhash) error {
+		for _, id := range ids {
+			err := store.UpdateTransfer(id, func(record *transferRecord) {
+				record.Signature = tx.Signatures[0].String()
+				record.Blockhash = blockhash.Hash.String()
+				record.Explorer = explorerTxURL(record.Signature)
+			})
+			if err != nil {
+				// Without the signature on disk a crash could lead to a double send, so don't broadcast.
+				return fmt.Errorf("can't record transfer signature: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// recordOutcome records the result of sending the transfer id and reports it to the notifiers. A transfer is only
+// recorded failed once it can't land; one interrupted, or whose transaction may have been broadcast without the
+// outcome being known, stays pending, so the next run checks on-chain whether it landed.
+func recordOutcome(store *Store, id string, sendErr error) {
+	err := store.UpdateTransfer(id, func(record *transferRecord) {
+		switch {
+		case sendErr == nil:
+			transfersConfirmed.Inc()
+			record.Status, record.Error = transferConfirmed, ""
+		case interrupted(sendErr) || record.Signature != "" && !cannotLand(sendErr):
+			record.Error = sendErr.Error()
+		default:
+			transfersFailed.Inc()
+			record.Status, record.Error = transferFailed, sendErr.Error()
+		}
+	})
+	if err != nil {
+		slog.Error("can't record transfer outcome", "id", id, "error", err)
+	}
+	if record, ok := store.Transfer(id); ok && record.Status != transferPending {
+		notifyTransfer(record)
+	}
+}
+
+// cannotLand reports whether sendErr means the transaction sent can't land: it failed on-chain, it failed
+// simulation and so wasn't broadcast, or its blockhash expired by the finalized block height. Other errors, such
+// as a broadcast or status lookup failing in transit, leave open whether it landed.
+func cannotLand(sendErr error) bool {
+	return errors.Is(sendErr, ErrTransactionFailed) || errors.Is(sendErr, ErrSimulationFailed) || errors.Is(sendErr, ErrBlockhashExpired)
+}
+
+// resumeTransfer decides what to do when a transfer with the same idempotency key already exists. It returns
+// done=true when the existing result stands and nothing must be sent; for a confirmed transfer err is nil, for a
+// failed one it describes the failure. done=false means the earlier attempt definitely never landed and the
+// transfer may be sent again under the same record.
+func resumeTransfer(ctx context.Context, client RPCClient, store *Store, record transferRecord) (bool, error) {
+	switch record.Status {
+	case transferConfirmed:
+		return true, nil
+	case transferFailed:
+		return true, fmt.Errorf("transfer %s with this idempotency key already failed: %s (use a new key to retry)", record.ID, record.Error)
+	case transferAwaitingApproval:
+		return true, fmt.Errorf("%w: transfer %s is awaiting approval", ErrAborted, record.ID)
+	}
+	if record.Signature == "" {
+		// Interrupted before signing, so nothing was broadcast.
+		return false, nil
+	}
+
+	sig, err := solanago.SignatureFromBase58(record.Signature)
+	if err != nil {
+		return true, fmt.Errorf("corrupt signature in transfer %s: %v", record.ID, err)
+	}
+	statuses, err := client.GetSignatureStatuses(ctx, true, sig)
+	if err != nil {
+		return true, fmt.Errorf("can't check status of %s: %w", sig, classifyRPCError(err))
+	}
+	if len(statuses.Value) > 0 && statuses.Value[0] != nil {
+		status := statuses.Value[0]
+		switch {
+		case status.Err != nil:
+			err := fmt.Errorf("%w: %v", ErrTransactionFailed, status.Err)
+			recordOutcome(store, record.ID, err)
+			return true, err
+		case status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed || status.ConfirmationStatus == rpc.ConfirmationStatusFinalized:
+			recordOutcome(store, record.ID, nil)
+			return true, nil
+		default:
+			return true, fmt.Errorf("%w: transfer %s (%s) is still being confirmed, retry later", ErrAborted, record.ID, sig)
+		}
+	}
+
+	// Not seen by the cluster. It can still land until its blockhash expires.
+	blockhash, err := solanago.HashFromBase58(record.Blockhash)
+	if err != nil {
+		return true, fmt.Errorf("corrupt blockhash in transfer %s: %v", record.ID, err)
+	}
+	valid, err := client.IsBlockhashValid(ctx, blockhash, rpc.CommitmentProcessed)
+	if err != nil {
+		return true, fmt.Errorf("can't check blockhash of %s: %w", sig, classifyRPCError(err))
+	}
+	if valid.Value {
+		return true, fmt.Errorf("%w: transfer %s (%s) may still land, retry once its blockhash expires (~1 minute)", ErrAborted, record.ID, sig)
+	}
+	slog.Info("previous attempt expired without landing, sending again", "id", record.ID, "signature", sig)
+	return false, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	solanago "github.com/gagliardetto/solana-go"
+
+	"github.com/csknk/token-transfer/pkg/rpcfake"
+)
+
+// setTestMint creates an SPL Token mint with decimals and no authorities on client.
+func setTestMint(client *rpcfake.Client, mint solanago.PublicKey, decimals uint8) {
+	// Mint authority (COption, none), supply, decimals, is_initialized, freeze authority (COption, none).
+	data := make([]byte, 82)
+	binary.LittleEndian.PutUint64(data[36:], 1_000_000_000)
+	data[44] = decimals
+	data[45] = 1
+	client.SetAccount(mint, solanago.TokenProgramID, 1_461_600, data)
+}
+
+// sendFakeTransfer records a transfer of 1.5 tokens of a 6-decimal mint to a new receiver and sends it with
+// sendRecorded through client. It returns the record as stored afterwards and the send error.
+func sendFakeTransfer(t *testing.T, client *rpcfake.Client) (transferRecord, solanago.Signature, error) {
+	t.Helper()
+	signer, receiver, mint := testKey(1), testKey(2).PublicKey(), testKey(3).PublicKey()
+	setTestMint(client, mint, 6)
+
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	record := newTransferRecord("", signer.PublicKey(), receiver, mint, "1.5")
+	if err := store.PutTransfer(record); err != nil {
+		t.Fatal(err)
+	}
+
+	clients := Clients{Read: client, Write: client}
+	clients.Sender = newRPCSender(clients)
+	sig, sendErr := sendRecorded(context.Background(), store, clients, signer, record.ID, receiver, 1_500_000, TransferOptions{Mint: mint})
+	record, _ = store.Transfer(record.ID)
+	return record, sig, sendErr
+}
+
+func TestSendRecordedConfirmed(t *testing.T) {
+	client := rpcfake.New()
+	record, sig, err := sendFakeTransfer(t, client)
+	if err != nil {
+		t.Fatalf("sendRecorded: %v", err)
+	}
+	if record.Status != transferConfirmed || record.Signature != sig.String() {
+		t.Errorf("recorded %s with signature %s, want %s with %s", record.Status, record.Signature, transferConfirmed, sig)
+	}
+	if sent := client.Sent(); len(sent) != 1 || sent[0].Signatures[0] != sig {
+		t.Errorf("sent %d transactions, want one signed %s", len(sent), sig)
+	}
+}
+
+func TestSendRecordedFailedOnChain(t *testing.T) {
+	client := rpcfake.New()
+	client.TxErr = map[string]any{"InstructionError": []any{2, map[string]any{"Custom": 1}}}
+	record, _, err := sendFakeTransfer(t, client)
+	if !errors.Is(err, ErrTransactionFailed) {
+		t.Fatalf("sendRecorded = %v, want ErrTransactionFailed", err)
+	}
+	if record.Status != transferFailed {
+		t.Errorf("recorded %s, want %s", record.Status, transferFailed)
+	}
+}
+
+func TestSendRecordedBroadcastErrorStaysPending(t *testing.T) {
+	client := rpcfake.New()
+	// The transaction may or may not have reached the cluster.
+	client.SendErr = errors.New("connection reset by peer")
+	record, _, err := sendFakeTransfer(t, client)
+	if err == nil {
+		t.Fatal("sendRecorded succeeded")
+	}
+	if record.Status != transferPending || record.Signature == "" {
+		t.Errorf("recorded %s with signature %q, want %s with the signature journaled", record.Status, record.Signature, transferPending)
+	}
+}
+
+func TestSendTestTransferUsesTransferOptions(t *testing.T) {
+	defer func(saved string) { testSend = saved }(testSend)
+	testSend = "0.1"
+	client := rpcfake.New()
+	signer, receiver, mint := testKey(1), testKey(2).PublicKey(), testKey(3).PublicKey()
+	setTestMint(client, mint, 6)
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	clients := Clients{Read: client, Write: client}
+	clients.Sender = newRPCSender(clients)
+	// The test transfer is sent before the operator is asked about it, which fails or aborts here.
+	sendTestTransfer(context.Background(), store, clients, signer, receiver, 1_500_000, 6, TransferOptions{Mint: mint, Memo: "invoice 42"})
+	sent := client.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d transactions, want the test transfer", len(sent))
+	}
+	for _, instruction := range sent[0].Message.Instructions {
+		if sent[0].Message.AccountKeys[instruction.ProgramIDIndex].Equals(solanago.MemoProgramID) {
+			return
+		}
+	}
+	t.Error("the test transfer has no memo")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// base58Alphabet are the characters addresses are written with; 0, O, I and l aren't among them.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// grindProgressInterval is how often keygen grind logs how many keys it has tried.
+const grindProgressInterval = 10 * time.Second
+
+func init() {
+	commands["keygen"] = runKeygen
+}
+
+func runKeygen(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "grind" {
+		return runKeygenGrind(ctx, args[1:])
+	}
+	// keygen without a subcommand is keygen new.
+	if len(args) > 0 && args[0] == "new" {
+		args = args[1:]
+	}
+	return runKeygenNew(ctx, args)
+}
+
+func runKeygenNew(ctx context.Context, args []string) error {
+	fs := newFlagSet("keygen new")
+	out := fs.String("out", "", "File to write the new keypair to; must not exist (required)")
+	plaintext := fs.Bool("plaintext", false, "Write an unencrypted solana-keygen file instead of an encrypted keypair")
+	mnemonic := fs.Bool("mnemonic", false, "Generate a BIP39 mnemonic phrase, print it to stderr and derive the key from it at --derivation-path")
+	words := fs.Int("words", 12, "Number of words of the --mnemonic phrase: 12 or 24")
+	parseFlags(fs, args)
+
+	if *out == "" {
+		return fmt.Errorf("%w: --out flag is required", ErrInvalidArgument)
+	}
+	var key solanago.PrivateKey
+	if *mnemonic {
+		if *words != 12 && *words != 24 {
+			return fmt.Errorf("%w: --words must be 12 or 24, got %d", ErrInvalidArgument, *words)
+		}
+		// Every 3 words encode 32 bits of entropy and a checksum bit.
+		entropy, err := bip39.NewEntropy(*words / 3 * 32)
+		if err != nil {
+			return fmt.Errorf("can't generate entropy: %v", err)
+		}
+		phrase, err := bip39.NewMnemonic(entropy)
+		if err != nil {
+			return fmt.Errorf("can't generate mnemonic: %v", err)
+		}
+		if key, err = keyFromMnemonic(phrase, derivationPath); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		fmt.Fprintf(os.Stderr, "Write down this recovery phrase and keep it secret; anyone with it controls the key:\n\n    %s\n\n", phrase)
+	} else {
+		var err error
+		if key, err = solanago.NewRandomPrivateKey(); err != nil {
+			return fmt.Errorf("can't generate keypair: %v", err)
+		}
+	}
+	if err := writeKeypair(*out, key, *plaintext); err != nil {
+		return err
+	}
+	slog.Info("keypair written", "path", *out, "encrypted", !*plaintext)
+	fmt.Println(key.PublicKey())
+	return nil
+}
+
+// vanityPattern is what keygen grind looks for in an address.
+type vanityPattern struct {
+	Prefix, Suffix string
+	IgnoreCase     bool
+}
+
+// matches reports whether address has the pattern's prefix and suffix.
+func (p vanityPattern) matches(address string) bool {
+	if p.IgnoreCase {
+		address = strings.ToLower(address)
+	}
+	return strings.HasPrefix(address, p.Prefix) && strings.HasSuffix(address, p.Suffix)
+}
+
+// GrindKeys generates keys on workers goroutines until count of them have addresses matching pattern or ctx ends,
+// calling found (from one goroutine at a time) with each match. It returns how many keys it tried.
+func GrindKeys(ctx context.Context, pattern vanityPattern, count, workers int, found func(solanago.PrivateKey) error) (uint64, error) {
+	if pattern.IgnoreCase {
+		pattern.Prefix, pattern.Suffix = strings.ToLower(pattern.Prefix), strings.ToLower(pattern.Suffix)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		tried   atomic.Uint64
+		mu      sync.Mutex
+		matched int
+		failure error
+		wg      sync.WaitGroup
+	)
+	fail := func(err error) {
+		mu.Lock()
+		failure = cmp.Or(failure, err)
+		mu.Unlock()
+		cancel(err)
+	}
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				public, private, err := ed25519.GenerateKey(rand.Reader)
+				if err != nil {
+					fail(fmt.Errorf("can't generate keypair: %v", err))
+					return
+				}
+				tried.Add(1)
+				if !pattern.matches(base58.Encode(public)) {
+					continue
+				}
+				mu.Lock()
+				var foundErr error
+				if matched < count && failure == nil {
+					if foundErr = found(solanago.PrivateKey(private)); foundErr == nil {
+						matched++
+					}
+				}
+				complete := matched == count
+				mu.Unlock()
+				if foundErr != nil {
+					fail(foundErr)
+				} else if complete {
+					cancel(nil)
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(grindProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			switch {
+			case failure != nil:
+				return tried.Load(), failure
+			case matched == count:
+				return tried.Load(), nil
+			}
+			return tried.Load(), fmt.Errorf("%w: found %d of %d keys: %w", ErrAborted, matched, count, context.Cause(ctx))
+		case <-ticker.C:
+			slog.Info("grinding", "tried", tried.Load())
+		}
+	}
+}
+
+func runKeygenGrind(ctx context.Context, args []string) error {
+	fs := newFlagSet("keygen grind")
+	prefix := fs.String("prefix", "", "Prefix the address must start with")
+	suffix := fs.String("suffix", "", "Suffix the address must end with")
+	ignoreCase := fs.Bool("ignore-case", false, "Match --prefix and --suffix case-insensitively")
+	count := fs.Int("count", 1, "Number of matching keypairs to generate")
+	outDir := fs.String("out-dir", ".", "Directory to write the keypairs to, as ADDRESS.json")
+	workers := fs.Int("threads", runtime.NumCPU(), "Number of keys to generate in parallel")
+	parseFlags(fs, args)
+
+	if *prefix == "" && *suffix == "" {
+		return fmt.Errorf("%w: --prefix or --suffix is required", ErrInvalidArgument)
+	}
+	for _, r := range *prefix + *suffix {
+		if !strings.ContainsRune(base58Alphabet, r) {
+			return fmt.Errorf("%w: %q can't appear in an address, which uses the characters %s", ErrInvalidArgument, r, base58Alphabet)
+		}
+	}
+	if len(*prefix)+len(*suffix) > 44 {
+		return fmt.Errorf("%w: addresses are at most 44 characters long", ErrInvalidArgument)
+	}
+	if *count < 1 {
+		return fmt.Errorf("%w: --count must be positive", ErrInvalidArgument)
+	}
+
+	slog.Info("grinding for a vanity address", "prefix", *prefix, "suffix", *suffix, "threads", *workers)
+	pattern := vanityPattern{Prefix: *prefix, Suffix: *suffix, IgnoreCase: *ignoreCase}
+	start := time.Now()
+	tried, err := GrindKeys(ctx, pattern, *count, *workers, func(key solanago.PrivateKey) error {
+		path := filepath.Join(*outDir, key.PublicKey().String()+".json")
+		if err := writeKeypair(path, key, true); err != nil {
+			return err
+		}
+		slog.Info("keypair written", "path", path)
+		fmt.Println(key.PublicKey())
+		return nil
+	})
+	slog.Info("grinding done", "tried", tried, "elapsed", time.Since(start).Round(time.Second))
+	return err
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// passphraseFile is the file holding the passphrase for encrypted keypairs. Without it the passphrase is prompted
+// for on the terminal.
+var passphraseFile string
+
+const (
+	keystoreVersion = 1
+	// scrypt cost parameters for new keystores: the interactive-login recommendation, around 100ms per unlock.
+	keystoreScryptN = 1 << 15
+	keystoreScryptR = 8
+	keystoreScryptP = 1
+)
+
+func init() {
+	commands["key"] = runKey
+}
+
+// registerPassphraseFlags adds --passphrase-file to fs.
+func registerPassphraseFlags(fs *flag.FlagSet) {
+	fs.StringVar(&passphraseFile, "passphrase-file", "", "File holding the passphrase for encrypted keypairs; prompted for on the terminal if not set")
+}
+
+// encryptedKeypair is the on-disk format of an encrypted keypair: the 64-byte Solana private key sealed with
+// AES-256-GCM under a key derived from the passphrase with scrypt. The public key is kept in the clear so the file
+// can be identified without the passphrase.
+type encryptedKeypair struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	PublicKey  string `json:"publicKey"`
+}
+
+// cipher derives the AES-GCM cipher for passphrase and the keystore's KDF parameters.
+func (k *encryptedKeypair) cipher(passphrase []byte) (cipher.AEAD, error) {
+	if k.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported kdf %q", k.KDF)
+	}
+	key, err := scrypt.Key(passphrase, k.Salt, k.N, k.R, k.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptKeypair seals key under passphrase.
+func encryptKeypair(key solanago.PrivateKey, passphrase []byte) (*encryptedKeypair, error) {
+	k := &encryptedKeypair{
+		Version:   keystoreVersion,
+		KDF:       "scrypt",
+		N:         keystoreScryptN,
+		R:         keystoreScryptR,
+		P:         keystoreScryptP,
+		Salt:      make([]byte, 16),
+		PublicKey: key.PublicKey().String(),
+	}
+	if _, err := rand.Read(k.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := k.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	k.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(k.Nonce); err != nil {
+		return nil, err
+	}
+	k.Ciphertext = aead.Seal(nil, k.Nonce, key, []byte(k.PublicKey))
+	return k, nil
+}
+
+// decrypt opens the keypair with passphrase. A wrong passphrase and a tampered file are indistinguishable.
+func (k *encryptedKeypair) decrypt(passphrase []byte) (solanago.PrivateKey, error) {
+	if k.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", k.Version)
+	}
+	aead, err := k.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(k.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	plain, err := aead.Open(nil, k.Nonce, k.Ciphertext, []byte(k.PublicKey))
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted keystore")
+	}
+	key := solanago.PrivateKey(plain)
+	if len(key) != ed25519.PrivateKeySize || key.PublicKey().String() != k.PublicKey {
+		return nil, errors.New("keystore holds an invalid key")
+	}
+	return key, nil
+}
+
+// isEncryptedKeypair reports whether data is an encrypted keypair rather than a solana-keygen file, which is a
+// JSON array of bytes.
+func isEncryptedKeypair(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// loadKeypair loads the keypair at path, either a plaintext solana-keygen file or an encrypted keypair, prompting
+// for the passphrase of the latter.
+func loadKeypair(path string) (solanago.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeypair(data, path)
+}
+
+// base64KeypairLength is the length of a 64-byte keypair in padded base64.
+const base64KeypairLength = 88
+
+// parseKeypair parses a private key in any of the formats accepted for keypairs: an encrypted keypair, a
+// solana-keygen JSON array of bytes, a base58 string as exported by wallets, the same 64 bytes in base64, or a
+// BIP39 mnemonic phrase, from which the key at --derivation-path is derived. source names where the key came
+// from in the passphrase prompt.
+func parseKeypair(data []byte, source string) (solanago.PrivateKey, error) {
+	data = bytes.TrimSpace(data)
+	var key solanago.PrivateKey
+	switch {
+	case isEncryptedKeypair(data):
+		var k encryptedKeypair
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("invalid keystore %s: %v", source, err)
+		}
+		passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for %s (%s)", source, k.PublicKey), false)
+		if err != nil {
+			return nil, err
+		}
+		return k.decrypt(passphrase)
+	case bytes.HasPrefix(data, []byte("[")):
+		// Decoding straight into a byte slice would expect base64, so go through ints.
+		var ints []int
+		if err := json.Unmarshal(data, &ints); err != nil {
+			return nil, fmt.Errorf("invalid keypair %s: %v", source, err)
+		}
+		key = make(solanago.PrivateKey, len(ints))
+		for i, b := range ints {
+			if b < 0 || b > 255 {
+				return nil, fmt.Errorf("invalid keypair %s: byte out of range", source)
+			}
+			key[i] = byte(b)
+		}
+	case len(data) == base64KeypairLength && bytes.HasSuffix(data, []byte("==")):
+		// Padding never occurs in base58, so this is a base64 keypair.
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(string(data)); err != nil {
+			return nil, fmt.Errorf("invalid keypair %s: %v", source, err)
+		}
+	case isMnemonic(data):
+		var err error
+		if key, err = keyFromMnemonic(string(data), derivationPath); err != nil {
+			return nil, fmt.Errorf("invalid keypair %s: %v", source, err)
+		}
+	default:
+		var err error
+		if key, err = solanago.PrivateKeyFromBase58(string(data)); err != nil {
+			return nil, fmt.Errorf("invalid keypair %s: %v", source, err)
+		}
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid keypair %s: %d bytes, want %d", source, len(key), ed25519.PrivateKeySize)
+	}
+	return key, nil
+}
+
+// readPassphrase returns the contents of --passphrase-file, or reads a passphrase from the terminal without echo.
+// With confirm the passphrase has to be typed twice.
+func readPassphrase(prompt string, confirm bool) ([]byte, error) {
+	if passphraseFile != "" {
+		data, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read passphrase file: %v", err)
+		}
+		passphrase := bytes.TrimRight(data, "\r\n")
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("passphrase file %s is empty", passphraseFile)
+		}
+		return passphrase, nil
+	}
+	if !stdinIsTerminal() {
+		return nil, errors.New("no terminal to prompt for the passphrase: set --passphrase-file")
+	}
+	passphrase, err := promptPassword(prompt + ": ")
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := promptPassword("Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New("passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
+
+// promptPassword prints prompt on stderr and reads a line from the terminal without echoing it.
+func promptPassword(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+// writeKeypair writes key to path, encrypted unless plaintext is set. It never overwrites an existing file.
+func writeKeypair(path string, key solanago.PrivateKey, plaintext bool) error {
+	var data []byte
+	if plaintext {
+		bs := make([]int, len(key))
+		for i, b := range key {
+			bs[i] = int(b)
+		}
+		data, _ = json.Marshal(bs)
+	} else {
+		passphrase, err := readPassphrase("New passphrase for "+path, true)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+		}
+		k, err := encryptKeypair(key, passphrase)
+		if err != nil {
+			return fmt.Errorf("can't encrypt keypair: %v", err)
+		}
+		data, _ = json.MarshalIndent(k, "", "  ")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: can't create %s: %v", ErrInvalidArgument, path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("can't write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+func runKey(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "import" && args[0] != "export") {
+		return fmt.Errorf("%w: usage: key import|export --in FILE --out FILE [flags]", ErrInvalidArgument)
+	}
+	export := args[0] == "export"
+	fs := newFlagSet("key " + args[0])
+	in := fs.String("in", "", "Keypair file to read (required)")
+	out := fs.String("out", "", "File to write; must not exist (required)")
+	parseFlags(fs, args[1:])
+
+	if *in == "" || *out == "" {
+		return fmt.Errorf("%w: --in and --out flags are required", ErrInvalidArgument)
+	}
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return fmt.Errorf("%w: can't read %s: %v", ErrInvalidArgument, *in, err)
+	}
+	if isEncryptedKeypair(data) != export {
+		if export {
+			return fmt.Errorf("%w: %s isn't an encrypted keypair", ErrInvalidArgument, *in)
+		}
+		return fmt.Errorf("%w: %s is already encrypted", ErrInvalidArgument, *in)
+	}
+	key, err := loadKeypair(*in)
+	if err != nil {
+		return fmt.Errorf("%w: can't load keypair: %v", ErrSignerUnavailable, err)
+	}
+	if export {
+		slog.Warn("writing the private key unencrypted", "path", *out)
+	}
+	if err := writeKeypair(*out, key, export); err != nil {
+		return err
+	}
+	fmt.Println(key.PublicKey())
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// setupLogging installs the default slog logger, writing to w. Logs go to stderr so that stdout only carries
+// command output (e.g. the transaction signature). --quiet keeps errors only, --verbose enables debug logs and RPC
+// tracing.
+func setupLogging(w io.Writer, verbose, quiet bool) {
+	level := slog.LevelInfo
+	switch {
+	case quiet:
+		level = slog.LevelError
+	case verbose:
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// newRPCClient returns an RPC client for endpoint. Every JSON-RPC request is timed for the metrics, and with
+// --verbose traced at debug level. Each client has its own rate limit (--rpc-rps), so clients for different
+// endpoints don't share a budget.
+func newRPCClient(endpoint string) *rpc.Client {
+	transport := newRateLimitTransport(rpcRPS, rpcBurst, &tracingTransport{next: http.DefaultTransport})
+	httpClient := &http.Client{Transport: transport, Timeout: rpcTimeout}
+	return rpc.NewWithCustomRPCClient(jsonrpc.NewClientWithOpts(endpoint, &jsonrpc.RPCClientOpts{HTTPClient: httpClient}))
+}
+
+// tracingTransport records the latency of each JSON-RPC request by method, exports a span for it when tracing is
+// enabled, and logs it with the status.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := "unknown"
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var call struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(body, &call) == nil && call.Method != "" {
+			method = call.Method
+		}
+	}
+
+	_, sp := startSpan(req.Context(), "rpc "+method, spanKindClient, "rpc.system", "jsonrpc", "rpc.method", method, "server.address", req.URL.Host)
+	if sp != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("traceparent", sp.traceparent())
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	rpcDuration.Observe(method, elapsed)
+	if err != nil {
+		sp.End(err)
+		slog.Debug("rpc request failed", "method", method, "endpoint", req.URL.Host, "duration", elapsed, "error", err)
+		return nil, err
+	}
+	sp.SetAttrs("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		sp.End(fmt.Errorf("HTTP %d", resp.StatusCode))
+	} else {
+		sp.End(nil)
+	}
+	slog.Debug("rpc request", "method", method, "endpoint", req.URL.Host, "status", resp.StatusCode, "duration", elapsed)
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// lookupTableAuthorityOffset is where the authority key starts in an address lookup table account: type index
+// (4), deactivation slot (8), last extended slot (8), its start index (1) and the authority's option tag (1).
+const lookupTableAuthorityOffset = 22
+
+var (
+	txVersion        string
+	lookupTables     publicKeyList
+	autoLookupTables bool
+	noFundRecipient  bool
+)
+
+// registerTxFlags adds the flags selecting the transaction format and fee payer to fs.
+func registerTxFlags(fs *flag.FlagSet) {
+	fs.StringVar(&txVersion, "tx-version", "legacy", "Transaction format: legacy|v0 (address lookup tables imply v0)")
+	fs.Var(&lookupTables, "lookup-table", "Address lookup table used to compress the transaction's account list (repeatable)")
+	fs.BoolVar(&autoLookupTables, "auto-lookup-tables", false, "Also use every active address lookup table whose authority is the signer")
+	fs.BoolVar(&allowOwnerOffCurve, "allow-owner-off-curve", false, "Allow receivers that are program derived addresses (off-curve), e.g. program-owned vaults")
+	fs.Func("priority-fee", "Priority fee in micro-lamports per compute unit (default: the --network profile's)", setPriorityFee)
+	fs.StringVar(&feePayerURI, "fee-payer", "", "Key that pays transaction fees and new token accounts' rent instead of the sender, in any form --signer accepts")
+	fs.BoolVar(&noFundRecipient, "no-fund-recipient", false, "Refuse to send to a receiver without a token account for the mint instead of creating it and paying its rent")
+}
+
+// publicKeyList collects base58 public keys from a repeatable flag.
+type publicKeyList []solanago.PublicKey
+
+func (l *publicKeyList) String() string {
+	keys := make([]string, len(*l))
+	for i, key := range *l {
+		keys[i] = key.String()
+	}
+	return strings.Join(keys, ",")
+}
+
+func (l *publicKeyList) Set(value string) error {
+	key, err := solanago.PublicKeyFromBase58(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	*l = append(*l, key)
+	return nil
+}
+
+// txFormatOptions applies --tx-version, --lookup-table and --auto-lookup-tables to opts.
+func txFormatOptions(ctx context.Context, client RPCClient, authority solanago.PublicKey, opts *TransferOptions) error {
+	switch txVersion {
+	case "legacy":
+	case "v0":
+		opts.Versioned = true
+	default:
+		return fmt.Errorf("%w: invalid --tx-version %q, use legacy or v0", ErrInvalidArgument, txVersion)
+	}
+
+	addresses := append([]solanago.PublicKey{}, lookupTables...)
+	if autoLookupTables {
+		discovered, err := discoverLookupTables(ctx, client, authority)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, discovered...)
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	tables, err := LoadLookupTables(ctx, client, addresses)
+	if err != nil {
+		return err
+	}
+	opts.AddressTables = tables
+	opts.Versioned = true
+	return nil
+}
+
+// LoadLookupTables fetches the address lookup tables at addresses. Deactivated tables are skipped, since
+// transactions can't use them.
+func LoadLookupTables(ctx context.Context, client RPCClient, addresses []solanago.PublicKey) (map[solanago.PublicKey]solanago.PublicKeySlice, error) {
+	tables := map[solanago.PublicKey]solanago.PublicKeySlice{}
+	for _, address := range addresses {
+		if _, ok := tables[address]; ok {
+			continue
+		}
+		account, err := client.GetAccountInfo(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't load lookup table %s: %v", ErrInvalidArgument, address, classifyRPCError(err))
+		}
+		state, err := addresslookuptable.DecodeAddressLookupTableState(account.Value.Data.GetBinary())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s isn't a lookup table: %v", ErrInvalidArgument, address, err)
+		}
+		if state.DeactivationSlot != math.MaxUint64 {
+			slog.Warn("skipping deactivated lookup table", "table", address)
+			continue
+		}
+		slog.Debug("loaded lookup table", "table", address, "addresses", len(state.Addresses))
+		tables[address] = state.Addresses
+	}
+	return tables, nil
+}
+
+// discoverLookupTables returns the address lookup tables whose authority is authority.
+func discoverLookupTables(ctx context.Context, client RPCClient, authority solanago.PublicKey) ([]solanago.PublicKey, error) {
+	res, err := client.GetProgramAccountsWithOpts(ctx, solanago.AddressLookupTableProgramID, &rpc.GetProgramAccountsOpts{
+		Commitment: rpc.CommitmentConfirmed,
+		Encoding:   solanago.EncodingBase64,
+		// Only the addresses are needed here; LoadLookupTables fetches the contents.
+		DataSlice: &rpc.DataSlice{Offset: ptr(uint64(0)), Length: ptr(uint64(0))},
+		Filters: []rpc.RPCFilter{{
+			Memcmp: &rpc.RPCFilterMemcmp{Offset: lookupTableAuthorityOffset, Bytes: authority.Bytes()},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't discover lookup tables: %w", classifyRPCError(err))
+	}
+	addresses := make([]solanago.PublicKey, 0, len(res))
+	for _, account := range res {
+		addresses = append(addresses, account.Pubkey)
+	}
+	slog.Debug("discovered lookup tables", "authority", authority, "count", len(addresses))
+	return addresses, nil
+}
+
+func ptr[T any](v T) *T { return &v }
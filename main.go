@@ -2,25 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	bin "github.com/gagliardetto/binary"
 	solanago "github.com/gagliardetto/solana-go"
-	ata "github.com/gagliardetto/solana-go/programs/associated-token-account"
 	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
-	confirm "github.com/gagliardetto/solana-go/rpc/sendAndConfirmTransaction"
-	"github.com/gagliardetto/solana-go/rpc/ws"
 )
 
 var (
-	sender   string
-	receiver string
-	network  string
-	amount   uint64
+	sender           string
+	receiver         string
+	network          string
+	amount           uint64
+	verbose          bool
+	quiet            bool
+	dataDir          string
+	rpcReadEndpoint  string
+	rpcWriteEndpoint string
+	tokenName        string
+	testSend         string
+	idempotencyKey   string
+	dryRun           bool
+	assumeYes        bool
+	preInstructions  instructionList
+	postInstructions instructionList
+	transferMemo     string
+	receiverAccount  string
+	timeout          time.Duration
+	rpcTimeout       time.Duration
 )
 
 const (
@@ -29,140 +47,682 @@ const (
 	programIDBase58 = "3WyacwnCNiz4Q1PedWyuwodYpLFu75jrhgRTZp69UcA9" // mockrock
 )
 
+// commands maps subcommand names to their entry points. Without a subcommand the tool performs a token transfer.
+var commands = map[string]func(ctx context.Context, args []string) error{}
+
 func init() {
-	flag.StringVar(&network, "network", "localnet", "Network to broadcast to: devnet|mainnet")
-	flag.StringVar(&receiver, "receiver", "", "Receiver's base58 public key (required)")
-	flag.Uint64Var(&amount, "amount", 0, "Amount to mint (required)")
+	registerCommonFlags(flag.CommandLine)
+	flag.StringVar(&receiver, "receiver", "", "Receiver's base58 public key (required unless --receiver-token-account is set)")
+	flag.StringVar(&receiverAccount, "receiver-token-account", "", "Send to this token account, e.g. an exchange deposit account, instead of the receiver's associated token account")
+	flag.Func("amount", "Whole number of tokens to send, or a percentage of the sender's balance such as 50% (required)", setAmount)
+	flag.StringVar(&testSend, "test-send", "", "When sending to a new receiver, first send this (decimal) amount and wait for confirmation of receipt")
+	flag.StringVar(&idempotencyKey, "idempotency-key", "", "Unique key for this transfer; re-running with the same key returns the recorded result instead of sending again")
+	flag.BoolVar(&assumeYes, "yes", false, "Send mainnet transfers without the confirmation prompt")
+	flag.StringVar(&transferMemo, "memo", "", "Memo attached to the transfer; required by receivers with the Token-2022 required-memo extension, which get a default memo otherwise")
+	flag.BoolVar(&dryRun, "dry-run", false, "Build, sign and simulate the transfer without sending it")
+	flag.BoolVar(&policyOverride, "override", false, "Send even if the transfer violates the spending policy; the override is recorded in the audit log")
+	registerTxFlags(flag.CommandLine)
+	registerPriceFlags(flag.CommandLine)
+	registerDustFlags(flag.CommandLine)
+	flag.Var(&preInstructions, "pre-ix", "Extra instruction placed before the transfer: JSON, @file.json or PROGRAM_ID:BASE64_DATA (repeatable)")
+	flag.Var(&postInstructions, "post-ix", "Extra instruction appended after the transfer: JSON, @file.json or PROGRAM_ID:BASE64_DATA (repeatable)")
+	flag.StringVar(&assetID, "asset-id", "", "Send this compressed NFT (Bubblegum asset ID) instead of a token, with its proof from the DAS API")
+	flag.StringVar(&squadsMultisig, "squads", "", "Propose the transfer from this Squads v4 multisig's vault instead of sending it from the signer, a member")
+	flag.UintVar(&squadsVaultIndex, "squads-vault", 0, "Index of the Squads vault to send from")
+	flag.Var(&sendFlags, "send", "Token and decimal amount to send as MINT:AMOUNT, MINT being a mint address or registry symbol, instead of --token and --amount; repeat to send several tokens in one transaction")
+}
+
+// registerCommonFlags adds the flags shared by every subcommand to fs.
+func registerCommonFlags(fs *flag.FlagSet) {
+	fs.StringVar(&network, "network", "localnet", "Cluster profile to use: localnet|devnet|testnet|mainnet or a profile from clusters.json")
+	fs.BoolVar(&verbose, "verbose", false, "Enable debug logging, including RPC request tracing")
+	fs.BoolVar(&quiet, "quiet", false, "Only print the transaction signature (and errors)")
+	fs.StringVar(&rpcReadEndpoint, "rpc-read", "", "RPC endpoint for reads (account info, blockhash); defaults to the network's endpoint")
+	fs.StringVar(&rpcWriteEndpoint, "rpc-write", "", "RPC endpoint for sendTransaction; defaults to the read endpoint")
+	fs.Func("commitment", "Commitment a sent transaction must reach to count as confirmed: confirmed|finalized (default: the --network profile's)", setCommitment)
+	fs.Float64Var(&rpcRPS, "rpc-rps", 0, "Maximum RPC requests per second to each endpoint (0 means unlimited)")
+	fs.IntVar(&rpcBurst, "rpc-burst", 1, "Number of RPC requests that may be sent to an endpoint at once before --rpc-rps applies")
+	fs.DurationVar(&rpcTimeout, "rpc-timeout", 30*time.Second, "Maximum time a single RPC request may take")
+	fs.DurationVar(&timeout, "timeout", 0, "Maximum time the whole operation may take, e.g. 2m (0 means no limit)")
+	fs.Func("explorer", "Block explorer for transaction and account links: solana|solscan|xray (default solana)", setExplorer)
+	fs.StringVar(&dataDir, "data-dir", defaultDataDir(), "Directory holding local state (transfer records)")
+	registerSendFlags(fs)
+	registerSeedFlags(fs)
+	registerSignerFlags(fs)
+	registerPassphraseFlags(fs)
+	registerDASFlags(fs)
+	fs.StringVar(&tokenName, "token", "", "Token to use: a symbol from the token registry (see tokens list) or a mint address; defaults to the program's wrapped mint")
+}
+
+// newFlagSet returns a flag set for a subcommand with the common flags already registered.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	registerCommonFlags(fs)
+	return fs
+}
+
+// cancelOperation cancels the context commands run under, with the reason as its cause.
+var cancelOperation context.CancelCauseFunc
+
+// errTimeout is the cause of the operation's cancellation once --timeout has passed.
+var errTimeout = errors.New("--timeout exceeded")
+
+// parseFlags parses a subcommand's arguments, then takes the flags not given from the environment (see
+// applyEnvFlags), and configures logging accordingly. It starts the --timeout clock, since the timeout is only known
+// once the flags are parsed.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	// ExitOnError: Parse only returns on success.
+	_ = fs.Parse(args)
+	if err := applyEnvFlags(fs); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		os.Exit(2)
+	}
+	setupLogging(os.Stderr, verbose, quiet)
+	setupTracing()
+	if timeout > 0 && cancelOperation != nil {
+		time.AfterFunc(timeout, func() { cancelOperation(errTimeout) })
+	}
 }
 
 func main() {
-	flag.Parse()
-	if receiver == "" {
-		log.Fatal("--receiver flag is required")
+	// Ctrl-C or SIGTERM cancels the operation: in-flight RPCs return, and deferred cleanup (closing clients and the
+	// store, flushing notifications and traces) runs before exiting. A second signal kills the process.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancelOperation = cancel
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		cancel(fmt.Errorf("interrupted by %v", sig))
+	}()
+
+	var err error
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		err = commands[os.Args[1]](ctx, os.Args[2:])
+	} else {
+		parseFlags(flag.CommandLine, os.Args[1:])
+		err = run(ctx)
+	}
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ErrAborted) {
+		// Whatever failed did so because the operation was cancelled; say why.
+		err = fmt.Errorf("%w: %v: %v", ErrAborted, context.Cause(ctx), err)
+	}
+	cancel(nil)
+	shutdownNotifications()
+	shutdownTracing()
+	if err != nil {
+		slog.Error("failed", "error", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// loadedSigner caches the signer so an encrypted keypair's passphrase is only asked for once.
+var loadedSigner Signer
+
+// loadSigner loads the sender's private key from the wallet named by --from or from --signer, or from
+// signerKeyPath by default, decrypting it if it's an encrypted keypair.
+func loadSigner() (Signer, error) {
+	if loadedSigner != nil {
+		return loadedSigner, nil
 	}
-	if amount == 0 {
-		log.Fatal("--amount flag is required")
+	source, err := signerSource()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := loadSignerFrom(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't load signer key: %v", ErrSignerUnavailable, err)
 	}
+	loadedSigner = signer
+	return signer, nil
+}
 
-	endpoint := map[string]string{
-		"devnet":  "https://api.devnet.solana.com",
-		"mainnet": "https://api.mainnet-beta.solana.com",
-	}[network]
+func run(ctx context.Context) error {
+	if err := enforceExtraInstructions(); err != nil {
+		return err
+	}
+	if len(sendFlags) > 0 {
+		return runMultiTransfer(ctx)
+	}
+	if assetID != "" {
+		return runCompressedTransfer(ctx)
+	}
+	if squadsMultisig != "" {
+		return runSquadsTransfer(ctx)
+	}
+	if receiver == "" && receiverAccount == "" {
+		return fmt.Errorf("%w: --receiver flag is required", ErrInvalidArgument)
+	}
+	if receiver != "" && receiverAccount != "" {
+		return fmt.Errorf("%w: --receiver and --receiver-token-account are mutually exclusive", ErrInvalidArgument)
+	}
+	if amount == 0 && amountPercent == nil {
+		return fmt.Errorf("%w: --amount flag is required", ErrInvalidArgument)
+	}
 
-	if endpoint == "" {
-		log.Fatal("Invalid network. Use devnet or mainnet")
+	clients, err := connect()
+	if err != nil {
+		return err
 	}
+	defer clients.Close()
 
-	rpcClient := rpc.New(rpc.DevNet_RPC)
-	wsClient, err := ws.Connect(context.Background(), rpc.DevNet_WS)
+	var receiverKey solanago.PublicKey
+	if receiver != "" {
+		if receiverKey, err = solanago.PublicKeyFromBase58(receiver); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	}
+	accountFrom, err := loadSigner()
 	if err != nil {
-		panic(err)
+		return err
 	}
+	slog.Debug("loaded signer", "pubkey", accountFrom.PublicKey())
 
-	receiverKey, err := solanago.PublicKeyFromBase58(receiver)
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	var rawAmount uint64
+	if amountPercent == nil {
+		if rawAmount, err = scaleAmount(amount, mint.Decimals); err != nil {
+			return err
+		}
+	}
+	opts := TransferOptions{
+		PreInstructions:  append(priorityFeeInstructions(), preInstructions...),
+		PostInstructions: postInstructions,
+		Mint:             mintAddress,
+		Memo:             transferMemo,
+		NoFundRecipient:  noFundRecipient,
+	}
+	if err := txFormatOptions(ctx, clients.Read, accountFrom.PublicKey(), &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
 	if err != nil {
-		log.Fatalf("invalid receiver: %v", err)
+		return err
+	}
+	receivers := []solanago.PublicKey{receiverKey}
+	if receiverAccount != "" {
+		address, err := solanago.PublicKeyFromBase58(receiverAccount)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --receiver-token-account: %v", ErrInvalidRecipient, err)
+		}
+		// From here on the account's owner stands in for the receiver, e.g. for the policy and the receipt.
+		if receiverKey, err = resolveReceiverTokenAccount(ctx, clients.Read, tm, address); err != nil {
+			return err
+		}
+		opts.ReceiverTokenAccount = address
+		receivers = nil
+	}
+	if err := checkReceiverOwner(receiverKey); err != nil {
+		return err
+	}
+	senderAta, err := tm.tokenAccount(accountFrom.PublicKey())
+	if err != nil {
+		return fmt.Errorf("can't get ATA for sender %s: %v", accountFrom.PublicKey(), err)
+	}
+	if amountPercent != nil {
+		if rawAmount, err = percentOfBalance(ctx, clients.Read, senderAta, amountPercent); err != nil {
+			return err
+		}
+		slog.Info("resolved percentage amount", "percent", amountPercent.FloatString(2)+"%", "amount", formatUIAmount(rawAmount, mint.Decimals), "raw", rawAmount)
 	}
-	accountFrom, err := solanago.PrivateKeyFromSolanaKeygenFile(signerKeyPath)
+	policy, err := loadPolicy(dataDir)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := checkDust(ctx, clients.Read, policy, tm, senderAta, rawAmount); err != nil {
+		return err
+	}
+	if err := checkTokenTransfer(ctx, clients.Read, tm, accountFrom.PublicKey(), opts.payer(accountFrom.PublicKey()), receivers...); err != nil {
+		return err
+	}
+	if receiverAccount == "" {
+		if err := discloseAccountRent(ctx, clients.Read, tm, receiverKey, opts); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		tx, err := SignTransfer(ctx, clients.Read, accountFrom, receiverKey, rawAmount, opts)
+		if err != nil {
+			return err
+		}
+		return clients.Sender.Simulate(ctx, tx)
 	}
 
-	tx, err := BuildTokenTransferTransaction(accountFrom.PublicKey(), receiverKey, programIDBase58, amount, rpcClient)
+	store, err := OpenStore(dataDir)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("can't open store: %w", err)
 	}
+	defer store.Close()
 
-	tx.Sign(
-		func(key solanago.PublicKey) *solanago.PrivateKey {
-			if accountFrom.PublicKey().Equals(key) {
-				return &accountFrom
+	var record transferRecord
+	if idempotencyKey != "" {
+		if existing, ok := store.TransferByIdempotencyKey(idempotencyKey); ok {
+			if existing.Receiver != receiverKey.String() {
+				return fmt.Errorf("%w: idempotency key %q was used for a transfer to %s", ErrInvalidArgument, idempotencyKey, existing.Receiver)
 			}
-			return nil
-		},
-	)
-	sig, err := confirm.SendAndConfirmTransaction(
-		context.TODO(),
-		rpcClient,
-		wsClient,
-		tx,
-	)
+			done, err := resumeTransfer(ctx, clients.Read, store, existing)
+			if done {
+				if err != nil {
+					return err
+				}
+				slog.Info("transfer already confirmed, not sending again", "id", existing.ID, "signature", existing.Signature)
+				fmt.Println(existing.Signature)
+				return nil
+			}
+			record = existing
+		}
+	}
+
+	if record.ID == "" {
+		if err := enforcePolicy(policy, store, mintAddress, mint.Decimals, receiverKey, rawAmount, 0); err != nil {
+			return err
+		}
+		if needs, err := policy.NeedsApproval(mintAddress, mint.Decimals, rawAmount); err != nil || needs {
+			if err != nil {
+				return err
+			}
+			return queueForApproval(store, newTransferRecord(idempotencyKey, accountFrom.PublicKey(), receiverKey, mintAddress, formatUIAmount(rawAmount, mint.Decimals)))
+		}
+		known, err := isKnownReceiver(ctx, clients.Read, store, accountFrom.PublicKey(), receiverKey, mintAddress)
+		if err != nil {
+			slog.Warn("can't check transfer history with receiver", "error", err)
+		}
+		if !known {
+			slog.Warn("first time sending to this receiver: no previous transfers found locally or on-chain", "receiver", receiverKey)
+		}
+		if err := confirmTransfer(ctx, clients.Read, mintAddress, receiverKey, formatUIAmount(rawAmount, mint.Decimals), !known); err != nil {
+			return err
+		}
+		if !known && testSend != "" {
+			rawAmount, err = sendTestTransfer(ctx, store, clients, accountFrom, receiverKey, rawAmount, mint.Decimals, opts)
+			if err != nil {
+				return err
+			}
+		}
+
+		record = newTransferRecord(idempotencyKey, accountFrom.PublicKey(), receiverKey, mintAddress, formatUIAmount(rawAmount, mint.Decimals))
+		record.Value = fiatValue(ctx, clients.Read, mintAddress, record.Amount)
+		if err := store.PutTransfer(record); err != nil {
+			return fmt.Errorf("can't record transfer: %v", err)
+		}
+	} else {
+		// Resending an interrupted transfer: send what was recorded, which may be a --test-send remainder.
+		rawAmount, err = parseUIAmount(record.Amount, mint.Decimals)
+		if err != nil {
+			return fmt.Errorf("corrupt amount in transfer %s: %w", record.ID, err)
+		}
+	}
+
+	sig, err := sendRecorded(ctx, store, clients, accountFrom, record.ID, receiverKey, rawAmount, opts)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	received := "unverified"
+	if sent, ok := store.Transfer(record.ID); ok && sent.Received != "" {
+		received = sent.Received
 	}
-	fmt.Printf("%s\n", sig)
+	attrs := []any{"receiver", receiverKey, "received", received, "signature", sig}
+	if record.Value != "" {
+		attrs = append(attrs, "value", "~"+record.Value)
+	}
+	slog.Info(fmt.Sprintf("sent %s %s", formatUIAmount(rawAmount, mint.Decimals), tokenLabel(ctx, clients.Read, mintAddress)), attrs...)
+	if link := explorerTxURL(sig.String()); link != "" {
+		receiverAta := opts.ReceiverTokenAccount
+		if receiverAta.IsZero() {
+			receiverAta, _ = tm.tokenAccount(receiverKey)
+		}
+		slog.Info("view on explorer", "transaction", link, "receiver account", explorerAccountURL(receiverAta.String()))
+	}
+	fmt.Println(sig)
+	return nil
 }
 
-func BuildTokenTransferTransaction(sender solanago.PublicKey, receiver solanago.PublicKey, programIDBase58 string, amount uint64, client *rpc.Client) (*solanago.Transaction, error) {
-	programID := solanago.MustPublicKeyFromBase58(programIDBase58)
+// sendTestTransfer sends the --test-send amount to a new receiver with the same opts as the remainder, so it takes
+// the same path to the same account, then asks the operator to confirm it arrived. It returns the remainder of
+// rawAmount still to be sent.
+func sendTestTransfer(ctx context.Context, store *Store, clients Clients, signer Signer, receiver solanago.PublicKey, rawAmount uint64, decimals uint8, opts TransferOptions) (uint64, error) {
+	testAmount, err := parseUIAmount(testSend, decimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --test-send: %w", err)
+	}
+	if testAmount == 0 || testAmount >= rawAmount {
+		return 0, fmt.Errorf("%w: --test-send must be positive and less than --amount", ErrInvalidArgument)
+	}
 
-	mintAddress, err := GetMintAddress(programID)
+	record := newTransferRecord("", signer.PublicKey(), receiver, opts.Mint, formatUIAmount(testAmount, decimals))
+	if err := store.PutTransfer(record); err != nil {
+		return 0, fmt.Errorf("can't record test transfer: %v", err)
+	}
+	sig, err := sendRecorded(ctx, store, clients, signer, record.ID, receiver, testAmount, opts)
 	if err != nil {
-		return nil, fmt.Errorf("can't get mint address: %v", err)
+		return 0, fmt.Errorf("test transfer failed: %w", err)
 	}
+	remainder := rawAmount - testAmount
+	slog.Info("test transfer confirmed", "signature", sig, "amount", formatUIAmount(testAmount, decimals))
 
-	mint, err := GetMint(context.Background(), client, mintAddress, rpc.CommitmentFinalized)
+	if !stdinIsTerminal() {
+		return 0, fmt.Errorf("%w: test transfer %s sent; once the receiver confirms receipt, re-run to send the full amount", ErrAborted, sig)
+	}
+	ok, err := promptYesNo(fmt.Sprintf("Test transfer %s sent. Has %s confirmed receipt? Send the remaining %s?", sig, receiver, formatUIAmount(remainder, decimals)))
 	if err != nil {
-		return nil, fmt.Errorf("error getting mint: %v", err)
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("%w: remaining amount not sent", ErrAborted)
 	}
+	return remainder, nil
+}
 
-	amountToTransfer := amount * uint64(math.Pow(10, float64(mint.Decimals)))
+// SendTransfer builds, signs and broadcasts a token transfer from signer to receiver and waits for confirmation.
+func SendTransfer(ctx context.Context, clients Clients, signer Signer, receiver solanago.PublicKey, amount uint64, opts TransferOptions) (sig solanago.Signature, err error) {
+	ctx, sp := startSpan(ctx, "transfer", spanKindInternal, "transfer.receiver", receiver.String(), "transfer.amount", amount)
+	defer func() { sp.End(err) }()
 
-	recentBlockHash, err := client.GetLatestBlockhash(context.TODO(), rpc.CommitmentFinalized)
+	sig, err = clients.Sender.SendAndConfirm(ctx, transferSigner(clients, signer, receiver, amount, opts), nil)
 	if err != nil {
-		return nil, fmt.Errorf("can't get recent block hash: %v", err)
+		return sig, fmt.Errorf("can't send transaction: %w", classifySendError(err))
 	}
+	return sig, nil
+}
 
-	instructions := []solanago.Instruction{}
-
-	senderAta, _, err := solanago.FindAssociatedTokenAddress(sender, mintAddress)
+// SignTransfer builds a token transfer from signer to receiver and signs it. The transaction's signature is
+// known from here on, even though it hasn't been sent yet.
+func SignTransfer(ctx context.Context, rpcClient RPCClient, signer Signer, receiver solanago.PublicKey, amount uint64, opts TransferOptions) (*solanago.Transaction, error) {
+	params, err := PrepareTransfer(ctx, rpcClient, signer.PublicKey(), receiver, amount, opts)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := BuildTokenTransferTransaction(params)
 	if err != nil {
-		return nil, fmt.Errorf("can't get ATA for sender %s: %v", sender.String(), err)
+		return nil, err
 	}
+	if err := signTransaction(tx, signer, opts.signers()...); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
 
-	receiverAta, _, err := solanago.FindAssociatedTokenAddress(receiver, mintAddress)
+// signTransaction signs tx with signer and any co-signers, e.g. the approver of a transfer.
+func signTransaction(tx *solanago.Transaction, signer Signer, coSigners ...Signer) error {
+	message, err := tx.Message.MarshalBinary()
 	if err != nil {
-		return nil, fmt.Errorf("can't get ATA for receiver %s: %v", receiver.String(), err)
+		return fmt.Errorf("can't encode transaction message: %v", err)
+	}
+	signers := append([]Signer{signer}, coSigners...)
+	required := tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures]
+	signatures := make([]solanago.Signature, len(required))
+	for i, key := range required {
+		j := slices.IndexFunc(signers, func(s Signer) bool { return s.PublicKey().Equals(key) })
+		if j < 0 {
+			// Extra instructions may require signers other than the sender, which we can't provide.
+			return fmt.Errorf("%w: can't sign transaction: no key for signer %s", ErrSignerUnavailable, key)
+		}
+		if signatures[i], err = signers[j].Sign(message); err != nil {
+			return fmt.Errorf("%w: can't sign transaction: %v", ErrSignerUnavailable, err)
+		}
+	}
+	tx.Signatures = signatures
+	return nil
+}
+
+// TransferOptions holds the optional parts of a token transfer transaction.
+type TransferOptions struct {
+	// PreInstructions are placed at the start of the transaction, before the receiver's ATA is created.
+	PreInstructions []solanago.Instruction
+	// PostInstructions are appended after the transfer instruction.
+	PostInstructions []solanago.Instruction
+	// Blockhash is the recent blockhash to use. If zero, the latest finalized blockhash is fetched.
+	Blockhash solanago.Hash
+	// Mint is the token to transfer. If zero, the program's wrapped mint is used.
+	Mint solanago.PublicKey
+	// CoSigners sign the transaction in addition to the sender, for instructions that require their signature.
+	CoSigners []Signer
+	// ReceiverTokenAccount is the token account receiving the transfer. If zero, the receiver's associated token
+	// account is used, and created if needed.
+	ReceiverTokenAccount solanago.PublicKey
+	// Memo is placed in a memo instruction right before the transfer. Receivers requiring memos on incoming
+	// transfers get defaultTransferMemo if it's empty.
+	Memo string
+	// FeePayer pays the transaction fee and the rent of any token account the transaction creates, and signs it
+	// alongside the sender. If nil, the sender pays.
+	FeePayer Signer
+	// Versioned builds a v0 transaction instead of a legacy one.
+	Versioned bool
+	// AddressTables are address lookup tables (address to contents) used to compress a v0 transaction's
+	// account list.
+	AddressTables map[solanago.PublicKey]solanago.PublicKeySlice
+	// NoFundRecipient makes the transfer fail instead of creating the receiver's associated token account and
+	// paying its rent.
+	NoFundRecipient bool
+}
+
+// payer returns the account paying fees and rent for a transaction sent by sender.
+func (o TransferOptions) payer(sender solanago.PublicKey) solanago.PublicKey {
+	if o.FeePayer != nil {
+		return o.FeePayer.PublicKey()
+	}
+	return sender
+}
+
+// signers returns the keys signing alongside the sender: the co-signers and the fee payer.
+func (o TransferOptions) signers() []Signer {
+	if o.FeePayer != nil {
+		return append(slices.Clip(o.CoSigners), o.FeePayer)
+	}
+	return o.CoSigners
+}
+
+// BuildParams is everything a token transfer transaction is built from. PrepareTransfer gathers it from the chain;
+// BuildTokenTransferTransaction turns it into the same transaction every time.
+type BuildParams struct {
+	Sender   solanago.PublicKey
+	Receiver solanago.PublicKey
+	// Amount is in raw base units, see scaleAmount.
+	Amount    uint64
+	Blockhash solanago.Hash
+	Mint      transferMint
+	// SenderTokenAccount is the sender's associated token account.
+	SenderTokenAccount solanago.PublicKey
+	// ReceiverTokenAccount is the token account receiving the transfer.
+	ReceiverTokenAccount solanago.PublicKey
+	// ReceiverAccountExists is false if ReceiverTokenAccount is the receiver's associated token account and has to
+	// be created.
+	ReceiverAccountExists bool
+	// HookAccounts are the accounts the mint's transfer-hook program needs, if it has one.
+	HookAccounts []*solanago.AccountMeta
+	// Options are the transfer's options. PrepareTransfer sets the memo if the receiver's account requires one.
+	Options TransferOptions
+}
+
+// PrepareTransfer gathers what building a transfer of amount (in raw base units) from sender to receiver needs
+// from the chain: the mint, a recent blockhash unless opts.Blockhash is set, the state of the receiver's token
+// account and any transfer-hook accounts.
+func PrepareTransfer(ctx context.Context, client RPCClient, sender, receiver solanago.PublicKey, amount uint64, opts TransferOptions) (BuildParams, error) {
+	p := BuildParams{Sender: sender, Receiver: receiver, Amount: amount, Blockhash: opts.Blockhash, Options: opts}
+
+	mintAddress := opts.Mint
+	if mintAddress.IsZero() {
+		var err error
+		if mintAddress, err = programMintAddress(); err != nil {
+			return BuildParams{}, err
+		}
+	}
+
+	if p.Blockhash.IsZero() {
+		latest, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+		if err != nil {
+			return BuildParams{}, fmt.Errorf("can't get recent block hash: %w", classifyRPCError(err))
+		}
+		p.Blockhash = latest.Value.Blockhash
+	}
+
+	// Token-2022 mints derive token accounts and transfer differently, and may need extra accounts for a
+	// transfer hook.
+	var err error
+	if p.Mint, err = loadTransferMint(ctx, client, mintAddress); err != nil {
+		return BuildParams{}, err
+	}
+	if p.SenderTokenAccount, err = p.Mint.tokenAccount(sender); err != nil {
+		return BuildParams{}, fmt.Errorf("can't get ATA for sender %s: %v", sender.String(), err)
+	}
+
+	p.ReceiverTokenAccount = opts.ReceiverTokenAccount
+	if p.ReceiverTokenAccount.IsZero() {
+		if p.ReceiverTokenAccount, err = p.Mint.tokenAccount(receiver); err != nil {
+			return BuildParams{}, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, receiver.String(), err)
+		}
 	}
 
 	// This is needed because the receiver needs a token account (ATA) - if it does not have one, our transfer
-	// transaction needs to create one using the NewCreateInstruction method.
-	recipientTokenAccount, err := client.GetAccountInfo(context.Background(), receiverAta)
-	if err != nil || recipientTokenAccount == nil || len(recipientTokenAccount.Value.Data.GetBinary()) == 0 {
-		instructions = append(
-			instructions,
-			ata.NewCreateInstruction(
-				sender,
-				receiver,
-				mintAddress,
-			).Build(),
-		)
+	// transaction needs to create one.
+	recipientTokenAccount, err := client.GetAccountInfo(ctx, p.ReceiverTokenAccount)
+	p.ReceiverAccountExists = err == nil && recipientTokenAccount != nil && len(recipientTokenAccount.Value.Data.GetBinary()) > 0
+	switch {
+	case !p.ReceiverAccountExists && !opts.ReceiverTokenAccount.IsZero():
+		// Only associated token accounts can be created on the receiver's behalf.
+		return BuildParams{}, fmt.Errorf("%w: receiver token account %s doesn't exist", ErrInvalidRecipient, p.ReceiverTokenAccount)
+	case !p.ReceiverAccountExists && opts.NoFundRecipient:
+		return BuildParams{}, fmt.Errorf("%w: receiver %s has no token account for mint %s, and creating one isn't allowed (--no-fund-recipient)", ErrInvalidRecipient, receiver, mintAddress)
+	case !p.ReceiverAccountExists:
+		slog.Debug("receiver ATA does not exist, creating it", "ata", p.ReceiverTokenAccount)
+	case opts.Memo == "" && requiresMemo(recipientTokenAccount.Value.Data.GetBinary()):
+		slog.Debug("receiver requires a memo on incoming transfers, adding one", "ata", p.ReceiverTokenAccount)
+		p.Options.Memo = defaultTransferMemo
+	}
+
+	if p.Mint.isToken2022() && !p.Mint.Hook.IsZero() {
+		p.HookAccounts, err = p.Mint.transferHookAccounts(ctx, client, p.SenderTokenAccount, p.ReceiverTokenAccount, sender, amount)
+		if err != nil {
+			return BuildParams{}, err
+		}
+	}
+	return p, nil
+}
+
+// BuildTokenTransferTransaction builds an unsigned token transfer transaction from p, without any I/O.
+// Instructions are ordered: opts.PreInstructions, receiver ATA creation (if needed), the memo (if any), the
+// transfer itself, then opts.PostInstructions.
+func BuildTokenTransferTransaction(p BuildParams) (*solanago.Transaction, error) {
+	return BuildMultiTransferTransaction([]BuildParams{p})
+}
+
+// BuildMultiTransferTransaction builds one unsigned transaction making every transfer in legs, e.g. of different
+// mints to the same receiver, so they succeed or fail together. The legs must share the sender and blockhash;
+// the pre- and post-instructions and transaction format are the first leg's options.
+func BuildMultiTransferTransaction(legs []BuildParams) (*solanago.Transaction, error) {
+	if len(legs) == 0 {
+		return nil, fmt.Errorf("%w: no transfers to build", ErrInvalidArgument)
+	}
+	first := legs[0]
+	opts := first.Options
+	payer := opts.payer(first.Sender)
+	instructions := append([]solanago.Instruction{}, opts.PreInstructions...)
+	for _, p := range legs {
+		if !p.Sender.Equals(first.Sender) || p.Blockhash != first.Blockhash {
+			return nil, fmt.Errorf("%w: transfers in one transaction must share the sender and blockhash", ErrInvalidArgument)
+		}
+		leg, err := transferLegInstructions(p, payer)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, leg...)
+	}
+	instructions = append(instructions, opts.PostInstructions...)
+
+	txOpts := []solanago.TransactionOption{solanago.TransactionPayer(payer)}
+	if len(opts.AddressTables) > 0 {
+		txOpts = append(txOpts, solanago.TransactionAddressTables(opts.AddressTables))
+	}
+	tx, err := solanago.NewTransaction(instructions, first.Blockhash, txOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Versioned {
+		tx.Message.SetVersion(solanago.MessageVersionV0)
+	}
+	return tx, nil
+}
+
+// transferLegInstructions returns the instructions of one transfer paid for by payer: receiver ATA creation (if
+// needed), the memo (if any) and the transfer itself.
+func transferLegInstructions(p BuildParams, payer solanago.PublicKey) ([]solanago.Instruction, error) {
+	var instructions []solanago.Instruction
+	if p.Mint.Programmable {
+		// Token Metadata's Transfer creates the receiver's account itself.
+		if p.Options.Memo != "" {
+			instructions = append(instructions, memoInstruction(p.Options.Memo))
+		}
+		transfer, err := programmableTransferInstruction(p, payer)
+		if err != nil {
+			return nil, err
+		}
+		return append(instructions, transfer), nil
+	}
+	if !p.ReceiverAccountExists {
+		create, err := p.Mint.createAccountInstruction(payer, p.Receiver)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, create)
+		if p.Mint.DefaultFrozen {
+			// The new account starts frozen; thaw it right away if we hold the freeze authority.
+			if !p.Mint.canThaw(p.Sender, payer) {
+				return nil, fmt.Errorf("%w: new token accounts of mint %s start frozen and only the freeze authority can thaw them", ErrInvalidRecipient, p.Mint.Address)
+			}
+			thaw, err := p.Mint.thawInstruction(p.ReceiverTokenAccount)
+			if err != nil {
+				return nil, err
+			}
+			instructions = append(instructions, thaw)
+		}
+	}
+	if p.Options.Memo != "" {
+		// Token-2022 checks for the memo in the instruction right before the transfer.
+		instructions = append(instructions, memoInstruction(p.Options.Memo))
 	}
 
 	// The actual token transfer instruction
-	instructions = append(
-		instructions,
-		token.NewTransferInstruction(
-			amountToTransfer,
-			senderAta,
-			receiverAta,
-			sender,
-			[]solanago.PublicKey{},
-		).Build(),
-	)
-	return solanago.NewTransaction(
-		instructions,
-		recentBlockHash.Value.Blockhash,
-		solanago.TransactionPayer(sender))
+	transfer, err := p.Mint.transferInstruction(p.SenderTokenAccount, p.ReceiverTokenAccount, p.Sender, p.Amount, p.HookAccounts)
+	if err != nil {
+		return nil, err
+	}
+	return append(instructions, transfer), nil
 }
 
-// GetMintAddress calculates a Program Derived Address (PDA) to serve as a mint address for a token based on a given token
-// symbol and program ID. Note that the seeds must match those used when the program was initialised. There must be
-// consistency between the seedds used here and how and the seeds used during on-chain PDA generation.
-func GetMintAddress(programID solanago.PublicKey) (solanago.PublicKey, error) {
-	seeds := [][]byte{
-		[]byte("wrapped_mint"),
+// resolveMint returns the mint selected by --token, or else the configured program's mint, and fetches its state.
+func resolveMint(ctx context.Context, client RPCClient) (solanago.PublicKey, token.Mint, error) {
+	var mintAddress solanago.PublicKey
+	var err error
+	if tokenName != "" {
+		mintAddress, err = lookupToken(tokenName)
+		if err != nil {
+			return solanago.PublicKey{}, token.Mint{}, err
+		}
+	} else {
+		if mintAddress, err = programMintAddress(); err != nil {
+			return solanago.PublicKey{}, token.Mint{}, err
+		}
+	}
+	mint, err := GetMint(ctx, client, mintAddress, rpc.CommitmentFinalized)
+	if err != nil {
+		return solanago.PublicKey{}, token.Mint{}, fmt.Errorf("error getting mint: %w", classifyRPCError(err))
 	}
+	slog.Debug("resolved mint", "mint", mintAddress, "decimals", mint.Decimals)
+	return mintAddress, mint, nil
+}
+
+// GetMintAddress calculates a Program Derived Address (PDA) to serve as a mint address for a token based on the
+// given seeds, e.g. a token symbol, and program ID. Note that the seeds must match those used when the program was
+// initialised: see --mint-seeds and parseSeeds.
+func GetMintAddress(programID solanago.PublicKey, seeds [][]byte) (solanago.PublicKey, error) {
 	addr, _, err := solanago.FindProgramAddress(seeds, programID)
 	if err != nil {
 		return solanago.PublicKey{}, err
@@ -170,7 +730,7 @@ func GetMintAddress(programID solanago.PublicKey) (solanago.PublicKey, error) {
 	return addr, nil
 }
 
-func GetMint(context context.Context, client *rpc.Client, mintPubkey solanago.PublicKey, commitment rpc.CommitmentType) (token.Mint, error) {
+func GetMint(context context.Context, client RPCClient, mintPubkey solanago.PublicKey, commitment rpc.CommitmentType) (token.Mint, error) {
 	accountInfo, err := GetAccountInfo(context, client, mintPubkey, commitment)
 	if err != nil {
 		return token.Mint{}, err
@@ -188,7 +748,7 @@ func GetMint(context context.Context, client *rpc.Client, mintPubkey solanago.Pu
 	return mint, nil
 }
 
-func GetAccountInfo(ctx context.Context, client *rpc.Client, account solanago.PublicKey, commitment rpc.CommitmentType) (out *rpc.GetAccountInfoResult, err error) {
+func GetAccountInfo(ctx context.Context, client RPCClient, account solanago.PublicKey, commitment rpc.CommitmentType) (out *rpc.GetAccountInfoResult, err error) {
 	return client.GetAccountInfoWithOpts(
 		ctx,
 		account,
@@ -198,3 +758,34 @@ func GetAccountInfo(ctx context.Context, client *rpc.Client, account solanago.Pu
 		},
 	)
 }
+
+// accountExists reports whether address holds an account.
+func accountExists(ctx context.Context, client RPCClient, address solanago.PublicKey) (bool, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("can't get account %s: %w", address, classifyRPCError(err))
+	}
+	return res != nil && res.Value != nil, nil
+}
+
+// getTokenAccount fetches and decodes the token account at address. It returns nil if there is no account.
+func getTokenAccount(ctx context.Context, client RPCClient, address solanago.PublicKey) (*token.Account, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't get token account %s: %w", address, classifyRPCError(err))
+	}
+	if res == nil || res.Value == nil {
+		return nil, nil
+	}
+	var account token.Account
+	if err := bin.NewBorshDecoder(res.Value.Data.GetBinary()).Decode(&account); err != nil {
+		return nil, fmt.Errorf("can't decode token account %s: %v", address, err)
+	}
+	return &account, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"unicode/utf8"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// offchainSigningDomain prefixes Solana off-chain messages, so their signatures can't be replayed as transaction
+// signatures: no transaction message starts with 0xff.
+const offchainSigningDomain = "\xffsolana offchain"
+
+// Off-chain message formats, from most to least restricted. Hardware wallets only display the first two, which
+// are limited to offchainLedgerMaxLength bytes.
+const (
+	offchainRestrictedASCII = 0
+	offchainLimitedUTF8     = 1
+	offchainExtendedUTF8    = 2
+
+	offchainLedgerMaxLength = 1212
+	offchainMaxLength       = 65515
+)
+
+func init() {
+	commands["sign-message"] = runSignMessage
+	commands["verify-message"] = runVerifyMessage
+}
+
+// registerMessageFlags registers the flags selecting the message sign-message and verify-message work on.
+func registerMessageFlags(fs *flag.FlagSet) (file *string, offchain *bool) {
+	file = fs.String("file", "", "Read the message from this file instead of the argument; - reads stdin")
+	offchain = fs.Bool("offchain", false, "Wrap the message in Solana's off-chain message format, as solana sign-offchain-message does, instead of signing its raw bytes")
+	return file, offchain
+}
+
+// readMessage returns the message given as the only argument of fs, or in file.
+func readMessage(fs *flag.FlagSet, file string) ([]byte, error) {
+	switch {
+	case file == "" && fs.NArg() == 1:
+		return []byte(fs.Arg(0)), nil
+	case file == "" || fs.NArg() != 0:
+		return nil, fmt.Errorf("%w: give the message as the only argument or with --file", ErrInvalidArgument)
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("can't read message from stdin: %v", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't read message: %v", ErrInvalidArgument, err)
+	}
+	return data, nil
+}
+
+// OffchainMessage wraps message in version 0 of Solana's off-chain message format: the signing domain, the
+// version, the narrowest format the message fits, and its length.
+func OffchainMessage(message []byte) ([]byte, error) {
+	if len(message) == 0 {
+		return nil, fmt.Errorf("%w: off-chain messages can't be empty", ErrInvalidArgument)
+	}
+	var format byte
+	switch {
+	case len(message) <= offchainLedgerMaxLength && isRestrictedASCII(message):
+		format = offchainRestrictedASCII
+	case !utf8.Valid(message):
+		return nil, fmt.Errorf("%w: off-chain messages must be UTF-8", ErrInvalidArgument)
+	case len(message) <= offchainLedgerMaxLength:
+		format = offchainLimitedUTF8
+	case len(message) <= offchainMaxLength:
+		format = offchainExtendedUTF8
+	default:
+		return nil, fmt.Errorf("%w: off-chain messages are at most %d bytes, got %d", ErrInvalidArgument, offchainMaxLength, len(message))
+	}
+	data := append([]byte(offchainSigningDomain), 0, format)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(message)))
+	return append(data, message...), nil
+}
+
+// isRestrictedASCII reports whether message only has printable ASCII characters.
+func isRestrictedASCII(message []byte) bool {
+	for _, c := range message {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func runSignMessage(ctx context.Context, args []string) error {
+	fs := newFlagSet("sign-message")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token-transfer sign-message [flags] MESSAGE")
+		fs.PrintDefaults()
+	}
+	file, offchain := registerMessageFlags(fs)
+	parseFlags(fs, args)
+
+	message, err := readMessage(fs, *file)
+	if err != nil {
+		return err
+	}
+	if *offchain {
+		if message, err = OffchainMessage(message); err != nil {
+			return err
+		}
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(message)
+	if err != nil {
+		return fmt.Errorf("%w: can't sign message: %v", ErrSignerUnavailable, err)
+	}
+	slog.Info("message signed", "signer", signer.PublicKey(), "offchain", *offchain)
+	fmt.Println(sig)
+	return nil
+}
+
+func runVerifyMessage(ctx context.Context, args []string) error {
+	fs := newFlagSet("verify-message")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: token-transfer verify-message --pubkey ADDRESS --signature SIGNATURE [flags] MESSAGE")
+		fs.PrintDefaults()
+	}
+	pubkeyFlag := fs.String("pubkey", "", "Address that signed the message (required)")
+	sigFlag := fs.String("signature", "", "Base58 signature to check (required)")
+	file, offchain := registerMessageFlags(fs)
+	parseFlags(fs, args)
+
+	if *pubkeyFlag == "" || *sigFlag == "" {
+		return fmt.Errorf("%w: --pubkey and --signature flags are required", ErrInvalidArgument)
+	}
+	pubkey, err := solanago.PublicKeyFromBase58(*pubkeyFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --pubkey: %v", ErrInvalidArgument, err)
+	}
+	sig, err := solanago.SignatureFromBase58(*sigFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --signature: %v", ErrInvalidArgument, err)
+	}
+	message, err := readMessage(fs, *file)
+	if err != nil {
+		return err
+	}
+	if *offchain {
+		if message, err = OffchainMessage(message); err != nil {
+			return err
+		}
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pubkey[:]), message, sig[:]) {
+		return fmt.Errorf("%w: signature doesn't match the message and %s", ErrInvalidArgument, pubkey)
+	}
+	fmt.Printf("valid signature by %s\n", pubkey)
+	return nil
+}
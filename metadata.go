@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// metaplexMetadataProgramID is the Metaplex Token Metadata program.
+var metaplexMetadataProgramID = solanago.MustPublicKeyFromBase58("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s")
+
+// tokenMetadataExtension is the Token-2022 TLV extension type holding token metadata.
+const tokenMetadataExtension = 19
+
+// errNoMetadata is returned when a mint has no metadata.
+var errNoMetadata = errors.New("no token metadata")
+
+// TokenMetadata is a token's name and symbol. URI points at off-chain JSON, which holds the logo ("image").
+type TokenMetadata struct {
+	Name   string
+	Symbol string
+	URI    string
+}
+
+// ResolveTokenMetadata reads mint's metadata from its Metaplex metadata account or, for Token-2022 mints, from
+// the mint's own metadata extension.
+func ResolveTokenMetadata(ctx context.Context, client RPCClient, mint solanago.PublicKey) (TokenMetadata, error) {
+	pda, err := metadataAddress(mint)
+	if err != nil {
+		return TokenMetadata{}, err
+	}
+
+	res, err := GetAccountInfo(ctx, client, pda, rpc.CommitmentConfirmed)
+	switch {
+	case err == nil && res.Value != nil:
+		return parseMetaplexMetadata(res.Value.Data.GetBinary())
+	case err != nil && !errors.Is(err, rpc.ErrNotFound):
+		return TokenMetadata{}, fmt.Errorf("can't get metadata account: %w", classifyRPCError(err))
+	}
+
+	res, err = GetAccountInfo(ctx, client, mint, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return TokenMetadata{}, fmt.Errorf("mint %s not found", mint)
+	}
+	if err != nil {
+		return TokenMetadata{}, fmt.Errorf("can't get mint: %w", classifyRPCError(err))
+	}
+	if !res.Value.Owner.Equals(solanago.Token2022ProgramID) {
+		return TokenMetadata{}, errNoMetadata
+	}
+	return parseToken2022Metadata(res.Value.Data.GetBinary())
+}
+
+// metadataAddress returns the address of mint's Metaplex metadata account.
+func metadataAddress(mint solanago.PublicKey) (solanago.PublicKey, error) {
+	return metaplexPDA(mint.Bytes())
+}
+
+// metaplexPDA derives a Token Metadata program address from the seeds following "metadata" and the program ID.
+func metaplexPDA(seeds ...[]byte) (solanago.PublicKey, error) {
+	pda, _, err := solanago.FindProgramAddress(append([][]byte{[]byte("metadata"), metaplexMetadataProgramID.Bytes()}, seeds...), metaplexMetadataProgramID)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't derive metadata address: %v", err)
+	}
+	return pda, nil
+}
+
+// tokenLabel returns the symbol of mint for display, falling back to its address.
+func tokenLabel(ctx context.Context, client RPCClient, mint solanago.PublicKey) string {
+	metadata, err := ResolveTokenMetadata(ctx, client, mint)
+	if err != nil {
+		if !errors.Is(err, errNoMetadata) {
+			slog.Debug("can't resolve token metadata", "mint", mint, "error", err)
+		}
+		return mint.String()
+	}
+	if metadata.Symbol == "" {
+		return mint.String()
+	}
+	return metadata.Symbol
+}
+
+// parseMetaplexMetadata decodes the start of a Metaplex metadata account: key (1), update authority (32),
+// mint (32), then the name, symbol and uri as borsh strings padded with NULs.
+func parseMetaplexMetadata(data []byte) (TokenMetadata, error) {
+	r := &borshReader{data: data, off: 1 + 32 + 32}
+	var m TokenMetadata
+	m.Name = r.string()
+	m.Symbol = r.string()
+	m.URI = r.string()
+	if r.err != nil {
+		return TokenMetadata{}, fmt.Errorf("can't decode metadata account: %v", r.err)
+	}
+	return m, nil
+}
+
+// parseToken2022Metadata finds the token metadata extension in a Token-2022 mint account.
+func parseToken2022Metadata(data []byte) (TokenMetadata, error) {
+	for _, ext := range token2022Extensions(data) {
+		if ext.Type != tokenMetadataExtension {
+			continue
+		}
+		// Update authority (32) and mint (32) precede the strings.
+		r := &borshReader{data: ext.Value, off: 32 + 32}
+		var m TokenMetadata
+		m.Name = r.string()
+		m.Symbol = r.string()
+		m.URI = r.string()
+		if r.err != nil {
+			return TokenMetadata{}, fmt.Errorf("can't decode metadata extension: %v", r.err)
+		}
+		return m, nil
+	}
+	return TokenMetadata{}, errNoMetadata
+}
+
+// tlvExtension is one Token-2022 account extension.
+type tlvExtension struct {
+	Type  uint16
+	Value []byte
+}
+
+// token2022Extensions returns the extensions of a Token-2022 mint or token account. Extensions follow the base
+// account, padded to the size of a token account (165 bytes) plus an account type byte, as type (u16),
+// length (u16), value entries.
+func token2022Extensions(data []byte) []tlvExtension {
+	const extensionsStart = 165 + 1
+	var extensions []tlvExtension
+	for off := extensionsStart; off+4 <= len(data); {
+		typ := binary.LittleEndian.Uint16(data[off:])
+		length := int(binary.LittleEndian.Uint16(data[off+2:]))
+		off += 4
+		if typ == 0 || off+length > len(data) {
+			// Uninitialized: the rest is padding.
+			break
+		}
+		extensions = append(extensions, tlvExtension{Type: typ, Value: data[off : off+length]})
+		off += length
+	}
+	return extensions
+}
+
+// borshReader reads borsh values, remembering the first error.
+type borshReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+// bytes returns the next n bytes.
+func (r *borshReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data)-r.off {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *borshReader) u8() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *borshReader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *borshReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *borshReader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *borshReader) publicKey() solanago.PublicKey {
+	if b := r.bytes(32); b != nil {
+		return solanago.PublicKeyFromBytes(b)
+	}
+	return solanago.PublicKey{}
+}
+
+// option reads an Option tag and reports whether a value follows.
+func (r *borshReader) option() bool {
+	return r.u8() == 1
+}
+
+func (r *borshReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	if r.off+4 > len(r.data) {
+		r.err = errors.New("unexpected end of data")
+		return ""
+	}
+	n := int(binary.LittleEndian.Uint32(r.data[r.off:]))
+	r.off += 4
+	if n > len(r.data)-r.off {
+		r.err = errors.New("string length out of range")
+		return ""
+	}
+	s := string(r.data[r.off : r.off+n])
+	r.off += n
+	return strings.TrimRight(s, "\x00")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Process-wide metrics, served in the Prometheus text format on /metrics in serve mode.
+var (
+	transfersSubmitted = newCounter("token_transfer_transfers_submitted_total", "Transfers accepted for sending.")
+	transfersConfirmed = newCounter("token_transfer_transfers_confirmed_total", "Transfers confirmed on-chain.")
+	transfersFailed    = newCounter("token_transfer_transfers_failed_total", "Transfers that failed.")
+	sendRetries        = newCounter("token_transfer_send_retries_total", "Transactions re-signed with a new blockhash after the previous attempt expired or was rejected.")
+	feesPaid           = newCounter("token_transfer_fees_paid_lamports_total", "Transaction fees paid for confirmed transactions, in lamports.")
+	transfersRejected  = newCounter("token_transfer_transfers_rejected_total", "Transfer requests turned away because the queue was full.")
+
+	queueDepth    = newGauge("token_transfer_queue_depth", "Transfers waiting in the queue for a worker.")
+	queueCapacity = newGauge("token_transfer_queue_capacity", "Maximum number of transfers the queue holds (--queue-depth).")
+
+	rpcDuration          = newHistogramVec("token_transfer_rpc_request_duration_seconds", "Latency of JSON-RPC requests.", "method", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+	confirmationDuration = newHistogramVec("token_transfer_confirmation_duration_seconds", "Time from broadcast to confirmation.", "", []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120})
+)
+
+// metrics lists everything written by writeMetrics, in output order.
+var metrics = []interface{ write(io.Writer) }{
+	transfersSubmitted, transfersConfirmed, transfersFailed, sendRetries, feesPaid, transfersRejected, queueDepth, queueCapacity,
+	rpcDuration, confirmationDuration,
+}
+
+type counter struct {
+	name, help string
+	value      atomic.Uint64
+}
+
+func newCounter(name, help string) *counter {
+	return &counter{name: name, help: help}
+}
+
+func (c *counter) Add(n uint64) { c.value.Add(n) }
+func (c *counter) Inc()         { c.value.Add(1) }
+
+func (c *counter) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Load())
+}
+
+type gauge struct {
+	name, help string
+	value      atomic.Int64
+}
+
+func newGauge(name, help string) *gauge {
+	return &gauge{name: name, help: help}
+}
+
+func (g *gauge) Add(n int64) { g.value.Add(n) }
+func (g *gauge) Set(n int64) { g.value.Store(n) }
+
+func (g *gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.value.Load())
+}
+
+// histogramVec is a histogram partitioned by the value of one label. With an empty label name it is a plain
+// histogram.
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+// Observe records d under the given label value.
+func (h *histogramVec) Observe(labelValue string, d time.Duration) {
+	v := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, labelValue := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[labelValue]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, h.labels(labelValue, strconv.FormatFloat(le, 'g', -1, 64)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, h.labels(labelValue, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.braced(labelValue), strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.braced(labelValue), s.count)
+	}
+}
+
+func (h *histogramVec) labels(labelValue, le string) string {
+	if h.label == "" {
+		return fmt.Sprintf("le=%q", le)
+	}
+	return fmt.Sprintf("%s=%q,le=%q", h.label, labelValue, le)
+}
+
+func (h *histogramVec) braced(labelValue string) string {
+	if h.label == "" {
+		return ""
+	}
+	return fmt.Sprintf("{%s=%q}", h.label, labelValue)
+}
+
+// handleMetrics serves GET /metrics.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrStoreTooNew is returned when the store was written by a newer version of this tool. Opening it anyway could
+// corrupt data written in a format we don't understand.
+var ErrStoreTooNew = errors.New("store schema is newer than this binary supports")
+
+const schemaVersionFile = "schema_version"
+
+// migration upgrades the store from version-1 to version. Migrations must be idempotent: if the process dies
+// before the schema version is bumped the migration runs again on the next start.
+type migration struct {
+	version     int
+	description string
+	up          func(dir string) error
+}
+
+// migrations lists every store migration in order. Append only; never edit a released migration.
+var migrations = []migration{
+	{1, "create transfers journal", func(dir string) error {
+		return touch(filepath.Join(dir, transfersJournalFile))
+	}},
+	{2, "store transfer amounts as decimal strings", func(dir string) error {
+		return rewriteJournal(filepath.Join(dir, transfersJournalFile), func(record map[string]any) {
+			if amount, ok := record["amount"].(json.Number); ok {
+				record["amount"] = amount.String()
+			}
+		})
+	}},
+}
+
+// migrate upgrades the store in dir to the latest schema version. Files are backed up before the first migration
+// runs, even with no schema version recorded yet, since a journal may predate it; a store with a schema newer than
+// this binary knows about is refused.
+func migrate(dir string) error {
+	current, err := schemaVersion(dir)
+	if err != nil {
+		return err
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("%w: %s is at version %d, this binary supports up to %d", ErrStoreTooNew, dir, current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+	if err := backupStore(dir, current); err != nil {
+		return fmt.Errorf("can't back up store before migrating: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		slog.Info("migrating store", "dir", dir, "version", m.version, "migration", m.description)
+		if err := m.up(dir); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.version, m.description, err)
+		}
+		if err := writeFileAtomic(filepath.Join(dir, schemaVersionFile), []byte(strconv.Itoa(m.version)+"\n"), 0o600); err != nil {
+			return fmt.Errorf("can't record schema version %d: %v", m.version, err)
+		}
+	}
+	return nil
+}
+
+// rewriteJournal applies fn to every record of a JSON lines journal and atomically replaces the file. Unreadable
+// lines are kept as they are.
+func rewriteJournal(path string, fn func(record map[string]any)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var record map[string]any
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&record); err != nil {
+			out.Write(line)
+			out.WriteByte('\n')
+			continue
+		}
+		fn(record)
+		rewritten, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		out.Write(rewritten)
+		out.WriteByte('\n')
+	}
+	return writeFileAtomic(path, out.Bytes(), 0o600)
+}
+
+// schemaVersion returns the schema version of the store in dir, or 0 for a new store.
+func schemaVersion(dir string) (int, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, schemaVersionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0, fmt.Errorf("corrupt schema version in %s: %v", dir, err)
+	}
+	return version, nil
+}
+
+// backupStore copies the store's files into backups/v<version>-<timestamp>. A store without files, such as a new
+// one, isn't backed up.
+func backupStore(dir string, version int) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, func(entry os.DirEntry) bool { return !entry.Type().IsRegular() })
+	if len(entries) == 0 {
+		return nil
+	}
+	backupDir := filepath.Join(dir, "backups", fmt.Sprintf("v%d-%d", version, time.Now().Unix()))
+	if err := os.MkdirAll(backupDir, 0o700); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := copyFile(filepath.Join(dir, entry.Name()), filepath.Join(backupDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	slog.Info("backed up store", "dir", backupDir)
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeFileAtomic replaces path with data so that readers see either the old or the new content, never a mix.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func touch(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
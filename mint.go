@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+	ata "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["mint"] = runMint
+}
+
+func runMint(ctx context.Context, args []string) error {
+	fs := newFlagSet("mint")
+	receiverFlag := fs.String("receiver", "", "Wallet receiving the new tokens (defaults to the signer)")
+	amountFlag := fs.String("amount", "", "Decimal token amount to mint (required)")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the mint without sending it")
+	parseFlags(fs, args)
+
+	if *amountFlag == "" {
+		return fmt.Errorf("%w: --amount flag is required", ErrInvalidArgument)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	receiverKey := signer.PublicKey()
+	if *receiverFlag != "" {
+		receiverKey, err = solanago.PublicKeyFromBase58(*receiverFlag)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	}
+
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	rawAmount, err := parseUIAmount(*amountFlag, mint.Decimals)
+	if err != nil {
+		return err
+	}
+	if rawAmount == 0 {
+		return fmt.Errorf("%w: --amount must be positive", ErrInvalidArgument)
+	}
+
+	instructions, err := mintInstructions(ctx, clients.Read, signer.PublicKey(), receiverKey, mintAddress, mint, rawAmount)
+	if err != nil {
+		return err
+	}
+	if *dryRunFlag {
+		return simulateInstructions(ctx, clients, signer, instructions...)
+	}
+	slog.Info("minting tokens", "receiver", receiverKey, "amount", formatUIAmount(rawAmount, mint.Decimals), "mint", mintAddress)
+	sig, err := sendInstructions(ctx, clients, signer, instructions...)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// mintInstructions returns the instructions minting amount raw base units of mint to receiver's ATA, creating
+// the ATA first if needed. authority must be the mint's mint authority.
+func mintInstructions(ctx context.Context, client RPCClient, authority, receiver, mintAddress solanago.PublicKey, mint token.Mint, amount uint64) ([]solanago.Instruction, error) {
+	switch {
+	case mint.MintAuthority == nil:
+		return nil, fmt.Errorf("%w: mint %s has no mint authority, its supply is fixed", ErrInvalidArgument, mintAddress)
+	case !mint.MintAuthority.Equals(authority):
+		// The program's wrapped mint is usually controlled by a program PDA, which only the program itself can
+		// sign for; minting then has to go through the program's own instruction (see --pre-ix/--post-ix).
+		return nil, fmt.Errorf("%w: mint authority of %s is %s, not the signer %s", ErrSignerUnavailable, mintAddress, mint.MintAuthority, authority)
+	}
+
+	receiverAta, _, err := solanago.FindAssociatedTokenAddress(receiver, mintAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, receiver, err)
+	}
+	var instructions []solanago.Instruction
+	exists, err := accountExists(ctx, client, receiverAta)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		slog.Debug("receiver ATA does not exist, creating it", "ata", receiverAta)
+		instructions = append(instructions, ata.NewCreateInstruction(authority, receiver, mintAddress).Build())
+	}
+	return append(instructions, token.NewMintToCheckedInstruction(amount, mint.Decimals, mintAddress, receiverAta, authority, nil).Build()), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func init() {
+	commands["mint-info"] = runMintInfo
+}
+
+// token2022ExtensionNames names the Token-2022 extension types, indexed by type.
+var token2022ExtensionNames = []string{
+	"Uninitialized",
+	"TransferFeeConfig",
+	"TransferFeeAmount",
+	"MintCloseAuthority",
+	"ConfidentialTransferMint",
+	"ConfidentialTransferAccount",
+	"DefaultAccountState",
+	"ImmutableOwner",
+	"MemoTransfer",
+	"NonTransferable",
+	"InterestBearingConfig",
+	"CpiGuard",
+	"PermanentDelegate",
+	"NonTransferableAccount",
+	"TransferHook",
+	"TransferHookAccount",
+	"ConfidentialTransferFeeConfig",
+	"ConfidentialTransferFeeAmount",
+	"MetadataPointer",
+	"TokenMetadata",
+	"GroupPointer",
+	"TokenGroup",
+	"GroupMemberPointer",
+	"TokenGroupMember",
+	"ConfidentialMintBurn",
+	"ScaledUiAmount",
+	"Pausable",
+	"PausableAccount",
+}
+
+func extensionName(typ uint16) string {
+	if int(typ) < len(token2022ExtensionNames) {
+		return token2022ExtensionNames[typ]
+	}
+	return fmt.Sprintf("Unknown(%d)", typ)
+}
+
+// mintInfo is the decoded state of a mint, as printed by mint-info.
+type mintInfo struct {
+	Address         string   `json:"address"`
+	Program         string   `json:"program"`
+	Name            string   `json:"name,omitempty"`
+	Symbol          string   `json:"symbol,omitempty"`
+	Decimals        uint8    `json:"decimals"`
+	Supply          string   `json:"supply"`
+	RawSupply       uint64   `json:"rawSupply"`
+	MintAuthority   *string  `json:"mintAuthority"`
+	FreezeAuthority *string  `json:"freezeAuthority"`
+	Extensions      []string `json:"extensions,omitempty"`
+}
+
+func runMintInfo(ctx context.Context, args []string) error {
+	fs := newFlagSet("mint-info")
+	fs.StringVar(&tokenName, "mint", "", "Mint address or token symbol; same as --token")
+	jsonOutput := fs.Bool("json", false, "Print the mint state as JSON")
+	parseFlags(fs, args)
+
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	info, err := GetMintInfo(ctx, client)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	return info.Print()
+}
+
+// GetMintInfo fetches and decodes the mint selected by --token, including its metadata and, for Token-2022
+// mints, its extensions.
+func GetMintInfo(ctx context.Context, client RPCClient) (mintInfo, error) {
+	mintAddress, mint, err := resolveMint(ctx, client)
+	if err != nil {
+		return mintInfo{}, err
+	}
+	res, err := GetAccountInfo(ctx, client, mintAddress, rpc.CommitmentFinalized)
+	if err != nil {
+		return mintInfo{}, fmt.Errorf("can't get mint: %w", classifyRPCError(err))
+	}
+
+	info := mintInfo{
+		Address:         mintAddress.String(),
+		Program:         res.Value.Owner.String(),
+		Decimals:        mint.Decimals,
+		Supply:          formatUIAmount(mint.Supply, mint.Decimals),
+		RawSupply:       mint.Supply,
+		MintAuthority:   optionalKey(mint.MintAuthority),
+		FreezeAuthority: optionalKey(mint.FreezeAuthority),
+	}
+	if metadata, err := ResolveTokenMetadata(ctx, client, mintAddress); err == nil {
+		info.Name, info.Symbol = metadata.Name, metadata.Symbol
+	}
+	if res.Value.Owner.Equals(solanago.Token2022ProgramID) {
+		for _, ext := range token2022Extensions(res.Value.Data.GetBinary()) {
+			info.Extensions = append(info.Extensions, extensionName(ext.Type))
+		}
+	}
+	return info, nil
+}
+
+func optionalKey(key *solanago.PublicKey) *string {
+	if key == nil {
+		return nil
+	}
+	s := key.String()
+	return &s
+}
+
+// Print writes the mint state to stdout as aligned key/value lines.
+func (m mintInfo) Print() error {
+	orNone := func(s *string) string {
+		if s == nil {
+			return "none"
+		}
+		return *s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Address:\t%s\n", m.Address)
+	fmt.Fprintf(w, "Program:\t%s\n", m.Program)
+	if m.Name != "" || m.Symbol != "" {
+		fmt.Fprintf(w, "Token:\t%s (%s)\n", m.Name, m.Symbol)
+	}
+	fmt.Fprintf(w, "Decimals:\t%d\n", m.Decimals)
+	fmt.Fprintf(w, "Supply:\t%s (%d raw)\n", m.Supply, m.RawSupply)
+	fmt.Fprintf(w, "Mint authority:\t%s\n", orNone(m.MintAuthority))
+	fmt.Fprintf(w, "Freeze authority:\t%s\n", orNone(m.FreezeAuthority))
+	for i, ext := range m.Extensions {
+		label := ""
+		if i == 0 {
+			label = "Extensions:"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", label, ext)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// defaultDerivationPath is the path Phantom and Solflare derive their first account from.
+const defaultDerivationPath = "m/44'/501'/0'/0'"
+
+// derivationPath is the BIP44 path keys are derived at from a mnemonic phrase.
+var derivationPath string
+
+// hardenedOffset is added to an index to make it a hardened BIP32 index.
+const hardenedOffset = 1 << 31
+
+// isMnemonic reports whether data looks like a mnemonic phrase rather than an encoded key: several words.
+func isMnemonic(data []byte) bool {
+	return len(strings.Fields(string(data))) > 1
+}
+
+// keyFromMnemonic derives the ed25519 key at path from a BIP39 mnemonic phrase (without a BIP39 passphrase), the
+// way Solana wallets do: SLIP-0010 derivation from the BIP39 seed, every level hardened.
+func keyFromMnemonic(mnemonic, path string) (solanago.PrivateKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+	return solanago.PrivateKey(deriveEd25519(seed, indexes)), nil
+}
+
+// parseDerivationPath parses a path like m/44'/501'/0'/0' into hardened indexes. ed25519 has no non-hardened
+// derivation, so every level must be marked hardened with ' or h.
+func parseDerivationPath(path string) ([]uint32, error) {
+	levels := strings.Split(path, "/")
+	if levels[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m/", path)
+	}
+	indexes := make([]uint32, 0, len(levels)-1)
+	for _, level := range levels[1:] {
+		n, hardened := strings.CutSuffix(level, "'")
+		if !hardened {
+			n, hardened = strings.CutSuffix(level, "h")
+		}
+		if !hardened {
+			return nil, fmt.Errorf("invalid derivation path %q: %q isn't hardened", path, level)
+		}
+		i, err := strconv.ParseUint(n, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: %v", path, errors.Unwrap(err))
+		}
+		indexes = append(indexes, uint32(i)+hardenedOffset)
+	}
+	return indexes, nil
+}
+
+// deriveEd25519 derives the ed25519 key at the hardened indexes from seed per SLIP-0010.
+func deriveEd25519(seed []byte, indexes []uint32) ed25519.PrivateKey {
+	key, chainCode := slip10([]byte("ed25519 seed"), seed)
+	for _, i := range indexes {
+		data := append([]byte{0}, key...)
+		data = binary.BigEndian.AppendUint32(data, i)
+		key, chainCode = slip10(chainCode, data)
+	}
+	return ed25519.NewKeyFromSeed(key)
+}
+
+// slip10 returns the two halves of HMAC-SHA512(key, data): the derived key and chain code.
+func slip10(key, data []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	txsender "github.com/csknk/token-transfer/pkg/sender"
+)
+
+// sendSpec is one --send value: a token, by mint address or registry symbol, and a decimal amount of it.
+type sendSpec struct {
+	Token  string
+	Amount string
+}
+
+// sendSpecs collects --send values.
+type sendSpecs []sendSpec
+
+var sendFlags sendSpecs
+
+func (l *sendSpecs) String() string {
+	return fmt.Sprintf("%d token(s)", len(*l))
+}
+
+func (l *sendSpecs) Set(value string) error {
+	token, amount, found := strings.Cut(value, ":")
+	if !found || token == "" || amount == "" {
+		return fmt.Errorf("expected MINT:AMOUNT, got %q", value)
+	}
+	*l = append(*l, sendSpec{Token: token, Amount: amount})
+	return nil
+}
+
+// TransferLeg is one of the transfers SignMultiTransfer combines into a transaction.
+type TransferLeg struct {
+	Mint solanago.PublicKey
+	// Amount is in raw base units of Mint.
+	Amount uint64
+}
+
+// SignMultiTransfer builds one transaction transferring every leg from signer to receiver and signs it. opts
+// applies to the whole transaction; its Mint and ReceiverTokenAccount are ignored.
+func SignMultiTransfer(ctx context.Context, client RPCClient, signer Signer, receiver solanago.PublicKey, legs []TransferLeg, opts TransferOptions) (*solanago.Transaction, error) {
+	if opts.Blockhash.IsZero() {
+		latest, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+		if err != nil {
+			return nil, fmt.Errorf("can't get recent block hash: %w", classifyRPCError(err))
+		}
+		opts.Blockhash = latest.Value.Blockhash
+	}
+	opts.ReceiverTokenAccount = solanago.PublicKey{}
+	params := make([]BuildParams, len(legs))
+	for i, leg := range legs {
+		legOpts := opts
+		legOpts.Mint = leg.Mint
+		p, err := PrepareTransfer(ctx, client, signer.PublicKey(), receiver, leg.Amount, legOpts)
+		if err != nil {
+			return nil, fmt.Errorf("transfer of %s: %w", leg.Mint, err)
+		}
+		params[i] = p
+	}
+	tx, err := BuildMultiTransferTransaction(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := signTransaction(tx, signer, opts.signers()...); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// multiTransferSigner returns a sign function building and signing the multi-mint transfer against the blockhash
+// the send loop provides.
+func multiTransferSigner(clients Clients, signer Signer, receiver solanago.PublicKey, legs []TransferLeg, opts TransferOptions) txsender.SignFunc {
+	return func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		opts.Blockhash = blockhash
+		tip, err := clients.Sender.TipInstructions(ctx, opts.payer(signer.PublicKey()))
+		if err != nil {
+			return nil, err
+		}
+		opts.PostInstructions = append(slices.Clip(opts.PostInstructions), tip...)
+		return SignMultiTransfer(ctx, clients.Read, signer, receiver, legs, opts)
+	}
+}
+
+// runMultiTransfer sends every --send token to --receiver in one transaction, so they succeed or fail together
+// and cost a single fee.
+func runMultiTransfer(ctx context.Context) error {
+	switch {
+	case receiver == "":
+		return fmt.Errorf("%w: --receiver flag is required", ErrInvalidArgument)
+	case amount != 0 || amountPercent != nil || tokenName != "":
+		return fmt.Errorf("%w: --send sets the tokens and amounts, --amount and --token can't be combined with it", ErrInvalidArgument)
+	case receiverAccount != "" || testSend != "" || idempotencyKey != "":
+		return fmt.Errorf("%w: --receiver-token-account, --test-send and --idempotency-key can't be combined with --send", ErrInvalidArgument)
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(receiver)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if err := checkReceiverOwner(receiverKey); err != nil {
+		return err
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	opts := TransferOptions{
+		PreInstructions:  append(priorityFeeInstructions(), preInstructions...),
+		PostInstructions: postInstructions,
+		Memo:             transferMemo,
+		NoFundRecipient:  noFundRecipient,
+	}
+	if err := txFormatOptions(ctx, clients.Read, signer.PublicKey(), &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+
+	legs := make([]TransferLeg, len(sendFlags))
+	mints := make([]transferMint, len(sendFlags))
+	for i, spec := range sendFlags {
+		mintAddress, err := lookupToken(spec.Token)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(legs[:i], func(l TransferLeg) bool { return l.Mint.Equals(mintAddress) }) {
+			return fmt.Errorf("%w: --send lists %s more than once", ErrInvalidArgument, spec.Token)
+		}
+		tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+		if err != nil {
+			return err
+		}
+		raw, err := parseUIAmount(spec.Amount, tm.Decimals)
+		if err != nil {
+			return err
+		}
+		if raw == 0 {
+			return fmt.Errorf("%w: amount of %s must be positive", ErrInvalidArgument, spec.Token)
+		}
+		if err := checkTokenTransfer(ctx, clients.Read, tm, signer.PublicKey(), opts.payer(signer.PublicKey()), receiverKey); err != nil {
+			return err
+		}
+		if err := discloseAccountRent(ctx, clients.Read, tm, receiverKey, opts); err != nil {
+			return err
+		}
+		legs[i], mints[i] = TransferLeg{Mint: mintAddress, Amount: raw}, tm
+	}
+
+	if dryRun {
+		tx, err := SignMultiTransfer(ctx, clients.Read, signer, receiverKey, legs, opts)
+		if err != nil {
+			return err
+		}
+		return clients.Sender.Simulate(ctx, tx)
+	}
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	known, err := isKnownReceiver(ctx, clients.Read, store, signer.PublicKey(), receiverKey, legs[0].Mint)
+	if err != nil {
+		slog.Warn("can't check transfer history with receiver", "error", err)
+	}
+	if !known {
+		slog.Warn("first time sending to this receiver: no previous transfers found locally or on-chain", "receiver", receiverKey)
+	}
+	records := make([]transferRecord, len(legs))
+	for i, leg := range legs {
+		decimals := mints[i].Decimals
+		if err := enforcePolicy(policy, store, leg.Mint, decimals, receiverKey, leg.Amount, 0); err != nil {
+			return err
+		}
+		if needs, err := policy.NeedsApproval(leg.Mint, decimals, leg.Amount); err != nil || needs {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: the transfer of %s needs approval, send it on its own", ErrPolicyViolation, leg.Mint)
+		}
+		uiAmount := formatUIAmount(leg.Amount, decimals)
+		if err := confirmTransfer(ctx, clients.Read, leg.Mint, receiverKey, uiAmount, !known); err != nil {
+			return err
+		}
+		records[i] = newTransferRecord("", signer.PublicKey(), receiverKey, leg.Mint, uiAmount)
+		records[i].Value = fiatValue(ctx, clients.Read, leg.Mint, uiAmount)
+	}
+	ids := make([]string, len(records))
+	for i, record := range records {
+		if err := store.PutTransfer(record); err != nil {
+			return fmt.Errorf("can't record transfer: %v", err)
+		}
+		ids[i] = record.ID
+	}
+
+	sig, err := clients.Sender.SendAndConfirm(ctx, multiTransferSigner(clients, signer, receiverKey, legs, opts), journalSignature(store, ids...))
+	if err != nil {
+		err = fmt.Errorf("can't send transaction: %w", classifySendError(err))
+	}
+	for _, id := range ids {
+		recordOutcome(store, id, err)
+	}
+	if err != nil {
+		return err
+	}
+	for i, leg := range legs {
+		slog.Info(fmt.Sprintf("sent %s %s", formatUIAmount(leg.Amount, mints[i].Decimals), tokenLabel(ctx, clients.Read, leg.Mint)), "receiver", receiverKey, "signature", sig)
+	}
+	if link := explorerTxURL(sig.String()); link != "" {
+		slog.Info("view on explorer", "transaction", link)
+	}
+	fmt.Println(sig)
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// multisigAccountSize is the size of a token multisig account: m, n, is_initialized and room for
+	// maxMultisigSigners signers.
+	multisigAccountSize = 3 + maxMultisigSigners*32
+	maxMultisigSigners  = 11
+	// mintAccountSize is the size of a mint without extensions. token2022MintAccountType marks Token-2022 mints
+	// with extensions, at the same offset as token2022AccountType.
+	mintAccountSize          = 82
+	token2022MintAccountType = 1
+)
+
+// authorityTypes names the token program's authority types, as --authority takes them.
+var authorityTypes = map[string]token.AuthorityType{
+	"mint":   token.AuthorityMintTokens,
+	"freeze": token.AuthorityFreezeAccount,
+	"owner":  token.AuthorityAccountOwner,
+	"close":  token.AuthorityCloseAccount,
+}
+
+func init() {
+	commands["multisig"] = runMultisig
+}
+
+// tokenMultisig is a token program multisig authority: M of its signers have to sign for it.
+type tokenMultisig struct {
+	Address string   `json:"address"`
+	Program string   `json:"program"`
+	M       uint8    `json:"m"`
+	N       uint8    `json:"n"`
+	Signers []string `json:"signers"`
+}
+
+func runMultisig(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("%w: usage: multisig create|show|assign [flags]", ErrInvalidArgument)
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "create":
+		return runMultisigCreate(ctx, args[1:])
+	case "show":
+		return runMultisigShow(ctx, args[1:])
+	case "assign":
+		return runMultisigAssign(ctx, args[1:])
+	}
+	return usage
+}
+
+func runMultisigCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("multisig create")
+	m := fs.Int("m", 0, "Number of signers that have to sign for the multisig (required)")
+	var signers publicKeyList
+	fs.Func("signers", "Comma-separated signer addresses, at most 11 (required)", func(value string) error {
+		for _, part := range strings.Split(value, ",") {
+			if err := signers.Set(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	token2022 := fs.Bool("token-2022", false, "Create the multisig for Token-2022 mints and accounts instead of SPL Token ones")
+	parseFlags(fs, args)
+
+	switch {
+	case len(signers) == 0 || len(signers) > maxMultisigSigners:
+		return fmt.Errorf("%w: --signers must list 1 to %d addresses", ErrInvalidArgument, maxMultisigSigners)
+	case *m < 1 || *m > len(signers):
+		return fmt.Errorf("%w: --m must be between 1 and the number of signers, %d", ErrInvalidArgument, len(signers))
+	}
+	for i, key := range signers {
+		if slices.Contains(signers[:i], key) {
+			return fmt.Errorf("%w: signer %s is listed twice", ErrInvalidArgument, key)
+		}
+	}
+	program := solanago.TokenProgramID
+	if *token2022 {
+		program = solanago.Token2022ProgramID
+	}
+
+	payer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	account, err := solanago.NewRandomPrivateKey()
+	if err != nil {
+		return fmt.Errorf("can't generate multisig address: %v", err)
+	}
+	rent, err := clients.Read.GetMinimumBalanceForRentExemption(ctx, multisigAccountSize, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get rent: %w", classifyRPCError(err))
+	}
+	create := system.NewCreateAccountInstruction(rent, multisigAccountSize, program, payer.PublicKey(), account.PublicKey()).Build()
+	initialize := token.NewInitializeMultisig2Instruction(uint8(*m), account.PublicKey(), signers).Build()
+	var instruction solanago.Instruction = initialize
+	if *token2022 {
+		if instruction, err = token2022Instruction(initialize); err != nil {
+			return err
+		}
+	}
+	sig, err := sendCoSignedInstructions(ctx, clients, payer, []Signer{account}, create, instruction)
+	if err != nil {
+		return fmt.Errorf("can't create multisig: %w", err)
+	}
+	slog.Info(fmt.Sprintf("created %d of %d multisig", *m, len(signers)), "multisig", account.PublicKey(), "rent", formatUIAmount(rent, solDecimals), "signature", sig)
+	fmt.Println(account.PublicKey())
+	return nil
+}
+
+func runMultisigShow(ctx context.Context, args []string) error {
+	fs := newFlagSet("multisig show")
+	addressFlag := fs.String("address", "", "Multisig account to show (required)")
+	jsonOutput := fs.Bool("json", false, "Print the multisig as JSON")
+	parseFlags(fs, args)
+
+	if *addressFlag == "" {
+		return fmt.Errorf("%w: --address flag is required", ErrInvalidArgument)
+	}
+	address, err := solanago.PublicKeyFromBase58(*addressFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --address: %v", ErrInvalidArgument, err)
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ms, err := loadMultisig(ctx, client, address)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ms)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Address\t%s\n", ms.Address)
+	fmt.Fprintf(w, "Program\t%s\n", ms.Program)
+	fmt.Fprintf(w, "Threshold\t%d of %d\n", ms.M, ms.N)
+	for i, signer := range ms.Signers {
+		fmt.Fprintf(w, "Signer %d\t%s\n", i+1, signer)
+	}
+	return w.Flush()
+}
+
+// loadMultisig fetches and decodes the token multisig at address.
+func loadMultisig(ctx context.Context, client RPCClient, address solanago.PublicKey) (tokenMultisig, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return tokenMultisig{}, fmt.Errorf("%w: multisig %s doesn't exist", ErrInvalidArgument, address)
+	}
+	if err != nil {
+		return tokenMultisig{}, fmt.Errorf("can't get multisig %s: %w", address, classifyRPCError(err))
+	}
+	program := res.Value.Owner
+	data := res.Value.Data.GetBinary()
+	if !program.Equals(solanago.TokenProgramID) && !program.Equals(solanago.Token2022ProgramID) || len(data) != multisigAccountSize {
+		return tokenMultisig{}, fmt.Errorf("%w: %s isn't a token multisig", ErrInvalidArgument, address)
+	}
+	var ms token.Multisig
+	if err := bin.NewBorshDecoder(data).Decode(&ms); err != nil {
+		return tokenMultisig{}, fmt.Errorf("can't decode multisig %s: %v", address, err)
+	}
+	if !ms.IsInitialized || int(ms.N) > maxMultisigSigners {
+		return tokenMultisig{}, fmt.Errorf("%w: multisig %s isn't initialized", ErrInvalidArgument, address)
+	}
+	out := tokenMultisig{Address: address.String(), Program: program.String(), M: ms.M, N: ms.N}
+	for _, signer := range ms.Signers[:ms.N] {
+		out.Signers = append(out.Signers, signer.String())
+	}
+	return out, nil
+}
+
+func runMultisigAssign(ctx context.Context, args []string) error {
+	fs := newFlagSet("multisig assign")
+	multisigFlag := fs.String("multisig", "", "Multisig to hand the authority to (required)")
+	accountFlag := fs.String("account", "", "Mint or token account whose authority the multisig gets (required)")
+	authorityFlag := fs.String("authority", "", "Authority to hand over: mint or freeze for a mint, owner or close for a token account (default: mint for mints, owner for token accounts)")
+	yes := fs.Bool("yes", false, "Hand over the authority without the confirmation prompt")
+	parseFlags(fs, args)
+
+	if *multisigFlag == "" || *accountFlag == "" {
+		return fmt.Errorf("%w: --multisig and --account flags are required", ErrInvalidArgument)
+	}
+	multisig, err := solanago.PublicKeyFromBase58(*multisigFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --multisig: %v", ErrInvalidArgument, err)
+	}
+	subject, err := solanago.PublicKeyFromBase58(*accountFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --account: %v", ErrInvalidArgument, err)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	ms, err := loadMultisig(ctx, clients.Read, multisig)
+	if err != nil {
+		return err
+	}
+	res, err := GetAccountInfo(ctx, clients.Read, subject, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return fmt.Errorf("%w: account %s doesn't exist", ErrInvalidArgument, subject)
+	}
+	if err != nil {
+		return fmt.Errorf("can't get account %s: %w", subject, classifyRPCError(err))
+	}
+	program := res.Value.Owner
+	if program.String() != ms.Program {
+		return fmt.Errorf("%w: %s belongs to %s but multisig %s to %s; create the multisig for the same token program", ErrInvalidArgument, subject, program, multisig, ms.Program)
+	}
+	isMint := isMintAccount(res.Value.Data.GetBinary())
+	name := *authorityFlag
+	if name == "" {
+		name = "owner"
+		if isMint {
+			name = "mint"
+		}
+	}
+	typ, ok := authorityTypes[name]
+	if !ok || isMint != (typ == token.AuthorityMintTokens || typ == token.AuthorityFreezeAccount) {
+		kind := "token account"
+		if isMint {
+			kind = "mint"
+		}
+		return fmt.Errorf("%w: --authority %q doesn't apply to %s, a %s", ErrInvalidArgument, name, subject, kind)
+	}
+
+	instruction, err := setAuthorityInstruction(program, subject, signer.PublicKey(), &multisig, typ)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "The %s authority of %s moves from %s to multisig %s (%d of %d signers).\n", name, subject, signer.PublicKey(), multisig, ms.M, ms.N)
+	if !*yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("%w: handing over an authority needs confirmation, pass --yes to do it non-interactively", ErrAborted)
+		}
+		ok, err := promptYesNo("Only the multisig's signers can use or change it afterwards. Continue?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: authority not handed over", ErrAborted)
+		}
+	}
+	sig, err := sendInstructions(ctx, clients, signer, instruction)
+	if err != nil {
+		return fmt.Errorf("can't set authority: %w", err)
+	}
+	slog.Info("handed authority to multisig", "account", subject, "authority", name, "multisig", multisig, "signature", sig)
+	fmt.Println(sig)
+	return nil
+}
+
+// setAuthorityInstruction changes the authority of type typ over subject, a mint or token account of program,
+// from current to newAuthority, or removes it if newAuthority is nil.
+func setAuthorityInstruction(program, subject, current solanago.PublicKey, newAuthority *solanago.PublicKey, typ token.AuthorityType) (solanago.Instruction, error) {
+	setAuthority := token.NewSetAuthorityInstruction(typ, solanago.PublicKey{}, subject, current, nil)
+	setAuthority.NewAuthority = newAuthority
+	instruction := setAuthority.Build()
+	if program.Equals(solanago.Token2022ProgramID) {
+		return token2022Instruction(instruction)
+	}
+	return instruction, nil
+}
+
+// isMintAccount reports whether data, owned by a token program, is a mint rather than a token account.
+func isMintAccount(data []byte) bool {
+	return len(data) == mintAccountSize || len(data) > token2022AccountTypeOffset && data[token2022AccountTypeOffset] == token2022MintAccountType
+}
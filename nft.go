@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// metaplexAuthRulesProgramID is the Metaplex Token Auth Rules program, which evaluates programmable NFT rule sets.
+var metaplexAuthRulesProgramID = solanago.MustPublicKeyFromBase58("auth9SigNpDKz4sJJ1DfCTuZrZNSAgh9sFD3rboVmgg")
+
+const (
+	// tokenStandardProgrammableNonFungible is the token_standard of programmable NFTs in their metadata account.
+	tokenStandardProgrammableNonFungible = 4
+	// metadataTransferInstruction is Token Metadata's Transfer instruction, and transferArgsV1 its only variant.
+	metadataTransferInstruction = 49
+	transferArgsV1              = 0
+)
+
+// loadProgrammableConfig reads mint's Metaplex metadata and reports whether the mint is a programmable NFT, and
+// if so its rule set (zero if it has none). Mints without metadata aren't programmable.
+func loadProgrammableConfig(ctx context.Context, client RPCClient, mint solanago.PublicKey) (bool, solanago.PublicKey, error) {
+	address, err := metadataAddress(mint)
+	if err != nil {
+		return false, solanago.PublicKey{}, err
+	}
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return false, solanago.PublicKey{}, nil
+	}
+	if err != nil {
+		return false, solanago.PublicKey{}, fmt.Errorf("can't get metadata account: %w", classifyRPCError(err))
+	}
+	programmable, ruleSet, err := parseProgrammableConfig(res.Value.Data.GetBinary())
+	if err != nil {
+		return false, solanago.PublicKey{}, err
+	}
+	if programmable {
+		slog.Debug("mint is a programmable NFT", "mint", mint, "ruleSet", ruleSet)
+	}
+	return programmable, ruleSet, nil
+}
+
+// parseProgrammableConfig decodes the token standard and the rule set of programmable_config from a Metaplex
+// metadata account. Accounts written before these fields existed end early, and aren't programmable.
+func parseProgrammableConfig(data []byte) (bool, solanago.PublicKey, error) {
+	r := &borshReader{data: data, off: 1 + 32 + 32}
+	r.string() // name
+	r.string() // symbol
+	r.string() // uri
+	r.bytes(2) // seller_fee_basis_points
+	if r.option() {
+		// creators: address, verified and share each.
+		r.bytes(int(r.u32()) * (32 + 1 + 1))
+	}
+	r.bytes(2) // primary_sale_happened, is_mutable
+	if r.option() {
+		r.u8() // edition_nonce
+	}
+	if r.err != nil {
+		return false, solanago.PublicKey{}, fmt.Errorf("can't decode metadata account: %v", r.err)
+	}
+	if r.off == len(data) || !r.option() {
+		return false, solanago.PublicKey{}, nil
+	}
+	if r.u8() != tokenStandardProgrammableNonFungible {
+		return false, solanago.PublicKey{}, nil
+	}
+	if r.option() {
+		r.bytes(1 + 32) // collection: verified, key
+	}
+	if r.option() {
+		r.bytes(1 + 8 + 8) // uses: use_method, remaining, total
+	}
+	if r.option() {
+		r.bytes(1 + 8) // collection_details: variant, size or padding
+	}
+	var ruleSet solanago.PublicKey
+	if r.option() {
+		// programmable_config: the V1 variant, then the optional rule set.
+		r.u8()
+		if r.option() {
+			ruleSet = solanago.PublicKeyFromBytes(r.bytes(32))
+		}
+	}
+	if r.err != nil {
+		return false, solanago.PublicKey{}, fmt.Errorf("can't decode metadata account: %v", r.err)
+	}
+	return true, ruleSet, nil
+}
+
+// programmableTransferInstruction moves p.Amount of a programmable NFT from the sender to the receiver with Token
+// Metadata's Transfer. Programmable NFTs sit in frozen token accounts, so a plain token transfer fails; Transfer
+// thaws and refreezes them, creates the receiver's token account if needed at payer's expense, updates both
+// token records and has the rule set checked.
+func programmableTransferInstruction(p BuildParams, payer solanago.PublicKey) (solanago.Instruction, error) {
+	mint := p.Mint.Address
+	metadata, err := metadataAddress(mint)
+	if err != nil {
+		return nil, err
+	}
+	edition, err := metaplexPDA(mint.Bytes(), []byte("edition"))
+	if err != nil {
+		return nil, err
+	}
+	ownerRecord, err := metaplexPDA(mint.Bytes(), []byte("token_record"), p.SenderTokenAccount.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	destinationRecord, err := metaplexPDA(mint.Bytes(), []byte("token_record"), p.ReceiverTokenAccount.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	// Optional accounts that are left out are passed as the Token Metadata program itself.
+	rulesProgram, rules := metaplexMetadataProgramID, metaplexMetadataProgramID
+	if !p.Mint.RuleSet.IsZero() {
+		rulesProgram, rules = metaplexAuthRulesProgramID, p.Mint.RuleSet
+	}
+
+	// TransferArgs::V1 { amount, authorization_data: None }
+	data := []byte{metadataTransferInstruction, transferArgsV1}
+	data = binary.LittleEndian.AppendUint64(data, p.Amount)
+	data = append(data, 0)
+	return solanago.NewInstruction(metaplexMetadataProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(p.SenderTokenAccount, true, false),
+		solanago.NewAccountMeta(p.Sender, false, false),
+		solanago.NewAccountMeta(p.ReceiverTokenAccount, true, false),
+		solanago.NewAccountMeta(p.Receiver, false, false),
+		solanago.NewAccountMeta(mint, false, false),
+		solanago.NewAccountMeta(metadata, true, false),
+		solanago.NewAccountMeta(edition, false, false),
+		solanago.NewAccountMeta(ownerRecord, true, false),
+		solanago.NewAccountMeta(destinationRecord, true, false),
+		solanago.NewAccountMeta(p.Sender, false, true), // authority
+		solanago.NewAccountMeta(payer, true, true),
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+		solanago.NewAccountMeta(solanago.SysVarInstructionsPubkey, false, false),
+		solanago.NewAccountMeta(solanago.TokenProgramID, false, false),
+		solanago.NewAccountMeta(solanago.SPLAssociatedTokenAccountProgramID, false, false),
+		solanago.NewAccountMeta(rulesProgram, false, false),
+		solanago.NewAccountMeta(rules, false, false),
+	}, data), nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+var (
+	webhookURL        string
+	webhookSecretFile string
+)
+
+// registerNotifyFlags registers the flags configuring where transfer outcomes are reported.
+func registerNotifyFlags(fs *flag.FlagSet) {
+	fs.StringVar(&webhookURL, "webhook-url", "", "URL that receives a signed JSON POST for every confirmed or failed transfer")
+	fs.StringVar(&webhookSecretFile, "webhook-secret-file", "", "File containing the secret used to sign webhook payloads (HMAC-SHA256)")
+	fs.StringVar(&slackWebhookURL, "slack-webhook-url", "", "Slack incoming webhook URL to post transfer results to")
+	fs.StringVar(&discordWebhookURL, "discord-webhook-url", "", "Discord webhook URL to post transfer results to")
+	fs.BoolVar(&chatFailuresOnly, "chat-failures-only", false, "Only post failed transfers to Slack and Discord")
+}
+
+// transferEvent is the outcome of a transfer as reported to notifiers.
+type transferEvent struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Signature string    `json:"signature,omitempty"`
+	Explorer  string    `json:"explorer,omitempty"`
+	Amount    string    `json:"amount"`
+	Mint      string    `json:"mint,omitempty"`
+	Sender    string    `json:"sender,omitempty"`
+	Receiver  string    `json:"receiver"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+func newTransferEvent(record transferRecord) transferEvent {
+	return transferEvent{
+		ID:        record.ID,
+		Status:    record.Status,
+		Signature: record.Signature,
+		Explorer:  record.Explorer,
+		Amount:    record.Amount,
+		Mint:      record.Mint,
+		Sender:    record.Sender,
+		Receiver:  record.Receiver,
+		Error:     record.Error,
+		Time:      record.UpdatedAt,
+	}
+}
+
+// notifier delivers transfer outcomes to an external system.
+type notifier interface {
+	Name() string
+	// Notify delivers event once. Errors wrapping errPermanent aren't retried.
+	Notify(ctx context.Context, event transferEvent) error
+}
+
+// errPermanent marks a delivery failure that retrying won't fix, e.g. a 4xx response.
+var errPermanent = errors.New("permanent failure")
+
+const (
+	// maxNotifyAttempts is how often delivery of an event to one notifier is attempted.
+	maxNotifyAttempts = 5
+	// notifyBackoff is the delay before the first retry; it doubles with every attempt.
+	notifyBackoff = time.Second
+	// notifyFlushTimeout bounds how long the process waits for outstanding deliveries on exit.
+	notifyFlushTimeout = 30 * time.Second
+)
+
+// notifications delivers events to the configured notifiers in the background. It is nil unless a command
+// enabled notifications.
+var notifications *dispatcher
+
+type dispatcher struct {
+	notifiers []notifier
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// setupNotifications enables the notifiers configured by the flags registered with registerNotifyFlags.
+func setupNotifications() error {
+	var notifiers []notifier
+	if webhookURL != "" {
+		n, err := newWebhookNotifier(webhookURL, webhookSecretFile)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if slackWebhookURL != "" {
+		n, err := newChatNotifier("slack", slackWebhookURL, "text", chatFailuresOnly)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if discordWebhookURL != "" {
+		n, err := newChatNotifier("discord", discordWebhookURL, "content", chatFailuresOnly)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	notifications = &dispatcher{notifiers: notifiers, ctx: ctx, cancel: cancel}
+	return nil
+}
+
+// shutdownNotifications waits for outstanding deliveries, giving up after notifyFlushTimeout.
+func shutdownNotifications() {
+	if notifications == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		notifications.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(notifyFlushTimeout):
+		slog.Warn("gave up waiting for notifications to be delivered")
+	}
+	notifications.cancel()
+}
+
+// notifyTransfer reports the outcome in record to every notifier, in the background.
+func notifyTransfer(record transferRecord) {
+	if notifications == nil {
+		return
+	}
+	event := newTransferEvent(record)
+	for _, n := range notifications.notifiers {
+		notifications.wg.Add(1)
+		go func() {
+			defer notifications.wg.Done()
+			deliver(notifications.ctx, n, event)
+		}()
+	}
+}
+
+// deliver sends event to n, retrying transient failures with jittered exponential backoff.
+func deliver(ctx context.Context, n notifier, event transferEvent) {
+	for attempt := 1; ; attempt++ {
+		err := n.Notify(ctx, event)
+		if err == nil {
+			slog.Debug("notification delivered", "notifier", n.Name(), "id", event.ID, "status", event.Status)
+			return
+		}
+		if errors.Is(err, errPermanent) || attempt == maxNotifyAttempts {
+			slog.Error("can't deliver notification", "notifier", n.Name(), "id", event.ID, "attempts", attempt, "error", err)
+			return
+		}
+		backoff := notifyBackoff << (attempt - 1)
+		delay := backoff/2 + rand.N(backoff/2+1)
+		slog.Warn("notification failed, retrying", "notifier", n.Name(), "id", event.ID, "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// postStatusError classifies an HTTP response status of a notification endpoint: 2xx is success, 429 and 5xx
+// are retried, anything else is permanent.
+func postStatusError(status int) error {
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == 429 || status >= 500:
+		return fmt.Errorf("HTTP %d", status)
+	}
+	return fmt.Errorf("%w: HTTP %d", errPermanent, status)
+}
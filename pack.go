@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	solanago "github.com/gagliardetto/solana-go"
+	ata "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// maxTransactionSize is the largest serialized transaction the cluster accepts.
+const maxTransactionSize = 1232
+
+// maxPackLookahead bounds how many queued transfers are considered for one pack. Even with lookup tables far fewer
+// transfers fit in a transaction.
+const maxPackLookahead = 64
+
+// queuedTransfer is a batch row journaled under transfer record ID and waiting to be sent.
+type queuedTransfer struct {
+	batchRow
+	ID string
+}
+
+// batchTransferInstructions returns the instructions paying every transfer from sender's token account,
+// creating the receiver ATAs in missing first at payer's expense.
+func batchTransferInstructions(sender, payer, mint solanago.PublicKey, transfers []queuedTransfer, missing map[solanago.PublicKey]bool) ([]solanago.Instruction, error) {
+	senderAta, _, err := solanago.FindAssociatedTokenAddress(sender, mint)
+	if err != nil {
+		return nil, fmt.Errorf("can't get ATA for sender %s: %v", sender, err)
+	}
+	var instructions []solanago.Instruction
+	created := map[solanago.PublicKey]bool{}
+	for _, t := range transfers {
+		receiverAta, _, err := solanago.FindAssociatedTokenAddress(t.Receiver, mint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, t.Receiver, err)
+		}
+		if missing[receiverAta] && !created[receiverAta] {
+			created[receiverAta] = true
+			instructions = append(instructions, ata.NewCreateInstruction(payer, t.Receiver, mint).Build())
+		}
+		instructions = append(instructions, token.NewTransferInstruction(t.RawAmount, senderAta, receiverAta, sender, nil).Build())
+	}
+	return instructions, nil
+}
+
+// buildBatchTransaction builds an unsigned transaction paying every transfer, followed by opts.PostInstructions,
+// in the format selected by opts.
+func buildBatchTransaction(sender, mint solanago.PublicKey, transfers []queuedTransfer, missing map[solanago.PublicKey]bool, blockhash solanago.Hash, opts TransferOptions) (*solanago.Transaction, error) {
+	payer := opts.payer(sender)
+	transferInstructions, err := batchTransferInstructions(sender, payer, mint, transfers, missing)
+	if err != nil {
+		return nil, err
+	}
+	instructions := append(slices.Clip(opts.PreInstructions), transferInstructions...)
+	instructions = append(instructions, opts.PostInstructions...)
+	txOpts := []solanago.TransactionOption{solanago.TransactionPayer(payer)}
+	if len(opts.AddressTables) > 0 {
+		txOpts = append(txOpts, solanago.TransactionAddressTables(opts.AddressTables))
+	}
+	tx, err := solanago.NewTransaction(instructions, blockhash, txOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("can't build transaction: %v", err)
+	}
+	if opts.Versioned {
+		tx.Message.SetVersion(solanago.MessageVersionV0)
+	}
+	return tx, nil
+}
+
+// transactionSize returns the serialized size of tx once signed: the signature count, the signatures and the
+// message.
+func transactionSize(tx *solanago.Transaction) (int, error) {
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return 1 + 64*int(tx.Message.Header.NumRequiredSignatures) + len(message), nil
+}
+
+// packSize returns how many transfers from the start of queue fit in one transaction, at most maxPerTx (0 means
+// no limit). It is always at least 1.
+func packSize(sender, mint solanago.PublicKey, queue []queuedTransfer, missing map[solanago.PublicKey]bool, opts TransferOptions, maxPerTx int) (int, error) {
+	limit := len(queue)
+	if maxPerTx > 0 {
+		limit = min(limit, maxPerTx)
+	}
+	fits := 1
+	for n := 2; n <= limit; n++ {
+		tx, err := buildBatchTransaction(sender, mint, queue[:n], missing, solanago.Hash{}, opts)
+		if err != nil {
+			return 0, err
+		}
+		size, err := transactionSize(tx)
+		if err != nil {
+			return 0, err
+		}
+		if size > maxTransactionSize {
+			break
+		}
+		fits = n
+	}
+	return fits, nil
+}
+
+// nextPack returns the transfers from the start of queue to send in the next transaction, and the receiver ATAs
+// it creates. The pack is as large as fits the size limit and is then verified by simulation, halving it
+// until it passes (e.g. when it exceeds the compute limit). A single transfer that fails simulation is returned
+// with the simulation error.
+func nextPack(ctx context.Context, client RPCClient, signer Signer, mint solanago.PublicKey, queue []queuedTransfer, opts TransferOptions, maxPerTx int) ([]queuedTransfer, map[solanago.PublicKey]bool, error) {
+	sender := signer.PublicKey()
+	// Check ATAs just before packing: earlier packs may have created some of them. Only the head of the queue
+	// can end up in this pack, so look up no more than that.
+	window := queue[:min(len(queue), maxPackLookahead)]
+	rows := make([]batchRow, len(window))
+	for i, t := range window {
+		rows[i] = t.batchRow
+	}
+	missing, err := missingATAs(ctx, client, mint, rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n, err := packSize(sender, mint, window, missing, opts, maxPerTx)
+	if err != nil {
+		return nil, nil, err
+	}
+	pack := window[:n]
+	for {
+		err := simulatePack(ctx, client, signer, mint, pack, missing, opts)
+		if err == nil || len(pack) == 1 {
+			return pack, packAccounts(pack, mint, missing), err
+		}
+		slog.Debug("pack failed simulation, splitting it", "transfers", len(pack), "error", err)
+		pack = pack[:len(pack)/2]
+	}
+}
+
+// packAccounts returns the receiver ATAs in missing that pack creates.
+func packAccounts(pack []queuedTransfer, mint solanago.PublicKey, missing map[solanago.PublicKey]bool) map[solanago.PublicKey]bool {
+	accounts := map[solanago.PublicKey]bool{}
+	for _, t := range pack {
+		receiverAta, _, err := solanago.FindAssociatedTokenAddress(t.Receiver, mint)
+		if err == nil && missing[receiverAta] {
+			accounts[receiverAta] = true
+		}
+	}
+	return accounts
+}
+
+// simulatePack simulates the transaction for pack without logging program output. The transaction isn't signed:
+// packs are simulated before signing, so the signatures are zero and not verified.
+func simulatePack(ctx context.Context, client RPCClient, signer Signer, mint solanago.PublicKey, pack []queuedTransfer, missing map[solanago.PublicKey]bool, opts TransferOptions) error {
+	tx, err := buildBatchTransaction(signer.PublicKey(), mint, pack, missing, solanago.Hash{}, opts)
+	if err != nil {
+		return err
+	}
+	// A transaction only serializes with one signature per required signer.
+	tx.Signatures = make([]solanago.Signature, tx.Message.Header.NumRequiredSignatures)
+	res, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		SigVerify:              false,
+		Commitment:             rpc.CommitmentConfirmed,
+		ReplaceRecentBlockhash: true,
+	})
+	if err != nil {
+		return fmt.Errorf("can't simulate transaction: %w", classifyRPCError(err))
+	}
+	if res.Value.Err != nil {
+		return simulationError(res.Value)
+	}
+	return nil
+}
+
+// sendPack sends one transaction paying every transfer in pack, journaling its signature on all of their records
+// before broadcasting and the outcome afterwards.
+func sendPack(ctx context.Context, store *Store, clients Clients, signer Signer, mint solanago.PublicKey, pack []queuedTransfer, missing map[solanago.PublicKey]bool, opts TransferOptions) (solanago.Signature, error) {
+	ids := make([]string, len(pack))
+	for i, t := range pack {
+		ids[i] = t.ID
+	}
+	sig, err := clients.Sender.SendAndConfirm(ctx, func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		tx, err := buildBatchTransaction(signer.PublicKey(), mint, pack, missing, blockhash, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := signTransaction(tx, signer, opts.signers()...); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}, journalSignature(store, ids...))
+	if err != nil {
+		err = fmt.Errorf("can't send transaction: %w", classifySendError(err))
+	} else {
+		markCreatedAccounts(store, mint, pack, missing)
+	}
+	for _, id := range ids {
+		recordOutcome(store, id, err)
+	}
+	return sig, err
+}
+
+// markCreatedAccounts records which transfers of a confirmed pack created their receiver's token account: the
+// first transfer to each receiver in missing.
+func markCreatedAccounts(store *Store, mint solanago.PublicKey, pack []queuedTransfer, missing map[solanago.PublicKey]bool) {
+	created := map[solanago.PublicKey]bool{}
+	for _, t := range pack {
+		receiverAta, _, err := solanago.FindAssociatedTokenAddress(t.Receiver, mint)
+		if err != nil || !missing[receiverAta] || created[receiverAta] {
+			continue
+		}
+		created[receiverAta] = true
+		if err := store.UpdateTransfer(t.ID, func(record *transferRecord) { record.CreatedAccount = true }); err != nil {
+			slog.Error("can't record transfer outcome", "id", t.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	solanago "github.com/gagliardetto/solana-go"
+
+	"github.com/csknk/token-transfer/pkg/rpcfake"
+)
+
+// testKey returns a fixed private key derived from seed.
+func testKey(seed byte) solanago.PrivateKey {
+	s := make([]byte, ed25519.SeedSize)
+	s[0] = seed
+	return solanago.PrivateKey(ed25519.NewKeyFromSeed(s))
+}
+
+// testQueue returns n queued transfers of 1 raw unit to distinct receivers.
+func testQueue(n int) []queuedTransfer {
+	queue := make([]queuedTransfer, n)
+	for i := range queue {
+		queue[i] = queuedTransfer{
+			batchRow: batchRow{Line: i + 1, Receiver: testKey(byte(100 + i)).PublicKey(), Amount: "1", RawAmount: 1},
+			ID:       string(rune('a' + i)),
+		}
+	}
+	return queue
+}
+
+func TestNextPackSimulatesSeveralTransfers(t *testing.T) {
+	client := rpcfake.New()
+	signer := testKey(1)
+	mint := testKey(2).PublicKey()
+
+	pack, created, err := nextPack(context.Background(), client, signer, mint, testQueue(3), TransferOptions{}, 0)
+	if err != nil {
+		t.Fatalf("nextPack: %v", err)
+	}
+	if len(pack) != 3 {
+		t.Errorf("packed %d transfers, want 3", len(pack))
+	}
+	if len(created) == 0 {
+		t.Errorf("pack creates no receiver accounts, but none exist")
+	}
+	simulated := client.Simulated()
+	if len(simulated) != 1 {
+		t.Fatalf("simulated %d transactions, want 1", len(simulated))
+	}
+	tx := simulated[0]
+	if len(tx.Signatures) != int(tx.Message.Header.NumRequiredSignatures) {
+		t.Errorf("simulated transaction has %d signatures, want %d", len(tx.Signatures), tx.Message.Header.NumRequiredSignatures)
+	}
+}
+
+func TestNextPackSplitsFailingPack(t *testing.T) {
+	client := rpcfake.New()
+	client.SimulateErr = map[string]any{"InstructionError": []any{0, map[string]any{"Custom": 1}}}
+
+	pack, _, err := nextPack(context.Background(), client, testKey(1), testKey(2).PublicKey(), testQueue(4), TransferOptions{}, 0)
+	if !errors.Is(err, ErrSimulationFailed) {
+		t.Fatalf("nextPack = %v, want ErrSimulationFailed", err)
+	}
+	if len(pack) != 1 {
+		t.Errorf("packed %d transfers, want the failing one alone", len(pack))
+	}
+	// 4, 2 and then 1 transfer.
+	if n := len(client.Simulated()); n != 3 {
+		t.Errorf("simulated %d transactions, want 3", n)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Parquet and Thrift compact protocol constants used by writeParquet.
+const (
+	parquetMagic = "PAR1"
+
+	parquetByteArray    = 6 // physical type BYTE_ARRAY
+	parquetRequired     = 0 // field repetition REQUIRED
+	parquetUTF8         = 0 // converted type UTF8
+	parquetPlain        = 0 // encoding PLAIN
+	parquetRLE          = 3 // encoding RLE
+	parquetDataPage     = 0 // page type DATA_PAGE
+	parquetUncompressed = 0 // codec UNCOMPRESSED
+
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// writeParquet writes rows as a Parquet file with one required UTF8 string column per name in columns, in a
+// single uncompressed row group with one PLAIN encoded data page per column. That's all an export needs, and every
+// Parquet reader understands it.
+func writeParquet(w io.Writer, columns []string, rows [][]string) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	chunks := make([]func(t *thriftWriter), len(columns))
+	var totalSize int64
+	for i, name := range columns {
+		var page bytes.Buffer
+		for _, row := range rows {
+			binary.Write(&page, binary.LittleEndian, uint32(len(row[i])))
+			page.WriteString(row[i])
+		}
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.structField(5, func(t *thriftWriter) {
+			t.i32(1, int32(len(rows)))
+			t.i32(2, parquetPlain)
+			t.i32(3, parquetRLE)
+			t.i32(4, parquetRLE)
+		})
+		header.stop()
+
+		offset := int64(file.Len())
+		size := int64(header.buf.Len() + page.Len())
+		totalSize += size
+		file.Write(header.buf.Bytes())
+		file.Write(page.Bytes())
+
+		chunks[i] = func(t *thriftWriter) {
+			t.i64(2, offset)
+			t.structField(3, func(t *thriftWriter) {
+				t.i32(1, parquetByteArray)
+				t.listI32(2, parquetPlain, parquetRLE)
+				t.listString(3, name)
+				t.i32(4, parquetUncompressed)
+				t.i64(5, int64(len(rows)))
+				t.i64(6, size)
+				t.i64(7, size)
+				t.i64(9, offset)
+			})
+		}
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	elements := []func(t *thriftWriter){func(t *thriftWriter) {
+		t.binary(4, "schema")
+		t.i32(5, int32(len(columns)))
+	}}
+	for _, name := range columns {
+		elements = append(elements, func(t *thriftWriter) {
+			t.i32(1, parquetByteArray)
+			t.i32(3, parquetRequired)
+			t.binary(4, name)
+			t.i32(6, parquetUTF8)
+		})
+	}
+	meta.listStruct(2, elements...)
+	meta.i64(3, int64(len(rows)))
+	meta.listStruct(4, func(t *thriftWriter) {
+		t.listStruct(1, chunks...)
+		t.i64(2, totalSize)
+		t.i64(3, int64(len(rows)))
+	})
+	meta.binary(6, "token-transfer")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// thriftWriter encodes a struct with the Thrift compact protocol, which Parquet uses for its page headers and
+// footer. Fields must be written in increasing id order.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) listHeader(id int16, size int, elem byte) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) listI32(id int16, values ...int32) {
+	t.listHeader(id, len(values), thriftI32)
+	for _, v := range values {
+		t.varint(zigzag(int64(v)))
+	}
+}
+
+func (t *thriftWriter) listString(id int16, values ...string) {
+	t.listHeader(id, len(values), thriftBinary)
+	for _, v := range values {
+		t.varint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+// structField writes a nested struct whose fields fn writes.
+func (t *thriftWriter) structField(id int16, fn func(t *thriftWriter)) {
+	t.field(id, thriftStruct)
+	t.nested(fn)
+}
+
+func (t *thriftWriter) listStruct(id int16, elements ...func(t *thriftWriter)) {
+	t.listHeader(id, len(elements), thriftStruct)
+	for _, fn := range elements {
+		t.nested(fn)
+	}
+}
+
+func (t *thriftWriter) nested(fn func(t *thriftWriter)) {
+	last := t.last
+	t.last = 0
+	fn(t)
+	t.stop()
+	t.last = last
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Payment request states.
+const (
+	paymentPending = "pending"
+	paymentPaid    = "paid"
+	paymentExpired = "expired"
+)
+
+// paymentRequest is a Solana Pay transfer request for the configured token. Each request gets a unique reference
+// key which the payer's wallet adds to the transfer, so the payment can be found on-chain.
+type paymentRequest struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Recipient string    `json:"recipient"`
+	Mint      string    `json:"mint"`
+	Amount    string    `json:"amount"`
+	Reference string    `json:"reference"`
+	Label     string    `json:"label,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Memo      string    `json:"memo,omitempty"`
+	Status    string    `json:"status"`
+	Signature string    `json:"signature,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	reference solanago.PublicKey
+	rawAmount uint64
+}
+
+// paymentRequests holds outstanding payment requests in memory.
+type paymentRequests struct {
+	server     *server
+	recipient  solanago.PublicKey
+	ttl        time.Duration
+	maxPending int
+
+	mu       sync.Mutex
+	requests map[string]*paymentRequest
+}
+
+func newPaymentRequests(s *server, recipient solanago.PublicKey, ttl time.Duration, maxPending int) *paymentRequests {
+	return &paymentRequests{
+		server:     s,
+		recipient:  recipient,
+		ttl:        ttl,
+		maxPending: maxPending,
+		requests:   map[string]*paymentRequest{},
+	}
+}
+
+type createPaymentRequest struct {
+	Amount  string `json:"amount"`
+	Label   string `json:"label"`
+	Message string `json:"message"`
+	Memo    string `json:"memo"`
+}
+
+// handleCreate serves POST /pay: it registers a new payment request and returns its Solana Pay URL.
+func (p *paymentRequests) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var body createPaymentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	rawAmount, err := parseUIAmount(body.Amount, p.server.decimals)
+	if err != nil || rawAmount == 0 {
+		writeError(w, http.StatusBadRequest, "amount must be a positive decimal number")
+		return
+	}
+	if len(body.Label) > 128 || len(body.Message) > 256 || len(body.Memo) > 256 {
+		writeError(w, http.StatusBadRequest, "label, message or memo too long")
+		return
+	}
+
+	reference, err := solanago.NewRandomPrivateKey()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "can't generate reference")
+		return
+	}
+	now := time.Now().UTC()
+	req := &paymentRequest{
+		ID:        newRequestID(),
+		Recipient: p.recipient.String(),
+		Mint:      p.server.mint.String(),
+		Amount:    formatUIAmount(rawAmount, p.server.decimals),
+		Reference: reference.PublicKey().String(),
+		Label:     body.Label,
+		Message:   body.Message,
+		Memo:      body.Memo,
+		Status:    paymentPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.ttl),
+		reference: reference.PublicKey(),
+		rawAmount: rawAmount,
+	}
+	req.URL = solanaPayURL(req)
+
+	if !p.add(req) {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, "too many outstanding payment requests")
+		return
+	}
+	slog.Info("payment request created", "id", req.ID, "amount", req.Amount, "reference", req.Reference)
+	writeJSON(w, http.StatusCreated, req)
+}
+
+// handleStatus serves GET /pay/{id}, checking the chain for a matching payment while the request is pending.
+func (p *paymentRequests) handleStatus(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	req, ok := p.requests[r.PathValue("id")]
+	var snapshot paymentRequest
+	if ok {
+		snapshot = *req
+	}
+	p.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "payment request not found")
+		return
+	}
+
+	if snapshot.Status == paymentPending {
+		sig, err := p.findPayment(r.Context(), &snapshot)
+		if err != nil {
+			slog.Warn("can't check payment status", "id", snapshot.ID, "error", err)
+		}
+		p.mu.Lock()
+		switch {
+		case sig != "":
+			req.Status, req.Signature = paymentPaid, sig
+			slog.Info("payment received", "id", req.ID, "signature", sig)
+		case time.Now().After(req.ExpiresAt):
+			req.Status = paymentExpired
+		}
+		snapshot = *req
+		p.mu.Unlock()
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// add stores req unless the pending limit is reached. Expired requests are pruned first.
+func (p *paymentRequests) add(req *paymentRequest) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.requests) >= p.maxPending {
+		// Keep settled and expired requests around for a while so clients can still read their final status.
+		cutoff := time.Now().Add(-p.ttl)
+		for id, existing := range p.requests {
+			if existing.ExpiresAt.Before(cutoff) {
+				delete(p.requests, id)
+			}
+		}
+	}
+	if len(p.requests) >= p.maxPending {
+		return false
+	}
+	p.requests[req.ID] = req
+	return true
+}
+
+// findPayment looks for a successful transaction referencing req that credited the recipient with at least the
+// requested amount, returning its signature or "" if none has landed yet.
+func (p *paymentRequests) findPayment(ctx context.Context, req *paymentRequest) (string, error) {
+	limit := 10
+	sigs, err := p.server.clients.Read.GetSignaturesForAddressWithOpts(ctx, req.reference, &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return "", classifyRPCError(err)
+	}
+	for _, sig := range sigs {
+		if sig.Err != nil {
+			continue
+		}
+		received, err := p.received(ctx, sig.Signature)
+		if err != nil {
+			return "", err
+		}
+		if received >= req.rawAmount {
+			return sig.Signature.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// received returns how many raw tokens of the configured mint the recipient gained in transaction sig.
+func (p *paymentRequests) received(ctx context.Context, sig solanago.Signature) (uint64, error) {
+	return receivedAmount(ctx, p.server.clients.Read, sig, p.recipient, p.server.mint)
+}
+
+// solanaPayURL encodes req as a Solana Pay transfer request URL.
+func solanaPayURL(req *paymentRequest) string {
+	params := []struct{ key, value string }{
+		{"amount", req.Amount},
+		{"spl-token", req.Mint},
+		{"reference", req.Reference},
+		{"label", req.Label},
+		{"message", req.Message},
+		{"memo", req.Memo},
+	}
+	var query []string
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		// The spec asks for encodeURIComponent style escaping, which uses %20 rather than + for spaces.
+		query = append(query, param.key+"="+strings.ReplaceAll(url.QueryEscape(param.value), "+", "%20"))
+	}
+	return "solana:" + req.Recipient + "?" + strings.Join(query, "&")
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
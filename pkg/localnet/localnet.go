@@ -0,0 +1,314 @@
+// Package localnet runs a throwaway solana-test-validator and sets up the accounts a transfer needs on it: funded
+// wallets, mints of either token program and token balances. It lets the transfer pipeline be exercised end to end
+// without touching devnet. The validator's genesis already includes the SPL Token, Token-2022 and associated token
+// account programs; other programs, e.g. a transfer hook, are deployed from their .so files at startup.
+package localnet
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// ErrNotInstalled is returned by Start when solana-test-validator isn't on the PATH, so callers can skip rather
+// than fail.
+var ErrNotInstalled = errors.New("solana-test-validator not found")
+
+// mintSize is the size of a mint account without extensions.
+const mintSize = 82
+
+// Token program instructions, by their index in the program's instruction enum.
+const (
+	instructionMintTo          = 7
+	instructionInitializeMint2 = 20
+)
+
+// Options configures the validator started by Start.
+type Options struct {
+	// Binary is the validator executable. Defaults to solana-test-validator.
+	Binary string
+	// RPCPort is the port of the JSON RPC endpoint; the websocket endpoint is the next one. Defaults to a free port.
+	RPCPort int
+	// Programs maps program IDs to the .so files to deploy at those addresses.
+	Programs map[solanago.PublicKey]string
+	// StartTimeout bounds the wait for the validator to become healthy. Defaults to a minute.
+	StartTimeout time.Duration
+	// Log receives the validator's output. Defaults to discarding it.
+	Log io.Writer
+}
+
+// Validator is a running solana-test-validator with a fresh ledger.
+type Validator struct {
+	// RPC and WS are the validator's endpoints, e.g. for --rpc-url.
+	RPC, WS string
+	Client  *rpc.Client
+
+	cmd    *exec.Cmd
+	ledger string
+	exited chan error
+}
+
+// Start launches a validator with an empty ledger and waits until it serves requests. Stop must be called to shut
+// it down and delete the ledger.
+func Start(ctx context.Context, opts Options) (*Validator, error) {
+	if opts.Binary == "" {
+		opts.Binary = "solana-test-validator"
+	}
+	exe, err := exec.LookPath(opts.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotInstalled, err)
+	}
+	if opts.RPCPort == 0 {
+		if opts.RPCPort, err = freePort(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = time.Minute
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	ledger, err := os.MkdirTemp("", "localnet-ledger-")
+	if err != nil {
+		return nil, fmt.Errorf("can't create ledger directory: %v", err)
+	}
+
+	// The faucet and gossip ports are derived from the RPC port so that several validators can run side by side.
+	args := []string{
+		"--ledger", ledger,
+		"--reset",
+		"--quiet",
+		"--rpc-port", strconv.Itoa(opts.RPCPort),
+		"--faucet-port", strconv.Itoa(opts.RPCPort + 2),
+		"--gossip-port", strconv.Itoa(opts.RPCPort + 3),
+		"--dynamic-port-range", fmt.Sprintf("%d-%d", opts.RPCPort+4, opts.RPCPort+40),
+	}
+	for id, path := range opts.Programs {
+		args = append(args, "--bpf-program", id.String(), path)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = opts.Log, opts.Log
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(ledger)
+		return nil, fmt.Errorf("can't start %s: %v", exe, err)
+	}
+	v := &Validator{
+		RPC:    "http://127.0.0.1:" + strconv.Itoa(opts.RPCPort),
+		WS:     "ws://127.0.0.1:" + strconv.Itoa(opts.RPCPort+1),
+		cmd:    cmd,
+		ledger: ledger,
+		exited: make(chan error, 1),
+	}
+	v.Client = rpc.New(v.RPC)
+	go func() { v.exited <- cmd.Wait() }()
+
+	if err := v.waitHealthy(ctx, opts.StartTimeout); err != nil {
+		v.Stop()
+		return nil, err
+	}
+	return v, nil
+}
+
+// waitHealthy polls getHealth until the validator reports ok, exits, or timeout passes.
+func (v *Validator) waitHealthy(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if health, err := v.Client.GetHealth(ctx); err == nil && health == rpc.HealthOk {
+			return nil
+		}
+		select {
+		case err := <-v.exited:
+			v.exited <- err
+			return fmt.Errorf("validator exited during startup: %v", err)
+		case <-ctx.Done():
+			return fmt.Errorf("validator not healthy within %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop shuts the validator down and deletes its ledger.
+func (v *Validator) Stop() error {
+	if v.cmd.Process != nil {
+		v.cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-v.exited:
+		case <-time.After(10 * time.Second):
+			v.cmd.Process.Kill()
+			<-v.exited
+		}
+	}
+	v.Client.Close()
+	return os.RemoveAll(v.ledger)
+}
+
+// NewWallet returns a new keypair funded with lamports from the validator's faucet.
+func (v *Validator) NewWallet(ctx context.Context, lamports uint64) (solanago.PrivateKey, error) {
+	key, err := solanago.NewRandomPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("can't generate keypair: %v", err)
+	}
+	if err := v.Airdrop(ctx, key.PublicKey(), lamports); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Airdrop funds an account from the validator's faucet and waits for it to be confirmed.
+func (v *Validator) Airdrop(ctx context.Context, to solanago.PublicKey, lamports uint64) error {
+	sig, err := v.Client.RequestAirdrop(ctx, to, lamports, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't airdrop to %s: %v", to, err)
+	}
+	return v.waitConfirmed(ctx, sig)
+}
+
+// CreateMint creates a mint of program (the SPL Token or Token-2022 program) with authority as its mint and freeze
+// authority, paid for by authority.
+func (v *Validator) CreateMint(ctx context.Context, authority solanago.PrivateKey, program solanago.PublicKey, decimals uint8) (solanago.PublicKey, error) {
+	mint, err := solanago.NewRandomPrivateKey()
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't generate mint keypair: %v", err)
+	}
+	rent, err := v.Client.GetMinimumBalanceForRentExemption(ctx, mintSize, rpc.CommitmentConfirmed)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't get rent for mint: %v", err)
+	}
+
+	// CreateAccount (system instruction 0): lamports, space, owner.
+	create := binary.LittleEndian.AppendUint32(nil, 0)
+	create = binary.LittleEndian.AppendUint64(create, rent)
+	create = binary.LittleEndian.AppendUint64(create, mintSize)
+	create = append(create, program[:]...)
+	// InitializeMint2: decimals, mint authority, then an optional freeze authority.
+	initialize := append([]byte{instructionInitializeMint2, decimals}, authority.PublicKey().Bytes()...)
+	initialize = append(append(initialize, 1), authority.PublicKey().Bytes()...)
+
+	err = v.send(ctx, authority, []solanago.PrivateKey{mint},
+		solanago.NewInstruction(solanago.SystemProgramID, solanago.AccountMetaSlice{
+			solanago.NewAccountMeta(authority.PublicKey(), true, true),
+			solanago.NewAccountMeta(mint.PublicKey(), true, true),
+		}, create),
+		solanago.NewInstruction(program, solanago.AccountMetaSlice{
+			solanago.NewAccountMeta(mint.PublicKey(), true, false),
+		}, initialize),
+	)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't create mint: %w", err)
+	}
+	return mint.PublicKey(), nil
+}
+
+// MintTo mints amount base units of mint to owner's associated token account, creating it if needed, and returns
+// the account. authority must be the mint authority and pays for the account.
+func (v *Validator) MintTo(ctx context.Context, authority solanago.PrivateKey, program, mint, owner solanago.PublicKey, amount uint64) (solanago.PublicKey, error) {
+	account, err := TokenAccount(program, mint, owner)
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	err = v.send(ctx, authority, nil,
+		// CreateIdempotent (1) doesn't fail if the account already exists.
+		solanago.NewInstruction(solanago.SPLAssociatedTokenAccountProgramID, solanago.AccountMetaSlice{
+			solanago.NewAccountMeta(authority.PublicKey(), true, true),
+			solanago.NewAccountMeta(account, true, false),
+			solanago.NewAccountMeta(owner, false, false),
+			solanago.NewAccountMeta(mint, false, false),
+			solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+			solanago.NewAccountMeta(program, false, false),
+		}, []byte{1}),
+		solanago.NewInstruction(program, solanago.AccountMetaSlice{
+			solanago.NewAccountMeta(mint, true, false),
+			solanago.NewAccountMeta(account, true, false),
+			solanago.NewAccountMeta(authority.PublicKey(), false, true),
+		}, binary.LittleEndian.AppendUint64([]byte{instructionMintTo}, amount)),
+	)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't mint to %s: %w", owner, err)
+	}
+	return account, nil
+}
+
+// TokenAccount returns owner's associated token account for mint of program.
+func TokenAccount(program, mint, owner solanago.PublicKey) (solanago.PublicKey, error) {
+	account, _, err := solanago.FindProgramAddress([][]byte{owner[:], program[:], mint[:]}, solanago.SPLAssociatedTokenAccountProgramID)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't derive token account of %s: %v", owner, err)
+	}
+	return account, nil
+}
+
+// send signs instructions with payer and signers, sends them, and waits for the transaction to be confirmed.
+func (v *Validator) send(ctx context.Context, payer solanago.PrivateKey, signers []solanago.PrivateKey, instructions ...solanago.Instruction) error {
+	blockhash, err := v.Client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get blockhash: %v", err)
+	}
+	tx, err := solanago.NewTransaction(instructions, blockhash.Value.Blockhash, solanago.TransactionPayer(payer.PublicKey()))
+	if err != nil {
+		return err
+	}
+	keys := append([]solanago.PrivateKey{payer}, signers...)
+	_, err = tx.Sign(func(key solanago.PublicKey) *solanago.PrivateKey {
+		for i := range keys {
+			if keys[i].PublicKey().Equals(key) {
+				return &keys[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't sign transaction: %v", err)
+	}
+	sig, err := v.Client.SendTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return v.waitConfirmed(ctx, sig)
+}
+
+// waitConfirmed polls a transaction's status until it's confirmed, fails, or ctx is done.
+func (v *Validator) waitConfirmed(ctx context.Context, sig solanago.Signature) error {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		res, err := v.Client.GetSignatureStatuses(ctx, false, sig)
+		if err == nil && len(res.Value) > 0 && res.Value[0] != nil {
+			status := res.Value[0]
+			if status.Err != nil {
+				return fmt.Errorf("transaction %s failed: %v", sig, status.Err)
+			}
+			if status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed || status.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("transaction %s not confirmed: %v", sig, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// freePort returns a TCP port that's free at the time of the call.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("can't find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
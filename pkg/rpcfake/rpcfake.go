@@ -0,0 +1,451 @@
+// Package rpcfake is an in-memory stand-in for a Solana RPC node, so code written against the RPC API runs without
+// a cluster. It serves the accounts, transactions and signature statuses a caller sets up and records the
+// transactions sent to it. It doesn't execute them: a sent transaction is confirmed right away, or fails with
+// TxErr, and changes no accounts.
+package rpcfake
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// tokenAccountSize is the size of an SPL token account without extensions.
+const tokenAccountSize = 165
+
+// blockhashLifetime is how many blocks a blockhash stays valid for.
+const blockhashLifetime = 150
+
+// Client is a fake RPC client. The exported fields may be set before the client is used.
+type Client struct {
+	// SendErr, if set, is returned by SendTransactionWithOpts instead of accepting the transaction, like an RPC
+	// node rejecting it in preflight.
+	SendErr error
+	// TxErr is the on-chain error sent transactions fail with, e.g. map[string]any{"InstructionError": ...}. Nil
+	// means they succeed.
+	TxErr any
+	// SimulateErr and SimulateLogs are what simulations report.
+	SimulateErr  any
+	SimulateLogs []string
+
+	mu           sync.Mutex
+	slot         uint64
+	blockHeight  uint64
+	blockhash    solanago.Hash
+	validUntil   map[solanago.Hash]uint64
+	accounts     map[solanago.PublicKey]*rpc.Account
+	transactions map[solanago.Signature]*rpc.GetTransactionResult
+	statuses     map[solanago.Signature]*rpc.SignatureStatusesResult
+	history      map[solanago.PublicKey][]*rpc.TransactionSignature
+	sent         []*solanago.Transaction
+	simulated    []*solanago.Transaction
+	nonce        uint64
+}
+
+// New returns a client for an empty chain at slot 1.
+func New() *Client {
+	c := &Client{
+		validUntil:   map[solanago.Hash]uint64{},
+		accounts:     map[solanago.PublicKey]*rpc.Account{},
+		transactions: map[solanago.Signature]*rpc.GetTransactionResult{},
+		statuses:     map[solanago.Signature]*rpc.SignatureStatusesResult{},
+		history:      map[solanago.PublicKey][]*rpc.TransactionSignature{},
+	}
+	c.AdvanceBlocks(1)
+	return c
+}
+
+// AdvanceBlocks moves the chain n blocks forward and issues a new latest blockhash. Blockhashes older than 150
+// blocks expire.
+func (c *Client) AdvanceBlocks(n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slot += n
+	c.blockHeight += n
+	c.blockhash = solanago.Hash(sha256.Sum256(binary.LittleEndian.AppendUint64([]byte("blockhash"), c.blockHeight)))
+	c.validUntil[c.blockhash] = c.blockHeight + blockhashLifetime
+}
+
+// SetAccount creates or replaces an account.
+func (c *Client) SetAccount(address, owner solanago.PublicKey, lamports uint64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accounts[address] = &rpc.Account{
+		Lamports: lamports,
+		Owner:    owner,
+		Data:     rpc.DataBytesOrJSONFromBytes(slices.Clone(data)),
+		Space:    uint64(len(data)),
+	}
+}
+
+// DeleteAccount removes an account, e.g. one closed by a transaction.
+func (c *Client) DeleteAccount(address solanago.PublicKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.accounts, address)
+}
+
+// SetTransaction makes a landed transaction available to GetTransaction and GetSignatureStatuses, and adds it to the
+// signature history of accounts.
+func (c *Client) SetTransaction(sig solanago.Signature, tx *rpc.GetTransactionResult, accounts ...solanago.PublicKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var txErr any
+	if tx.Meta != nil {
+		txErr = tx.Meta.Err
+	}
+	c.transactions[sig] = tx
+	c.landLocked(sig, tx.Slot, txErr, accounts...)
+}
+
+// SetStatus sets the status GetSignatureStatuses reports for sig; nil makes it unknown.
+func (c *Client) SetStatus(sig solanago.Signature, status *rpc.SignatureStatusesResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if status == nil {
+		delete(c.statuses, sig)
+		return
+	}
+	c.statuses[sig] = status
+}
+
+// Sent returns the transactions accepted by SendTransactionWithOpts, in order.
+func (c *Client) Sent() []*solanago.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.sent)
+}
+
+// Simulated returns the transactions passed to SimulateTransactionWithOpts, in order.
+func (c *Client) Simulated() []*solanago.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.simulated)
+}
+
+// landLocked records sig as confirmed at slot in the history of accounts.
+func (c *Client) landLocked(sig solanago.Signature, slot uint64, txErr any, accounts ...solanago.PublicKey) {
+	c.statuses[sig] = &rpc.SignatureStatusesResult{Slot: slot, Err: txErr, ConfirmationStatus: rpc.ConfirmationStatusConfirmed}
+	entry := &rpc.TransactionSignature{Signature: sig, Slot: slot, Err: txErr, ConfirmationStatus: rpc.ConfirmationStatusConfirmed}
+	for _, account := range accounts {
+		// Newest first, like getSignaturesForAddress.
+		c.history[account] = append([]*rpc.TransactionSignature{entry}, c.history[account]...)
+	}
+}
+
+func (c *Client) context() rpc.RPCContext {
+	return rpc.RPCContext{Context: rpc.Context{Slot: c.slot}}
+}
+
+func (c *Client) GetAccountInfo(ctx context.Context, account solanago.PublicKey) (*rpc.GetAccountInfoResult, error) {
+	return c.GetAccountInfoWithOpts(ctx, account, nil)
+}
+
+func (c *Client) GetAccountInfoWithOpts(ctx context.Context, account solanago.PublicKey, opts *rpc.GetAccountInfoOpts) (*rpc.GetAccountInfoResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.accounts[account]
+	if !ok {
+		return nil, rpc.ErrNotFound
+	}
+	if opts != nil {
+		a = sliceData(a, opts.DataSlice)
+	}
+	return &rpc.GetAccountInfoResult{RPCContext: c.context(), Value: a}, nil
+}
+
+// sliceData returns a with only the part of its data selected by slice, or a itself if slice is nil.
+func sliceData(a *rpc.Account, slice *rpc.DataSlice) *rpc.Account {
+	if slice == nil {
+		return a
+	}
+	data := a.Data.GetBinary()
+	start, end := uint64(0), uint64(len(data))
+	if slice.Offset != nil {
+		start = min(*slice.Offset, end)
+	}
+	if slice.Length != nil {
+		end = min(start+*slice.Length, end)
+	}
+	sliced := *a
+	sliced.Data = rpc.DataBytesOrJSONFromBytes(slices.Clone(data[start:end]))
+	return &sliced
+}
+
+func (c *Client) GetMultipleAccounts(ctx context.Context, accounts ...solanago.PublicKey) (*rpc.GetMultipleAccountsResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &rpc.GetMultipleAccountsResult{RPCContext: c.context()}
+	for _, account := range accounts {
+		res.Value = append(res.Value, c.accounts[account])
+	}
+	return res, nil
+}
+
+func (c *Client) GetBalance(ctx context.Context, account solanago.PublicKey, commitment rpc.CommitmentType) (*rpc.GetBalanceResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &rpc.GetBalanceResult{RPCContext: c.context()}
+	if a, ok := c.accounts[account]; ok {
+		res.Value = a.Lamports
+	}
+	return res, nil
+}
+
+func (c *Client) GetTokenAccountsByOwner(ctx context.Context, owner solanago.PublicKey, conf *rpc.GetTokenAccountsConfig, opts *rpc.GetTokenAccountsOpts) (*rpc.GetTokenAccountsResult, error) {
+	if conf == nil || (conf.Mint == nil) == (conf.ProgramId == nil) {
+		return nil, errors.New("either a mint or a program ID is required")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &rpc.GetTokenAccountsResult{RPCContext: c.context()}
+	for _, address := range c.sortedAccounts() {
+		a := c.accounts[address]
+		if !a.Owner.Equals(solanago.TokenProgramID) && !a.Owner.Equals(solanago.Token2022ProgramID) {
+			continue
+		}
+		// A token account starts with its mint and owner.
+		data := a.Data.GetBinary()
+		if len(data) < tokenAccountSize || !bytes.Equal(data[32:64], owner[:]) {
+			continue
+		}
+		if conf.Mint != nil && !bytes.Equal(data[:32], conf.Mint[:]) || conf.ProgramId != nil && !a.Owner.Equals(*conf.ProgramId) {
+			continue
+		}
+		res.Value = append(res.Value, &rpc.TokenAccount{Pubkey: address, Account: a})
+	}
+	return res, nil
+}
+
+func (c *Client) GetProgramAccountsWithOpts(ctx context.Context, program solanago.PublicKey, opts *rpc.GetProgramAccountsOpts) (rpc.GetProgramAccountsResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var res rpc.GetProgramAccountsResult
+	for _, address := range c.sortedAccounts() {
+		a := c.accounts[address]
+		if !a.Owner.Equals(program) {
+			continue
+		}
+		if opts == nil {
+			res = append(res, &rpc.KeyedAccount{Pubkey: address, Account: a})
+			continue
+		}
+		if !matchFilters(a.Data.GetBinary(), opts.Filters) {
+			continue
+		}
+		res = append(res, &rpc.KeyedAccount{Pubkey: address, Account: sliceData(a, opts.DataSlice)})
+	}
+	return res, nil
+}
+
+// maxLargestAccounts is how many accounts getTokenLargestAccounts returns at most.
+const maxLargestAccounts = 20
+
+func (c *Client) GetTokenLargestAccounts(ctx context.Context, tokenMint solanago.PublicKey, commitment rpc.CommitmentType) (*rpc.GetTokenLargestAccountsResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mint, ok := c.accounts[tokenMint]
+	// The decimals follow the mint authority option and the supply.
+	if !ok || len(mint.Data.GetBinary()) < 45 {
+		return nil, errors.New("invalid param: not a Token mint")
+	}
+	decimals := mint.Data.GetBinary()[44]
+	type largest struct {
+		address solanago.PublicKey
+		amount  uint64
+	}
+	var accounts []largest
+	for _, address := range c.sortedAccounts() {
+		a := c.accounts[address]
+		data := a.Data.GetBinary()
+		if !a.Owner.Equals(mint.Owner) || len(data) < tokenAccountSize || !bytes.Equal(data[:32], tokenMint[:]) {
+			continue
+		}
+		accounts = append(accounts, largest{address: address, amount: binary.LittleEndian.Uint64(data[64:72])})
+	}
+	slices.SortStableFunc(accounts, func(a, b largest) int { return cmp.Compare(b.amount, a.amount) })
+	res := &rpc.GetTokenLargestAccountsResult{RPCContext: c.context()}
+	for _, a := range accounts[:min(len(accounts), maxLargestAccounts)] {
+		res.Value = append(res.Value, &rpc.TokenLargestAccountsResult{
+			Address:       a.address,
+			UiTokenAmount: rpc.UiTokenAmount{Amount: strconv.FormatUint(a.amount, 10), Decimals: decimals},
+		})
+	}
+	return res, nil
+}
+
+// matchFilters reports whether account data passes getProgramAccounts filters.
+func matchFilters(data []byte, filters []rpc.RPCFilter) bool {
+	for _, f := range filters {
+		if f.DataSize != 0 && uint64(len(data)) != f.DataSize {
+			return false
+		}
+		if m := f.Memcmp; m != nil {
+			if m.Offset+uint64(len(m.Bytes)) > uint64(len(data)) || !bytes.Equal(data[m.Offset:m.Offset+uint64(len(m.Bytes))], m.Bytes) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortedAccounts returns the account addresses in a stable order, for deterministic results.
+func (c *Client) sortedAccounts() []solanago.PublicKey {
+	addresses := make([]solanago.PublicKey, 0, len(c.accounts))
+	for address := range c.accounts {
+		addresses = append(addresses, address)
+	}
+	slices.SortFunc(addresses, func(a, b solanago.PublicKey) int { return bytes.Compare(a[:], b[:]) })
+	return addresses
+}
+
+// GetMinimumBalanceForRentExemption uses the mainnet rent rate.
+func (c *Client) GetMinimumBalanceForRentExemption(ctx context.Context, dataSize uint64, commitment rpc.CommitmentType) (uint64, error) {
+	// 3480 lamports per byte-year, for two years, including 128 bytes of account metadata.
+	return (128 + dataSize) * 3480 * 2, nil
+}
+
+func (c *Client) GetLatestBlockhash(ctx context.Context, commitment rpc.CommitmentType) (*rpc.GetLatestBlockhashResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &rpc.GetLatestBlockhashResult{
+		RPCContext: c.context(),
+		Value:      &rpc.LatestBlockhashResult{Blockhash: c.blockhash, LastValidBlockHeight: c.validUntil[c.blockhash]},
+	}, nil
+}
+
+func (c *Client) IsBlockhashValid(ctx context.Context, blockhash solanago.Hash, commitment rpc.CommitmentType) (*rpc.IsValidBlockhashResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	validUntil, ok := c.validUntil[blockhash]
+	return &rpc.IsValidBlockhashResult{RPCContext: c.context(), Value: ok && c.blockHeight <= validUntil}, nil
+}
+
+func (c *Client) GetBlockHeight(ctx context.Context, commitment rpc.CommitmentType) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blockHeight, nil
+}
+
+func (c *Client) GetSlot(ctx context.Context, commitment rpc.CommitmentType) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slot, nil
+}
+
+// SendTransactionWithOpts records tx and confirms it in the current slot, unless SendErr is set. The transaction
+// must be signed and use a valid blockhash.
+func (c *Client) SendTransactionWithOpts(ctx context.Context, tx *solanago.Transaction, opts rpc.TransactionOpts) (solanago.Signature, error) {
+	if c.SendErr != nil {
+		return solanago.Signature{}, c.SendErr
+	}
+	if len(tx.Signatures) == 0 {
+		return solanago.Signature{}, errors.New("transaction isn't signed")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if validUntil, ok := c.validUntil[tx.Message.RecentBlockhash]; !ok || c.blockHeight > validUntil {
+		return solanago.Signature{}, errors.New("Blockhash not found")
+	}
+	sig := tx.Signatures[0]
+	if _, ok := c.statuses[sig]; !ok {
+		c.sent = append(c.sent, tx)
+		c.landLocked(sig, c.slot, c.TxErr, tx.Message.AccountKeys...)
+	}
+	return sig, nil
+}
+
+// SimulateTransactionWithOpts records tx and reports SimulateErr and SimulateLogs. Like an RPC node, it rejects a
+// transaction that doesn't serialize, e.g. because it lacks signatures, and checks the signatures if opts asks for
+// it.
+func (c *Client) SimulateTransactionWithOpts(ctx context.Context, tx *solanago.Transaction, opts *rpc.SimulateTransactionOpts) (*rpc.SimulateTransactionResponse, error) {
+	if _, err := tx.MarshalBinary(); err != nil {
+		return nil, fmt.Errorf("simulate transaction: encode transaction: %w", err)
+	}
+	if opts != nil && opts.SigVerify {
+		if opts.ReplaceRecentBlockhash {
+			return nil, errors.New("sigVerify may not be used with replaceRecentBlockhash")
+		}
+		if err := tx.VerifySignatures(); err != nil {
+			return nil, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.simulated = append(c.simulated, tx)
+	units := uint64(0)
+	return &rpc.SimulateTransactionResponse{
+		RPCContext: c.context(),
+		Value:      &rpc.SimulateTransactionResult{Err: c.SimulateErr, Logs: slices.Clone(c.SimulateLogs), UnitsConsumed: &units},
+	}, nil
+}
+
+func (c *Client) GetSignatureStatuses(ctx context.Context, searchTransactionHistory bool, signatures ...solanago.Signature) (*rpc.GetSignatureStatusesResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &rpc.GetSignatureStatusesResult{RPCContext: c.context()}
+	for _, sig := range signatures {
+		res.Value = append(res.Value, c.statuses[sig])
+	}
+	return res, nil
+}
+
+func (c *Client) GetSignaturesForAddressWithOpts(ctx context.Context, account solanago.PublicKey, opts *rpc.GetSignaturesForAddressOpts) ([]*rpc.TransactionSignature, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sigs := c.history[account]
+	if opts != nil && !opts.Before.IsZero() {
+		i := slices.IndexFunc(sigs, func(s *rpc.TransactionSignature) bool { return s.Signature == opts.Before })
+		if i < 0 {
+			return nil, nil
+		}
+		sigs = sigs[i+1:]
+	}
+	limit := 1000
+	if opts != nil && opts.Limit != nil {
+		limit = *opts.Limit
+	}
+	return slices.Clone(sigs[:min(limit, len(sigs))]), nil
+}
+
+func (c *Client) GetTransaction(ctx context.Context, sig solanago.Signature, opts *rpc.GetTransactionOpts) (*rpc.GetTransactionResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.transactions[sig]
+	if !ok {
+		return nil, rpc.ErrNotFound
+	}
+	return tx, nil
+}
+
+// RequestAirdrop credits lamports to account, creating it as a system account if needed.
+func (c *Client) RequestAirdrop(ctx context.Context, account solanago.PublicKey, lamports uint64, commitment rpc.CommitmentType) (solanago.Signature, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.accounts[account]
+	if !ok {
+		a = &rpc.Account{Owner: solanago.SystemProgramID, Data: rpc.DataBytesOrJSONFromBytes(nil)}
+		c.accounts[account] = a
+	}
+	a.Lamports += lamports
+	c.nonce++
+	var sig solanago.Signature
+	h := sha256.Sum256(binary.LittleEndian.AppendUint64([]byte("airdrop"), c.nonce))
+	copy(sig[:], h[:])
+	c.landLocked(sig, c.slot, nil, account)
+	return sig, nil
+}
+
+func (c *Client) Close() error {
+	return nil
+}
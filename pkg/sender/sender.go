@@ -0,0 +1,241 @@
+// Package sender drives a Solana transaction from signing to confirmation: it broadcasts, polls for the
+// signature status, rebroadcasts while waiting, and re-signs with a fresh blockhash once the previous one has
+// expired without the transaction landing. The clock and RPC access are interfaces so the state machine can be
+// driven deterministically.
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+var (
+	// ErrExpired is returned when every attempt's blockhash expired before the transaction was confirmed.
+	ErrExpired = errors.New("blockhash expired before confirmation")
+	// ErrFailed is returned when the transaction landed but failed on-chain.
+	ErrFailed = errors.New("transaction failed")
+)
+
+// State is a step of the send loop.
+type State int
+
+const (
+	StateSigning   State = iota // fetching a blockhash and signing
+	StateSent                   // broadcast, waiting for confirmation
+	StateConfirmed              // confirmed; terminal
+	StateExpired                // blockhash expired without the transaction landing
+	StateFailed                 // failed on-chain or couldn't be sent; terminal
+)
+
+func (s State) String() string {
+	switch s {
+	case StateSigning:
+		return "signing"
+	case StateSent:
+		return "sent"
+	case StateConfirmed:
+		return "confirmed"
+	case StateExpired:
+		return "expired"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Clock abstracts time so tests can drive the loop without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock returns the wall clock.
+func SystemClock() Clock { return systemClock{} }
+
+// Blockhash is a recent blockhash and the last block height at which transactions using it are accepted.
+type Blockhash struct {
+	Hash                 solanago.Hash
+	LastValidBlockHeight uint64
+}
+
+// Status is what the cluster knows about a signature.
+type Status struct {
+	// Found is false while the cluster hasn't seen the transaction.
+	Found bool
+	// Confirmed is true once the transaction reached the commitment the caller waits for.
+	Confirmed bool
+	// Err is the on-chain error of a transaction that landed but failed.
+	Err error
+}
+
+// RPC is the subset of the cluster's API the send loop needs.
+type RPC interface {
+	LatestBlockhash(ctx context.Context) (Blockhash, error)
+	SendTransaction(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error)
+	SignatureStatus(ctx context.Context, sig solanago.Signature) (Status, error)
+	BlockHeight(ctx context.Context) (uint64, error)
+}
+
+// SignFunc builds and signs a transaction using blockhash.
+type SignFunc func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error)
+
+// Config tunes a Sender. Zero values select the defaults.
+type Config struct {
+	Clock Clock
+	// PollInterval is how often the signature status is checked. Default 500ms.
+	PollInterval time.Duration
+	// ResendInterval is how often an unconfirmed transaction is rebroadcast. Default 2s.
+	ResendInterval time.Duration
+	// MaxAttempts is how many blockhashes are tried before giving up with ErrExpired. Default 3.
+	MaxAttempts int
+	// OnSigned is called with every signed transaction before it is broadcast, e.g. to persist its signature.
+	// Returning an error aborts without broadcasting.
+	OnSigned func(tx *solanago.Transaction, blockhash Blockhash) error
+	// OnState is called on every state change.
+	OnState func(state State, sig solanago.Signature)
+	// OnRetry is called before every attempt after the first, with the error that ended the previous one.
+	OnRetry func(attempt int, err error)
+	// Retryable reports whether an error from the initial broadcast should be retried with a fresh blockhash
+	// (e.g. "blockhash not found") instead of being returned.
+	Retryable func(err error) bool
+}
+
+// Sender runs the send loop against an RPC.
+type Sender struct {
+	rpc RPC
+	cfg Config
+}
+
+// New returns a Sender using rpc, with cfg's zero values replaced by defaults.
+func New(rpc RPC, cfg Config) *Sender {
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock()
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 500 * time.Millisecond
+	}
+	if cfg.ResendInterval <= 0 {
+		cfg.ResendInterval = 2 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	return &Sender{rpc: rpc, cfg: cfg}
+}
+
+// Send signs a transaction with sign, broadcasts it and waits until it is confirmed, fails, or the last
+// attempt's blockhash expires. A new attempt is only started once the previous blockhash has expired, so at
+// most one of the signed transactions can ever land.
+func (s *Sender) Send(ctx context.Context, sign SignFunc) (solanago.Signature, error) {
+	var lastErr error
+	for attempt := 1; attempt <= s.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 && s.cfg.OnRetry != nil {
+			s.cfg.OnRetry(attempt, lastErr)
+		}
+		sig, err := s.attempt(ctx, sign)
+		switch {
+		case err == nil:
+			return sig, nil
+		case errors.Is(err, ErrExpired):
+			lastErr = err
+		case s.cfg.Retryable != nil && s.cfg.Retryable(err):
+			lastErr = err
+		default:
+			return sig, err
+		}
+	}
+	if errors.Is(lastErr, ErrExpired) {
+		return solanago.Signature{}, fmt.Errorf("%w after %d attempts", ErrExpired, s.cfg.MaxAttempts)
+	}
+	return solanago.Signature{}, fmt.Errorf("%w after %d attempts: %w", ErrExpired, s.cfg.MaxAttempts, lastErr)
+}
+
+// attempt runs one blockhash's worth of the loop.
+func (s *Sender) attempt(ctx context.Context, sign SignFunc) (solanago.Signature, error) {
+	s.setState(StateSigning, solanago.Signature{})
+	blockhash, err := s.rpc.LatestBlockhash(ctx)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't get recent block hash: %w", err)
+	}
+	tx, err := sign(ctx, blockhash.Hash)
+	if err != nil {
+		return solanago.Signature{}, err
+	}
+	sig := tx.Signatures[0]
+	if s.cfg.OnSigned != nil {
+		if err := s.cfg.OnSigned(tx, blockhash); err != nil {
+			return sig, err
+		}
+	}
+
+	if _, err := s.rpc.SendTransaction(ctx, tx); err != nil {
+		s.setState(StateFailed, sig)
+		return sig, err
+	}
+	s.setState(StateSent, sig)
+	lastSent := s.cfg.Clock.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return sig, ctx.Err()
+		case <-s.cfg.Clock.After(s.cfg.PollInterval):
+		}
+
+		done, err := s.checkStatus(ctx, sig)
+		if done {
+			return sig, err
+		}
+
+		height, err := s.rpc.BlockHeight(ctx)
+		if err != nil {
+			return sig, fmt.Errorf("can't get block height: %w", err)
+		}
+		if height > blockhash.LastValidBlockHeight {
+			// It may have landed between the status check and now.
+			if done, err := s.checkStatus(ctx, sig); done {
+				return sig, err
+			}
+			s.setState(StateExpired, sig)
+			return sig, ErrExpired
+		}
+
+		if s.cfg.Clock.Now().Sub(lastSent) >= s.cfg.ResendInterval {
+			// Rebroadcasting is best effort: the status checks decide the outcome.
+			_, _ = s.rpc.SendTransaction(ctx, tx)
+			lastSent = s.cfg.Clock.Now()
+		}
+	}
+}
+
+// checkStatus reports whether sig reached a terminal state, and the error to return if it failed. Status lookup
+// errors are treated as not known yet; the block height check bounds how long that can go on.
+func (s *Sender) checkStatus(ctx context.Context, sig solanago.Signature) (bool, error) {
+	status, err := s.rpc.SignatureStatus(ctx, sig)
+	switch {
+	case err != nil || !status.Found:
+		return false, nil
+	case status.Err != nil:
+		s.setState(StateFailed, sig)
+		return true, fmt.Errorf("%w: %v", ErrFailed, status.Err)
+	case status.Confirmed:
+		s.setState(StateConfirmed, sig)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *Sender) setState(state State, sig solanago.Signature) {
+	if s.cfg.OnState != nil {
+		s.cfg.OnState(state, sig)
+	}
+}
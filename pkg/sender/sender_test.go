@@ -0,0 +1,347 @@
+package sender_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+
+	"github.com/csknk/token-transfer/pkg/sender"
+)
+
+// fakeClock advances by the requested duration whenever the loop waits, so tests run without sleeping. Once
+// stopped, waits never end.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if !c.stopped {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
+
+func (c *fakeClock) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+}
+
+// fakeRPC is a scripted cluster. Each LatestBlockhash call issues a new blockhash valid for validFor blocks. The
+// finalized block height grows by heightStep on every BlockHeight call. onPoll, if set, runs before every
+// signature status lookup and may change the statuses.
+type fakeRPC struct {
+	mu          sync.Mutex
+	height      uint64
+	heightStep  uint64
+	validFor    uint64
+	blockhashes []sender.Blockhash
+	sends       []solanago.Signature
+	statuses    map[solanago.Signature]sender.Status
+	polls       int
+	onPoll      func(r *fakeRPC, poll int)
+}
+
+func newFakeRPC() *fakeRPC {
+	return &fakeRPC{height: 100, validFor: 150, statuses: map[solanago.Signature]sender.Status{}}
+}
+
+func (r *fakeRPC) LatestBlockhash(ctx context.Context) (sender.Blockhash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := sender.Blockhash{Hash: solanago.Hash{byte(len(r.blockhashes) + 1)}, LastValidBlockHeight: r.height + r.validFor}
+	r.blockhashes = append(r.blockhashes, b)
+	return b, nil
+}
+
+func (r *fakeRPC) SendTransaction(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sends = append(r.sends, tx.Signatures[0])
+	return tx.Signatures[0], nil
+}
+
+func (r *fakeRPC) SignatureStatus(ctx context.Context, sig solanago.Signature) (sender.Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.polls++
+	if r.onPoll != nil {
+		r.onPoll(r, r.polls)
+	}
+	return r.statuses[sig], nil
+}
+
+func (r *fakeRPC) BlockHeight(ctx context.Context) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.height += r.heightStep
+	return r.height, nil
+}
+
+// sign returns a sign function whose transaction's signature is derived from the blockhash, so every attempt
+// has its own.
+func sign(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+	return &solanago.Transaction{Signatures: []solanago.Signature{{0xa0, blockhash[0]}}}, nil
+}
+
+func signatureFor(b sender.Blockhash) solanago.Signature {
+	return solanago.Signature{0xa0, b.Hash[0]}
+}
+
+// recordStates returns a Config.OnState hook appending to states.
+func recordStates(states *[]sender.State) func(sender.State, solanago.Signature) {
+	return func(state sender.State, _ solanago.Signature) { *states = append(*states, state) }
+}
+
+func TestSendConfirmed(t *testing.T) {
+	rpc := newFakeRPC()
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		if poll == 3 {
+			r.statuses[signatureFor(r.blockhashes[0])] = sender.Status{Found: true, Confirmed: true}
+		}
+	}
+	var states []sender.State
+	var signed []solanago.Signature
+	s := sender.New(rpc, sender.Config{
+		Clock: &fakeClock{},
+		OnSigned: func(tx *solanago.Transaction, _ sender.Blockhash) error {
+			signed = append(signed, tx.Signatures[0])
+			return nil
+		},
+		OnState: recordStates(&states),
+	})
+
+	sig, err := s.Send(context.Background(), sign)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if want := signatureFor(rpc.blockhashes[0]); sig != want {
+		t.Errorf("signature = %v, want %v", sig, want)
+	}
+	if len(signed) != 1 || signed[0] != sig {
+		t.Errorf("OnSigned saw %v, want only %v", signed, sig)
+	}
+	if len(rpc.sends) != 1 {
+		t.Errorf("sent %d times, want once", len(rpc.sends))
+	}
+	if want := []sender.State{sender.StateSigning, sender.StateSent, sender.StateConfirmed}; !slices.Equal(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+}
+
+func TestSendRebroadcastsUntilConfirmed(t *testing.T) {
+	rpc := newFakeRPC()
+	// Polled every 500ms, so confirmed 5s after the first broadcast.
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		if poll == 10 {
+			r.statuses[signatureFor(r.blockhashes[0])] = sender.Status{Found: true, Confirmed: true}
+		}
+	}
+	s := sender.New(rpc, sender.Config{Clock: &fakeClock{}, PollInterval: 500 * time.Millisecond, ResendInterval: 2 * time.Second})
+
+	sig, err := s.Send(context.Background(), sign)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// The first broadcast and rebroadcasts after 2s and 4s, all of the same transaction.
+	if want := []solanago.Signature{sig, sig, sig}; !slices.Equal(rpc.sends, want) {
+		t.Errorf("sent %v, want %v", rpc.sends, want)
+	}
+	if len(rpc.blockhashes) != 1 {
+		t.Errorf("fetched %d blockhashes, want 1", len(rpc.blockhashes))
+	}
+}
+
+func TestSendWaitsForFinalizedBlockHeightToExpire(t *testing.T) {
+	rpc := newFakeRPC()
+	// Long past the ~1 minute a blockhash usually lasts, but the finalized block height hasn't passed it.
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		if poll == 1000 {
+			r.statuses[signatureFor(r.blockhashes[0])] = sender.Status{Found: true, Confirmed: true}
+		}
+	}
+	clock := &fakeClock{}
+	s := sender.New(rpc, sender.Config{Clock: clock})
+
+	if _, err := s.Send(context.Background(), sign); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if clock.Now().Sub(time.Time{}) < 5*time.Minute {
+		t.Fatalf("confirmed after %v, the test expects minutes", clock.Now().Sub(time.Time{}))
+	}
+	if len(rpc.blockhashes) != 1 {
+		t.Errorf("re-signed %d times while the blockhash was valid", len(rpc.blockhashes)-1)
+	}
+}
+
+func TestSendExpires(t *testing.T) {
+	rpc := newFakeRPC()
+	rpc.heightStep, rpc.validFor = 1, 5
+	var retries []int
+	var heightsAtRetry []uint64
+	s := sender.New(rpc, sender.Config{
+		Clock:       &fakeClock{},
+		MaxAttempts: 2,
+		OnRetry: func(attempt int, err error) {
+			retries = append(retries, attempt)
+			heightsAtRetry = append(heightsAtRetry, rpc.height)
+			if !errors.Is(err, sender.ErrExpired) {
+				t.Errorf("retry after %v, want ErrExpired", err)
+			}
+		},
+	})
+
+	_, err := s.Send(context.Background(), sign)
+	if !errors.Is(err, sender.ErrExpired) {
+		t.Fatalf("Send = %v, want ErrExpired", err)
+	}
+	if len(rpc.blockhashes) != 2 {
+		t.Fatalf("fetched %d blockhashes, want one per attempt", len(rpc.blockhashes))
+	}
+	if !slices.Equal(retries, []int{2}) {
+		t.Errorf("retries = %v, want [2]", retries)
+	}
+	// A new attempt only starts once the previous blockhash can't land anymore.
+	if heightsAtRetry[0] <= rpc.blockhashes[0].LastValidBlockHeight {
+		t.Errorf("retried at block height %d, before the first blockhash expired at %d", heightsAtRetry[0], rpc.blockhashes[0].LastValidBlockHeight)
+	}
+	if sigs := slices.Compact(slices.Clone(rpc.sends)); len(sigs) != 2 || sigs[0] == sigs[1] {
+		t.Errorf("sent %v, want one transaction per attempt", rpc.sends)
+	}
+}
+
+func TestSendLandsAsItExpires(t *testing.T) {
+	rpc := newFakeRPC()
+	rpc.heightStep, rpc.validFor = 1, 3
+	// Seen only by the lookup made after the block height passed the blockhash's last valid one.
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		if r.height > r.blockhashes[0].LastValidBlockHeight {
+			r.statuses[signatureFor(r.blockhashes[0])] = sender.Status{Found: true, Confirmed: true}
+		}
+	}
+	s := sender.New(rpc, sender.Config{Clock: &fakeClock{}})
+
+	if _, err := s.Send(context.Background(), sign); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(rpc.blockhashes) != 1 {
+		t.Errorf("re-signed a transaction that landed")
+	}
+}
+
+func TestSendTransactionError(t *testing.T) {
+	rpc := newFakeRPC()
+	onChain := errors.New(`{"InstructionError":[0,{"Custom":1}]}`)
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		r.statuses[signatureFor(r.blockhashes[0])] = sender.Status{Found: true, Err: onChain}
+	}
+	var states []sender.State
+	s := sender.New(rpc, sender.Config{Clock: &fakeClock{}, OnState: recordStates(&states)})
+
+	_, err := s.Send(context.Background(), sign)
+	if !errors.Is(err, sender.ErrFailed) {
+		t.Fatalf("Send = %v, want ErrFailed", err)
+	}
+	if len(rpc.blockhashes) != 1 {
+		t.Errorf("retried a transaction that failed on-chain")
+	}
+	if states[len(states)-1] != sender.StateFailed {
+		t.Errorf("final state = %v, want %v", states[len(states)-1], sender.StateFailed)
+	}
+}
+
+func TestSendRetriesRetryableBroadcastErrors(t *testing.T) {
+	rpc := newFakeRPC()
+	errBlockhashNotFound := errors.New("Blockhash not found")
+	failing := &failingSendRPC{fakeRPC: rpc, err: errBlockhashNotFound, failures: 1}
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		r.statuses[signatureFor(r.blockhashes[len(r.blockhashes)-1])] = sender.Status{Found: true, Confirmed: true}
+	}
+	s := sender.New(failing, sender.Config{
+		Clock:     &fakeClock{},
+		Retryable: func(err error) bool { return errors.Is(err, errBlockhashNotFound) },
+	})
+
+	sig, err := s.Send(context.Background(), sign)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if want := signatureFor(rpc.blockhashes[1]); len(rpc.blockhashes) != 2 || sig != want {
+		t.Errorf("signature = %v after %d blockhashes, want %v from the second", sig, len(rpc.blockhashes), want)
+	}
+}
+
+// failingSendRPC fails the first failures broadcasts with err.
+type failingSendRPC struct {
+	*fakeRPC
+	err      error
+	failures int
+}
+
+func (r *failingSendRPC) SendTransaction(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+	if r.failures > 0 {
+		r.failures--
+		return solanago.Signature{}, r.err
+	}
+	return r.fakeRPC.SendTransaction(ctx, tx)
+}
+
+func TestSendContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := &fakeClock{}
+	rpc := newFakeRPC()
+	rpc.onPoll = func(r *fakeRPC, poll int) {
+		if poll == 2 {
+			// Nothing but the cancellation can end the next wait.
+			clock.stop()
+			cancel()
+		}
+	}
+	s := sender.New(rpc, sender.Config{Clock: clock})
+
+	sig, err := s.Send(ctx, sign)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send = %v, want context.Canceled", err)
+	}
+	// The caller needs the signature of the broadcast transaction to find out later whether it landed.
+	if want := signatureFor(rpc.blockhashes[0]); sig != want {
+		t.Errorf("signature = %v, want %v", sig, want)
+	}
+	if len(rpc.blockhashes) != 1 {
+		t.Errorf("retried after cancellation")
+	}
+}
+
+func TestSendOnSignedErrorAbortsBeforeBroadcast(t *testing.T) {
+	rpc := newFakeRPC()
+	errJournal := errors.New("can't record transfer signature")
+	s := sender.New(rpc, sender.Config{
+		Clock:    &fakeClock{},
+		OnSigned: func(*solanago.Transaction, sender.Blockhash) error { return errJournal },
+	})
+
+	if _, err := s.Send(context.Background(), sign); !errors.Is(err, errJournal) {
+		t.Fatalf("Send = %v, want %v", err, errJournal)
+	}
+	if len(rpc.sends) != 0 {
+		t.Errorf("broadcast %d times after OnSigned failed", len(rpc.sends))
+	}
+}
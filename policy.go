@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+const (
+	// policyFile holds the spending policy, in the data directory.
+	policyFile = "policy.json"
+	// auditLogFile records policy overrides, in the data directory.
+	auditLogFile = "audit.jsonl"
+)
+
+// policyOverride lets the CLI send transfers that violate the policy; every override is written to the audit log.
+var policyOverride bool
+
+// policyConfig is the spending policy as configured in policy.json.
+type policyConfig struct {
+	// AllowedRecipients lists the wallets transfers may go to; empty allows any.
+	AllowedRecipients []string `json:"allowedRecipients,omitempty"`
+	// Tokens maps a token symbol or mint address to its limits. When set, only the listed tokens may be sent.
+	Tokens map[string]tokenLimits `json:"tokens,omitempty"`
+	// Approvers lists the public keys that may approve transfers held by approvalAbove; empty allows none.
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+// tokenLimits are decimal token amounts; empty means unlimited.
+type tokenLimits struct {
+	MaxPerTransfer string `json:"maxPerTransfer,omitempty"`
+	MaxPerDay      string `json:"maxPerDay,omitempty"`
+	// ApprovalAbove queues transfers of more than this amount until a second operator approves them.
+	ApprovalAbove string `json:"approvalAbove,omitempty"`
+	// MinTransfer and DustThreshold are the defaults of --min-transfer and --dust-threshold, see checkDust.
+	MinTransfer   string `json:"minTransfer,omitempty"`
+	DustThreshold string `json:"dustThreshold,omitempty"`
+}
+
+// Policy is a validated spending policy. A nil Policy allows everything.
+type Policy struct {
+	recipients map[solanago.PublicKey]bool
+	tokens     map[solanago.PublicKey]tokenLimits
+	approvers  map[solanago.PublicKey]bool
+	// client is set for the policy of an API key in serve mode, the key's name; its daily limits only count the
+	// transfers requested with that key.
+	client string
+}
+
+// loadPolicy reads the spending policy in dir. It returns nil if there is none.
+func loadPolicy(dir string) (*Policy, error) {
+	path := filepath.Join(dir, policyFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read policy: %v", err)
+	}
+	var config policyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	return newPolicy(config, path)
+}
+
+// newPolicy validates config, read from path.
+func newPolicy(config policyConfig, path string) (*Policy, error) {
+	p := &Policy{}
+	if len(config.AllowedRecipients) > 0 {
+		p.recipients = map[solanago.PublicKey]bool{}
+		for _, recipient := range config.AllowedRecipients {
+			key, err := solanago.PublicKeyFromBase58(recipient)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: invalid recipient %q: %v", ErrInvalidArgument, path, recipient, err)
+			}
+			p.recipients[key] = true
+		}
+	}
+	if len(config.Tokens) > 0 {
+		p.tokens = map[solanago.PublicKey]tokenLimits{}
+		for name, limits := range config.Tokens {
+			mint, err := lookupToken(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			p.tokens[mint] = limits
+		}
+	}
+	if len(config.Approvers) > 0 {
+		p.approvers = map[solanago.PublicKey]bool{}
+		for _, approver := range config.Approvers {
+			key, err := solanago.PublicKeyFromBase58(approver)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: invalid approver %q: %v", ErrInvalidArgument, path, approver, err)
+			}
+			p.approvers[key] = true
+		}
+	}
+	return p, nil
+}
+
+// Check returns an ErrPolicyViolation error if sending amount raw base units of mint to receiver breaks the
+// policy. planned is the amount of mint about to be sent in the same run but not recorded in store yet, and
+// counts towards the daily cap.
+func (p *Policy) Check(store *Store, mint solanago.PublicKey, decimals uint8, receiver solanago.PublicKey, amount, planned uint64) error {
+	return p.check(store, mint, decimals, receiver, amount, planned, "")
+}
+
+// CheckApproved is Check for the recorded transfer id, held for approval and about to be sent with amount raw base
+// units: it counts towards today's cap once, whichever day it was requested on.
+func (p *Policy) CheckApproved(store *Store, id string, mint solanago.PublicKey, decimals uint8, receiver solanago.PublicKey, amount uint64) error {
+	return p.check(store, mint, decimals, receiver, amount, 0, id)
+}
+
+// check is Check, leaving the transfer exclude out of what's been sent today.
+func (p *Policy) check(store *Store, mint solanago.PublicKey, decimals uint8, receiver solanago.PublicKey, amount, planned uint64, exclude string) error {
+	if p == nil {
+		return nil
+	}
+	if p.recipients != nil && !p.recipients[receiver] {
+		return fmt.Errorf("%w: %s is not an allowed recipient", ErrPolicyViolation, receiver)
+	}
+	if p.tokens == nil {
+		return nil
+	}
+	limits, ok := p.tokens[mint]
+	if !ok {
+		return fmt.Errorf("%w: token %s is not allowed", ErrPolicyViolation, mint)
+	}
+	if limits.MaxPerTransfer != "" {
+		max, err := parseUIAmount(limits.MaxPerTransfer, decimals)
+		if err != nil {
+			return fmt.Errorf("%w: invalid maxPerTransfer for %s: %v", ErrInvalidArgument, mint, err)
+		}
+		if amount > max {
+			return fmt.Errorf("%w: %s exceeds the per-transfer limit of %s", ErrPolicyViolation, formatUIAmount(amount, decimals), limits.MaxPerTransfer)
+		}
+	}
+	if limits.MaxPerDay != "" {
+		max, err := parseUIAmount(limits.MaxPerDay, decimals)
+		if err != nil {
+			return fmt.Errorf("%w: invalid maxPerDay for %s: %v", ErrInvalidArgument, mint, err)
+		}
+		spent := sentToday(store, mint, decimals, p.client, exclude) + planned
+		if spent+amount > max {
+			return fmt.Errorf("%w: %s would exceed the daily limit of %s (%s already sent or pending today)", ErrPolicyViolation, formatUIAmount(amount, decimals), limits.MaxPerDay, formatUIAmount(spent, decimals))
+		}
+	}
+	return nil
+}
+
+// NeedsApproval reports whether sending amount raw base units of mint needs a second operator's approval.
+func (p *Policy) NeedsApproval(mint solanago.PublicKey, decimals uint8, amount uint64) (bool, error) {
+	if p == nil {
+		return false, nil
+	}
+	limits, ok := p.tokens[mint]
+	if !ok || limits.ApprovalAbove == "" {
+		return false, nil
+	}
+	threshold, err := parseUIAmount(limits.ApprovalAbove, decimals)
+	if err != nil {
+		return false, fmt.Errorf("%w: invalid approvalAbove for %s: %v", ErrInvalidArgument, mint, err)
+	}
+	return amount > threshold, nil
+}
+
+// CheckApprover returns an ErrPolicyViolation error unless approver is listed in the policy's approvers. Without
+// a policy or approvers nobody may approve transfers.
+func (p *Policy) CheckApprover(approver solanago.PublicKey) error {
+	if p == nil || !p.approvers[approver] {
+		return fmt.Errorf("%w: %s is not an allowed approver", ErrPolicyViolation, approver)
+	}
+	return nil
+}
+
+// allowsToken reports whether the policy lists mint among the tokens that may be sent. A policy without a token
+// list doesn't list any.
+func (p *Policy) allowsToken(mint solanago.PublicKey) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.tokens[mint]
+	return ok
+}
+
+// sentToday sums the transfers of mint recorded since midnight UTC that haven't failed, only those requested with
+// the API key named client if it's set.
+func sentToday(store *Store, mint solanago.PublicKey, decimals uint8, client, exclude string) uint64 {
+	var total uint64
+	midnight := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, record := range store.TransfersSince(midnight) {
+		if record.Mint != mint.String() || record.Status == transferFailed || client != "" && record.Client != client || record.ID == exclude {
+			continue
+		}
+		amount, err := parseUIAmount(record.Amount, decimals)
+		if err != nil {
+			slog.Warn("can't parse recorded amount", "id", record.ID, "error", err)
+			continue
+		}
+		total += amount
+	}
+	return total
+}
+
+// enforcePolicy checks a transfer against policy. With --override a violation is logged to the audit log and the
+// transfer goes ahead.
+func enforcePolicy(policy *Policy, store *Store, mint solanago.PublicKey, decimals uint8, receiver solanago.PublicKey, amount, planned uint64) error {
+	err := policy.Check(store, mint, decimals, receiver, amount, planned)
+	if err == nil || !errors.Is(err, ErrPolicyViolation) || !policyOverride {
+		return err
+	}
+	slog.Warn("policy violation overridden", "receiver", receiver, "amount", formatUIAmount(amount, decimals), "mint", mint, "violation", err)
+	return writeAuditLog(dataDir, map[string]any{
+		"action":    "policy-override",
+		"violation": err.Error(),
+		"receiver":  receiver.String(),
+		"mint":      mint.String(),
+		"amount":    formatUIAmount(amount, decimals),
+	})
+}
+
+// enforceUncheckedPolicy refuses an operation that moves funds in a way the spending policy can't check, such as a
+// SOL transfer or a delegation, if a policy is configured: the policy would otherwise be silently bypassed. With
+// --override the operation is logged to the audit log like a violation and goes ahead.
+func enforceUncheckedPolicy(policy *Policy, operation string, details map[string]any) error {
+	if policy == nil {
+		return nil
+	}
+	err := fmt.Errorf("%w: the spending policy can't check %s, pass --override to go ahead anyway", ErrPolicyViolation, operation)
+	if !policyOverride {
+		return err
+	}
+	slog.Warn("policy violation overridden", "operation", operation, "violation", err)
+	entry := map[string]any{
+		"action":    "policy-override",
+		"violation": err.Error(),
+	}
+	maps.Copy(entry, details)
+	return writeAuditLog(dataDir, entry)
+}
+
+// enforceExtraInstructions applies enforceUncheckedPolicy to --pre-ix and --post-ix, which can carry transfers of
+// their own.
+func enforceExtraInstructions() error {
+	if len(preInstructions) == 0 && len(postInstructions) == 0 {
+		return nil
+	}
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	programs := make([]string, 0, len(preInstructions)+len(postInstructions))
+	for _, instruction := range append(slices.Clip(preInstructions), postInstructions...) {
+		programs = append(programs, instruction.ProgramID().String())
+	}
+	return enforceUncheckedPolicy(policy, "--pre-ix and --post-ix instructions", map[string]any{"programs": programs})
+}
+
+var auditMu sync.Mutex
+
+// writeAuditLog appends entry, with the time and the operating system user, to the audit log in dir. Actions
+// that must be audited fail if the entry can't be written.
+func writeAuditLog(dir string, entry map[string]any) error {
+	entry["time"] = time.Now().UTC()
+	if u, err := user.Current(); err == nil {
+		entry["user"] = u.Username
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(dir, auditLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't open audit log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("can't write audit log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckApprover(t *testing.T) {
+	listed, unlisted := testKey(1).PublicKey(), testKey(2).PublicKey()
+	policy, err := newPolicy(policyConfig{Approvers: []string{listed.String()}}, policyFile)
+	if err != nil {
+		t.Fatalf("newPolicy: %v", err)
+	}
+	withoutApprovers, err := newPolicy(policyConfig{}, policyFile)
+	if err != nil {
+		t.Fatalf("newPolicy: %v", err)
+	}
+
+	if err := policy.CheckApprover(listed); err != nil {
+		t.Errorf("listed approver refused: %v", err)
+	}
+	for name, check := range map[string]error{
+		"unlisted approver": policy.CheckApprover(unlisted),
+		"empty list":        withoutApprovers.CheckApprover(listed),
+		"no policy":         (*Policy)(nil).CheckApprover(listed),
+	} {
+		if !errors.Is(check, ErrPolicyViolation) {
+			t.Errorf("%s: CheckApprover = %v, want ErrPolicyViolation", name, check)
+		}
+	}
+}
+
+func TestNewPolicyRejectsInvalidApprover(t *testing.T) {
+	_, err := newPolicy(policyConfig{Approvers: []string{"not-a-key"}}, policyFile)
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("newPolicy = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestEnforceUncheckedPolicy(t *testing.T) {
+	defer func(savedDir string, savedOverride bool) { dataDir, policyOverride = savedDir, savedOverride }(dataDir, policyOverride)
+	dataDir = t.TempDir()
+	policy, err := newPolicy(policyConfig{}, policyFile)
+	if err != nil {
+		t.Fatalf("newPolicy: %v", err)
+	}
+
+	if err := enforceUncheckedPolicy(nil, "SOL transfers", nil); err != nil {
+		t.Errorf("refused without a policy: %v", err)
+	}
+	policyOverride = false
+	if err := enforceUncheckedPolicy(policy, "SOL transfers", nil); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("enforceUncheckedPolicy = %v, want ErrPolicyViolation", err)
+	}
+	policyOverride = true
+	if err := enforceUncheckedPolicy(policy, "SOL transfers", map[string]any{"receiver": "x"}); err != nil {
+		t.Fatalf("refused with --override: %v", err)
+	}
+	audit, err := os.ReadFile(filepath.Join(dataDir, auditLogFile))
+	if err != nil || !strings.Contains(string(audit), `"action":"policy-override"`) || !strings.Contains(string(audit), `"receiver":"x"`) {
+		t.Errorf("audit log %q (%v), want the override recorded", audit, err)
+	}
+}
+
+func TestCheckApprovedCountsTheTransferOnce(t *testing.T) {
+	mint := testKey(3).PublicKey()
+	policy, err := newPolicy(policyConfig{Tokens: map[string]tokenLimits{mint.String(): {MaxPerDay: "10"}}}, policyFile)
+	if err != nil {
+		t.Fatalf("newPolicy: %v", err)
+	}
+	store, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	sender, receiver := testKey(1).PublicKey(), testKey(2).PublicKey()
+	held := newTransferRecord("", sender, receiver, mint, "6")
+	held.Status = transferAwaitingApproval
+	if err := store.PutTransfer(held); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := policy.CheckApproved(store, held.ID, mint, 0, receiver, 6); err != nil {
+		t.Errorf("CheckApproved counted the held transfer twice: %v", err)
+	}
+	if err := store.PutTransfer(newTransferRecord("", sender, receiver, mint, "5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.CheckApproved(store, held.ID, mint, 0, receiver, 6); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("CheckApproved = %v past the daily cap, want ErrPolicyViolation", err)
+	}
+}
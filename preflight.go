@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// nonTransferableExtension is the Token-2022 mint extension marking tokens as soulbound.
+const nonTransferableExtension = 9
+
+// allowOwnerOffCurve permits receivers that aren't ed25519 public keys, i.e. program derived addresses.
+var allowOwnerOffCurve bool
+
+// checkReceiverOwner rejects a receiver that is off the ed25519 curve unless --allow-owner-off-curve is set. Such
+// an address is a PDA: nobody holds a private key for it, and only the program owning it can move tokens out of its
+// token account. That's intended for program vaults, but a PDA pasted by mistake, e.g. a token account address
+// instead of a wallet, loses the tokens.
+func checkReceiverOwner(receiver solanago.PublicKey) error {
+	if receiver.IsOnCurve() {
+		return nil
+	}
+	if !allowOwnerOffCurve {
+		return fmt.Errorf("%w: receiver %s is off-curve (a program derived address), so only its program can spend what it receives; pass --allow-owner-off-curve if that's intended", ErrInvalidRecipient, receiver)
+	}
+	slog.Warn("sending to an off-curve receiver: only its owning program can move the tokens", "receiver", receiver)
+	return nil
+}
+
+// resolveReceiverTokenAccount validates a token account given as the destination in place of a receiver wallet and
+// returns its owner. The account must exist, be held by the mint's token program, be an account of the mint and not
+// be frozen. Whoever owns the account owns what is sent to it, so the owner is logged for the operator to check.
+func resolveReceiverTokenAccount(ctx context.Context, client RPCClient, mint transferMint, address solanago.PublicKey) (solanago.PublicKey, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || (err == nil && (res == nil || res.Value == nil)) {
+		return solanago.PublicKey{}, fmt.Errorf("%w: receiver token account %s doesn't exist", ErrInvalidRecipient, address)
+	}
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't get token account %s: %w", address, classifyRPCError(err))
+	}
+	if !res.Value.Owner.Equals(mint.Program) {
+		return solanago.PublicKey{}, fmt.Errorf("%w: %s isn't a token account of mint %s's token program", ErrInvalidRecipient, address, mint.Address)
+	}
+	var account token.Account
+	if err := bin.NewBorshDecoder(res.Value.Data.GetBinary()).Decode(&account); err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("%w: can't decode token account %s: %v", ErrInvalidRecipient, address, err)
+	}
+	if !account.Mint.Equals(mint.Address) {
+		return solanago.PublicKey{}, fmt.Errorf("%w: token account %s holds mint %s, not %s", ErrInvalidRecipient, address, account.Mint, mint.Address)
+	}
+	if account.State == token.Frozen {
+		return solanago.PublicKey{}, fmt.Errorf("%w: receiver token account %s is frozen by the mint's freeze authority", ErrInvalidRecipient, address)
+	}
+	if ata, err := mint.tokenAccount(account.Owner); err == nil && ata.Equals(address) {
+		slog.Info("receiver token account is its owner's associated token account", "account", address, "owner", account.Owner)
+	} else {
+		slog.Warn("sending to a token account directly: the tokens belong to its owner, make sure that's the intended receiver", "account", address, "owner", account.Owner)
+	}
+	return account.Owner, nil
+}
+
+// checkTokenTransfer rejects transfers the token program would refuse because of the mint's or the accounts'
+// state, before a fee is spent on them: a non-transferable mint, a frozen sender token account, or a frozen
+// receiver token account. Receivers without a token account are fine, the transfer creates it, unless the mint
+// creates accounts frozen and neither the sender nor the fee payer is the freeze authority that could thaw it.
+func checkTokenTransfer(ctx context.Context, client RPCClient, mint transferMint, sender, payer solanago.PublicKey, receivers ...solanago.PublicKey) error {
+	if mint.NonTransferable {
+		return fmt.Errorf("%w: mint %s is non-transferable: its tokens can only be burned, not sent", ErrInvalidArgument, mint.Address)
+	}
+
+	senderAta, err := mint.tokenAccount(sender)
+	if err != nil {
+		return fmt.Errorf("can't get ATA for sender %s: %v", sender, err)
+	}
+	account, err := getTokenAccount(ctx, client, senderAta)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return fmt.Errorf("%w: sender %s has no token account for mint %s", ErrInsufficientFunds, sender, mint.Address)
+	}
+	// Programmable NFTs are always in frozen accounts; Token Metadata thaws them to transfer.
+	if account.State == token.Frozen && !mint.Programmable {
+		return fmt.Errorf("%w: sender token account %s is frozen by the mint's freeze authority", ErrInvalidArgument, senderAta)
+	}
+
+	for _, receiver := range receivers {
+		receiverAta, err := mint.tokenAccount(receiver)
+		if err != nil {
+			return fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, receiver, err)
+		}
+		account, err := getTokenAccount(ctx, client, receiverAta)
+		if err != nil {
+			return err
+		}
+		if account == nil && mint.DefaultFrozen && !mint.canThaw(sender, payer) {
+			return fmt.Errorf("%w: receiver has no token account, and new accounts of mint %s start frozen; the freeze authority %s has to create and thaw it first", ErrInvalidRecipient, mint.Address, mint.FreezeAuthority)
+		}
+		if account != nil && account.State == token.Frozen && !mint.Programmable {
+			return fmt.Errorf("%w: receiver token account %s is frozen by the mint's freeze authority", ErrInvalidRecipient, receiverAta)
+		}
+	}
+	return nil
+}
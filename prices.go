@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// pricesFile caches fetched token prices in the data directory.
+const pricesFile = "prices.json"
+
+// coingeckoURL is CoinGecko's token price endpoint for Solana mints.
+const coingeckoURL = "https://api.coingecko.com/api/v3/simple/token_price/solana"
+
+var (
+	priceSource  string
+	fiatCurrency string
+	priceTTL     time.Duration
+)
+
+// registerPriceFlags adds the flags selecting where fiat values come from to fs.
+func registerPriceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&priceSource, "price-source", "", "Annotate amounts with their fiat value from: coingecko, pyth:<price update account>, or an HTTP URL with {mint} and {currency} placeholders returning {\"price\": n}")
+	fs.StringVar(&fiatCurrency, "fiat", "usd", "Currency of fiat values")
+	fs.DurationVar(&priceTTL, "price-ttl", 5*time.Minute, "How long a fetched price is reused")
+}
+
+// cachedPrice is a price in pricesFile.
+type cachedPrice struct {
+	Price     float64   `json:"price"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// fiatValue returns the approximate value of a decimal amount of mint, e.g. "12.50 USD", or "" if --price-source
+// isn't set. A price that can't be fetched only warns: the value is an annotation, not something to fail over.
+func fiatValue(ctx context.Context, client RPCClient, mint solanago.PublicKey, amount string) string {
+	if priceSource == "" {
+		return ""
+	}
+	price, err := tokenPrice(ctx, client, mint)
+	if err != nil {
+		slog.Warn("can't get token price", "source", priceSource, "mint", mint, "error", err)
+		return ""
+	}
+	n, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f %s", n*price, strings.ToUpper(fiatCurrency))
+}
+
+// tokenPrice returns the price of one token of mint in --fiat, from the cache if it's younger than --price-ttl.
+func tokenPrice(ctx context.Context, client RPCClient, mint solanago.PublicKey) (float64, error) {
+	key := priceSource + " " + mint.String() + " " + strings.ToLower(fiatCurrency)
+	path := filepath.Join(dataDir, pricesFile)
+	cache := map[string]cachedPrice{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			slog.Warn("ignoring corrupt price cache", "path", path, "error", err)
+			cache = map[string]cachedPrice{}
+		}
+	}
+	if cached, ok := cache[key]; ok && time.Since(cached.FetchedAt) < priceTTL {
+		return cached.Price, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var price float64
+	var err error
+	switch source, account, _ := strings.Cut(priceSource, ":"); {
+	case source == "coingecko":
+		price, err = coingeckoPrice(ctx, mint)
+	case source == "pyth":
+		price, err = pythPrice(ctx, client, account)
+	case source == "http" || source == "https":
+		price, err = httpPrice(ctx, priceSource, mint)
+	default:
+		return 0, fmt.Errorf("%w: unknown --price-source %q", ErrInvalidArgument, priceSource)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cache[key] = cachedPrice{Price: price, FetchedAt: time.Now().UTC()}
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			slog.Debug("can't write price cache", "path", path, "error", err)
+		}
+	}
+	return price, nil
+}
+
+// coingeckoPrice asks CoinGecko for the price of mint. COINGECKO_API_KEY, if set, is sent as a demo API key.
+func coingeckoPrice(ctx context.Context, mint solanago.PublicKey) (float64, error) {
+	currency := strings.ToLower(fiatCurrency)
+	query := url.Values{"contract_addresses": {mint.String()}, "vs_currencies": {currency}}
+	var resp map[string]map[string]float64
+	if err := getPriceJSON(ctx, coingeckoURL+"?"+query.Encode(), map[string]string{"x-cg-demo-api-key": os.Getenv("COINGECKO_API_KEY")}, &resp); err != nil {
+		return 0, err
+	}
+	price, ok := resp[mint.String()][currency]
+	if !ok {
+		return 0, fmt.Errorf("coingecko has no %s price for %s", currency, mint)
+	}
+	return price, nil
+}
+
+// httpPrice fetches the price from a URL template, which has {mint} and {currency} replaced and must return
+// {"price": n}.
+func httpPrice(ctx context.Context, template string, mint solanago.PublicKey) (float64, error) {
+	u := strings.NewReplacer("{mint}", mint.String(), "{currency}", url.QueryEscape(strings.ToLower(fiatCurrency))).Replace(template)
+	var resp struct {
+		Price *float64 `json:"price"`
+	}
+	if err := getPriceJSON(ctx, u, nil, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Price == nil {
+		return 0, errors.New("price missing from response")
+	}
+	return *resp.Price, nil
+}
+
+func getPriceJSON(ctx context.Context, u string, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		if value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid price response: %v", err)
+	}
+	return nil
+}
+
+// pythPrice reads a Pyth price update account (PriceUpdateV2, as posted by the Pyth receiver program). Pyth prices
+// are in USD.
+func pythPrice(ctx context.Context, client RPCClient, account string) (float64, error) {
+	if strings.ToLower(fiatCurrency) != "usd" {
+		return 0, fmt.Errorf("%w: pyth prices are in USD, not %s", ErrInvalidArgument, fiatCurrency)
+	}
+	address, err := solanago.PublicKeyFromBase58(account)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid pyth price account %q: %v", ErrInvalidArgument, account, err)
+	}
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("can't get pyth price account: %w", classifyRPCError(err))
+	}
+	// Anchor discriminator (8), write authority (32), verification level (Partial: tag and signature count; Full:
+	// tag only), then the price message: feed ID (32), price (i64), confidence (u64), exponent (i32).
+	data := res.Value.Data.GetBinary()
+	offset := 8 + 32 + 1
+	if len(data) > 40 && data[40] == 0 {
+		offset++
+	}
+	offset += 32
+	if len(data) < offset+20 {
+		return 0, fmt.Errorf("pyth price account %s is %d bytes, not a price update", address, len(data))
+	}
+	price := int64(binary.LittleEndian.Uint64(data[offset:]))
+	exponent := int32(binary.LittleEndian.Uint32(data[offset+16:]))
+	return float64(price) * math.Pow10(int(exponent)), nil
+}
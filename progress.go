@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressRedrawInterval is how often the live progress line is redrawn.
+	progressRedrawInterval = 250 * time.Millisecond
+	// progressLogInterval is how often progress is logged when the output isn't a terminal.
+	progressLogInterval = 10 * time.Second
+	// progressRateWindow is the span the current transfer rate is measured over.
+	progressRateWindow = 10 * time.Second
+	// progressBarWidth is the number of characters in the progress bar.
+	progressBarWidth = 30
+)
+
+// batchProgress tracks the transfers of a batch run as they complete. When stdout and stderr are a terminal it
+// keeps a status line redrawn at the bottom of stderr; otherwise it logs the counts every progressLogInterval.
+type batchProgress struct {
+	live bool
+	stop chan struct{}
+	done chan struct{}
+
+	mu                       sync.Mutex
+	total, completed, failed int
+	samples                  []progressSample
+	drawn                    bool // whether the status line is on screen
+}
+
+// progressSample is the number of transfers completed at a point in time.
+type progressSample struct {
+	at        time.Time
+	completed int
+}
+
+// newBatchProgress starts showing the progress of total transfers. Close stops it.
+func newBatchProgress(total int) *batchProgress {
+	p := &batchProgress{
+		live:    !quiet && isTerminal(os.Stdout) && isTerminal(os.Stderr),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		total:   total,
+		samples: []progressSample{{at: time.Now()}},
+	}
+	go p.run()
+	return p
+}
+
+// Add counts transfers that completed or failed.
+func (p *batchProgress) Add(completed, failed int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed += completed
+	p.failed += failed
+}
+
+// Writer returns a writer to w that keeps the status line below whatever is written. Logs and signatures are
+// written through it while the progress is shown.
+func (p *batchProgress) Writer(w io.Writer) io.Writer {
+	if !p.live {
+		return w
+	}
+	return progressWriter{p: p, w: w}
+}
+
+type progressWriter struct {
+	p *batchProgress
+	w io.Writer
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	pw.p.clear()
+	n, err := pw.w.Write(b)
+	pw.p.draw()
+	return n, err
+}
+
+// Close stops showing the progress, leaving the final status line on screen.
+func (p *batchProgress) Close() {
+	close(p.stop)
+	<-p.done
+	if p.live {
+		p.mu.Lock()
+		p.draw()
+		fmt.Fprintln(os.Stderr)
+		p.mu.Unlock()
+	}
+}
+
+func (p *batchProgress) run() {
+	defer close(p.done)
+	interval := progressLogInterval
+	if p.live {
+		interval = progressRedrawInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			p.sample(now)
+			if p.live {
+				p.draw()
+				p.mu.Unlock()
+				continue
+			}
+			completed, failed, pending := p.completed, p.failed, p.pending()
+			rate, eta := p.rate(), p.eta()
+			p.mu.Unlock()
+			slog.Info("batch progress", "completed", completed, "failed", failed, "pending", pending, "tps", fmt.Sprintf("%.1f", rate), "eta", formatETA(eta))
+		}
+	}
+}
+
+// sample records the completed count at now and forgets the samples older than progressRateWindow.
+func (p *batchProgress) sample(now time.Time) {
+	p.samples = append(p.samples, progressSample{at: now, completed: p.completed})
+	for len(p.samples) > 2 && now.Sub(p.samples[1].at) >= progressRateWindow {
+		p.samples = p.samples[1:]
+	}
+}
+
+func (p *batchProgress) pending() int {
+	return p.total - p.completed - p.failed
+}
+
+// rate returns the transfers completed per second over the last progressRateWindow.
+func (p *batchProgress) rate() float64 {
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.completed-first.completed) / elapsed
+}
+
+// eta returns how long the pending transfers should take at the current rate, or -1 if it isn't known.
+func (p *batchProgress) eta() time.Duration {
+	rate := p.rate()
+	if rate <= 0 {
+		return -1
+	}
+	return time.Duration(float64(p.pending()) / rate * float64(time.Second))
+}
+
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "unknown"
+	}
+	return eta.Round(time.Second).String()
+}
+
+// draw redraws the status line. p.mu must be held.
+func (p *batchProgress) draw() {
+	if !p.live {
+		return
+	}
+	filled := 0
+	if p.total > 0 {
+		filled = (p.completed + p.failed) * progressBarWidth / p.total
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+	fmt.Fprintf(os.Stderr, "\r\033[K[%s] %d/%d sent, %d failed, %d pending  %.1f transfers/s  ETA %s",
+		bar, p.completed, p.total, p.failed, p.pending(), p.rate(), formatETA(p.eta()))
+	p.drawn = true
+}
+
+// clear erases the status line. p.mu must be held.
+func (p *batchProgress) clear() {
+	if p.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.drawn = false
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stdinIsTerminal reports whether stdin is attached to a terminal, i.e. whether a human can answer prompts.
+func stdinIsTerminal() bool {
+	return isTerminal(os.Stdin)
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// promptYesNo asks a yes/no question on stderr and reads the answer from stdin. Anything but "y" or "yes" is a no.
+func promptYesNo(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// promptTyped asks the operator to type expected to go ahead, for actions that are expensive to get wrong. Only an
+// exact match (ignoring surrounding whitespace) counts as confirmation.
+func promptTyped(question, expected string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s\nType %q to confirm: ", question, expected)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, err
+	}
+	return strings.TrimSpace(answer) == expected, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// rpcRPS caps the JSON-RPC requests per second sent to each endpoint; 0 means unlimited.
+	rpcRPS float64
+	// rpcBurst is how many requests may be sent to an endpoint at once before rpcRPS applies.
+	rpcBurst int
+)
+
+const (
+	// maxRateLimitRetries is how often a request rejected with 429 Too Many Requests is retried.
+	maxRateLimitRetries = 5
+	// rateLimitBackoff is the initial delay before retrying a 429 response without a Retry-After header; it
+	// doubles with every attempt.
+	rateLimitBackoff = 500 * time.Millisecond
+	// maxRetryAfter caps the delay honored from a Retry-After header.
+	maxRetryAfter = time.Minute
+)
+
+// rateLimitTransport keeps requests to one endpoint within its token bucket and retries requests the provider
+// rejected with 429, honoring Retry-After.
+type rateLimitTransport struct {
+	limiter *rate.Limiter // nil means unlimited
+	next    http.RoundTripper
+}
+
+// newRateLimitTransport wraps next with a token bucket of rps requests per second and the given burst. A
+// non-positive rps only adds the 429 handling.
+func newRateLimitTransport(rps float64, burst int, next http.RoundTripper) http.RoundTripper {
+	t := &rateLimitTransport{next: next}
+	if rps > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+	}
+	return t
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body has to be replayed on retries.
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if t.limiter != nil {
+			if err := t.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == maxRateLimitRetries {
+			return resp, err
+		}
+
+		delay := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if delay <= 0 {
+			backoff := rateLimitBackoff << attempt
+			// Jitter spreads out the retries of concurrent requests that were rejected together.
+			delay = backoff/2 + rand.N(backoff/2+1)
+		}
+		resp.Body.Close()
+		slog.Warn("rate limited by rpc endpoint, retrying", "endpoint", req.URL.Host, "attempt", attempt+1, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an HTTP date, into a delay capped at
+// maxRetryAfter. It returns 0 if the header is missing or invalid.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		delay = at.Sub(now)
+	}
+	return min(max(delay, 0), maxRetryAfter)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// receiptDomain prefixes the signed receipt bytes, so a receipt signature can't be replayed as a signature over
+// anything else, e.g. a transaction message.
+const receiptDomain = "token-transfer receipt v1\n"
+
+func init() {
+	commands["receipt"] = runReceipt
+	commands["verify-receipt"] = runVerifyReceipt
+}
+
+// receipt is the proof of payment a sender hands to a receiver: what was paid, to whom, in which transaction.
+type receipt struct {
+	Transaction string    `json:"transaction"`
+	Slot        uint64    `json:"slot"`
+	BlockTime   time.Time `json:"blockTime"`
+	Sender      string    `json:"sender"`
+	Receiver    string    `json:"receiver"`
+	Mint        string    `json:"mint"`
+	// Amount is the decimal amount the receiver's balance grew by in the transaction.
+	Amount string `json:"amount"`
+	// Value is the approximate fiat value of Amount, when the transfer was sent if it's in the local store,
+	// otherwise when the receipt was issued.
+	Value string `json:"value,omitempty"`
+}
+
+// signedReceipt is a receipt with the sender's detached ed25519 signature over receiptDomain and the receipt's
+// JSON encoding.
+type signedReceipt struct {
+	receipt
+	SenderSignature string `json:"senderSignature"`
+}
+
+// message returns the bytes the sender signs.
+func (r receipt) message() ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(receiptDomain), data...), nil
+}
+
+func runReceipt(ctx context.Context, args []string) error {
+	fs := newFlagSet("receipt")
+	sigFlag := fs.String("signature", "", "Signature of the confirmed transfer transaction (required)")
+	receiverFlag := fs.String("receiver", "", "Receiver to issue the receipt for; needed when the transaction paid several")
+	out := fs.String("out", "", "Write the receipt to this file instead of stdout")
+	registerPriceFlags(fs)
+	parseFlags(fs, args)
+
+	if *sigFlag == "" {
+		return fmt.Errorf("%w: --signature flag is required", ErrInvalidArgument)
+	}
+	sig, err := solanago.SignatureFromBase58(*sigFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --signature: %v", ErrInvalidArgument, err)
+	}
+	var receiverKey solanago.PublicKey
+	if *receiverFlag != "" {
+		if receiverKey, err = solanago.PublicKeyFromBase58(*receiverFlag); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+		}
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	r, err := buildReceipt(ctx, client, sig, signer.PublicKey(), receiverKey)
+	if err != nil {
+		return err
+	}
+	if priceSource != "" {
+		if store, err := OpenStore(dataDir); err == nil {
+			// The value recorded at send time, unless a transfer fee made the receiver get less than was sent.
+			if record, ok := store.TransferBySignature(r.Transaction); ok && record.Amount == r.Amount {
+				r.Value = record.Value
+			}
+			store.Close()
+		}
+		if r.Value == "" {
+			r.Value = fiatValue(ctx, client, solanago.MustPublicKeyFromBase58(r.Mint), r.Amount)
+		}
+	}
+	message, err := r.message()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(message)
+	if err != nil {
+		return fmt.Errorf("%w: can't sign receipt: %v", ErrSignerUnavailable, err)
+	}
+	data, err := json.MarshalIndent(signedReceipt{receipt: r, SenderSignature: signature.String()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return fmt.Errorf("can't write receipt: %v", err)
+	}
+	slog.Info("receipt written", "path", *out, "receiver", r.Receiver, "amount", r.Amount)
+	return nil
+}
+
+// buildReceipt reads the confirmed transaction sig and describes the payment sender made in it of the mint selected
+// by --token. The receiver is whichever account owner gained tokens, or receiver if it's set.
+func buildReceipt(ctx context.Context, client RPCClient, sig solanago.Signature, sender, receiver solanago.PublicKey) (receipt, error) {
+	mintAddress, mint, err := resolveMint(ctx, client)
+	if err != nil {
+		return receipt{}, err
+	}
+	maxVersion := uint64(0)
+	res, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return receipt{}, fmt.Errorf("%w: transaction %s not found, or not confirmed yet", ErrInvalidArgument, sig)
+	}
+	if err != nil {
+		return receipt{}, fmt.Errorf("can't get transaction %s: %w", sig, classifyRPCError(err))
+	}
+	if res == nil || res.Meta == nil || res.Transaction == nil {
+		return receipt{}, fmt.Errorf("transaction %s has no metadata", sig)
+	}
+	if res.Meta.Err != nil {
+		return receipt{}, fmt.Errorf("%w: transaction %s failed: %v", ErrInvalidArgument, sig, res.Meta.Err)
+	}
+	tx, err := res.Transaction.GetTransaction()
+	if err != nil {
+		return receipt{}, fmt.Errorf("can't decode transaction %s: %v", sig, err)
+	}
+	if !slices.ContainsFunc(tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures], sender.Equals) {
+		return receipt{}, fmt.Errorf("%w: %s didn't sign transaction %s", ErrInvalidArgument, sender, sig)
+	}
+
+	gains := map[solanago.PublicKey]uint64{}
+	for _, balance := range res.Meta.PostTokenBalances {
+		if balance.Owner == nil || balance.Owner.Equals(sender) || !balance.Mint.Equals(mintAddress) {
+			continue
+		}
+		owner := *balance.Owner
+		pre := ownerTokenBalance(res.Meta.PreTokenBalances, owner, mintAddress)
+		if post := ownerTokenBalance(res.Meta.PostTokenBalances, owner, mintAddress); post > pre {
+			gains[owner] = post - pre
+		}
+	}
+	if receiver.IsZero() {
+		if len(gains) != 1 {
+			return receipt{}, fmt.Errorf("%w: transaction %s paid %d receivers of mint %s, select one with --receiver", ErrInvalidArgument, sig, len(gains), mintAddress)
+		}
+		for owner := range gains {
+			receiver = owner
+		}
+	}
+	received, ok := gains[receiver]
+	if !ok {
+		return receipt{}, fmt.Errorf("%w: %s received no %s in transaction %s", ErrInvalidRecipient, receiver, mintAddress, sig)
+	}
+
+	r := receipt{
+		Transaction: sig.String(),
+		Slot:        res.Slot,
+		Sender:      sender.String(),
+		Receiver:    receiver.String(),
+		Mint:        mintAddress.String(),
+		Amount:      formatUIAmount(received, mint.Decimals),
+	}
+	if res.BlockTime != nil {
+		r.BlockTime = res.BlockTime.Time().UTC()
+	}
+	return r, nil
+}
+
+func runVerifyReceipt(ctx context.Context, args []string) error {
+	fs := newFlagSet("verify-receipt")
+	in := fs.String("in", "", "Receipt file to verify (required)")
+	onChain := fs.Bool("on-chain", false, "Also check the receipt against the transaction on --network")
+	parseFlags(fs, args)
+
+	if *in == "" {
+		return fmt.Errorf("%w: --in flag is required", ErrInvalidArgument)
+	}
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return fmt.Errorf("%w: can't read receipt: %v", ErrInvalidArgument, err)
+	}
+	var r signedReceipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("%w: can't parse receipt: %v", ErrInvalidArgument, err)
+	}
+	if err := r.verify(); err != nil {
+		return err
+	}
+	if *onChain {
+		client, err := newReadClient()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if err := r.verifyOnChain(ctx, client); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("valid receipt: %s %s from %s to %s in %s\n", r.Amount, r.Mint, r.Sender, r.Receiver, r.Transaction)
+	return nil
+}
+
+// verify checks the sender's signature on the receipt.
+func (r signedReceipt) verify() error {
+	sender, err := solanago.PublicKeyFromBase58(r.Sender)
+	if err != nil {
+		return fmt.Errorf("%w: invalid sender in receipt: %v", ErrInvalidArgument, err)
+	}
+	signature, err := solanago.SignatureFromBase58(r.SenderSignature)
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature in receipt: %v", ErrInvalidArgument, err)
+	}
+	message, err := r.message()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(sender[:]), message, signature[:]) {
+		return fmt.Errorf("%w: receipt signature doesn't match its contents and sender %s", ErrInvalidArgument, sender)
+	}
+	return nil
+}
+
+// verifyOnChain checks that the transaction the receipt names paid what the receipt claims.
+func (r signedReceipt) verifyOnChain(ctx context.Context, client RPCClient) error {
+	sig, err := solanago.SignatureFromBase58(r.Transaction)
+	if err != nil {
+		return fmt.Errorf("%w: invalid transaction in receipt: %v", ErrInvalidArgument, err)
+	}
+	sender, err := solanago.PublicKeyFromBase58(r.Sender)
+	if err != nil {
+		return fmt.Errorf("%w: invalid sender in receipt: %v", ErrInvalidArgument, err)
+	}
+	receiver, err := solanago.PublicKeyFromBase58(r.Receiver)
+	if err != nil {
+		return fmt.Errorf("%w: invalid receiver in receipt: %v", ErrInvalidArgument, err)
+	}
+	// The receipt's mint is the one to look for, whatever --token says.
+	tokenName = r.Mint
+	actual, err := buildReceipt(ctx, client, sig, sender, receiver)
+	if err != nil {
+		return err
+	}
+	if actual.Slot != r.Slot || actual.Amount != r.Amount {
+		return fmt.Errorf("%w: transaction %s paid %s in slot %d, the receipt claims %s in slot %d", ErrInvalidArgument, sig, actual.Amount, actual.Slot, r.Amount, r.Slot)
+	}
+	return nil
+}
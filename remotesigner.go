@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	tokentransferv1 "github.com/csknk/token-transfer/gen/tokentransfer/v1"
+)
+
+// remoteSignerTimeout bounds each request to a remote signer, which may be waiting on an HSM or an approval step.
+const remoteSignerTimeout = 30 * time.Second
+
+// Signer signs transaction messages on behalf of a public key. A solanago.PrivateKey is a Signer; so is a key held
+// by a RemoteSigner.
+type Signer interface {
+	PublicKey() solanago.PublicKey
+	Sign(message []byte) (solanago.Signature, error)
+}
+
+// RemoteSigner is an external signing service, e.g. backed by an HSM or a cloud KMS. The private key never enters
+// this process: the serialized transaction message is sent to the service, which returns the signature.
+type RemoteSigner interface {
+	// PublicKey returns the public key the service signs for.
+	PublicKey(ctx context.Context) (solanago.PublicKey, error)
+	// SignMessage returns the ed25519 signature of message.
+	SignMessage(ctx context.Context, message []byte) (solanago.Signature, error)
+}
+
+// remoteKey is the Signer for a key held by a RemoteSigner. Every signature returned by the service is verified
+// before use, so a misbehaving service fails the transfer here rather than on-chain.
+type remoteKey struct {
+	remote RemoteSigner
+	key    solanago.PublicKey
+}
+
+// newRemoteKey asks remote for its public key.
+func newRemoteKey(remote RemoteSigner) (*remoteKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	key, err := remote.PublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get public key from remote signer: %v", err)
+	}
+	return &remoteKey{remote: remote, key: key}, nil
+}
+
+func (k *remoteKey) PublicKey() solanago.PublicKey {
+	return k.key
+}
+
+func (k *remoteKey) Sign(message []byte) (solanago.Signature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	sig, err := k.remote.SignMessage(ctx, message)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("remote signer: %v", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(k.key[:]), message, sig[:]) {
+		return solanago.Signature{}, fmt.Errorf("remote signer returned an invalid signature for %s", k.key)
+	}
+	return sig, nil
+}
+
+// httpRemoteSigner is a RemoteSigner speaking the HTTP version of proto/tokentransfer/v1/signer.proto:
+//
+//	GET  /v1/public-key -> {"publicKey": "<base58>"}
+//	POST /v1/sign {"message": "<base64>"} -> {"signature": "<base58>"}
+//
+// Requests carry REMOTE_SIGNER_TOKEN, if set, as a bearer token.
+type httpRemoteSigner struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newHTTPRemoteSigner returns a client for the signing service at baseURL.
+func newHTTPRemoteSigner(baseURL string) (*httpRemoteSigner, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote signer URL %q", baseURL)
+	}
+	return &httpRemoteSigner{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		token:  os.Getenv("REMOTE_SIGNER_TOKEN"),
+		client: &http.Client{},
+	}, nil
+}
+
+func (s *httpRemoteSigner) PublicKey(ctx context.Context) (solanago.PublicKey, error) {
+	var resp struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if err := s.call(ctx, http.MethodGet, "/v1/public-key", nil, &resp); err != nil {
+		return solanago.PublicKey{}, err
+	}
+	key, err := solanago.PublicKeyFromBase58(resp.PublicKey)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("invalid public key %q: %v", resp.PublicKey, err)
+	}
+	return key, nil
+}
+
+func (s *httpRemoteSigner) SignMessage(ctx context.Context, message []byte) (solanago.Signature, error) {
+	req := struct {
+		Message []byte `json:"message"`
+	}{message}
+	var resp struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.call(ctx, http.MethodPost, "/v1/sign", req, &resp); err != nil {
+		return solanago.Signature{}, err
+	}
+	sig, err := solanago.SignatureFromBase58(resp.Signature)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("invalid signature %q: %v", resp.Signature, err)
+	}
+	return sig, nil
+}
+
+// call makes a JSON request to the signing service and decodes the response into out.
+func (s *httpRemoteSigner) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if len(bytes.TrimSpace(msg)) > 0 {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// grpcRemoteSigner is a RemoteSigner speaking the SignerService of proto/tokentransfer/v1/signer.proto. The
+// connection uses TLS with the system's roots, except to a loopback address such as a signing sidecar's. Requests
+// carry REMOTE_SIGNER_TOKEN, if set, as a bearer token in the authorization metadata.
+type grpcRemoteSigner struct {
+	client tokentransferv1.SignerServiceClient
+	token  string
+}
+
+// newGRPCRemoteSigner returns a client for the signing service at target, a HOST:PORT address.
+func newGRPCRemoteSigner(target string) (*grpcRemoteSigner, error) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("invalid remote signer address %q, want remote+grpc://HOST:PORT", target)
+	}
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signer address %q: %v", target, err)
+	}
+	return &grpcRemoteSigner{
+		client: tokentransferv1.NewSignerServiceClient(conn),
+		token:  os.Getenv("REMOTE_SIGNER_TOKEN"),
+	}, nil
+}
+
+func (s *grpcRemoteSigner) PublicKey(ctx context.Context) (solanago.PublicKey, error) {
+	resp, err := s.client.GetPublicKey(s.outgoing(ctx), &tokentransferv1.GetPublicKeyRequest{})
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	key, err := solanago.PublicKeyFromBase58(resp.GetPublicKey())
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("invalid public key %q: %v", resp.GetPublicKey(), err)
+	}
+	return key, nil
+}
+
+func (s *grpcRemoteSigner) SignMessage(ctx context.Context, message []byte) (solanago.Signature, error) {
+	resp, err := s.client.SignMessage(s.outgoing(ctx), &tokentransferv1.SignMessageRequest{Message: message})
+	if err != nil {
+		return solanago.Signature{}, err
+	}
+	sig, err := solanago.SignatureFromBase58(resp.GetSignature())
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("invalid signature %q: %v", resp.GetSignature(), err)
+	}
+	return sig, nil
+}
+
+// outgoing adds the bearer token, if any, to the metadata of calls made with ctx.
+func (s *grpcRemoteSigner) outgoing(ctx context.Context) context.Context {
+	if s.token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+s.token)
+}
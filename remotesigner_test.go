@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	tokentransferv1 "github.com/csknk/token-transfer/gen/tokentransfer/v1"
+)
+
+// fakeSignerService signs with key for callers presenting token, and with wrongKey if it's set.
+type fakeSignerService struct {
+	tokentransferv1.UnimplementedSignerServiceServer
+	key, wrongKey solanago.PrivateKey
+	token         string
+}
+
+func (f *fakeSignerService) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer "+f.token {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+func (f *fakeSignerService) GetPublicKey(ctx context.Context, _ *tokentransferv1.GetPublicKeyRequest) (*tokentransferv1.GetPublicKeyResponse, error) {
+	if err := f.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return &tokentransferv1.GetPublicKeyResponse{PublicKey: f.key.PublicKey().String()}, nil
+}
+
+func (f *fakeSignerService) SignMessage(ctx context.Context, req *tokentransferv1.SignMessageRequest) (*tokentransferv1.SignMessageResponse, error) {
+	if err := f.authorize(ctx); err != nil {
+		return nil, err
+	}
+	key := f.key
+	if f.wrongKey != nil {
+		key = f.wrongKey
+	}
+	sig, err := key.Sign(req.GetMessage())
+	if err != nil {
+		return nil, err
+	}
+	return &tokentransferv1.SignMessageResponse{Signature: sig.String()}, nil
+}
+
+// serveFakeSigner serves service on a loopback port and returns the --signer URI of it.
+func serveFakeSigner(t *testing.T, service *fakeSignerService) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	tokentransferv1.RegisterSignerServiceServer(srv, service)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return "remote+grpc://" + lis.Addr().String()
+}
+
+func TestGRPCRemoteSigner(t *testing.T) {
+	t.Setenv("REMOTE_SIGNER_TOKEN", "secret")
+	service := &fakeSignerService{key: testKey(1), token: "secret"}
+	signer, err := loadSignerFrom(serveFakeSigner(t, service))
+	if err != nil {
+		t.Fatalf("loadSignerFrom: %v", err)
+	}
+	if !signer.PublicKey().Equals(testKey(1).PublicKey()) {
+		t.Errorf("public key %s, want %s", signer.PublicKey(), testKey(1).PublicKey())
+	}
+	if _, err := signer.Sign([]byte("message")); err != nil {
+		t.Errorf("Sign: %v", err)
+	}
+
+	service.wrongKey = testKey(2)
+	if _, err := signer.Sign([]byte("message")); err == nil {
+		t.Error("Sign accepted a signature by another key")
+	}
+}
+
+func TestGRPCRemoteSignerSendsToken(t *testing.T) {
+	t.Setenv("REMOTE_SIGNER_TOKEN", "wrong")
+	uri := serveFakeSigner(t, &fakeSignerService{key: testKey(1), token: "secret"})
+	if _, err := loadSignerFrom(uri); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("loadSignerFrom = %v, want the service's rejection", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	"github.com/csknk/token-transfer/pkg/rpcfake"
+)
+
+// RPCClient is the part of the Solana JSON RPC API the tool uses. RPCClient implements it; rpcfake.Client is an
+// in-memory implementation for running code without a cluster.
+type RPCClient interface {
+	GetAccountInfo(ctx context.Context, account solanago.PublicKey) (*rpc.GetAccountInfoResult, error)
+	GetAccountInfoWithOpts(ctx context.Context, account solanago.PublicKey, opts *rpc.GetAccountInfoOpts) (*rpc.GetAccountInfoResult, error)
+	GetMultipleAccounts(ctx context.Context, accounts ...solanago.PublicKey) (*rpc.GetMultipleAccountsResult, error)
+	GetBalance(ctx context.Context, account solanago.PublicKey, commitment rpc.CommitmentType) (*rpc.GetBalanceResult, error)
+	GetTokenAccountsByOwner(ctx context.Context, owner solanago.PublicKey, conf *rpc.GetTokenAccountsConfig, opts *rpc.GetTokenAccountsOpts) (*rpc.GetTokenAccountsResult, error)
+	GetProgramAccountsWithOpts(ctx context.Context, program solanago.PublicKey, opts *rpc.GetProgramAccountsOpts) (rpc.GetProgramAccountsResult, error)
+	GetTokenLargestAccounts(ctx context.Context, tokenMint solanago.PublicKey, commitment rpc.CommitmentType) (*rpc.GetTokenLargestAccountsResult, error)
+	GetMinimumBalanceForRentExemption(ctx context.Context, dataSize uint64, commitment rpc.CommitmentType) (uint64, error)
+	GetLatestBlockhash(ctx context.Context, commitment rpc.CommitmentType) (*rpc.GetLatestBlockhashResult, error)
+	IsBlockhashValid(ctx context.Context, blockhash solanago.Hash, commitment rpc.CommitmentType) (*rpc.IsValidBlockhashResult, error)
+	GetBlockHeight(ctx context.Context, commitment rpc.CommitmentType) (uint64, error)
+	GetSlot(ctx context.Context, commitment rpc.CommitmentType) (uint64, error)
+	SendTransactionWithOpts(ctx context.Context, tx *solanago.Transaction, opts rpc.TransactionOpts) (solanago.Signature, error)
+	SimulateTransactionWithOpts(ctx context.Context, tx *solanago.Transaction, opts *rpc.SimulateTransactionOpts) (*rpc.SimulateTransactionResponse, error)
+	GetSignatureStatuses(ctx context.Context, searchTransactionHistory bool, signatures ...solanago.Signature) (*rpc.GetSignatureStatusesResult, error)
+	GetSignaturesForAddressWithOpts(ctx context.Context, account solanago.PublicKey, opts *rpc.GetSignaturesForAddressOpts) ([]*rpc.TransactionSignature, error)
+	GetTransaction(ctx context.Context, sig solanago.Signature, opts *rpc.GetTransactionOpts) (*rpc.GetTransactionResult, error)
+	RequestAirdrop(ctx context.Context, account solanago.PublicKey, lamports uint64, commitment rpc.CommitmentType) (solanago.Signature, error)
+	Close() error
+}
+
+var (
+	_ RPCClient = (*rpc.Client)(nil)
+	_ RPCClient = (*rpcfake.Client)(nil)
+)
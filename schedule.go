@@ -0,0 +1,228 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// schedulesFile holds the recurring transfers, in the data directory.
+const schedulesFile = "schedules.json"
+
+func init() {
+	commands["schedule"] = runSchedule
+}
+
+// scheduleConfig is a recurring transfer as configured in schedules.json.
+type scheduleConfig struct {
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Receiver string `json:"receiver"`
+	// Amount is a decimal token amount, e.g. "12.5".
+	Amount string `json:"amount"`
+	// Token is a symbol or mint address; it defaults to the token the daemon serves.
+	Token string `json:"token,omitempty"`
+}
+
+// schedule is a validated recurring transfer.
+type schedule struct {
+	scheduleConfig
+	cron     *cronSchedule
+	receiver solanago.PublicKey
+}
+
+// loadSchedules reads and validates the recurring transfers in dir. A missing file means there are none.
+func loadSchedules(dir string) ([]schedule, error) {
+	path := filepath.Join(dir, schedulesFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read schedules: %v", err)
+	}
+	var configs []scheduleConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+
+	schedules := make([]schedule, 0, len(configs))
+	names := map[string]bool{}
+	for i, config := range configs {
+		if config.Name == "" || strings.Contains(config.Name, ":") {
+			return nil, fmt.Errorf("%w: %s: schedule %d needs a name without colons", ErrInvalidArgument, path, i+1)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("%w: %s: duplicate schedule %q", ErrInvalidArgument, path, config.Name)
+		}
+		names[config.Name] = true
+
+		c, err := parseCron(config.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: schedule %q: %v", ErrInvalidArgument, path, config.Name, err)
+		}
+		receiverKey, err := solanago.PublicKeyFromBase58(config.Receiver)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: schedule %q: %v", ErrInvalidRecipient, path, config.Name, err)
+		}
+		if config.Amount == "" {
+			return nil, fmt.Errorf("%w: %s: schedule %q has no amount", ErrInvalidArgument, path, config.Name)
+		}
+		schedules = append(schedules, schedule{scheduleConfig: config, cron: c, receiver: receiverKey})
+	}
+	return schedules, nil
+}
+
+// runKey is the idempotency key of the run of s due at t, so a run is executed at most once even across
+// restarts.
+func (s schedule) runKey(t time.Time) string {
+	return fmt.Sprintf("schedule:%s:%s", s.Name, t.UTC().Format(time.RFC3339))
+}
+
+func runSchedule(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%w: usage: schedule list [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("schedule list")
+	parseFlags(fs, args[1:])
+
+	schedules, err := loadSchedules(dataDir)
+	if err != nil {
+		return err
+	}
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCRON\tRECEIVER\tAMOUNT\tTOKEN\tNEXT RUN\tLAST RUN\tSTATUS")
+	for _, s := range schedules {
+		lastRun, status := "-", "-"
+		if runs := store.TransfersByKeyPrefix("schedule:" + s.Name + ":"); len(runs) > 0 {
+			last := runs[len(runs)-1]
+			lastRun, status = last.CreatedAt.Format(time.RFC3339), last.Status
+		}
+		next := "never"
+		if t := s.cron.Next(now); !t.IsZero() {
+			next = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Cron, s.Receiver, s.Amount, cmp.Or(s.Token, "default"), next, lastRun, status)
+	}
+	return w.Flush()
+}
+
+// runScheduler executes the recurring transfers until ctx is cancelled. Transactions are built and signed just
+// in time, when a run is due. Runs missed while the daemon was down are not caught up. Every run is journaled
+// like any other transfer, under the schedule's run key.
+func (s *server) runScheduler(ctx context.Context, schedules []schedule) {
+	for _, sched := range schedules {
+		go s.runSchedule(ctx, sched)
+	}
+}
+
+func (s *server) runSchedule(ctx context.Context, sched schedule) {
+	for {
+		next := sched.cron.Next(time.Now().UTC())
+		if next.IsZero() {
+			slog.Warn("schedule never runs", "schedule", sched.Name, "cron", sched.Cron)
+			return
+		}
+		slog.Debug("next scheduled run", "schedule", sched.Name, "at", next)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if err := s.executeScheduledRun(ctx, sched, next); err != nil {
+			slog.Error("scheduled transfer failed", "schedule", sched.Name, "run", next, "error", err)
+		}
+	}
+}
+
+// executeScheduledRun sends the run of sched due at t, unless it was already recorded.
+func (s *server) executeScheduledRun(ctx context.Context, sched schedule, t time.Time) error {
+	key := sched.runKey(t)
+	if _, ok := s.store.TransferByIdempotencyKey(key); ok {
+		slog.Info("scheduled run already executed", "schedule", sched.Name, "run", t)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	mintAddress, decimals := s.mint, s.decimals
+	if sched.Token != "" {
+		var err error
+		if mintAddress, err = lookupToken(sched.Token); err != nil {
+			return err
+		}
+		mint, err := GetMint(ctx, s.clients.Read, mintAddress, rpc.CommitmentFinalized)
+		if err != nil {
+			return fmt.Errorf("error getting mint: %w", classifyRPCError(err))
+		}
+		decimals = mint.Decimals
+	}
+	rawAmount, err := parseUIAmount(sched.Amount, decimals)
+	if err != nil {
+		return err
+	}
+
+	// Like the transfer API, check the daily limit and record the transfer atomically.
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	if err := s.policy.Check(s.store, mintAddress, decimals, sched.receiver, rawAmount, 0); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	needsApproval, err := s.policy.NeedsApproval(mintAddress, decimals, rawAmount)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	record := newTransferRecord(key, s.signer.PublicKey(), sched.receiver, mintAddress, formatUIAmount(rawAmount, decimals))
+	if needsApproval {
+		record.Status = transferAwaitingApproval
+	}
+	err = s.store.PutTransfer(record)
+	if err == nil && !needsApproval {
+		// Once started, let it confirm even if the daemon is shutting down.
+		s.inFlight[record.ID] = true
+		s.work.Add(1)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("can't record transfer: %v", err)
+	}
+	if needsApproval {
+		slog.Info("scheduled transfer queued for approval", "schedule", sched.Name, "id", record.ID, "amount", record.Amount)
+		return nil
+	}
+	defer func() {
+		s.mu.Lock()
+		delete(s.inFlight, record.ID)
+		s.mu.Unlock()
+		s.work.Done()
+	}()
+	transfersSubmitted.Inc()
+	slog.Info("scheduled transfer started", "schedule", sched.Name, "id", record.ID, "receiver", record.Receiver, "amount", record.Amount)
+	sendCtx, cancelSend := context.WithTimeout(s.drain, 2*time.Minute)
+	defer cancelSend()
+	_, err = sendRecorded(sendCtx, s.store, s.clients, s.signer, record.ID, sched.receiver, rawAmount, TransferOptions{Mint: mintAddress})
+	return err
+}
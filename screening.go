@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// screeningHistoryLimit bounds how many recent signatures per token account are compared on-chain.
+const screeningHistoryLimit = 1000
+
+// isKnownReceiver reports whether sender has sent mint to receiver before, according to the local store or to
+// the on-chain history of their token accounts (a transaction touching both ATAs).
+func isKnownReceiver(ctx context.Context, client RPCClient, store *Store, sender, receiver, mint solanago.PublicKey) (bool, error) {
+	if store != nil && store.HasConfirmedTransferTo(receiver.String()) {
+		return true, nil
+	}
+
+	senderAta, _, err := solanago.FindAssociatedTokenAddress(sender, mint)
+	if err != nil {
+		return false, err
+	}
+	receiverAta, _, err := solanago.FindAssociatedTokenAddress(receiver, mint)
+	if err != nil {
+		return false, err
+	}
+
+	receiverSigs, err := recentSignatures(ctx, client, receiverAta)
+	if err != nil || len(receiverSigs) == 0 {
+		// No history at all (or no ATA yet): nothing was ever received.
+		return false, err
+	}
+	senderSigs, err := recentSignatures(ctx, client, senderAta)
+	if err != nil {
+		return false, err
+	}
+	for sig := range senderSigs {
+		if receiverSigs[sig] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recentSignatures returns the successful recent transaction signatures that touched account.
+func recentSignatures(ctx context.Context, client RPCClient, account solanago.PublicKey) (map[solanago.Signature]bool, error) {
+	limit := screeningHistoryLimit
+	sigs, err := client.GetSignaturesForAddressWithOpts(ctx, account, &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, classifyRPCError(err)
+	}
+	found := make(map[solanago.Signature]bool, len(sigs))
+	for _, sig := range sigs {
+		if sig.Err == nil {
+			found[sig.Signature] = true
+		}
+	}
+	return found, nil
+}
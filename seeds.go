@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// defaultMintSeeds are the seeds the program's wrapped mint is derived from unless configured otherwise.
+var defaultMintSeeds = []string{"str:wrapped_mint"}
+
+var (
+	mintSeedsFlag string
+	mintSymbol    string
+)
+
+// registerSeedFlags registers the flags selecting how the program's mint address is derived.
+func registerSeedFlags(fs *flag.FlagSet) {
+	fs.StringVar(&mintSeedsFlag, "mint-seeds", "", "Comma separated seeds the program's mint PDA is derived from: str:TEXT, hex:BYTES, pubkey:BASE58 or symbol (default: the --network profile's, or str:wrapped_mint)")
+	fs.StringVar(&mintSymbol, "mint-symbol", "", "Token symbol used for the symbol seed of --mint-seeds")
+}
+
+// parseSeeds decodes seed specs into PDA seeds. Each spec is one of:
+//
+//	str:TEXT       the UTF-8 bytes of TEXT (a spec without a prefix is read the same way)
+//	hex:BYTES      raw bytes
+//	pubkey:BASE58  the 32 bytes of a public key
+//	symbol         the UTF-8 bytes of symbol
+func parseSeeds(specs []string, symbol string) ([][]byte, error) {
+	if len(specs) > solanago.MaxSeeds {
+		return nil, fmt.Errorf("%w: at most %d seeds are allowed, got %d", ErrInvalidArgument, solanago.MaxSeeds, len(specs))
+	}
+	seeds := make([][]byte, 0, len(specs))
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		kind, value, found := strings.Cut(spec, ":")
+		if !found && spec != "symbol" {
+			kind, value = "str", spec
+		}
+		var seed []byte
+		switch kind {
+		case "str":
+			seed = []byte(value)
+		case "hex":
+			b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid hex seed %q", ErrInvalidArgument, value)
+			}
+			seed = b
+		case "pubkey":
+			key, err := solanago.PublicKeyFromBase58(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid pubkey seed %q: %v", ErrInvalidArgument, value, err)
+			}
+			seed = key[:]
+		case "symbol":
+			if found {
+				return nil, fmt.Errorf("%w: invalid seed %q, the symbol seed is set with --mint-symbol", ErrInvalidArgument, spec)
+			}
+			if symbol == "" {
+				return nil, fmt.Errorf("%w: the mint seeds include the token symbol, set --mint-symbol", ErrInvalidArgument)
+			}
+			seed = []byte(symbol)
+		default:
+			return nil, fmt.Errorf("%w: invalid seed %q, use str:TEXT, hex:BYTES, pubkey:BASE58 or symbol", ErrInvalidArgument, spec)
+		}
+		if len(seed) > solanago.MaxSeedLength {
+			return nil, fmt.Errorf("%w: seed %q is longer than %d bytes", ErrInvalidArgument, spec, solanago.MaxSeedLength)
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+// mintSeeds returns the seeds of the program's mint: --mint-seeds, or else the profile's, or else the defaults.
+func mintSeeds() ([][]byte, error) {
+	specs := defaultMintSeeds
+	if mintSeedsFlag != "" {
+		specs = strings.Split(mintSeedsFlag, ",")
+	} else if c, err := currentCluster(); err == nil && len(c.MintSeeds) > 0 {
+		specs = c.MintSeeds
+	}
+	return parseSeeds(specs, mintSymbol)
+}
+
+// programMintAddress returns the address of the configured program's mint.
+func programMintAddress() (solanago.PublicKey, error) {
+	seeds, err := mintSeeds()
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	mint, err := GetMintAddress(solanago.MustPublicKeyFromBase58(programIDBase58), seeds)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't get mint address: %v", err)
+	}
+	return mint, nil
+}
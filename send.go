@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	txsender "github.com/csknk/token-transfer/pkg/sender"
+)
+
+var (
+	sendStrategy   string
+	spamInterval   time.Duration
+	resendInterval time.Duration
+	skipPreflight  bool
+	jitoURL        string
+	jitoTip        uint64
+)
+
+// registerSendFlags registers the flags selecting how signed transactions are submitted.
+func registerSendFlags(fs *flag.FlagSet) {
+	fs.StringVar(&sendStrategy, "send-strategy", "rpc", "How transactions are submitted: rpc (sendTransaction, rebroadcast every --resend-interval), spam (sendTransaction without preflight, rebroadcast every --spam-interval) or jito (bundle to a Jito block engine)")
+	fs.DurationVar(&resendInterval, "resend-interval", 2*time.Second, "Rebroadcast interval of the rpc send strategy")
+	fs.BoolVar(&skipPreflight, "skip-preflight", false, "Broadcast transactions without simulating them first, and without the RPC node's preflight check")
+	fs.DurationVar(&spamInterval, "spam-interval", 200*time.Millisecond, "Rebroadcast interval of the spam send strategy")
+	fs.StringVar(&jitoURL, "jito-url", defaultJitoURL, "Jito block engine URL, used by the jito send strategy")
+	fs.Uint64Var(&jitoTip, "jito-tip", 10000, "Tip in lamports paid to Jito with every transaction, used by the jito send strategy")
+}
+
+// Sender submits transactions and waits for them to be confirmed. Implementations differ in how a transaction
+// reaches the leader; blockhashes and confirmation always come from the read endpoint.
+type Sender interface {
+	// SendAndConfirm signs a transaction with sign, submits it and waits for confirmation, re-signing with a fresh
+	// blockhash if it expires. onSigned, if not nil, is called with every signed transaction before it is
+	// submitted; an error from it aborts the send.
+	SendAndConfirm(ctx context.Context, sign txsender.SignFunc, onSigned func(*solanago.Transaction, txsender.Blockhash) error) (solanago.Signature, error)
+	// Simulate runs tx against the cluster without submitting it, logging the program logs.
+	Simulate(ctx context.Context, tx *solanago.Transaction) error
+	// TipInstructions returns the instructions, paid by payer, that every transaction sent through this sender
+	// must end with.
+	TipInstructions(ctx context.Context, payer solanago.PublicKey) ([]solanago.Instruction, error)
+}
+
+// newSender returns the Sender selected by --send-strategy.
+func newSender(clients Clients) (Sender, error) {
+	switch sendStrategy {
+	case "rpc":
+		if resendInterval <= 0 {
+			return nil, fmt.Errorf("%w: --resend-interval must be positive", ErrInvalidArgument)
+		}
+		return newRPCSender(clients), nil
+	case "spam":
+		if spamInterval <= 0 {
+			return nil, fmt.Errorf("%w: --spam-interval must be positive", ErrInvalidArgument)
+		}
+		return newSpamSender(clients, spamInterval), nil
+	case "jito":
+		if jitoTip == 0 {
+			return nil, fmt.Errorf("%w: bundles without a tip are dropped, set --jito-tip", ErrInvalidArgument)
+		}
+		return newJitoSender(clients, newJitoClient(jitoURL, jitoTip)), nil
+	}
+	return nil, fmt.Errorf("%w: invalid --send-strategy %q, use rpc, spam or jito", ErrInvalidArgument, sendStrategy)
+}
+
+// rpcSender submits transactions with sendTransaction on the write endpoint, rebroadcasting them every
+// resendInterval until they are confirmed or expire.
+type rpcSender struct {
+	clients        Clients
+	resendInterval time.Duration
+	skipPreflight  bool
+}
+
+// newRPCSender returns the standard sender: sendTransaction, with preflight unless --skip-preflight is set,
+// rebroadcast every --resend-interval.
+func newRPCSender(clients Clients) *rpcSender {
+	return &rpcSender{clients: clients, resendInterval: resendInterval, skipPreflight: skipPreflight}
+}
+
+// newSpamSender returns a sender that rebroadcasts every interval and skips preflight, so a transaction reaches
+// as many leaders as possible while it is valid. A transaction that would fail lands and pays its fee, so
+// callers should simulate first.
+func newSpamSender(clients Clients, interval time.Duration) *rpcSender {
+	return &rpcSender{clients: clients, resendInterval: interval, skipPreflight: true}
+}
+
+func (s *rpcSender) SendAndConfirm(ctx context.Context, sign txsender.SignFunc, onSigned func(*solanago.Transaction, txsender.Blockhash) error) (solanago.Signature, error) {
+	// The loop rebroadcasts the same signed transaction until it's confirmed or its blockhash expires, so the
+	// node is told not to queue retries of its own.
+	maxRetries := uint(0)
+	broadcast := func(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+		sig, err := s.clients.Write.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{SkipPreflight: s.skipPreflight, MaxRetries: &maxRetries})
+		return sig, classifyRPCError(err)
+	}
+	return sendLoop(ctx, senderRPC{s.clients, broadcast}, txsender.Config{ResendInterval: s.resendInterval, OnSigned: onSigned}).Send(ctx, sign)
+}
+
+func (s *rpcSender) Simulate(ctx context.Context, tx *solanago.Transaction) error {
+	return simulateTransaction(ctx, s.clients.Read, tx)
+}
+
+func (s *rpcSender) TipInstructions(context.Context, solanago.PublicKey) ([]solanago.Instruction, error) {
+	return nil, nil
+}
+
+// jitoSender submits every transaction as a single-transaction bundle to a Jito block engine, paying its tip.
+type jitoSender struct {
+	clients Clients
+	jito    *jitoClient
+}
+
+func newJitoSender(clients Clients, jito *jitoClient) *jitoSender {
+	return &jitoSender{clients: clients, jito: jito}
+}
+
+func (s *jitoSender) SendAndConfirm(ctx context.Context, sign txsender.SignFunc, onSigned func(*solanago.Transaction, txsender.Blockhash) error) (solanago.Signature, error) {
+	return sendLoop(ctx, senderRPC{s.clients, s.jito.SendBundle}, txsender.Config{OnSigned: onSigned}).Send(ctx, sign)
+}
+
+func (s *jitoSender) Simulate(ctx context.Context, tx *solanago.Transaction) error {
+	return simulateTransaction(ctx, s.clients.Read, tx)
+}
+
+func (s *jitoSender) TipInstructions(ctx context.Context, payer solanago.PublicKey) ([]solanago.Instruction, error) {
+	return s.jito.TipInstructions(ctx, payer)
+}
+
+// senderRPC adapts Clients to the send loop: reads go to the read endpoint, broadcasts to broadcast.
+type senderRPC struct {
+	clients   Clients
+	broadcast func(context.Context, *solanago.Transaction) (solanago.Signature, error)
+}
+
+func (r senderRPC) LatestBlockhash(ctx context.Context) (txsender.Blockhash, error) {
+	res, err := r.clients.Read.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return txsender.Blockhash{}, classifyRPCError(err)
+	}
+	return txsender.Blockhash{Hash: res.Value.Blockhash, LastValidBlockHeight: res.Value.LastValidBlockHeight}, nil
+}
+
+func (r senderRPC) SendTransaction(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+	return r.broadcast(ctx, tx)
+}
+
+func (r senderRPC) SignatureStatus(ctx context.Context, sig solanago.Signature) (txsender.Status, error) {
+	res, err := r.clients.Read.GetSignatureStatuses(ctx, false, sig)
+	if err != nil {
+		return txsender.Status{}, classifyRPCError(err)
+	}
+	if len(res.Value) == 0 || res.Value[0] == nil {
+		return txsender.Status{}, nil
+	}
+	status := res.Value[0]
+	spanFromContext(ctx).SetAttrs("solana.slot", status.Slot)
+	st := txsender.Status{
+		Found:     true,
+		Confirmed: status.ConfirmationStatus == rpc.ConfirmationStatusFinalized || (status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed && !finalizedOnly()),
+	}
+	if status.Err != nil {
+		st.Err = fmt.Errorf("%v", status.Err)
+	}
+	return st, nil
+}
+
+// BlockHeight returns the finalized block height, so a blockhash is only considered expired once that is
+// certain.
+func (r senderRPC) BlockHeight(ctx context.Context) (uint64, error) {
+	height, err := r.clients.Read.GetBlockHeight(ctx, rpc.CommitmentFinalized)
+	return height, classifyRPCError(err)
+}
+
+// sendLoop returns the send/confirm loop shared by every Sender. Unless --skip-preflight is set, every signed
+// transaction is simulated before it's broadcast. It logs progress and records metrics.
+func sendLoop(ctx context.Context, r senderRPC, cfg txsender.Config) *txsender.Sender {
+	var (
+		sentAt      time.Time
+		signatures  int
+		confirmSpan *span
+	)
+	onSigned := cfg.OnSigned
+	cfg.OnSigned = func(tx *solanago.Transaction, blockhash txsender.Blockhash) error {
+		signatures = len(tx.Signatures)
+		if !skipPreflight {
+			if err := preflightTransaction(ctx, r.clients.Read, tx); err != nil {
+				return err
+			}
+		}
+		if onSigned != nil {
+			return onSigned(tx, blockhash)
+		}
+		return nil
+	}
+	cfg.Retryable = func(err error) bool { return errors.Is(err, ErrBlockhashExpired) }
+	cfg.OnRetry = func(attempt int, err error) {
+		sendRetries.Inc()
+		slog.Info("retrying with a new blockhash", "attempt", attempt, "error", err)
+	}
+	cfg.OnState = func(state txsender.State, sig solanago.Signature) {
+		switch state {
+		case txsender.StateSent:
+			sentAt = time.Now()
+			_, confirmSpan = startSpan(ctx, "confirm", spanKindInternal, "solana.signature", sig.String())
+			spanFromContext(ctx).SetAttrs("solana.signature", sig.String())
+			slog.Info("transaction sent", "signature", sig)
+		case txsender.StateConfirmed:
+			confirmationDuration.Observe("", time.Since(sentAt))
+			feesPaid.Add(uint64(signatures) * lamportsPerSignature)
+			confirmSpan.End(nil)
+			slog.Info("transaction confirmed", "signature", sig)
+		case txsender.StateExpired:
+			confirmSpan.End(txsender.ErrExpired)
+			slog.Warn("blockhash expired before confirmation", "signature", sig)
+		case txsender.StateFailed:
+			confirmSpan.End(txsender.ErrFailed)
+			slog.Debug("send state", "state", state, "signature", sig)
+		default:
+			slog.Debug("send state", "state", state, "signature", sig)
+		}
+	}
+	return txsender.New(r, cfg)
+}
+
+// transferSigner returns a sign function building and signing the transfer against the blockhash the send loop
+// provides.
+func transferSigner(clients Clients, signer Signer, receiver solanago.PublicKey, amount uint64, opts TransferOptions) txsender.SignFunc {
+	return func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		_, sp := startSpan(ctx, "build and sign", spanKindInternal, "solana.blockhash", blockhash.String())
+		opts.Blockhash = blockhash
+		tip, err := clients.Sender.TipInstructions(ctx, opts.payer(signer.PublicKey()))
+		if err != nil {
+			sp.End(err)
+			return nil, err
+		}
+		opts.PostInstructions = append(slices.Clip(opts.PostInstructions), tip...)
+		tx, err := SignTransfer(ctx, clients.Read, signer, receiver, amount, opts)
+		if err == nil {
+			sp.SetAttrs("solana.signature", tx.Signatures[0].String())
+		}
+		sp.End(err)
+		return tx, err
+	}
+}
+
+// sendInstructions sends instructions in one transaction paid and signed by signer, and waits for confirmation.
+func sendInstructions(ctx context.Context, clients Clients, signer Signer, instructions ...solanago.Instruction) (solanago.Signature, error) {
+	return sendCoSignedInstructions(ctx, clients, signer, nil, instructions...)
+}
+
+// sendCoSignedInstructions is sendInstructions for instructions that also need coSigners' signatures, such as a
+// new account's.
+func sendCoSignedInstructions(ctx context.Context, clients Clients, signer Signer, coSigners []Signer, instructions ...solanago.Instruction) (sig solanago.Signature, err error) {
+	ctx, sp := startSpan(ctx, "send", spanKindInternal)
+	defer func() { sp.End(err) }()
+
+	sign := func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		tip, err := clients.Sender.TipInstructions(ctx, signer.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+		instructions := append(slices.Clip(instructions), tip...)
+		tx, err := solanago.NewTransaction(instructions, blockhash, solanago.TransactionPayer(signer.PublicKey()))
+		if err != nil {
+			return nil, fmt.Errorf("can't build transaction: %v", err)
+		}
+		if err := signTransaction(tx, signer, coSigners...); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	sig, err = clients.Sender.SendAndConfirm(ctx, sign, nil)
+	if err != nil {
+		return sig, fmt.Errorf("can't send transaction: %w", classifySendError(err))
+	}
+	return sig, nil
+}
+
+// simulateInstructions builds and signs instructions like sendInstructions, but only simulates the transaction.
+func simulateInstructions(ctx context.Context, clients Clients, signer Signer, instructions ...solanago.Instruction) error {
+	latest, err := clients.Read.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return fmt.Errorf("can't get recent block hash: %w", classifyRPCError(err))
+	}
+	tx, err := solanago.NewTransaction(instructions, latest.Value.Blockhash, solanago.TransactionPayer(signer.PublicKey()))
+	if err != nil {
+		return fmt.Errorf("can't build transaction: %v", err)
+	}
+	if err := signTransaction(tx, signer); err != nil {
+		return err
+	}
+	return clients.Sender.Simulate(ctx, tx)
+}
+
+// simulateTransaction runs tx against the cluster without broadcasting it and logs the program logs. A
+// transaction that would fail returns an ErrSimulationFailed error.
+func simulateTransaction(ctx context.Context, client RPCClient, tx *solanago.Transaction) error {
+	res, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{SigVerify: true, Commitment: rpc.CommitmentConfirmed})
+	if err != nil {
+		return fmt.Errorf("can't simulate transaction: %w", classifyRPCError(err))
+	}
+	for _, line := range res.Value.Logs {
+		slog.Info("simulation log", "line", line)
+	}
+	if res.Value.Err != nil {
+		return simulationError(res.Value)
+	}
+	if res.Value.UnitsConsumed != nil {
+		slog.Info("simulation succeeded", "units", *res.Value.UnitsConsumed)
+	} else {
+		slog.Info("simulation succeeded")
+	}
+	return nil
+}
+
+// preflightTransaction simulates tx before it's broadcast, so a transaction that would fail is never sent and
+// doesn't pay a fee. Unlike simulateTransaction it only logs the program logs at debug level.
+func preflightTransaction(ctx context.Context, client RPCClient, tx *solanago.Transaction) error {
+	res, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{SigVerify: true, Commitment: rpc.CommitmentConfirmed})
+	if err != nil {
+		return fmt.Errorf("can't simulate transaction: %w", classifyRPCError(err))
+	}
+	if res.Value.Err != nil {
+		for _, line := range res.Value.Logs {
+			slog.Debug("simulation log", "line", line)
+		}
+		return simulationError(res.Value)
+	}
+	return nil
+}
+
+// simulationError returns the classified error of a failed simulation, explaining SPL token program errors.
+func simulationError(res *rpc.SimulateTransactionResult) error {
+	msg := fmt.Sprintf("%v", res.Err)
+	classes := append([]error{ErrSimulationFailed}, messageClasses(msg+" "+strings.Join(res.Logs, " "))...)
+	if e, ok := decodeTokenError(res.Logs); ok {
+		if e.class != nil {
+			classes = append(classes, e.class)
+		}
+		msg = fmt.Sprintf("%s (%s)", e, msg)
+	}
+	return &classifiedError{classes: classes, err: fmt.Errorf("simulation failed: %s", msg)}
+}
+
+// classifySendError maps the send loop's errors to the matching error classes.
+func classifySendError(err error) error {
+	switch {
+	case errors.Is(err, txsender.ErrExpired):
+		return &classifiedError{classes: []error{ErrBlockhashExpired}, err: err}
+	case errors.Is(err, txsender.ErrFailed):
+		return &classifiedError{classes: []error{ErrTransactionFailed}, err: err}
+	}
+	return err
+}
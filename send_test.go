@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+
+	"github.com/csknk/token-transfer/pkg/rpcfake"
+	txsender "github.com/csknk/token-transfer/pkg/sender"
+)
+
+// testSendLoop returns the send loop against client, polling every millisecond. broadcast defaults to the
+// client's sendTransaction.
+func testSendLoop(ctx context.Context, client *rpcfake.Client, broadcast func(context.Context, *solanago.Transaction) (solanago.Signature, error)) *txsender.Sender {
+	if broadcast == nil {
+		broadcast = func(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+			return client.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{})
+		}
+	}
+	clients := Clients{Read: client, Write: client}
+	return sendLoop(ctx, senderRPC{clients, broadcast}, txsender.Config{PollInterval: time.Millisecond, ResendInterval: time.Millisecond})
+}
+
+// memoSigner returns a sign function for a memo transaction paid and signed by key.
+func memoSigner(key solanago.PrivateKey) txsender.SignFunc {
+	return func(ctx context.Context, blockhash solanago.Hash) (*solanago.Transaction, error) {
+		memo := solanago.NewInstruction(solanago.MemoProgramID, solanago.AccountMetaSlice{solanago.NewAccountMeta(key.PublicKey(), false, true)}, []byte("test"))
+		tx, err := solanago.NewTransaction([]solanago.Instruction{memo}, blockhash, solanago.TransactionPayer(key.PublicKey()))
+		if err != nil {
+			return nil, err
+		}
+		return tx, signTransaction(tx, key)
+	}
+}
+
+func TestSendLoopConfirms(t *testing.T) {
+	client := rpcfake.New()
+	ctx := context.Background()
+
+	sig, err := testSendLoop(ctx, client, nil).Send(ctx, memoSigner(testKey(1)))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sent := client.Sent()
+	if len(sent) != 1 || sent[0].Signatures[0] != sig {
+		t.Fatalf("sent %d transactions, want one signed %s", len(sent), sig)
+	}
+	// Preflight simulated it first.
+	if len(client.Simulated()) != 1 {
+		t.Errorf("simulated %d transactions, want 1", len(client.Simulated()))
+	}
+}
+
+func TestSendLoopResignsAfterExpiry(t *testing.T) {
+	client := rpcfake.New()
+	ctx := context.Background()
+	var dropped []*solanago.Transaction
+	// The first transaction never reaches the cluster, which moves past its blockhash's lifetime.
+	broadcast := func(ctx context.Context, tx *solanago.Transaction) (solanago.Signature, error) {
+		if len(dropped) == 0 {
+			dropped = append(dropped, tx)
+			client.AdvanceBlocks(151)
+			return tx.Signatures[0], nil
+		}
+		return client.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{})
+	}
+
+	sig, err := testSendLoop(ctx, client, broadcast).Send(ctx, memoSigner(testKey(1)))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	sent := client.Sent()
+	if len(sent) != 1 || sent[0].Signatures[0] != sig {
+		t.Fatalf("sent %d transactions, want one signed %s", len(sent), sig)
+	}
+	if sent[0].Message.RecentBlockhash == dropped[0].Message.RecentBlockhash {
+		t.Errorf("re-sent with the expired blockhash")
+	}
+}
+
+func TestSendLoopOnChainFailure(t *testing.T) {
+	client := rpcfake.New()
+	client.TxErr = map[string]any{"InstructionError": []any{0, map[string]any{"Custom": 1}}}
+	ctx := context.Background()
+
+	_, err := testSendLoop(ctx, client, nil).Send(ctx, memoSigner(testKey(1)))
+	if !errors.Is(err, txsender.ErrFailed) {
+		t.Fatalf("Send = %v, want ErrFailed", err)
+	}
+	if !errors.Is(classifySendError(err), ErrTransactionFailed) {
+		t.Errorf("classified as %v, want ErrTransactionFailed", classifySendError(err))
+	}
+	if len(client.Sent()) != 1 {
+		t.Errorf("sent %d transactions, want 1", len(client.Sent()))
+	}
+}
+
+func TestSendLoopSimulationFailureIsNotBroadcast(t *testing.T) {
+	client := rpcfake.New()
+	client.SimulateErr = map[string]any{"InstructionError": []any{0, map[string]any{"Custom": 1}}}
+	ctx := context.Background()
+
+	_, err := testSendLoop(ctx, client, nil).Send(ctx, memoSigner(testKey(1)))
+	if !errors.Is(err, ErrSimulationFailed) {
+		t.Fatalf("Send = %v, want ErrSimulationFailed", err)
+	}
+	if len(client.Sent()) != 0 {
+		t.Errorf("broadcast a transaction that failed preflight")
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	commands["serve"] = runServe
+}
+
+// server is the long-running HTTP daemon started by `serve`.
+type server struct {
+	clients    Clients
+	signer     Signer
+	apiClients []apiClient // the transfer API is disabled without any
+	mint       solanago.PublicKey
+	decimals   uint8
+	payments   *paymentRequests
+	store      *Store
+	policy     *Policy
+
+	mu       sync.Mutex
+	inFlight map[string]bool // transfer ids queued or being sent by this process
+	// queue holds the transfers accepted but not yet picked up by one of the --workers. Transfers are only queued
+	// under mu, after checking there's room, so a full queue is reported to the client rather than blocking.
+	queue chan transferJob
+
+	mintsMu sync.Mutex
+	mints   map[solanago.PublicKey]uint8 // decimals of the mints requested besides mint
+
+	// drain is the context transfers are sent under. It outlives the server's context by --shutdown-grace, so
+	// transfers in flight at shutdown can still be confirmed; work tracks them.
+	drain   context.Context
+	work    sync.WaitGroup
+	closing bool // set under mu once no more transfers may start
+
+	ready readiness
+}
+
+func runServe(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve")
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on")
+	grpcListen := fs.String("grpc-listen", "", "Address to serve the transfer API over gRPC on, e.g. 127.0.0.1:9090 (needs --api-key-file or --api-keys-file)")
+	merchant := fs.String("merchant", "", "Wallet receiving payment requests (defaults to the signer's public key)")
+	payRate := fs.Float64("pay-rate", 1, "Requests per second allowed per client IP on the public payment endpoints")
+	payBurst := fs.Int("pay-burst", 5, "Burst size allowed per client IP on the public payment endpoints")
+	payTTL := fs.Duration("pay-ttl", 15*time.Minute, "How long a payment request stays payable")
+	payMaxPending := fs.Int("pay-max-pending", 10000, "Maximum number of outstanding payment requests")
+	apiKeyFile := fs.String("api-key-file", "", "File containing an API key for the transfer endpoints, restricted only by policy.json (transfers are disabled without it or --api-keys-file)")
+	apiKeysFile := fs.String("api-keys-file", "", "JSON file of named API keys for the transfer endpoints, each with its own policy")
+	queueSize := fs.Int("queue-depth", 100, "Maximum number of accepted transfers waiting to be sent; further requests get 429 until there's room")
+	workers := fs.Int("workers", 4, "Number of transfers sent at the same time")
+	readyInterval := fs.Duration("ready-interval", 10*time.Second, "How often the readiness checks served on /readyz run")
+	registerNotifyFlags(fs)
+	registerShutdownFlags(fs)
+	parseFlags(fs, args)
+	if *queueSize < 1 || *workers < 1 {
+		return fmt.Errorf("%w: --queue-depth and --workers must be at least 1", ErrInvalidArgument)
+	}
+	if err := setupNotifications(); err != nil {
+		return err
+	}
+
+	var apiClients []apiClient
+	if *apiKeyFile != "" {
+		raw, err := os.ReadFile(*apiKeyFile)
+		if err != nil {
+			return fmt.Errorf("%w: can't read API key: %v", ErrInvalidArgument, err)
+		}
+		apiKey := strings.TrimSpace(string(raw))
+		if apiKey == "" {
+			return fmt.Errorf("%w: API key file %s is empty", ErrInvalidArgument, *apiKeyFile)
+		}
+		apiClients = append(apiClients, apiClient{name: defaultAPIClient, key: apiKey})
+	}
+	if *apiKeysFile != "" {
+		clients, err := loadAPIKeys(*apiKeysFile)
+		if err != nil {
+			return err
+		}
+		for _, c := range clients {
+			if len(apiClients) > 0 && c.key == apiClients[0].key {
+				return fmt.Errorf("%w: API key %q is the key in --api-key-file", ErrInvalidArgument, c.name)
+			}
+		}
+		apiClients = append(apiClients, clients...)
+	}
+	transfersEnabled := len(apiClients) > 0
+	if *grpcListen != "" && !transfersEnabled {
+		return fmt.Errorf("%w: --grpc-listen needs --api-key-file or --api-keys-file", ErrInvalidArgument)
+	}
+
+	recipient, err := merchantKey(*merchant)
+	if err != nil {
+		return err
+	}
+
+	// Without the transfer API only reads are needed.
+	var clients Clients
+	if transfersEnabled {
+		clients, err = connect()
+		if err != nil {
+			return err
+		}
+		defer clients.Close()
+	} else {
+		clients.Read, err = newReadClient()
+		if err != nil {
+			return err
+		}
+	}
+
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+
+	s := &server{
+		clients:    clients,
+		apiClients: apiClients,
+		mint:       mintAddress,
+		decimals:   mint.Decimals,
+		inFlight:   map[string]bool{},
+		mints:      map[solanago.PublicKey]uint8{},
+		queue:      make(chan transferJob, *queueSize),
+	}
+	s.payments = newPaymentRequests(s, recipient, *payTTL, *payMaxPending)
+	var stopDrain func()
+	s.drain, stopDrain = drainContext(ctx)
+	defer stopDrain()
+
+	if transfersEnabled {
+		s.store, err = OpenStore(dataDir)
+		if err != nil {
+			return fmt.Errorf("can't open store: %w", err)
+		}
+		defer s.store.Close()
+		// Picks up transfers approved with `approve <id>` while the daemon runs, for StreamConfirmations.
+		followCtx, stopFollowing := context.WithCancel(ctx)
+		defer stopFollowing()
+		go s.store.Follow(followCtx, time.Second)
+
+		s.signer, err = loadSigner()
+		if err != nil {
+			return err
+		}
+		s.policy, err = loadPolicy(dataDir)
+		if err != nil {
+			return err
+		}
+		queueCapacity.Set(int64(*queueSize))
+		for range *workers {
+			go s.transferWorker(ctx)
+		}
+	} else {
+		slog.Warn("transfer API disabled, set --api-key-file or --api-keys-file to enable it")
+	}
+
+	schedules, err := loadSchedules(dataDir)
+	if err != nil {
+		return err
+	}
+	if len(schedules) > 0 {
+		if !transfersEnabled {
+			return fmt.Errorf("%w: %d scheduled transfers configured, but transfers are disabled without --api-key-file or --api-keys-file", ErrInvalidArgument, len(schedules))
+		}
+		slog.Info("running scheduled transfers", "schedules", len(schedules))
+		s.runScheduler(ctx, schedules)
+	}
+	s.runReadinessChecks(ctx, *readyInterval)
+
+	// Either server failing shuts the other down.
+	serveCtx, stopServing := context.WithCancel(ctx)
+	defer stopServing()
+	grpcErr := make(chan error, 1)
+	if *grpcListen != "" {
+		lis, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			return fmt.Errorf("can't listen for gRPC: %w", err)
+		}
+		go func() {
+			err := s.serveGRPC(serveCtx, lis)
+			stopServing()
+			grpcErr <- err
+		}()
+	} else {
+		grpcErr <- nil
+	}
+	err = s.listenAndServe(serveCtx, *listen, s.routes(rate.Limit(*payRate), *payBurst))
+	stopServing()
+	if err := errors.Join(err, <-grpcErr); err != nil {
+		return err
+	}
+	return s.finishInFlight()
+}
+
+// finishInFlight waits for the transfers being sent to be confirmed, or for --shutdown-grace to pass, and reports
+// the ones still unconfirmed. Those stay pending in the store; a request retried with the same Idempotency-Key
+// after a restart checks on-chain whether they landed.
+func (s *server) finishInFlight() error {
+	s.mu.Lock()
+	s.closing = true
+	ids := slices.Collect(maps.Keys(s.inFlight))
+	s.mu.Unlock()
+	if len(ids) > 0 {
+		slog.Info("waiting for in-flight transfers", "transfers", len(ids), "grace", shutdownGrace)
+	}
+	s.work.Wait()
+	if s.store == nil {
+		return nil
+	}
+	var unconfirmed int
+	for _, id := range ids {
+		if record, ok := s.store.Transfer(id); ok && record.Status == transferPending {
+			unconfirmed++
+			slog.Warn("transfer unconfirmed at exit", "id", id, "receiver", record.Receiver, "amount", record.Amount, "signature", record.Signature)
+		}
+	}
+	if unconfirmed > 0 {
+		return fmt.Errorf("%w: %d transfers were still unconfirmed at exit", ErrAborted, unconfirmed)
+	}
+	return nil
+}
+
+// merchantKey returns the wallet that receives payment requests: the --merchant flag if set, otherwise the signer.
+func merchantKey(merchant string) (solanago.PublicKey, error) {
+	if merchant != "" {
+		key, err := solanago.PublicKeyFromBase58(merchant)
+		if err != nil {
+			return solanago.PublicKey{}, fmt.Errorf("%w: invalid --merchant: %v", ErrInvalidArgument, err)
+		}
+		return key, nil
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	return signer.PublicKey(), nil
+}
+
+func (s *server) routes(payRate rate.Limit, payBurst int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+
+	if len(s.apiClients) > 0 {
+		mux.Handle("POST /transfers", s.requireAPIKey(http.HandlerFunc(s.handleCreateTransfer)))
+		mux.Handle("GET /transfers/{id}", s.requireAPIKey(http.HandlerFunc(s.handleGetTransfer)))
+	}
+
+	// Public, unauthenticated endpoints are rate limited per client IP.
+	limiter := newIPRateLimiter(payRate, payBurst)
+	mux.Handle("POST /pay", limiter.middleware(http.HandlerFunc(s.payments.handleCreate)))
+	mux.Handle("GET /pay/{id}", limiter.middleware(http.HandlerFunc(s.payments.handleStatus)))
+	return mux
+}
+
+// listenAndServe runs handler on addr until ctx is cancelled, then shuts down gracefully.
+func (s *server) listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ipRateLimiter keeps a token bucket per client IP. Idle buckets are dropped so the map can't grow without bound.
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*ipLimiter
+}
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newIPRateLimiter(limit rate.Limit, burst int) *ipRateLimiter {
+	l := &ipRateLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: map[string]*ipLimiter{},
+	}
+	go l.cleanup(time.Minute)
+	return l
+}
+
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter.Allow()
+}
+
+func (l *ipRateLimiter) cleanup(idle time.Duration) {
+	for range time.Tick(idle) {
+		l.mu.Lock()
+		for ip, entry := range l.limiters {
+			if time.Since(entry.lastSeen) > idle {
+				delete(l.limiters, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !l.allow(ip) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("can't write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
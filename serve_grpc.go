@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	tokentransferv1 "github.com/csknk/token-transfer/gen/tokentransfer/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcTransferService serves the transfer API over gRPC, as defined in proto/tokentransfer/v1/transfer.proto. It
+// shares the HTTP API's keys, policies, queue and store.
+type grpcTransferService struct {
+	tokentransferv1.UnimplementedTransferServiceServer
+	s *server
+	// done is closed when the server shuts down, ending the confirmation streams.
+	done <-chan struct{}
+}
+
+func (g grpcTransferService) SubmitTransfer(ctx context.Context, req *tokentransferv1.SubmitTransferRequest) (*tokentransferv1.SubmitTransferResponse, error) {
+	body := createTransferRequest{Receiver: req.GetReceiver(), Amount: req.GetAmount(), Mint: req.GetMint()}
+	record, _, err := g.s.submitTransfer(ctx, contextClient(ctx), body, req.GetIdempotencyKey())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &tokentransferv1.SubmitTransferResponse{Transfer: transferProto(record)}, nil
+}
+
+func (g grpcTransferService) GetTransferStatus(ctx context.Context, req *tokentransferv1.GetTransferStatusRequest) (*tokentransferv1.Transfer, error) {
+	record, ok := g.s.store.Transfer(req.GetId())
+	if !ok || !contextClient(ctx).sees(record) {
+		return nil, status.Error(codes.NotFound, "transfer not found")
+	}
+	return transferProto(record), nil
+}
+
+func (g grpcTransferService) StreamConfirmations(req *tokentransferv1.StreamConfirmationsRequest, stream grpc.ServerStreamingServer[tokentransferv1.TransferEvent]) error {
+	client := contextClient(stream.Context())
+	// Watched before the current states are read, so no change in between is missed.
+	changes, stop := g.s.store.Watch()
+	defer stop()
+
+	ids := map[string]bool{}
+	for _, id := range req.GetIds() {
+		if ids[id] {
+			continue
+		}
+		ids[id] = true
+		if record, ok := g.s.store.Transfer(id); ok && client.sees(record) {
+			if err := stream.Send(&tokentransferv1.TransferEvent{Transfer: transferProto(record)}); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-g.done:
+			return status.Error(codes.Unavailable, "server shutting down")
+		case record, ok := <-changes:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "stream fell behind, resubscribe")
+			}
+			if !client.sees(record) || len(ids) > 0 && !ids[record.ID] {
+				continue
+			}
+			if err := stream.Send(&tokentransferv1.TransferEvent{Transfer: transferProto(record)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// transferStatuses maps the store's transfer states to the API's.
+var transferStatuses = map[string]tokentransferv1.TransferStatus{
+	transferPending:          tokentransferv1.TransferStatus_TRANSFER_STATUS_PENDING,
+	transferConfirmed:        tokentransferv1.TransferStatus_TRANSFER_STATUS_CONFIRMED,
+	transferFailed:           tokentransferv1.TransferStatus_TRANSFER_STATUS_FAILED,
+	transferAwaitingApproval: tokentransferv1.TransferStatus_TRANSFER_STATUS_AWAITING_APPROVAL,
+}
+
+func transferProto(record transferRecord) *tokentransferv1.Transfer {
+	return &tokentransferv1.Transfer{
+		Id:        record.ID,
+		Receiver:  record.Receiver,
+		Amount:    record.Amount,
+		Status:    transferStatuses[record.Status],
+		Signature: record.Signature,
+		Error:     record.Error,
+		CreatedAt: timestamppb.New(record.CreatedAt),
+		UpdatedAt: timestamppb.New(record.UpdatedAt),
+		Mint:      record.Mint,
+	}
+}
+
+// grpcCodes maps the HTTP statuses of apiError to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusUnprocessableEntity: codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusBadGateway:          codes.Unavailable,
+}
+
+// grpcError converts an error of submitTransfer to a gRPC status error.
+func grpcError(err error) error {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	code, ok := grpcCodes[apiErr.status]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, apiErr.message)
+}
+
+// grpcAuthenticate identifies the client by the API key in the request metadata, sent like over HTTP as a bearer
+// token in authorization or in x-api-key, and returns ctx carrying it for contextClient.
+func (s *server) grpcAuthenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var key string
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		key = values[0]
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		if bearer, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+			key = bearer
+		}
+	}
+	client, ok := s.authenticate(key)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return context.WithValue(ctx, apiClientContextKey{}, client), nil
+}
+
+// authenticatedStream is a server stream whose context carries the authenticated client.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a authenticatedStream) Context() context.Context { return a.ctx }
+
+// serveGRPC serves the gRPC transfer API on lis until ctx is cancelled, then shuts down gracefully.
+func (s *server) serveGRPC(ctx context.Context, lis net.Listener) error {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := s.grpcAuthenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.grpcAuthenticate(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, authenticatedStream{stream, ctx})
+		}),
+	)
+	tokentransferv1.RegisterTransferServiceServer(srv, grpcTransferService{s: s, done: ctx.Done()})
+
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("listening for gRPC", "addr", lis.Addr())
+		errc <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		srv.Stop()
+	}
+	return <-errc
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultAPIClient names the caller using the key in --api-key-file, which no policy of its own restricts.
+const defaultAPIClient = "default"
+
+// apiKeyConfig is an API key as configured in --api-keys-file: the services using it are identified by its name in
+// logs and transfer records, and restricted by its policy.
+type apiKeyConfig struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Policy restricts the key's transfers, on top of policy.json: the recipients allowed and, per token, the
+	// limits. Tokens other than the daemon's --token can only be sent with a key whose policy lists them.
+	Policy policyConfig `json:"policy"`
+}
+
+// apiClient is a caller of the transfer API, identified by its API key.
+type apiClient struct {
+	name   string
+	key    string
+	policy *Policy // nil for the --api-key-file key
+}
+
+// loadAPIKeys reads the API keys and their policies from path.
+func loadAPIKeys(path string) ([]apiClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't read API keys: %v", ErrInvalidArgument, err)
+	}
+	var configs []apiKeyConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	var clients []apiClient
+	names, keys := map[string]bool{}, map[string]bool{}
+	for i, c := range configs {
+		switch {
+		case c.Name == "" || c.Key == "":
+			return nil, fmt.Errorf("%w: %s: API key %d needs a name and a key", ErrInvalidArgument, path, i+1)
+		case c.Name == defaultAPIClient:
+			return nil, fmt.Errorf("%w: %s: the name %q is reserved for --api-key-file", ErrInvalidArgument, path, c.Name)
+		case names[c.Name]:
+			return nil, fmt.Errorf("%w: %s: duplicate API key name %q", ErrInvalidArgument, path, c.Name)
+		case keys[c.Key]:
+			return nil, fmt.Errorf("%w: %s: API key %q reuses another key", ErrInvalidArgument, path, c.Name)
+		}
+		names[c.Name], keys[c.Key] = true, true
+		policy, err := newPolicy(c.Policy, path)
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %w", c.Name, err)
+		}
+		policy.client = c.Name
+		clients = append(clients, apiClient{name: c.Name, key: c.Key, policy: policy})
+	}
+	return clients, nil
+}
+
+// apiClientContextKey is the request context key of the apiClient that sent the request.
+type apiClientContextKey struct{}
+
+// sees reports whether the client may see record: a key with a policy of its own only sees its own transfers.
+func (c apiClient) sees(record transferRecord) bool {
+	return c.policy == nil || record.Client == c.name
+}
+
+// requestClient returns the API client requireAPIKey identified r as.
+func requestClient(r *http.Request) apiClient {
+	return contextClient(r.Context())
+}
+
+// contextClient returns the API client stored in ctx by requireAPIKey or the gRPC API's interceptors.
+func contextClient(ctx context.Context) apiClient {
+	client, _ := ctx.Value(apiClientContextKey{}).(apiClient)
+	return client
+}
+
+// authenticate returns the client whose API key is key.
+func (s *server) authenticate(key string) (apiClient, bool) {
+	var client apiClient
+	found := false
+	// Every key is compared, so the time taken doesn't tell which one nearly matched.
+	for _, c := range s.apiClients {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(c.key)) == 1 {
+			client, found = c, true
+		}
+	}
+	return client, key != "" && found
+}
+
+// requireAPIKey rejects requests that don't carry one of the configured API keys, either as a bearer token or in
+// the X-API-Key header, and makes the key's client available to next through requestClient.
+func (s *server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+		client, ok := s.authenticate(key)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiClientContextKey{}, client)))
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+// readinessCheckTimeout bounds each readiness check, so one hanging endpoint can't hold up the others.
+const readinessCheckTimeout = 5 * time.Second
+
+// readiness holds the results of the last readiness checks. The checks run in the background every
+// --ready-interval, so probes are answered at once and the endpoints aren't hit once per probe.
+type readiness struct {
+	mu      sync.Mutex
+	checked time.Time
+	checks  map[string]string // check name -> "ok" or what failed
+	ready   bool
+}
+
+// runReadinessChecks checks s's dependencies now and then every interval until ctx is cancelled.
+func (s *server) runReadinessChecks(ctx context.Context, interval time.Duration) {
+	s.checkReadiness(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkReadiness(ctx)
+			}
+		}
+	}()
+}
+
+// checkReadiness checks, concurrently, that the RPC endpoints answer, that the websocket endpoint accepts
+// connections, and, with the transfer API enabled, that the signer is loaded and the store is open.
+func (s *server) checkReadiness(ctx context.Context) {
+	checks := map[string]func(context.Context) error{
+		"rpc": func(ctx context.Context) error {
+			_, err := s.clients.Read.GetSlot(ctx, rpc.CommitmentConfirmed)
+			return err
+		},
+		"websocket": func(ctx context.Context) error {
+			endpoint, err := wsEndpoint()
+			if err != nil {
+				return err
+			}
+			conn, err := ws.Connect(ctx, endpoint)
+			if err != nil {
+				return err
+			}
+			conn.Close()
+			return nil
+		},
+	}
+	if s.clients.Write != nil && s.clients.Write != s.clients.Read {
+		checks["rpc-write"] = func(ctx context.Context) error {
+			_, err := s.clients.Write.GetSlot(ctx, rpc.CommitmentConfirmed)
+			return err
+		}
+	}
+	if len(s.apiClients) > 0 {
+		checks["signer"] = func(context.Context) error {
+			if s.signer == nil {
+				return errors.New("not loaded")
+			}
+			return nil
+		}
+		checks["store"] = func(context.Context) error {
+			if s.store == nil {
+				return errors.New("not open")
+			}
+			return s.store.Ping()
+		}
+	}
+
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		ready = ready && result == "ok"
+	}
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+	if ready != s.ready.ready || s.ready.checked.IsZero() {
+		if ready {
+			slog.Info("ready")
+		} else {
+			slog.Warn("not ready", "checks", fmt.Sprint(results))
+		}
+	}
+	s.ready.checked, s.ready.checks, s.ready.ready = time.Now(), results, ready
+}
+
+// handleReadyz serves GET /readyz: 200 if the last readiness checks all passed, 503 otherwise, with each check's
+// result.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+	status, code := "ready", http.StatusOK
+	if !s.ready.ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": s.ready.checks, "checked": s.ready.checked.UTC()})
+}
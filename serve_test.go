@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTransferWorkerSkipsQueuedTransfersAtShutdown(t *testing.T) {
+	s := &server{inFlight: map[string]bool{}, queue: make(chan transferJob, 2)}
+	s.mu.Lock()
+	s.startTransfer("a", testKey(1).PublicKey(), testKey(2).PublicKey(), 1)
+	s.startTransfer("b", testKey(1).PublicKey(), testKey(2).PublicKey(), 1)
+	s.closing = true
+	s.mu.Unlock()
+	close(s.queue)
+
+	// Neither transfer is sent: the server has no store or RPC clients to send them with.
+	s.transferWorker(context.Background())
+	s.work.Wait()
+	if len(s.inFlight) != 0 {
+		t.Errorf("transfers %v still in flight", s.inFlight)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// queueRetryAfter is the Retry-After sent with 429 responses when the transfer queue is full.
+const queueRetryAfter = 5 * time.Second
+
+type createTransferRequest struct {
+	Receiver string `json:"receiver"`
+	Amount   uint64 `json:"amount"`
+	// Mint is a registry symbol or mint address; the daemon's --token if empty.
+	Mint string `json:"mint,omitempty"`
+}
+
+// apiError is a transfer request the API turns down, with the HTTP status it's answered with. The gRPC API maps the
+// status to a code.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+// errQueueFull turns a transfer request away while the queue is full; the client should retry it later.
+var errQueueFull = &apiError{http.StatusTooManyRequests, "transfer queue full"}
+
+// handleCreateTransfer serves POST /transfers. The transfer is sent in the background; clients poll
+// GET /transfers/{id} for the outcome. Requests carrying an Idempotency-Key header that was seen before return the
+// existing transfer instead of sending again.
+func (s *server) handleCreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var body createTransferRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	record, existed, err := s.submitTransfer(r.Context(), requestClient(r), body, r.Header.Get("Idempotency-Key"))
+	if err != nil {
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) {
+			apiErr = &apiError{http.StatusInternalServerError, err.Error()}
+		}
+		if apiErr == errQueueFull {
+			w.Header().Set("Retry-After", strconv.Itoa(int(queueRetryAfter.Seconds())))
+		}
+		writeError(w, apiErr.status, apiErr.message)
+		return
+	}
+	if existed {
+		writeJSON(w, http.StatusOK, record)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, record)
+}
+
+// submitTransfer records the transfer client requested and queues it for sending, unless it needs approval first.
+// A request with an idempotency key that was seen before returns the existing transfer, and existed, instead. The
+// transfer is checked against policy.json and against the policy of the client's API key. Errors the client
+// caused are *apiError.
+func (s *server) submitTransfer(ctx context.Context, client apiClient, req createTransferRequest, key string) (record transferRecord, existed bool, err error) {
+	receiverKey, err := solanago.PublicKeyFromBase58(req.Receiver)
+	if err != nil {
+		return transferRecord{}, false, &apiError{http.StatusBadRequest, "invalid receiver"}
+	}
+	mint, decimals := s.mint, s.decimals
+	if req.Mint != "" {
+		if mint, err = lookupToken(req.Mint); err != nil {
+			return transferRecord{}, false, &apiError{http.StatusBadRequest, "unknown mint"}
+		}
+		if !mint.Equals(s.mint) && !client.policy.allowsToken(mint) {
+			return transferRecord{}, false, &apiError{http.StatusForbidden, "mint not allowed for this API key"}
+		}
+		if decimals, err = s.mintDecimals(ctx, mint); err != nil {
+			slog.Error("can't get mint", "mint", mint, "error", err)
+			return transferRecord{}, false, &apiError{http.StatusBadGateway, "can't get mint"}
+		}
+	}
+	rawAmount, err := scaleAmount(req.Amount, decimals)
+	if err != nil || rawAmount == 0 {
+		return transferRecord{}, false, &apiError{http.StatusBadRequest, "amount must be positive and in range"}
+	}
+	amount := formatUIAmount(rawAmount, decimals)
+
+	// Lookup, creation and the in-flight check must be atomic so concurrent retries can't both send.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Idempotency keys are scoped to the client, so another client's transfer under the same key isn't found, and
+	// neither is one recorded by the CLI.
+	if existing, ok := s.store.ClientTransferByIdempotencyKey(client.name, key); ok && key != "" {
+		if existing.Receiver != receiverKey.String() || existing.Mint != mint.String() || existing.Amount != amount {
+			return transferRecord{}, false, &apiError{http.StatusUnprocessableEntity, "idempotency key was used for a different transfer"}
+		}
+		if existing.Status == transferPending && !s.inFlight[existing.ID] {
+			if s.queueFull() {
+				return transferRecord{}, false, errQueueFull
+			}
+			// Left over from before a restart: find out whether it landed, and resend if it never will. That takes
+			// RPC calls, made without the lock; marking it in flight first keeps concurrent retries from resuming it
+			// too.
+			s.inFlight[existing.ID] = true
+			s.mu.Unlock()
+			done, err := resumeTransfer(ctx, s.clients.Read, s.store, existing)
+			s.mu.Lock()
+			switch {
+			case !done && !s.queueFull():
+				s.startTransfer(existing.ID, receiverKey, mint, rawAmount)
+			case !done:
+				delete(s.inFlight, existing.ID)
+				return transferRecord{}, false, errQueueFull
+			default:
+				delete(s.inFlight, existing.ID)
+				if err != nil {
+					slog.Warn("can't resume transfer", "id", existing.ID, "error", err)
+				}
+			}
+			existing, _ = s.store.Transfer(existing.ID)
+		}
+		return existing, true, nil
+	}
+
+	needsApproval := false
+	for _, policy := range []*Policy{s.policy, client.policy} {
+		if err := policy.Check(s.store, mint, decimals, receiverKey, rawAmount, 0); err != nil {
+			slog.Warn("transfer rejected by policy", "client", client.name, "receiver", receiverKey, "amount", amount, "mint", mint, "error", err)
+			return transferRecord{}, false, &apiError{http.StatusForbidden, err.Error()}
+		}
+		needs, err := policy.NeedsApproval(mint, decimals, rawAmount)
+		if err != nil {
+			slog.Error("can't check approval threshold", "client", client.name, "error", err)
+			return transferRecord{}, false, &apiError{http.StatusInternalServerError, "invalid policy"}
+		}
+		needsApproval = needsApproval || needs
+	}
+	// Checked before the transfer is recorded, so a turned-away request leaves nothing behind to retry against.
+	if !needsApproval && s.queueFull() {
+		return transferRecord{}, false, errQueueFull
+	}
+
+	record = newTransferRecord(key, s.signer.PublicKey(), receiverKey, mint, amount)
+	record.Client = client.name
+	if needsApproval {
+		record.Status = transferAwaitingApproval
+	}
+	if err := s.store.PutTransfer(record); err != nil {
+		slog.Error("can't store transfer", "error", err)
+		return transferRecord{}, false, &apiError{http.StatusInternalServerError, "can't store transfer"}
+	}
+	transfersSubmitted.Inc()
+	slog.Info("transfer accepted", "id", record.ID, "client", record.Client, "receiver", record.Receiver, "amount", record.Amount, "status", record.Status)
+	// Otherwise sent once an operator runs `token-transfer approve <id>`.
+	if !needsApproval {
+		s.startTransfer(record.ID, receiverKey, mint, rawAmount)
+	}
+	return record, false, nil
+}
+
+// transferJob is a stored transfer waiting in the queue to be sent.
+type transferJob struct {
+	id       string
+	receiver solanago.PublicKey
+	mint     solanago.PublicKey
+	amount   uint64
+}
+
+// queueFull reports whether the transfer queue is full, counting the request turned away if it is. The caller must
+// hold s.mu, and keep holding it until the transfer is queued.
+func (s *server) queueFull() bool {
+	if len(s.queue) < cap(s.queue) {
+		return false
+	}
+	transfersRejected.Inc()
+	slog.Warn("transfer queue full, request turned away", "depth", len(s.queue))
+	return true
+}
+
+// startTransfer queues the stored transfer id for a worker to send. The caller must hold s.mu and have checked
+// with queueFull that there's room.
+func (s *server) startTransfer(id string, receiver, mint solanago.PublicKey, amount uint64) {
+	s.inFlight[id] = true
+	s.work.Add(1)
+	queueDepth.Add(1)
+	s.queue <- transferJob{id: id, receiver: receiver, mint: mint, amount: amount}
+}
+
+// transferWorker sends queued transfers one at a time, forever. Once ctx is done or the server is closing, transfers
+// still queued aren't started: they stay pending in the store, to be resumed by a retried request after a restart,
+// and only those already being sent are drained.
+func (s *server) transferWorker(ctx context.Context) {
+	for t := range s.queue {
+		queueDepth.Add(-1)
+		s.mu.Lock()
+		stopped := s.closing || ctx.Err() != nil
+		s.mu.Unlock()
+		if stopped {
+			slog.Warn("transfer not started before shutdown, left pending", "id", t.id)
+		} else {
+			s.executeTransfer(t.id, t.receiver, t.mint, t.amount)
+		}
+		s.mu.Lock()
+		delete(s.inFlight, t.id)
+		s.mu.Unlock()
+		s.work.Done()
+	}
+}
+
+// executeTransfer sends amount raw base units of mint to receiver, journaling the transfer under id.
+func (s *server) executeTransfer(id string, receiver, mint solanago.PublicKey, amount uint64) {
+	ctx, cancel := context.WithTimeout(s.drain, 2*time.Minute)
+	defer cancel()
+
+	if _, err := sendRecorded(ctx, s.store, s.clients, s.signer, id, receiver, amount, TransferOptions{Mint: mint}); err != nil {
+		slog.Error("transfer failed", "id", id, "error", err)
+	}
+}
+
+// handleGetTransfer serves GET /transfers/{id}.
+func (s *server) handleGetTransfer(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.store.Transfer(r.PathValue("id"))
+	if !ok || !requestClient(r).sees(record) {
+		writeError(w, http.StatusNotFound, "transfer not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// mintDecimals returns the decimals of mint, fetching them the first time.
+func (s *server) mintDecimals(ctx context.Context, mint solanago.PublicKey) (uint8, error) {
+	s.mintsMu.Lock()
+	defer s.mintsMu.Unlock()
+	if decimals, ok := s.mints[mint]; ok {
+		return decimals, nil
+	}
+	m, err := GetMint(ctx, s.clients.Read, mint, rpc.CommitmentFinalized)
+	if err != nil {
+		return 0, err
+	}
+	s.mints[mint] = m.Decimals
+	return m.Decimals, nil
+}
+
+// handleHealthz serves GET /healthz.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
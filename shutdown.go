@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"time"
+)
+
+// shutdownGrace is how long transactions already sent may keep confirming after an interrupt.
+var shutdownGrace time.Duration
+
+// registerShutdownFlags adds the flags of commands that drain in-flight transfers on shutdown to fs.
+func registerShutdownFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&shutdownGrace, "shutdown-grace", 30*time.Second, "On Ctrl-C or SIGTERM, how long to wait for transactions already sent to confirm before exiting")
+}
+
+// drainContext returns a context for finishing work started under ctx. It isn't cancelled with ctx but
+// shutdownGrace later, so transactions in flight when the operation is interrupted can still be confirmed. Call
+// stop to release it.
+func drainContext(ctx context.Context) (drain context.Context, stop func()) {
+	drain, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stopAfter := context.AfterFunc(ctx, func() {
+		slog.Warn("shutting down: no new transfers are started, waiting for in-flight ones to confirm", "grace", shutdownGrace)
+		time.AfterFunc(shutdownGrace, func() { cancel(context.Cause(ctx)) })
+	})
+	return drain, func() {
+		stopAfter()
+		cancel(context.Canceled)
+	}
+}
+
+// interrupted reports whether err comes from the operation being cancelled or timing out rather than from the
+// transfer itself failing. A transfer interrupted after it was broadcast may still land.
+func interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// signerURI selects where the sender's key is loaded from; see loadSignerFrom.
+var signerURI string
+
+// registerSignerFlags adds the flags selecting the signer to fs.
+func registerSignerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&signerURI, "signer", "", "Where to load the sender's key from: a keypair file, mnemonic://, env://VAR, keychain://SERVICE/ACCOUNT, dpapi://PATH, aws-sm://SECRET, gcp-sm://PROJECT/SECRET[/VERSION], vault://PATH[#FIELD], remote+https://SIGNING_SERVICE or remote+grpc://HOST:PORT; defaults to the built-in keypair path")
+	fs.StringVar(&walletName, "from", "", "Name of the wallet in wallets.json that signs and pays; instead of --signer")
+	fs.StringVar(&derivationPath, "derivation-path", defaultDerivationPath, "BIP44 path of the key derived from a mnemonic phrase")
+}
+
+// feePayerURI selects the key paying fees and rent instead of the sender, in any form signerURI accepts.
+var feePayerURI string
+
+// loadFeePayer loads the --fee-payer key, or returns nil if the sender pays.
+func loadFeePayer() (Signer, error) {
+	if feePayerURI == "" {
+		return nil, nil
+	}
+	payer, err := loadSignerFrom(feePayerURI)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't load fee payer key: %v", ErrSignerUnavailable, err)
+	}
+	slog.Debug("loaded fee payer", "pubkey", payer.PublicKey())
+	return payer, nil
+}
+
+// secretTimeout bounds each lookup against a keychain or secret manager.
+const secretTimeout = 30 * time.Second
+
+// loadSignerFrom loads a private key from uri. A URI without a scheme, or with file://, is a keypair file. The
+// other schemes fetch the key from a secret store, where it may be stored in any format a keypair file accepts
+// (including a mnemonic phrase):
+//
+//   - mnemonic://: a mnemonic phrase typed on the terminal
+//   - env://VAR: the environment variable VAR
+//   - keychain://SERVICE/ACCOUNT: the macOS Keychain (security) or the Linux Secret Service (secret-tool)
+//   - dpapi://PATH: a file encrypted with the Windows Data Protection API for the current user
+//   - aws-sm://SECRET: AWS Secrets Manager, through the aws CLI and its usual credentials and region
+//   - gcp-sm://PROJECT/SECRET[/VERSION]: GCP Secret Manager, through gcloud; VERSION defaults to latest
+//   - vault://PATH[#FIELD]: a HashiCorp Vault KV secret (v1 or v2) read over VAULT_ADDR with VAULT_TOKEN; FIELD
+//     defaults to "key"
+//
+// remote+https://HOST[/PATH] and remote+grpc://HOST:PORT keep the key out of this process entirely: transactions
+// are signed by the remote signing service at that address (see RemoteSigner).
+func loadSignerFrom(uri string) (Signer, error) {
+	scheme, ref, ok := strings.Cut(uri, "://")
+	if !ok {
+		return loadKeypair(uri)
+	}
+	if service, ok := strings.CutPrefix(scheme, "remote+"); ok {
+		var remote RemoteSigner
+		var err error
+		if service == "grpc" {
+			remote, err = newGRPCRemoteSigner(ref)
+		} else {
+			remote, err = newHTTPRemoteSigner(service + "://" + ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newRemoteKey(remote)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+
+	var data []byte
+	var err error
+	switch scheme {
+	case "file":
+		return loadKeypair(ref)
+	case "mnemonic":
+		data, err = promptMnemonic()
+	case "env":
+		data = []byte(os.Getenv(ref))
+		if len(data) == 0 {
+			err = fmt.Errorf("%s isn't set", ref)
+		}
+	case "keychain":
+		data, err = keychainSecret(ctx, ref)
+	case "dpapi":
+		data, err = dpapiSecret(ref)
+	case "aws-sm":
+		data, err = commandSecret(ctx, "aws", "secretsmanager", "get-secret-value", "--secret-id", ref, "--query", "SecretString", "--output", "text")
+	case "gcp-sm":
+		data, err = gcpSecret(ctx, ref)
+	case "vault":
+		data, err = vaultSecret(ctx, ref)
+	default:
+		return nil, fmt.Errorf("unknown signer scheme %q", scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read %s: %v", uri, err)
+	}
+	return parseKeypair(data, uri)
+}
+
+// promptMnemonic reads a mnemonic phrase from the terminal without echo.
+func promptMnemonic() ([]byte, error) {
+	if !stdinIsTerminal() {
+		return nil, errors.New("no terminal to prompt for the mnemonic phrase")
+	}
+	return promptPassword("Mnemonic phrase: ")
+}
+
+// keychainSecret looks up the password stored for service and account in the operating system's keychain.
+func keychainSecret(ctx context.Context, ref string) ([]byte, error) {
+	service, account, ok := strings.Cut(ref, "/")
+	if !ok || service == "" || account == "" {
+		return nil, errors.New("keychain signer must be keychain://SERVICE/ACCOUNT")
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return commandSecret(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return nil, errors.New("the keychain scheme isn't supported on Windows, use dpapi://")
+	default:
+		return commandSecret(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	}
+}
+
+// gcpSecret reads PROJECT/SECRET[/VERSION] from GCP Secret Manager.
+func gcpSecret(ctx context.Context, ref string) ([]byte, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("gcp-sm signer must be gcp-sm://PROJECT/SECRET[/VERSION]")
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	return commandSecret(ctx, "gcloud", "secrets", "versions", "access", version, "--secret", parts[1], "--project", parts[0])
+}
+
+// commandSecret runs a secret store's CLI and returns what it prints. The CLIs bring their own authentication
+// (profiles, SSO, instance credentials), which is why they're used instead of talking to the APIs directly.
+func commandSecret(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %v: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return out, nil
+}
+
+// vaultSecret reads a field of a Vault KV secret. ref is the API path below /v1/, which for KV v2 includes the
+// data/ segment, e.g. secret/data/payouts#key.
+func vaultSecret(ctx context.Context, ref string) ([]byte, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	if field == "" {
+		field = "key"
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR isn't set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.New("VAULT_TOKEN isn't set")
+		}
+		data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+		if err != nil {
+			return nil, errors.New("VAULT_TOKEN isn't set and there's no ~/.vault-token")
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	u, err := url.JoinPath(addr, "v1", path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid vault response: %v", err)
+	}
+	fields := body.Data
+	// KV v2 nests the secret's fields under data.data.
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, fmt.Errorf("invalid vault response: %v", err)
+		}
+	}
+	raw, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// Not a string: a keypair stored as a JSON array.
+		return raw, nil
+	}
+	return []byte(value), nil
+}
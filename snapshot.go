@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// tokenAccountOwnerOffset is where a token account's owner follows its mint; the amount comes after the owner.
+	tokenAccountOwnerOffset = 32
+	// token2022AccountTypeOffset is the byte after the base token account that tells Token-2022 accounts from
+	// mints, whose extensions start at the same offset.
+	token2022AccountTypeOffset = tokenAccountSize
+	token2022AccountType       = 2
+	// snapshotPages is how many getProgramAccounts requests --paginate splits a snapshot into, one for each
+	// value of the first byte of the owner.
+	snapshotPages = 256
+	// maxLargestAccounts is how many accounts getTokenLargestAccounts returns at most.
+	maxLargestAccounts = 20
+)
+
+func init() {
+	commands["snapshot"] = runSnapshot
+}
+
+// tokenHolder is an owner's total balance across its token accounts for a mint.
+type tokenHolder struct {
+	Owner  solanago.PublicKey
+	Amount uint64 // raw base units
+}
+
+func runSnapshot(ctx context.Context, args []string) error {
+	fs := newFlagSet("snapshot")
+	mintFlag := fs.String("mint", "", "Mint whose holders to snapshot: a registry symbol or address (required)")
+	minBalance := fs.String("min-balance", "", "Leave out holders with less than this decimal token amount")
+	excludeFile := fs.String("exclude", "", "File of addresses to leave out, one per line; owners or their token accounts")
+	top := fs.Int("top", 0, "Only snapshot the holders of the largest token accounts, up to 20, with getTokenLargestAccounts")
+	paginate := fs.Bool("paginate", false, fmt.Sprintf("Split the getProgramAccounts scan into %d requests, for RPC providers that limit response sizes", snapshotPages))
+	out := fs.String("out", "", "Write the snapshot to this file instead of stdout")
+	parseFlags(fs, args)
+
+	if *mintFlag == "" {
+		return fmt.Errorf("%w: --mint flag is required", ErrInvalidArgument)
+	}
+	if *top < 0 || *top > maxLargestAccounts {
+		return fmt.Errorf("%w: --top must be at most %d", ErrInvalidArgument, maxLargestAccounts)
+	}
+	mintAddress, err := lookupToken(*mintFlag)
+	if err != nil {
+		return err
+	}
+	exclude := map[solanago.PublicKey]bool{}
+	if *excludeFile != "" {
+		if exclude, err = readAddressList(*excludeFile); err != nil {
+			return err
+		}
+	}
+
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+	if err != nil {
+		return err
+	}
+	var threshold uint64
+	if *minBalance != "" {
+		if threshold, err = parseUIAmount(*minBalance, tm.Decimals); err != nil {
+			return fmt.Errorf("invalid --min-balance: %w", err)
+		}
+	}
+
+	var accounts map[solanago.PublicKey]tokenHolder
+	switch {
+	case *top > 0:
+		accounts, err = largestTokenAccounts(ctx, clients.Read, tm, *top)
+	case useDAS():
+		accounts, err = dasTokenHolders(ctx, tm)
+	default:
+		accounts, err = scanTokenAccounts(ctx, clients.Read, tm, *paginate)
+	}
+	if err != nil {
+		return err
+	}
+	holders := aggregateHolders(accounts, exclude, threshold)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("can't create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	var total uint64
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"receiver", "weight"})
+	for _, h := range holders {
+		cw.Write([]string{h.Owner.String(), formatUIAmount(h.Amount, tm.Decimals)})
+		total += h.Amount
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("can't write snapshot: %v", err)
+	}
+	slog.Info("snapshot taken", "mint", mintAddress, "accounts", len(accounts), "holders", len(holders), "total", formatUIAmount(total, tm.Decimals))
+	return nil
+}
+
+// scanTokenAccounts returns the owner and balance of every token account of the mint, keyed by account address.
+// Only the owner and amount of each account are fetched. With paginate the scan is split into snapshotPages
+// requests by the first byte of the owner.
+func scanTokenAccounts(ctx context.Context, client RPCClient, tm transferMint, paginate bool) (map[solanago.PublicKey]tokenHolder, error) {
+	filters := []rpc.RPCFilter{{Memcmp: &rpc.RPCFilterMemcmp{Offset: 0, Bytes: tm.Address.Bytes()}}}
+	if tm.isToken2022() {
+		// Token-2022 accounts with extensions are larger than the base layout.
+		filters = append(filters, rpc.RPCFilter{Memcmp: &rpc.RPCFilterMemcmp{Offset: token2022AccountTypeOffset, Bytes: []byte{token2022AccountType}}})
+	} else {
+		filters = append(filters, rpc.RPCFilter{DataSize: tokenAccountSize})
+	}
+	pages := [][]rpc.RPCFilter{filters}
+	if paginate {
+		pages = pages[:0]
+		for b := range snapshotPages {
+			page := append(slices.Clip(filters), rpc.RPCFilter{Memcmp: &rpc.RPCFilterMemcmp{Offset: tokenAccountOwnerOffset, Bytes: []byte{byte(b)}}})
+			pages = append(pages, page)
+		}
+	}
+
+	accounts := map[solanago.PublicKey]tokenHolder{}
+	for i, page := range pages {
+		res, err := client.GetProgramAccountsWithOpts(ctx, tm.Program, &rpc.GetProgramAccountsOpts{
+			Commitment: rpc.CommitmentConfirmed,
+			Encoding:   solanago.EncodingBase64,
+			DataSlice:  &rpc.DataSlice{Offset: ptr(uint64(tokenAccountOwnerOffset)), Length: ptr(uint64(40))},
+			Filters:    page,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't get token accounts of %s: %w", tm.Address, classifyRPCError(err))
+		}
+		for _, account := range res {
+			data := account.Account.Data.GetBinary()
+			if len(data) < 40 {
+				continue
+			}
+			accounts[account.Pubkey] = tokenHolder{Owner: solanago.PublicKeyFromBytes(data[:32]), Amount: binary.LittleEndian.Uint64(data[32:40])}
+		}
+		if paginate && (i+1)%32 == 0 {
+			slog.Info("scanning token accounts", "pages", i+1, "of", len(pages), "accounts", len(accounts))
+		}
+	}
+	return accounts, nil
+}
+
+// dasTokenHolders returns the owner and balance of every token account of the mint, keyed by account address, as
+// listed by the DAS provider's getTokenAccounts. Providers serve this from an index, so it's far faster than a
+// getProgramAccounts scan for mints with many holders.
+func dasTokenHolders(ctx context.Context, tm transferMint) (map[solanago.PublicKey]tokenHolder, error) {
+	das, err := newDASClient()
+	if err != nil {
+		return nil, err
+	}
+	listed, err := das.TokenAccounts(ctx, map[string]any{"mint": tm.Address.String()})
+	if err != nil {
+		return nil, fmt.Errorf("can't get token accounts of %s: %w", tm.Address, err)
+	}
+	accounts := map[solanago.PublicKey]tokenHolder{}
+	for _, ta := range listed {
+		address, err := solanago.PublicKeyFromBase58(ta.Address)
+		if err != nil {
+			return nil, fmt.Errorf("das listed an invalid token account %q: %v", ta.Address, err)
+		}
+		owner, err := solanago.PublicKeyFromBase58(ta.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("das listed token account %s with an invalid owner %q: %v", ta.Address, ta.Owner, err)
+		}
+		accounts[address] = tokenHolder{Owner: owner, Amount: ta.Amount}
+	}
+	return accounts, nil
+}
+
+// largestTokenAccounts returns the owner and balance of the top (at most maxLargestAccounts) token accounts of the
+// mint, keyed by account address.
+func largestTokenAccounts(ctx context.Context, client RPCClient, tm transferMint, top int) (map[solanago.PublicKey]tokenHolder, error) {
+	res, err := client.GetTokenLargestAccounts(ctx, tm.Address, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("can't get largest token accounts of %s: %w", tm.Address, classifyRPCError(err))
+	}
+	largest := res.Value[:min(top, len(res.Value))]
+	addresses := make([]solanago.PublicKey, len(largest))
+	for i, account := range largest {
+		addresses[i] = account.Address
+	}
+	// getTokenLargestAccounts doesn't say who owns the accounts.
+	infos, err := client.GetMultipleAccounts(ctx, addresses...)
+	if err != nil {
+		return nil, fmt.Errorf("can't get token accounts: %w", classifyRPCError(err))
+	}
+	accounts := map[solanago.PublicKey]tokenHolder{}
+	for i, info := range infos.Value {
+		if info == nil || len(info.Data.GetBinary()) < tokenAccountOwnerOffset+40 {
+			continue
+		}
+		data := info.Data.GetBinary()[tokenAccountOwnerOffset:]
+		accounts[addresses[i]] = tokenHolder{Owner: solanago.PublicKeyFromBytes(data[:32]), Amount: binary.LittleEndian.Uint64(data[32:40])}
+	}
+	return accounts, nil
+}
+
+// aggregateHolders sums the token accounts by owner, leaving out the accounts and owners in exclude and the owners
+// holding less than threshold or nothing. Holders are sorted by balance, largest first.
+func aggregateHolders(accounts map[solanago.PublicKey]tokenHolder, exclude map[solanago.PublicKey]bool, threshold uint64) []tokenHolder {
+	balances := map[solanago.PublicKey]uint64{}
+	for address, account := range accounts {
+		if exclude[address] || exclude[account.Owner] {
+			continue
+		}
+		balances[account.Owner] += account.Amount
+	}
+	var holders []tokenHolder
+	for owner, amount := range balances {
+		if amount > 0 && amount >= threshold {
+			holders = append(holders, tokenHolder{Owner: owner, Amount: amount})
+		}
+	}
+	slices.SortFunc(holders, func(a, b tokenHolder) int {
+		return cmp.Or(cmp.Compare(b.Amount, a.Amount), strings.Compare(a.Owner.String(), b.Owner.String()))
+	})
+	return holders
+}
+
+// readAddressList reads a file of addresses, one per line. Blank lines and lines starting with # are skipped.
+func readAddressList(path string) (map[solanago.PublicKey]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: can't open %s: %v", ErrInvalidArgument, path, err)
+	}
+	defer f.Close()
+	addresses := map[solanago.PublicKey]bool{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		address, err := solanago.PublicKeyFromBase58(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s line %d: %v", ErrInvalidArgument, path, line, err)
+		}
+		addresses[address] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: can't read %s: %v", ErrInvalidArgument, path, err)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// solDecimals is the number of decimals of a lamport amount expressed in SOL.
+const solDecimals = 9
+
+func init() {
+	commands["transfer-sol"] = runTransferSOL
+}
+
+func runTransferSOL(ctx context.Context, args []string) error {
+	fs := newFlagSet("transfer-sol")
+	receiverFlag := fs.String("receiver", "", "Receiver's base58 public key (required)")
+	amountFlag := fs.String("amount", "", "Amount of SOL to send, e.g. 0.25 (required)")
+	fs.BoolVar(&policyOverride, "override", false, "Send even though the spending policy can't check SOL transfers; the override is recorded in the audit log")
+	fs.BoolVar(&assumeYes, "yes", false, "Send on mainnet without the confirmation prompt")
+	parseFlags(fs, args)
+
+	if *receiverFlag == "" || *amountFlag == "" {
+		return fmt.Errorf("%w: --receiver and --amount flags are required", ErrInvalidArgument)
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(*receiverFlag)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	lamports, err := parseUIAmount(*amountFlag, solDecimals)
+	if err != nil {
+		return err
+	}
+	if lamports == 0 {
+		return fmt.Errorf("%w: --amount must be positive", ErrInvalidArgument)
+	}
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := enforceUncheckedPolicy(policy, "SOL transfers", map[string]any{"receiver": receiverKey.String(), "amount": formatUIAmount(lamports, solDecimals) + " SOL"}); err != nil {
+		return err
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	amount := formatUIAmount(lamports, solDecimals)
+	summary := fmt.Sprintf("Send:      %s SOL\nTo:        %s\nFee:       ~%s SOL\n", amount, receiverKey, formatUIAmount(lamportsPerSignature, solDecimals))
+	if err := confirmMainnetOperation(summary, amount); err != nil {
+		return err
+	}
+	sig, err := SendSOL(ctx, clients, signer, receiverKey, lamports)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// SendSOL transfers lamports of native SOL from signer to receiver through the System Program. Transfers that
+// would leave either account funded below the rent-exempt minimum are rejected before sending, since the
+// cluster would refuse them.
+func SendSOL(ctx context.Context, clients Clients, signer Signer, receiver solanago.PublicKey, lamports uint64) (solanago.Signature, error) {
+	if err := checkSOLTransfer(ctx, clients.Read, signer.PublicKey(), receiver, lamports); err != nil {
+		return solanago.Signature{}, err
+	}
+	slog.Info("sending SOL", "receiver", receiver, "amount", formatUIAmount(lamports, solDecimals))
+	return sendInstructions(ctx, clients, signer, system.NewTransferInstruction(lamports, signer.PublicKey(), receiver).Build())
+}
+
+// checkSOLTransfer verifies the sender can pay lamports plus the fee and that both accounts remain rent exempt.
+func checkSOLTransfer(ctx context.Context, client RPCClient, sender, receiver solanago.PublicKey, lamports uint64) error {
+	rentExempt, err := client.GetMinimumBalanceForRentExemption(ctx, 0, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get rent exemption minimum: %w", classifyRPCError(err))
+	}
+
+	senderBalance, err := client.GetBalance(ctx, sender, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get sender balance: %w", classifyRPCError(err))
+	}
+	needed := lamports + lamportsPerSignature
+	if senderBalance.Value < needed {
+		return fmt.Errorf("%w: sender has %s SOL, needs %s SOL including the fee", ErrInsufficientFunds,
+			formatUIAmount(senderBalance.Value, solDecimals), formatUIAmount(needed, solDecimals))
+	}
+	if left := senderBalance.Value - needed; left > 0 && left < rentExempt {
+		return fmt.Errorf("%w: sender would be left with %s SOL, below the rent-exempt minimum of %s SOL; send less or everything",
+			ErrInsufficientFunds, formatUIAmount(left, solDecimals), formatUIAmount(rentExempt, solDecimals))
+	}
+
+	receiverBalance, err := client.GetBalance(ctx, receiver, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("can't get receiver balance: %w", classifyRPCError(err))
+	}
+	if receiverBalance.Value+lamports < rentExempt {
+		return fmt.Errorf("%w: receiver would hold %s SOL, below the rent-exempt minimum of %s SOL", ErrInvalidArgument,
+			formatUIAmount(receiverBalance.Value+lamports, solDecimals), formatUIAmount(rentExempt, solDecimals))
+	}
+	return nil
+}
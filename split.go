@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Batch distribution modes (--split), sharing --total among the rows of the file instead of reading an amount
+// from each.
+const (
+	// splitEqual gives every receiver the same share; the file only needs a receiver column.
+	splitEqual = "equal"
+	// splitProportional shares the total by the positive decimal weight in each row's second column.
+	splitProportional = "proportional"
+)
+
+// readSplitFile parses a batch file for --split mode: receivers, and for splitProportional their weights, keyed by
+// line. Rows with an invalid receiver or weight are left out and returned as issues.
+func readSplitFile(path, mode string) ([]batchRow, map[int]*big.Rat, []batchIssue, error) {
+	records, err := readBatchRecords(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var rows []batchRow
+	var issues []batchIssue
+	weights := map[int]*big.Rat{}
+	for _, record := range records {
+		receiver, issue := parseBatchReceiver(record)
+		if issue != nil {
+			issues = append(issues, *issue)
+			continue
+		}
+		weight := big.NewRat(1, 1)
+		if mode == splitProportional {
+			if len(record.Fields) < 2 {
+				issues = append(issues, batchIssue{Line: record.Line, Problem: "expected receiver,weight"})
+				continue
+			}
+			var ok bool
+			weight, ok = new(big.Rat).SetString(strings.TrimSpace(record.Fields[1]))
+			if !ok || weight.Sign() <= 0 {
+				issues = append(issues, batchIssue{Line: record.Line, Problem: fmt.Sprintf("weight %q isn't a positive number", record.Fields[1])})
+				continue
+			}
+		}
+		rows = append(rows, batchRow{Line: record.Line, Receiver: receiver})
+		weights[record.Line] = weight
+	}
+	return rows, weights, issues, nil
+}
+
+// splitTotal shares total (in base units) among rows by their weights, setting each row's amount. Every row gets
+// the floor of its exact share; the base units left over by rounding go one each to the rows with the largest
+// remainders, earlier rows first on ties, so with equal weights the first rows get one unit more. It returns the
+// lines of the rows that got a leftover unit.
+func splitTotal(rows []batchRow, weights map[int]*big.Rat, total uint64, decimals uint8) []int {
+	sum := new(big.Rat)
+	for _, row := range rows {
+		sum.Add(sum, weights[row.Line])
+	}
+	remainders := make([]*big.Rat, len(rows))
+	var allocated uint64
+	for i := range rows {
+		share := new(big.Rat).SetUint64(total)
+		share.Mul(share, weights[rows[i].Line]).Quo(share, sum)
+		floor := new(big.Int).Quo(share.Num(), share.Denom())
+		rows[i].RawAmount = floor.Uint64()
+		remainders[i] = share.Sub(share, new(big.Rat).SetInt(floor))
+		allocated += rows[i].RawAmount
+	}
+
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return remainders[b].Cmp(remainders[a]) })
+	var lines []int
+	for _, i := range order[:total-allocated] {
+		rows[i].RawAmount++
+		lines = append(lines, rows[i].Line)
+	}
+	slices.Sort(lines)
+	for i := range rows {
+		rows[i].Amount = formatUIAmount(rows[i].RawAmount, decimals)
+	}
+	return lines
+}
+
+// printSplit explains how the total was split, including where the rounding remainder went.
+func printSplit(w io.Writer, mode string, rows []batchRow, total uint64, decimals uint8, label string, leftover []int) {
+	fmt.Fprintf(w, "Split %s %s %s among %d recipients", formatUIAmount(total, decimals), label, mode+"ly", len(rows))
+	if mode == splitProportional {
+		fmt.Fprint(w, " by weight")
+	}
+	fmt.Fprintln(w)
+	if len(leftover) == 0 {
+		fmt.Fprintln(w, "The total divides exactly; nothing is left over from rounding.")
+		return
+	}
+	names := make([]string, len(leftover))
+	for i, line := range leftover {
+		names[i] = strconv.Itoa(line)
+	}
+	fmt.Fprintf(w, "Shares are rounded down to whole base units of %s %s; the %d units left over went one each to the rows with the largest remainders, on lines %s.\n",
+		formatUIAmount(1, decimals), label, len(leftover), strings.Join(names, ", "))
+}
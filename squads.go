@@ -0,0 +1,574 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// squadsProgramID is the Squads v4 multisig program.
+var squadsProgramID = solanago.MustPublicKeyFromBase58("SQDS4ep65T869zMMBKyuUq6aD6EgTu8psMjkvj52pCf")
+
+// Squads member permissions, a bit mask.
+const (
+	squadsPermissionInitiate = 1 << 0
+	squadsPermissionVote     = 1 << 1
+	squadsPermissionExecute  = 1 << 2
+)
+
+// squadsProposalStatuses names the variants of a proposal's status. Executing has no timestamp, the others do.
+var squadsProposalStatuses = []string{"Draft", "Active", "Rejected", "Approved", "Executing", "Executed", "Cancelled"}
+
+const (
+	squadsProposalRejected  = 2
+	squadsProposalApproved  = 3
+	squadsProposalExecuting = 4
+)
+
+var (
+	// squadsMultisig makes the transfer a proposal from this Squads multisig's vault instead of a transfer
+	// signed by the signer.
+	squadsMultisig string
+	// squadsVaultIndex selects the multisig's vault the tokens are sent from.
+	squadsVaultIndex uint
+)
+
+func init() {
+	commands["squads"] = runSquads
+}
+
+// anchorDiscriminator returns the discriminator of the Anchor instruction name: the first 8 bytes of
+// sha256("global:<name>").
+func anchorDiscriminator(name string) []byte {
+	sum := sha256.Sum256([]byte("global:" + name))
+	return sum[:8]
+}
+
+// squadsMember is a member of a Squads multisig and what it may do.
+type squadsMember struct {
+	Key         solanago.PublicKey
+	Permissions uint8
+}
+
+// squadsMultisigState is the part of a Squads multisig account proposals need.
+type squadsMultisigState struct {
+	Address               solanago.PublicKey
+	Threshold             uint16
+	TimeLock              uint32
+	TransactionIndex      uint64
+	StaleTransactionIndex uint64
+	Members               []squadsMember
+}
+
+// member returns key's membership, if it is a member.
+func (m squadsMultisigState) member(key solanago.PublicKey) (squadsMember, bool) {
+	for _, member := range m.Members {
+		if member.Key.Equals(key) {
+			return member, true
+		}
+	}
+	return squadsMember{}, false
+}
+
+// requirePermission returns an error unless key is a member with permission.
+func (m squadsMultisigState) requirePermission(key solanago.PublicKey, permission uint8, action string) error {
+	member, ok := m.member(key)
+	if !ok {
+		return fmt.Errorf("%w: %s isn't a member of Squads multisig %s", ErrSignerUnavailable, key, m.Address)
+	}
+	if member.Permissions&permission == 0 {
+		return fmt.Errorf("%w: member %s of Squads multisig %s may not %s", ErrSignerUnavailable, key, m.Address, action)
+	}
+	return nil
+}
+
+// loadSquadsMultisig fetches and decodes the Squads multisig at address.
+func loadSquadsMultisig(ctx context.Context, client RPCClient, address solanago.PublicKey) (squadsMultisigState, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentConfirmed)
+	if errors.Is(err, rpc.ErrNotFound) || err == nil && res.Value == nil {
+		return squadsMultisigState{}, fmt.Errorf("%w: Squads multisig %s doesn't exist", ErrInvalidArgument, address)
+	}
+	if err != nil {
+		return squadsMultisigState{}, fmt.Errorf("can't get Squads multisig %s: %w", address, classifyRPCError(err))
+	}
+	if !res.Value.Owner.Equals(squadsProgramID) {
+		return squadsMultisigState{}, fmt.Errorf("%w: %s isn't a Squads v4 multisig", ErrInvalidArgument, address)
+	}
+	// Anchor discriminator, create_key and config_authority come first.
+	r := &borshReader{data: res.Value.Data.GetBinary(), off: 8 + 32 + 32}
+	m := squadsMultisigState{Address: address}
+	m.Threshold = r.u16()
+	m.TimeLock = r.u32()
+	m.TransactionIndex = r.u64()
+	m.StaleTransactionIndex = r.u64()
+	if r.option() {
+		r.bytes(32) // rent_collector
+	}
+	r.u8() // bump
+	for range r.u32() {
+		if r.err != nil {
+			break
+		}
+		m.Members = append(m.Members, squadsMember{Key: r.publicKey(), Permissions: r.u8()})
+	}
+	if r.err != nil {
+		return squadsMultisigState{}, fmt.Errorf("can't decode Squads multisig %s: %v", address, r.err)
+	}
+	return m, nil
+}
+
+// squadsPDA derives a Squads account of the multisig from seeds following its "multisig" prefix and address.
+func squadsPDA(multisig solanago.PublicKey, seeds ...[]byte) (solanago.PublicKey, error) {
+	address, _, err := solanago.FindProgramAddress(append([][]byte{[]byte("multisig"), multisig.Bytes()}, seeds...), squadsProgramID)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("can't derive Squads account: %v", err)
+	}
+	return address, nil
+}
+
+// squadsVault returns the address of the multisig's vault index, which holds its funds.
+func squadsVault(multisig solanago.PublicKey, index uint8) (solanago.PublicKey, error) {
+	return squadsPDA(multisig, []byte("vault"), []byte{index})
+}
+
+// squadsTransaction returns the address of the multisig's vault transaction index.
+func squadsTransaction(multisig solanago.PublicKey, index uint64) (solanago.PublicKey, error) {
+	return squadsPDA(multisig, []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index))
+}
+
+// squadsProposal returns the address of the proposal to execute the multisig's vault transaction index.
+func squadsProposal(multisig solanago.PublicKey, index uint64) (solanago.PublicKey, error) {
+	return squadsPDA(multisig, []byte("transaction"), binary.LittleEndian.AppendUint64(nil, index), []byte("proposal"))
+}
+
+// squadsTransactionMessage encodes tx's message the way vault_transaction_create takes it: the header as the
+// numbers of signers, writable signers and writable non-signers, then the account keys and instructions as
+// vectors with a u8 length, except instruction data, which has a u16 length. Lookup tables aren't used.
+func squadsTransactionMessage(tx *solanago.Transaction) ([]byte, error) {
+	m := tx.Message
+	header := m.Header
+	if len(m.AccountKeys) > 255 || len(m.Instructions) > 255 {
+		return nil, fmt.Errorf("%w: the transaction is too large for a Squads proposal", ErrInvalidArgument)
+	}
+	numWritableNonSigners := len(m.AccountKeys) - int(header.NumRequiredSignatures) - int(header.NumReadonlyUnsignedAccounts)
+	data := []byte{header.NumRequiredSignatures, header.NumRequiredSignatures - header.NumReadonlySignedAccounts, byte(numWritableNonSigners)}
+	data = append(data, byte(len(m.AccountKeys)))
+	for _, key := range m.AccountKeys {
+		data = append(data, key.Bytes()...)
+	}
+	data = append(data, byte(len(m.Instructions)))
+	for _, ix := range m.Instructions {
+		data = append(data, byte(ix.ProgramIDIndex), byte(len(ix.Accounts)))
+		for _, index := range ix.Accounts {
+			data = append(data, byte(index))
+		}
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(ix.Data)))
+		data = append(data, ix.Data...)
+	}
+	return append(data, 0), nil // address_table_lookups
+}
+
+// squadsOptionalString encodes an Option<String> argument.
+func squadsOptionalString(data []byte, s string) []byte {
+	if s == "" {
+		return append(data, 0)
+	}
+	data = append(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(s)))
+	return append(data, s...)
+}
+
+// squadsProposeInstructions returns the instructions creating vault transaction index from message and its
+// proposal, created by member, who pays their rent, and approving it too if approve is set.
+func squadsProposeInstructions(multisig, member solanago.PublicKey, index uint64, vaultIndex uint8, message []byte, memo string, approve bool) ([]solanago.Instruction, error) {
+	transaction, err := squadsTransaction(multisig, index)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := squadsProposal(multisig, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// VaultTransactionCreateArgs { vault_index, ephemeral_signers, transaction_message, memo }
+	data := append(anchorDiscriminator("vault_transaction_create"), vaultIndex, 0)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(message)))
+	data = append(data, message...)
+	data = squadsOptionalString(data, memo)
+	create := solanago.NewInstruction(squadsProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(multisig, true, false),
+		solanago.NewAccountMeta(transaction, true, false),
+		solanago.NewAccountMeta(member, false, true), // creator
+		solanago.NewAccountMeta(member, true, true),  // rent_payer
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+	}, data)
+
+	// ProposalCreateArgs { transaction_index, draft }
+	data = binary.LittleEndian.AppendUint64(anchorDiscriminator("proposal_create"), index)
+	data = append(data, 0)
+	propose := solanago.NewInstruction(squadsProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(multisig, false, false),
+		solanago.NewAccountMeta(proposal, true, false),
+		solanago.NewAccountMeta(member, false, true), // creator
+		solanago.NewAccountMeta(member, true, true),  // rent_payer
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+	}, data)
+
+	instructions := []solanago.Instruction{create, propose}
+	if approve {
+		instructions = append(instructions, squadsApproveInstruction(multisig, member, proposal))
+	}
+	return instructions, nil
+}
+
+// squadsApproveInstruction approves proposal as member.
+func squadsApproveInstruction(multisig, member, proposal solanago.PublicKey) solanago.Instruction {
+	// ProposalVoteArgs { memo }
+	data := squadsOptionalString(anchorDiscriminator("proposal_approve"), "")
+	return solanago.NewInstruction(squadsProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(multisig, false, false),
+		solanago.NewAccountMeta(member, true, true),
+		solanago.NewAccountMeta(proposal, true, false),
+		// The optional system program, only used when cancelling, is left out by passing the program itself.
+		solanago.NewAccountMeta(squadsProgramID, false, false),
+	}, data)
+}
+
+// runSquadsTransfer proposes the transfer of --amount of the selected token from a Squads multisig's vault to
+// --receiver, instead of sending it from the signer. The signer, a member, creates the proposal and approves it
+// if it may vote; the other members approve it and one executes it with the squads subcommands.
+func runSquadsTransfer(ctx context.Context) error {
+	switch {
+	case receiver == "":
+		return fmt.Errorf("%w: --receiver flag is required", ErrInvalidArgument)
+	case amount == 0 && amountPercent == nil:
+		return fmt.Errorf("%w: --amount flag is required", ErrInvalidArgument)
+	case receiverAccount != "" || testSend != "" || idempotencyKey != "" || len(sendFlags) > 0:
+		return fmt.Errorf("%w: --receiver-token-account, --test-send, --idempotency-key and --send can't be combined with --squads", ErrInvalidArgument)
+	case squadsVaultIndex > 255:
+		return fmt.Errorf("%w: --squads-vault must be at most 255", ErrInvalidArgument)
+	}
+	multisig, err := solanago.PublicKeyFromBase58(squadsMultisig)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --squads: %v", ErrInvalidArgument, err)
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(receiver)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if err := checkReceiverOwner(receiverKey); err != nil {
+		return err
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	state, err := loadSquadsMultisig(ctx, clients.Read, multisig)
+	if err != nil {
+		return err
+	}
+	if err := state.requirePermission(signer.PublicKey(), squadsPermissionInitiate, "propose transactions"); err != nil {
+		return err
+	}
+	vault, err := squadsVault(multisig, uint8(squadsVaultIndex))
+	if err != nil {
+		return err
+	}
+	mintAddress, mint, err := resolveMint(ctx, clients.Read)
+	if err != nil {
+		return err
+	}
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+	if err != nil {
+		return err
+	}
+	var rawAmount uint64
+	if amountPercent != nil {
+		vaultAta, err := tm.tokenAccount(vault)
+		if err != nil {
+			return fmt.Errorf("can't get ATA for vault %s: %v", vault, err)
+		}
+		if rawAmount, err = percentOfBalance(ctx, clients.Read, vaultAta, amountPercent); err != nil {
+			return err
+		}
+	} else if rawAmount, err = scaleAmount(amount, mint.Decimals); err != nil {
+		return err
+	}
+	// The vault pays for the receiver's token account, if it needs one.
+	if err := checkTokenTransfer(ctx, clients.Read, tm, vault, vault, receiverKey); err != nil {
+		return err
+	}
+
+	params, err := PrepareTransfer(ctx, clients.Read, vault, receiverKey, rawAmount, TransferOptions{Mint: mintAddress, Memo: transferMemo})
+	if err != nil {
+		return err
+	}
+	inner, err := BuildTokenTransferTransaction(params)
+	if err != nil {
+		return err
+	}
+	message, err := squadsTransactionMessage(inner)
+	if err != nil {
+		return err
+	}
+	index := state.TransactionIndex + 1
+	member, _ := state.member(signer.PublicKey())
+	approve := member.Permissions&squadsPermissionVote != 0
+	propose, err := squadsProposeInstructions(multisig, signer.PublicKey(), index, uint8(squadsVaultIndex), message, transferMemo, approve)
+	if err != nil {
+		return err
+	}
+	instructions := append(priorityFeeInstructions(), propose...)
+	if dryRun {
+		return simulateInstructions(ctx, clients, signer, instructions...)
+	}
+
+	sig, err := sendInstructions(ctx, clients, signer, instructions...)
+	if err != nil {
+		return fmt.Errorf("can't create Squads proposal: %w", err)
+	}
+	approvals := 0
+	if approve {
+		approvals = 1
+	}
+	slog.Info(fmt.Sprintf("proposed sending %s %s from the Squads vault", formatUIAmount(rawAmount, mint.Decimals), tokenLabel(ctx, clients.Read, mintAddress)),
+		"vault", vault, "receiver", receiverKey, "index", index, "approvals", fmt.Sprintf("%d of %d", approvals, state.Threshold), "signature", sig)
+	fmt.Println(index)
+	return nil
+}
+
+// squadsProposalState is the part of a proposal account listing and executing needs.
+type squadsProposalState struct {
+	Index    uint64
+	Status   uint8
+	Approved []solanago.PublicKey
+}
+
+// parseSquadsProposal decodes a proposal account.
+func parseSquadsProposal(data []byte) (squadsProposalState, error) {
+	// Anchor discriminator and multisig.
+	r := &borshReader{data: data, off: 8 + 32}
+	p := squadsProposalState{Index: r.u64(), Status: r.u8()}
+	if p.Status != squadsProposalExecuting {
+		r.bytes(8) // timestamp
+	}
+	r.u8() // bump
+	for range r.u32() {
+		if r.err != nil {
+			break
+		}
+		p.Approved = append(p.Approved, r.publicKey())
+	}
+	if r.err != nil {
+		return squadsProposalState{}, fmt.Errorf("can't decode Squads proposal: %v", r.err)
+	}
+	if int(p.Status) >= len(squadsProposalStatuses) {
+		return squadsProposalState{}, fmt.Errorf("can't decode Squads proposal: unknown status %d", p.Status)
+	}
+	return p, nil
+}
+
+func runSquads(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "approve" && args[0] != "execute") {
+		return fmt.Errorf("%w: usage: squads list|approve|execute --multisig ADDRESS [--index N] [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("squads " + args[0])
+	multisigFlag := fs.String("multisig", "", "Squads v4 multisig (required)")
+	index := fs.Uint64("index", 0, "Transaction index of the proposal to approve or execute (required for approve and execute)")
+	all := fs.Bool("all", false, "List settled proposals too, not just those waiting for approval or execution")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the execution without sending it")
+	parseFlags(fs, args[1:])
+
+	if *multisigFlag == "" {
+		return fmt.Errorf("%w: --multisig flag is required", ErrInvalidArgument)
+	}
+	multisig, err := solanago.PublicKeyFromBase58(*multisigFlag)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --multisig: %v", ErrInvalidArgument, err)
+	}
+	if args[0] != "list" && *index == 0 {
+		return fmt.Errorf("%w: --index flag is required", ErrInvalidArgument)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	state, err := loadSquadsMultisig(ctx, clients.Read, multisig)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return listSquadsProposals(ctx, clients.Read, state, *all)
+	case "approve":
+		if err := state.requirePermission(signer.PublicKey(), squadsPermissionVote, "vote"); err != nil {
+			return err
+		}
+		proposal, err := squadsProposal(multisig, *index)
+		if err != nil {
+			return err
+		}
+		sig, err := sendInstructions(ctx, clients, signer, append(priorityFeeInstructions(), squadsApproveInstruction(multisig, signer.PublicKey(), proposal))...)
+		if err != nil {
+			return fmt.Errorf("can't approve Squads proposal %d: %w", *index, err)
+		}
+		slog.Info("approved Squads proposal", "multisig", multisig, "index", *index, "signature", sig)
+		fmt.Println(sig)
+		return nil
+	default:
+		if err := state.requirePermission(signer.PublicKey(), squadsPermissionExecute, "execute"); err != nil {
+			return err
+		}
+		return executeSquadsProposal(ctx, clients, signer, state, *index, *dryRunFlag)
+	}
+}
+
+// listSquadsProposals prints the multisig's proposals since its stale transaction index, the older ones being
+// void. Only proposals waiting for approval or execution are listed unless all is set.
+func listSquadsProposals(ctx context.Context, client RPCClient, state squadsMultisigState, all bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tSTATUS\tAPPROVALS\tPROPOSAL")
+	for start := state.StaleTransactionIndex + 1; start <= state.TransactionIndex; start += 100 {
+		var addresses []solanago.PublicKey
+		for index := start; index <= min(start+99, state.TransactionIndex); index++ {
+			address, err := squadsProposal(state.Address, index)
+			if err != nil {
+				return err
+			}
+			addresses = append(addresses, address)
+		}
+		res, err := client.GetMultipleAccounts(ctx, addresses...)
+		if err != nil {
+			return fmt.Errorf("can't get Squads proposals: %w", classifyRPCError(err))
+		}
+		for i, account := range res.Value {
+			if account == nil {
+				// Transactions can be created without a proposal, or their proposal closed.
+				continue
+			}
+			p, err := parseSquadsProposal(account.Data.GetBinary())
+			if err != nil {
+				slog.Warn("skipping proposal", "proposal", addresses[i], "error", err)
+				continue
+			}
+			if !all && (p.Status == squadsProposalRejected || p.Status > squadsProposalApproved) {
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d of %d\t%s\n", p.Index, squadsProposalStatuses[p.Status], len(p.Approved), state.Threshold, addresses[i])
+		}
+	}
+	return w.Flush()
+}
+
+// executeSquadsProposal executes the approved vault transaction index as signer, or only simulates it if simulate
+// is set. The vault transaction's accounts follow the instruction's own, in the order of its message, with the
+// vault signing through the program.
+func executeSquadsProposal(ctx context.Context, clients Clients, signer Signer, state squadsMultisigState, index uint64, simulate bool) error {
+	proposal, err := squadsProposal(state.Address, index)
+	if err != nil {
+		return err
+	}
+	transaction, err := squadsTransaction(state.Address, index)
+	if err != nil {
+		return err
+	}
+	res, err := clients.Read.GetMultipleAccounts(ctx, proposal, transaction)
+	if err != nil {
+		return fmt.Errorf("can't get Squads proposal %d: %w", index, classifyRPCError(err))
+	}
+	if len(res.Value) != 2 || res.Value[0] == nil || res.Value[1] == nil {
+		return fmt.Errorf("%w: Squads multisig %s has no vault transaction proposal %d", ErrInvalidArgument, state.Address, index)
+	}
+	p, err := parseSquadsProposal(res.Value[0].Data.GetBinary())
+	if err != nil {
+		return err
+	}
+	if p.Status != squadsProposalApproved {
+		return fmt.Errorf("%w: Squads proposal %d is %s, not Approved (%d of %d approvals)", ErrInvalidArgument, index, squadsProposalStatuses[p.Status], len(p.Approved), state.Threshold)
+	}
+	accounts, err := squadsTransactionAccounts(res.Value[1].Data.GetBinary())
+	if err != nil {
+		return err
+	}
+
+	execute := solanago.NewInstruction(squadsProgramID, append(solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(state.Address, false, false),
+		solanago.NewAccountMeta(proposal, true, false),
+		solanago.NewAccountMeta(transaction, false, false),
+		solanago.NewAccountMeta(signer.PublicKey(), false, true), // member
+	}, accounts...), anchorDiscriminator("vault_transaction_execute"))
+	instructions := append(priorityFeeInstructions(), execute)
+	if simulate {
+		return simulateInstructions(ctx, clients, signer, instructions...)
+	}
+	sig, err := sendInstructions(ctx, clients, signer, instructions...)
+	if err != nil {
+		if state.TimeLock > 0 {
+			slog.Warn(fmt.Sprintf("the multisig has a %d second time lock after approval", state.TimeLock))
+		}
+		return fmt.Errorf("can't execute Squads proposal %d: %w", index, err)
+	}
+	slog.Info("executed Squads proposal", "multisig", state.Address, "index", index, "signature", sig)
+	if link := explorerTxURL(sig.String()); link != "" {
+		slog.Info("view on explorer", "transaction", link)
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// squadsTransactionAccounts decodes a vault transaction account's message and returns its accounts as
+// vault_transaction_execute takes them: writable as in the message, none signing.
+func squadsTransactionAccounts(data []byte) ([]*solanago.AccountMeta, error) {
+	// Anchor discriminator, multisig, creator, index, bump, vault_index and vault_bump.
+	r := &borshReader{data: data, off: 8 + 32 + 32 + 8 + 1 + 1 + 1}
+	r.bytes(int(r.u32())) // ephemeral_signer_bumps
+	numSigners, numWritableSigners, numWritableNonSigners := int(r.u8()), int(r.u8()), int(r.u8())
+	var keys []solanago.PublicKey
+	for range r.u32() {
+		if r.err != nil {
+			break
+		}
+		keys = append(keys, r.publicKey())
+	}
+	for range r.u32() {
+		if r.err != nil {
+			break
+		}
+		r.u8()                // program_id_index
+		r.bytes(int(r.u32())) // account_indexes
+		r.bytes(int(r.u32())) // data
+	}
+	lookups := r.u32()
+	if r.err != nil {
+		return nil, fmt.Errorf("can't decode Squads vault transaction: %v", r.err)
+	}
+	if lookups > 0 {
+		return nil, fmt.Errorf("%w: Squads vault transactions using address lookup tables aren't supported", ErrInvalidArgument)
+	}
+	accounts := make([]*solanago.AccountMeta, len(keys))
+	for i, key := range keys {
+		writable := i < numWritableSigners || i >= numSigners && i < numSigners+numWritableNonSigners
+		accounts[i] = solanago.NewAccountMeta(key, writable, false)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	transfersJournalFile = "transfers.jsonl"
+	// storeLockFile is locked by every process using the store in the same data directory, see Store.
+	storeLockFile = "store.lock"
+)
+
+// Transfer states.
+const (
+	transferPending   = "pending"
+	transferConfirmed = "confirmed"
+	transferFailed    = "failed"
+	// transferAwaitingApproval transfers are held until a second operator approves them, see approvals.go.
+	transferAwaitingApproval = "awaiting-approval"
+)
+
+// transferRecord is a transfer tracked by the local store.
+type transferRecord struct {
+	ID             string `json:"id"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Sender         string `json:"sender,omitempty"`
+	Receiver       string `json:"receiver"`
+	Mint           string `json:"mint,omitempty"`
+	// Amount is the decimal token amount, e.g. "12.5".
+	Amount string `json:"amount"`
+	Status string `json:"status"`
+	// Signature and Blockhash are recorded before the transaction is broadcast, so an interrupted transfer can
+	// be looked up on-chain instead of being sent twice.
+	Signature string `json:"signature,omitempty"`
+	Blockhash string `json:"blockhash,omitempty"`
+	// Value is the approximate fiat value of Amount when the transfer was sent, e.g. "12.50 USD", if a
+	// --price-source was set.
+	Value string `json:"value,omitempty"`
+	// Received is the decimal amount the receiver's balance grew by, checked after confirmation. It's less than
+	// Amount if the mint charges a transfer fee.
+	Received string `json:"received,omitempty"`
+	// CreatedAccount reports whether the transaction created the receiver's token account, for batch transfers.
+	CreatedAccount bool `json:"createdAccount,omitempty"`
+	// Explorer links to the transaction on the block explorer selected by --explorer.
+	Explorer string `json:"explorer,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Client is the name of the API key a transfer was requested with in serve mode.
+	Client string `json:"client,omitempty"`
+	// ApprovedBy is the public key of the operator who approved a transfer that needed approval.
+	ApprovedBy string    `json:"approvedBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Store is the local persistent state kept under --data-dir. Records are held in memory and every change is
+// appended to a JSON lines journal, so replaying the journal (last line per id wins) restores the state.
+//
+// Several processes may use the same store at once, e.g. the serve daemon and an operator running
+// `approve <id>`. Each change is appended holding an exclusive lock on store.lock, after reading back the lines
+// other processes appended, so checks such as idempotency key uniqueness see every process's records; lookups
+// read those lines back too, under a shared lock. Within a process the store is safe for concurrent use.
+type Store struct {
+	dir string
+
+	mu        sync.Mutex
+	transfers map[string]*transferRecord
+	byKey     map[clientKey]string // idempotency key -> transfer id
+	journal   *os.File
+	offset    int64 // bytes of the journal indexed so far
+	lock      *os.File
+	watchers  map[chan transferRecord]bool
+}
+
+// watcherBuffer is how many changes a watcher may fall behind by before it's dropped.
+const watcherBuffer = 256
+
+// defaultDataDir returns the directory used for local state when --data-dir isn't set.
+func defaultDataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".token-transfer"
+	}
+	return filepath.Join(dir, "token-transfer")
+}
+
+// OpenStore opens the store in dir, creating it or migrating it to the current schema as needed.
+func OpenStore(dir string) (_ *Store, err error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("can't create data directory: %v", err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, storeLockFile), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("can't open store lock: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			lock.Close()
+		}
+	}()
+	// Migrations and repairs rewrite the journal, so no other process may be using it meanwhile.
+	if err := lockFile(lock, true); err != nil {
+		return nil, fmt.Errorf("can't lock store: %v", err)
+	}
+	defer unlockFile(lock)
+	if err := migrate(dir); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:       dir,
+		transfers: map[string]*transferRecord{},
+		byKey:     map[clientKey]string{},
+		lock:      lock,
+		watchers:  map[chan transferRecord]bool{},
+	}
+	if err := repairJournal(filepath.Join(dir, transfersJournalFile)); err != nil {
+		return nil, fmt.Errorf("can't repair journal: %v", err)
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, transfersJournalFile), os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s.journal = journal
+	if err := s.readJournal(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// repairJournal drops a partial last line left behind by a crash mid-append, so new records start on a fresh line.
+func repairJournal(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 || data[len(data)-1] == '\n' {
+		return err
+	}
+	keep := bytes.LastIndexByte(data, '\n') + 1
+	slog.Warn("dropping truncated journal entry", "file", path, "bytes", len(data)-keep)
+	return os.Truncate(path, int64(keep))
+}
+
+// readJournal indexes the lines appended to the journal since it was last read, by this or another process, and
+// publishes them to the watchers. A partial last line, still being written, is left for later. The caller must
+// hold s.mu and the store lock, unless the store isn't shared yet.
+func (s *Store) readJournal() error {
+	info, err := s.journal.Stat()
+	if err != nil {
+		return fmt.Errorf("can't read journal: %v", err)
+	}
+	if info.Size() <= s.offset {
+		return nil
+	}
+	data := make([]byte, info.Size()-s.offset)
+	if _, err := s.journal.ReadAt(data, s.offset); err != nil {
+		return fmt.Errorf("can't read journal: %v", err)
+	}
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+		line := data[:end+1]
+		data = data[end+1:]
+		var record transferRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			slog.Warn("skipping unreadable journal line", "file", s.journal.Name(), "offset", s.offset, "error", err)
+		} else {
+			s.index(&record)
+			s.publish(record)
+		}
+		s.offset += int64(len(line))
+	}
+}
+
+// refresh reads back what other processes appended to the journal, if anything, under a shared store lock. Lookups
+// go on with what's already indexed if that fails. The caller must hold s.mu.
+func (s *Store) refresh() {
+	if info, err := s.journal.Stat(); err == nil && info.Size() <= s.offset {
+		return
+	}
+	if err := lockFile(s.lock, false); err != nil {
+		slog.Warn("can't lock store", "error", err)
+		return
+	}
+	defer unlockFile(s.lock)
+	if err := s.readJournal(); err != nil {
+		slog.Warn("can't read back journal", "error", err)
+	}
+}
+
+// Follow reads back other processes' changes every interval until ctx is done, so watchers hear of them without
+// waiting for a lookup.
+func (s *Store) Follow(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.refresh()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// lockForWrite takes the store lock exclusively and catches up with the journal, so a change is checked against
+// and appended after every other process's. A partial last line left by a process that died mid-append is
+// dropped. The caller must hold s.mu, and call the returned function once the change is appended.
+func (s *Store) lockForWrite() (func(), error) {
+	if err := lockFile(s.lock, true); err != nil {
+		return nil, fmt.Errorf("can't lock store: %v", err)
+	}
+	unlock := func() { unlockFile(s.lock) }
+	if err := s.readJournal(); err != nil {
+		unlock()
+		return nil, err
+	}
+	if info, err := s.journal.Stat(); err == nil && info.Size() > s.offset {
+		slog.Warn("dropping truncated journal entry", "file", s.journal.Name(), "bytes", info.Size()-s.offset)
+		if err := s.journal.Truncate(s.offset); err != nil {
+			unlock()
+			return nil, fmt.Errorf("can't repair journal: %v", err)
+		}
+	}
+	return unlock, nil
+}
+
+// clientKey is an idempotency key in the scope of the API client whose requests use it. Keys are only unique per
+// client, so one client can't find or block another's transfers by guessing its keys; the CLI's records have no
+// client and share a scope of their own.
+type clientKey struct {
+	client, key string
+}
+
+// index makes record visible to lookups. The caller must hold s.mu unless the store isn't shared yet.
+func (s *Store) index(record *transferRecord) {
+	s.transfers[record.ID] = record
+	if record.IdempotencyKey != "" {
+		s.byKey[clientKey{record.Client, record.IdempotencyKey}] = record.ID
+	}
+}
+
+// append writes record to the journal and syncs it to disk. The caller must hold s.mu and the lock from
+// lockForWrite.
+func (s *Store) append(record *transferRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := s.journal.Write(line); err != nil {
+		return fmt.Errorf("can't write journal: %v", err)
+	}
+	s.offset += int64(len(line))
+	return s.journal.Sync()
+}
+
+// PutTransfer stores a new transfer record. Idempotency keys must be unique per client.
+func (s *Store) PutTransfer(record transferRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if id, ok := s.byKey[clientKey{record.Client, record.IdempotencyKey}]; ok && record.IdempotencyKey != "" && id != record.ID {
+		return fmt.Errorf("idempotency key %q is already used by transfer %s", record.IdempotencyKey, id)
+	}
+	if err := s.append(&record); err != nil {
+		return err
+	}
+	s.index(&record)
+	s.publish(record)
+	return nil
+}
+
+// Watch returns a channel that receives every transfer stored or updated from now on, and a function to stop
+// watching. A watcher that falls more than watcherBuffer changes behind has its channel closed, so it can tell that
+// it missed some.
+func (s *Store) Watch() (<-chan transferRecord, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := make(chan transferRecord, watcherBuffer)
+	s.watchers[changes] = true
+	return changes, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.watchers[changes] {
+			delete(s.watchers, changes)
+			close(changes)
+		}
+	}
+}
+
+// publish sends record to the watchers. The caller must hold s.mu.
+func (s *Store) publish(record transferRecord) {
+	for changes := range s.watchers {
+		select {
+		case changes <- record:
+		default:
+			delete(s.watchers, changes)
+			close(changes)
+		}
+	}
+}
+
+// TransferByIdempotencyKey returns the transfer recorded under key without an API client, as the CLI records them.
+func (s *Store) TransferByIdempotencyKey(key string) (transferRecord, bool) {
+	return s.ClientTransferByIdempotencyKey("", key)
+}
+
+// ClientTransferByIdempotencyKey returns the transfer the API client named client requested with key.
+func (s *Store) ClientTransferByIdempotencyKey(client, key string) (transferRecord, bool) {
+	s.mu.Lock()
+	s.refresh()
+	id, ok := s.byKey[clientKey{client, key}]
+	s.mu.Unlock()
+	if !ok {
+		return transferRecord{}, false
+	}
+	return s.Transfer(id)
+}
+
+// Transfer returns the transfer with the given id.
+func (s *Store) Transfer(id string) (transferRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	record, ok := s.transfers[id]
+	if !ok {
+		return transferRecord{}, false
+	}
+	return *record, true
+}
+
+// TransferBySignature returns the transfer whose (latest) transaction has signature sig.
+func (s *Store) TransferBySignature(sig string) (transferRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	for _, record := range s.transfers {
+		if record.Signature == sig {
+			return *record, true
+		}
+	}
+	return transferRecord{}, false
+}
+
+// HasConfirmedTransferTo reports whether a confirmed transfer to receiver has been recorded.
+func (s *Store) HasConfirmedTransferTo(receiver string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	for _, record := range s.transfers {
+		if record.Receiver == receiver && record.Status == transferConfirmed {
+			return true
+		}
+	}
+	return false
+}
+
+// TransfersByKeyPrefix returns the transfers without an API client whose idempotency key starts with prefix, oldest
+// first.
+func (s *Store) TransfersByKeyPrefix(prefix string) []transferRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	var records []transferRecord
+	for key, id := range s.byKey {
+		if key.client == "" && strings.HasPrefix(key.key, prefix) {
+			records = append(records, *s.transfers[id])
+		}
+	}
+	slices.SortFunc(records, func(a, b transferRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return records
+}
+
+// TransfersSince returns the transfers created at or after t.
+func (s *Store) TransfersSince(t time.Time) []transferRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	var records []transferRecord
+	for _, record := range s.transfers {
+		if !record.CreatedAt.Before(t) {
+			records = append(records, *record)
+		}
+	}
+	return records
+}
+
+// UpdateTransfer applies fn to the transfer with the given id and persists the result.
+func (s *Store) UpdateTransfer(id string, fn func(*transferRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	record, ok := s.transfers[id]
+	if !ok {
+		return fmt.Errorf("transfer %s not found", id)
+	}
+	updated := *record
+	fn(&updated)
+	updated.UpdatedAt = time.Now().UTC()
+	if err := s.append(&updated); err != nil {
+		return err
+	}
+	*record = updated
+	s.publish(updated)
+	return nil
+}
+
+// Ping reports whether the store can still record transfers, i.e. its journal is open.
+func (s *Store) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.journal.Stat(); err != nil {
+		return fmt.Errorf("journal: %v", err)
+	}
+	return nil
+}
+
+// Close closes the journal.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.journal.Close(), s.lock.Close())
+}
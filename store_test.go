@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+// openTestStores opens the store in one directory twice, as two processes would.
+func openTestStores(t *testing.T) (*Store, *Store) {
+	t.Helper()
+	dir := t.TempDir()
+	var stores [2]*Store
+	for i := range stores {
+		store, err := OpenStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[i] = store
+	}
+	return stores[0], stores[1]
+}
+
+func TestStoreSharedIdempotencyKeys(t *testing.T) {
+	first, second := openTestStores(t)
+	receiver, mint := testKey(2).PublicKey(), testKey(3).PublicKey()
+	if err := first.PutTransfer(newTransferRecord("key", testKey(1).PublicKey(), receiver, mint, "1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.PutTransfer(newTransferRecord("key", testKey(1).PublicKey(), receiver, mint, "1")); err == nil {
+		t.Error("the other store reused the idempotency key")
+	}
+}
+
+func TestStoreSharedUpdates(t *testing.T) {
+	first, second := openTestStores(t)
+	record := newTransferRecord("", testKey(1).PublicKey(), testKey(2).PublicKey(), testKey(3).PublicKey(), "1")
+	if err := first.PutTransfer(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.UpdateTransfer(record.ID, func(r *transferRecord) { r.Signature = "sig" }); err != nil {
+		t.Fatalf("UpdateTransfer in the other store: %v", err)
+	}
+	// Updated on top of the other store's change rather than its own stale copy.
+	if err := first.UpdateTransfer(record.ID, func(r *transferRecord) { r.Status = transferConfirmed }); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenStore(first.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, _ := reopened.Transfer(record.ID); got.Status != transferConfirmed || got.Signature != "sig" {
+		t.Errorf("recorded %s with signature %q, want %s with both changes", got.Status, got.Signature, transferConfirmed)
+	}
+}
+
+func TestStoreSeesOtherProcessesChanges(t *testing.T) {
+	daemon, cli := openTestStores(t)
+	record := newTransferRecord("", testKey(1).PublicKey(), testKey(2).PublicKey(), testKey(3).PublicKey(), "1")
+	record.Status = transferAwaitingApproval
+	if err := daemon.PutTransfer(record); err != nil {
+		t.Fatal(err)
+	}
+	changes, stop := daemon.Watch()
+	defer stop()
+
+	// As `approve <id>` does while the daemon runs.
+	if err := cli.UpdateTransfer(record.ID, func(r *transferRecord) { r.Status = transferPending }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := daemon.Transfer(record.ID); got.Status != transferPending {
+		t.Errorf("daemon sees %s, want %s", got.Status, transferPending)
+	}
+	select {
+	case change := <-changes:
+		if change.Status != transferPending {
+			t.Errorf("watcher got %s, want %s", change.Status, transferPending)
+		}
+	default:
+		t.Error("watcher wasn't told about the change")
+	}
+}
+
+func TestStoreIdempotencyKeysPerClient(t *testing.T) {
+	store, _ := openTestStores(t)
+	receiver, mint := testKey(2).PublicKey(), testKey(3).PublicKey()
+	for _, client := range []string{"", "a", "b"} {
+		record := newTransferRecord("key", testKey(1).PublicKey(), receiver, mint, "1")
+		record.Client = client
+		if err := store.PutTransfer(record); err != nil {
+			t.Fatalf("client %q: %v", client, err)
+		}
+	}
+	for _, client := range []string{"", "a", "b"} {
+		if record, ok := store.ClientTransferByIdempotencyKey(client, "key"); !ok || record.Client != client {
+			t.Errorf("client %q found %+v, %t; want its own transfer", client, record, ok)
+		}
+	}
+	if _, ok := store.ClientTransferByIdempotencyKey("c", "key"); ok {
+		t.Error("client c found another client's transfer")
+	}
+}
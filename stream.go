@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// maxStreamLine is the longest input line stream accepts.
+const maxStreamLine = 64 * 1024
+
+// transferRejected is the status of stream results for lines that were invalid or refused, so nothing was sent.
+const transferRejected = "rejected"
+
+func init() {
+	commands["stream"] = runStream
+}
+
+// streamSpec is one JSON line of stream input. Mint is a registry symbol or mint address and defaults to
+// --token; ID, if set, is the transfer's idempotency key.
+type streamSpec struct {
+	Receiver string `json:"receiver"`
+	Amount   string `json:"amount"`
+	Mint     string `json:"mint"`
+	ID       string `json:"id"`
+}
+
+// streamResult is the JSON line stream writes for every input line.
+type streamResult struct {
+	Line      int    `json:"line"`
+	ID        string `json:"id,omitempty"`
+	Receiver  string `json:"receiver,omitempty"`
+	Mint      string `json:"mint,omitempty"`
+	Amount    string `json:"amount,omitempty"`
+	Status    string `json:"status"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// transferStream sends the transfers read by runStream one at a time.
+type transferStream struct {
+	clients Clients
+	signer  Signer
+	store   *Store
+	policy  *Policy
+	opts    TransferOptions
+	// defaultMint is --token, used for lines without a mint.
+	defaultMint string
+	// mints caches the mints seen so far.
+	mints map[solanago.PublicKey]transferMint
+}
+
+func runStream(ctx context.Context, args []string) error {
+	fs := newFlagSet("stream")
+	input := fs.String("input", "-", "File of JSON lines {\"receiver\", \"amount\", \"mint\", \"id\"} to send; - reads stdin")
+	yes := fs.Bool("yes", false, "Send mainnet transfers; stream can't prompt for confirmation since stdin carries the input")
+	registerTxFlags(fs)
+	registerShutdownFlags(fs)
+	parseFlags(fs, args)
+
+	if clusterKind() == "mainnet" && !*yes {
+		return fmt.Errorf("%w: mainnet transfers need confirmation, pass --yes to stream them", ErrAborted)
+	}
+	in := io.Reader(os.Stdin)
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("%w: can't open input: %v", ErrInvalidArgument, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+
+	s := &transferStream{
+		clients:     clients,
+		signer:      signer,
+		store:       store,
+		policy:      policy,
+		opts:        TransferOptions{PreInstructions: priorityFeeInstructions(), NoFundRecipient: noFundRecipient},
+		defaultMint: tokenName,
+		mints:       map[solanago.PublicKey]transferMint{},
+	}
+	if err := txFormatOptions(ctx, clients.Read, signer.PublicKey(), &s.opts); err != nil {
+		return err
+	}
+	if s.opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+
+	// Sends run under drain, so Ctrl-C stops reading but lets the transfer in flight confirm.
+	drain, stop := drainContext(ctx)
+	defer stop()
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 4096), maxStreamLine)
+	var line, sent, failed int
+	for ctx.Err() == nil && scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		result := s.transfer(drain, line, text)
+		switch result.Status {
+		case transferConfirmed:
+			sent++
+		case transferAwaitingApproval:
+		default:
+			failed++
+		}
+		if err := out.Encode(result); err != nil {
+			// The reader went away; what was sent is in the store.
+			return fmt.Errorf("can't write result: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: can't read input line %d: %v", ErrInvalidArgument, line+1, err)
+	}
+
+	slog.Info("stream finished", "sent", sent, "failed", failed)
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %v: stopped after line %d", ErrAborted, context.Cause(ctx), line)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d transfers weren't sent, see their results", ErrTransactionFailed, failed)
+	}
+	return nil
+}
+
+// streamTransfer is a transfer read from the stream, checked and recorded.
+type streamTransfer struct {
+	record   transferRecord
+	receiver solanago.PublicKey
+	mint     solanago.PublicKey
+	amount   uint64 // raw base units
+}
+
+// transfer validates, records and sends the transfer on input line number line, and describes the outcome.
+func (s *transferStream) transfer(ctx context.Context, line int, text string) streamResult {
+	result := streamResult{Line: line, Status: transferRejected}
+	var spec streamSpec
+	if err := json.Unmarshal([]byte(text), &spec); err != nil {
+		result.Error = fmt.Sprintf("invalid JSON: %v", err)
+		return result
+	}
+	result.Receiver = spec.Receiver
+	t, err := s.prepare(ctx, spec)
+	if err != nil {
+		result.Error = err.Error()
+		slog.Warn("transfer rejected", "line", line, "receiver", spec.Receiver, "error", err)
+		return result
+	}
+	result.ID, result.Mint, result.Amount = t.record.ID, t.record.Mint, t.record.Amount
+	if t.record.Status != transferPending {
+		// Held for approval, or already sent under the same id.
+		result.Status, result.Signature, result.Error = t.record.Status, t.record.Signature, t.record.Error
+		return result
+	}
+
+	opts := s.opts
+	opts.Mint = t.mint
+	sig, err := sendRecorded(ctx, s.store, s.clients, s.signer, t.record.ID, t.receiver, t.amount, opts)
+	record, _ := s.store.Transfer(t.record.ID)
+	switch {
+	case err == nil:
+		result.Status, result.Signature = transferConfirmed, sig.String()
+	case record.Status == transferPending:
+		// The outcome isn't known; sending the line again with the same id checks whether it landed.
+		result.Status, result.Error = transferPending, err.Error()
+	default:
+		result.Status, result.Error = transferFailed, err.Error()
+		slog.Error("transfer failed", "line", line, "receiver", t.receiver, "error", err)
+	}
+	return result
+}
+
+// prepare checks spec and records its transfer, or returns the record already stored under its id. The record's
+// status is pending when it has to be sent.
+func (s *transferStream) prepare(ctx context.Context, spec streamSpec) (streamTransfer, error) {
+	receiver, err := solanago.PublicKeyFromBase58(spec.Receiver)
+	if err != nil {
+		return streamTransfer{}, fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if err := checkReceiverOwner(receiver); err != nil {
+		return streamTransfer{}, err
+	}
+	tm, err := s.mint(ctx, cmp.Or(spec.Mint, s.defaultMint))
+	if err != nil {
+		return streamTransfer{}, err
+	}
+	raw, err := parseUIAmount(spec.Amount, tm.Decimals)
+	if err != nil {
+		return streamTransfer{}, err
+	}
+	if raw == 0 {
+		return streamTransfer{}, fmt.Errorf("%w: amount must be positive", ErrInvalidArgument)
+	}
+	t := streamTransfer{receiver: receiver, mint: tm.Address, amount: raw}
+	amount := formatUIAmount(raw, tm.Decimals)
+
+	if existing, ok := s.store.TransferByIdempotencyKey(spec.ID); ok && spec.ID != "" {
+		if existing.Receiver != receiver.String() || existing.Mint != tm.Address.String() || existing.Amount != amount {
+			return streamTransfer{}, fmt.Errorf("%w: id %q was used for a different transfer", ErrInvalidArgument, spec.ID)
+		}
+		send, err := resumeBatchRow(ctx, s.clients.Read, s.store, existing)
+		if err != nil {
+			return streamTransfer{}, err
+		}
+		t.record, _ = s.store.Transfer(existing.ID)
+		if send {
+			t.record.Status = transferPending
+		}
+		return t, nil
+	}
+
+	if err := checkTokenTransfer(ctx, s.clients.Read, tm, s.signer.PublicKey(), s.opts.payer(s.signer.PublicKey()), receiver); err != nil {
+		return streamTransfer{}, err
+	}
+	if err := enforcePolicy(s.policy, s.store, tm.Address, tm.Decimals, receiver, raw, 0); err != nil {
+		return streamTransfer{}, err
+	}
+	needs, err := s.policy.NeedsApproval(tm.Address, tm.Decimals, raw)
+	if err != nil {
+		return streamTransfer{}, err
+	}
+	t.record = newTransferRecord(spec.ID, s.signer.PublicKey(), receiver, tm.Address, amount)
+	if needs {
+		// Held for a second operator, who approves and sends it on its own.
+		t.record.Status = transferAwaitingApproval
+	}
+	if err := s.store.PutTransfer(t.record); err != nil {
+		return streamTransfer{}, fmt.Errorf("can't record transfer: %v", err)
+	}
+	return t, nil
+}
+
+// mint returns the mint named name (a registry symbol or address, or the program's mint if empty), loading it the
+// first time.
+func (s *transferStream) mint(ctx context.Context, name string) (transferMint, error) {
+	var address solanago.PublicKey
+	var err error
+	if name != "" {
+		address, err = lookupToken(name)
+	} else {
+		address, err = programMintAddress()
+	}
+	if err != nil {
+		return transferMint{}, err
+	}
+	if tm, ok := s.mints[address]; ok {
+		return tm, nil
+	}
+	tm, err := loadTransferMint(ctx, s.clients.Read, address)
+	if err != nil {
+		return transferMint{}, err
+	}
+	s.mints[address] = tm
+	return tm, nil
+}
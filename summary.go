@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// transferSummary describes a transfer for the operator to check before it is sent.
+type transferSummary struct {
+	Network     string
+	Token       string
+	Amount      string
+	Receiver    solanago.PublicKey
+	NewAta      bool   // the receiver's token account will be created
+	AtaRent     uint64 // lamports paid by AtaPayer if NewAta
+	AtaPayer    string // "the sender" or "the fee payer"
+	FeeLamports uint64
+	FirstTime   bool
+	Value       string // approximate fiat value of Amount, if a price source is set
+}
+
+// summarizeTransfer gathers what the operator needs to see before sending amount to receiver.
+func summarizeTransfer(ctx context.Context, client RPCClient, mint, receiver solanago.PublicKey, amount string, firstTime bool) (transferSummary, error) {
+	summary := transferSummary{
+		Network:     network,
+		Token:       tokenLabel(ctx, client, mint),
+		Amount:      amount,
+		Receiver:    receiver,
+		FeeLamports: lamportsPerSignature,
+		FirstTime:   firstTime,
+		Value:       fiatValue(ctx, client, mint, amount),
+	}
+	tm, err := loadTransferMint(ctx, client, mint)
+	if err != nil {
+		return transferSummary{}, err
+	}
+	if summary.AtaRent, err = receiverAccountRent(ctx, client, tm, receiver); err != nil {
+		return transferSummary{}, err
+	}
+	summary.NewAta = summary.AtaRent > 0
+	summary.AtaPayer = "the sender"
+	if feePayerURI != "" {
+		summary.AtaPayer = "the fee payer"
+	}
+	return summary, nil
+}
+
+// receiverAccountRent returns the rent, in lamports, of receiver's associated token account for mint if a
+// transfer has to create it, or 0 if it exists.
+func receiverAccountRent(ctx context.Context, client RPCClient, mint transferMint, receiver solanago.PublicKey) (uint64, error) {
+	address, err := mint.tokenAccount(receiver)
+	if err != nil {
+		return 0, fmt.Errorf("%w: can't get ATA for receiver %s: %v", ErrInvalidRecipient, receiver, err)
+	}
+	exists, err := accountExists(ctx, client, address)
+	if err != nil || exists {
+		return 0, err
+	}
+	rent, err := client.GetMinimumBalanceForRentExemption(ctx, mint.accountSize(), rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("can't get rent exemption minimum: %w", classifyRPCError(err))
+	}
+	return rent, nil
+}
+
+// discloseAccountRent logs what creating receiver's associated token account for mint will cost, if the transfer
+// has to create it, or refuses with opts.NoFundRecipient.
+func discloseAccountRent(ctx context.Context, client RPCClient, mint transferMint, receiver solanago.PublicKey, opts TransferOptions) error {
+	rent, err := receiverAccountRent(ctx, client, mint, receiver)
+	if err != nil || rent == 0 {
+		return err
+	}
+	if opts.NoFundRecipient {
+		return fmt.Errorf("%w: receiver %s has no token account for mint %s, and creating one isn't allowed (--no-fund-recipient)", ErrInvalidRecipient, receiver, mint.Address)
+	}
+	payer := "sender"
+	if opts.FeePayer != nil {
+		payer = "fee payer"
+	}
+	slog.Info("receiver has no token account for the mint, creating it", "receiver", receiver, "rent", formatUIAmount(rent, solDecimals)+" SOL", "paid by", payer)
+	return nil
+}
+
+// confirmMainnetOperation is confirmTransfer for operations other than token transfers, such as SOL transfers and
+// delegations: on mainnet it shows summary and requires the operator to type expected, unless --yes was given.
+func confirmMainnetOperation(summary, expected string) error {
+	if clusterKind() != "mainnet" || assumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w: mainnet transactions need confirmation, pass --yes to send non-interactively", ErrAborted)
+	}
+	fmt.Fprintf(os.Stderr, "Network:   %s\n%s", network, summary)
+	ok, err := promptTyped("Transactions can't be reversed.", expected)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: transaction not confirmed", ErrAborted)
+	}
+	return nil
+}
+
+func (s transferSummary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Network:   %s\n", s.Network)
+	fmt.Fprintf(w, "Send:      %s %s\n", s.Amount, s.Token)
+	if s.Value != "" {
+		fmt.Fprintf(w, "Value:     ~%s\n", s.Value)
+	}
+	fmt.Fprintf(w, "To:        %s\n", s.Receiver)
+	if s.FirstTime {
+		fmt.Fprintf(w, "           (no previous transfers to this receiver)\n")
+	}
+	if s.NewAta {
+		fmt.Fprintf(w, "New ATA:   %s SOL rent, paid by %s (--no-fund-recipient refuses instead)\n", formatUIAmount(s.AtaRent, solDecimals), s.AtaPayer)
+	}
+	fmt.Fprintf(w, "Fee:       ~%s SOL\n", formatUIAmount(s.FeeLamports, solDecimals))
+}
+
+// confirmTransfer shows the transfer summary on mainnet and requires the operator to type the amount, unless
+// --yes was given. Other networks aren't prompted.
+func confirmTransfer(ctx context.Context, client RPCClient, mint, receiver solanago.PublicKey, amount string, firstTime bool) error {
+	if clusterKind() != "mainnet" || assumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w: mainnet transfers need confirmation, pass --yes to send non-interactively", ErrAborted)
+	}
+	summary, err := summarizeTransfer(ctx, client, mint, receiver, amount, firstTime)
+	if err != nil {
+		return err
+	}
+	summary.Print(os.Stderr)
+	ok, err := promptTyped("Transfers can't be reversed.", amount)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: transfer not confirmed", ErrAborted)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+func init() {
+	commands["sweep"] = runSweep
+}
+
+func runSweep(ctx context.Context, args []string) error {
+	fs := newFlagSet("sweep")
+	mintFlag := fs.String("mint", "", "Token to sweep, by mint address or registry symbol (default: --token, or the program's wrapped mint)")
+	receiverFlag := fs.String("receiver", "", "Receiver's base58 public key (required)")
+	closeFlag := fs.Bool("close", false, "Close the emptied token account in the same transaction, returning its rent to the signer")
+	dryRunFlag := fs.Bool("dry-run", false, "Build, sign and simulate the sweep without sending it")
+	parseFlags(fs, args)
+
+	if *receiverFlag == "" {
+		return fmt.Errorf("%w: --receiver flag is required", ErrInvalidArgument)
+	}
+	receiverKey, err := solanago.PublicKeyFromBase58(*receiverFlag)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecipient, err)
+	}
+	if err := checkReceiverOwner(receiverKey); err != nil {
+		return err
+	}
+	if *mintFlag != "" {
+		tokenName = *mintFlag
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	var mintAddress solanago.PublicKey
+	if tokenName != "" {
+		mintAddress, err = lookupToken(tokenName)
+	} else {
+		mintAddress, err = programMintAddress()
+	}
+	if err != nil {
+		return err
+	}
+	tm, err := loadTransferMint(ctx, clients.Read, mintAddress)
+	if err != nil {
+		return err
+	}
+	sender := signer.PublicKey()
+	source, err := tm.tokenAccount(sender)
+	if err != nil {
+		return fmt.Errorf("can't get ATA for sender %s: %v", sender, err)
+	}
+	account, err := getTokenAccount(ctx, clients.Read, source)
+	if err != nil {
+		return err
+	}
+	if account == nil || account.Amount == 0 {
+		return fmt.Errorf("%w: %s holds no tokens to sweep", ErrInsufficientFunds, source)
+	}
+	if *closeFlag && !canClose(*account, sender) {
+		return fmt.Errorf("%w: %s can't be closed by the signer (it's frozen or has another close authority)", ErrInvalidArgument, source)
+	}
+	rawAmount := account.Amount
+	uiAmount := formatUIAmount(rawAmount, tm.Decimals)
+
+	opts := TransferOptions{
+		PreInstructions: priorityFeeInstructions(),
+		Mint:            mintAddress,
+	}
+	if *closeFlag {
+		// If more tokens arrive between reading the balance and sending, closing fails and so does the transfer,
+		// so nothing is left behind half done.
+		closeAccount, err := tm.closeAccountInstruction(source, sender, sender)
+		if err != nil {
+			return err
+		}
+		opts.PostInstructions = []solanago.Instruction{closeAccount}
+	}
+	if err := txFormatOptions(ctx, clients.Read, sender, &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+	if err := checkTokenTransfer(ctx, clients.Read, tm, sender, opts.payer(sender), receiverKey); err != nil {
+		return err
+	}
+
+	if *dryRunFlag {
+		tx, err := SignTransfer(ctx, clients.Read, signer, receiverKey, rawAmount, opts)
+		if err != nil {
+			return err
+		}
+		return clients.Sender.Simulate(ctx, tx)
+	}
+
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := enforcePolicy(policy, store, mintAddress, tm.Decimals, receiverKey, rawAmount, 0); err != nil {
+		return err
+	}
+	if needs, err := policy.NeedsApproval(mintAddress, tm.Decimals, rawAmount); err != nil || needs {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: sweeping %s needs approval, send it as a regular transfer", ErrPolicyViolation, uiAmount)
+	}
+	known, err := isKnownReceiver(ctx, clients.Read, store, sender, receiverKey, mintAddress)
+	if err != nil {
+		slog.Warn("can't check transfer history with receiver", "error", err)
+	}
+	if err := confirmTransfer(ctx, clients.Read, mintAddress, receiverKey, uiAmount, !known); err != nil {
+		return err
+	}
+
+	record := newTransferRecord("", sender, receiverKey, mintAddress, uiAmount)
+	record.Value = fiatValue(ctx, clients.Read, mintAddress, uiAmount)
+	if err := store.PutTransfer(record); err != nil {
+		return fmt.Errorf("can't record transfer: %v", err)
+	}
+	slog.Info("sweeping token account", "account", source, "amount", uiAmount, "receiver", receiverKey, "close", *closeFlag)
+	sig, err := sendRecorded(ctx, store, clients, signer, record.ID, receiverKey, rawAmount, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// templatesFile holds the saved transfer templates in the data directory.
+const templatesFile = "templates.json"
+
+// templateFlags are the transfer flags a template can set. Flags given to run-template override them.
+var templateFlags = []string{"receiver", "receiver-token-account", "token", "amount", "memo", "network", "from", "fee-payer", "priority-fee", "send-strategy", "jito-tip"}
+
+func init() {
+	commands["template"] = runTemplate
+	commands["run-template"] = runRunTemplate
+}
+
+// transferTemplate is a named, partly filled-in transfer: the values of some of templateFlags.
+type transferTemplate struct {
+	Name  string            `json:"name"`
+	Flags map[string]string `json:"flags"`
+}
+
+// args returns the template's flags as command-line arguments, in templateFlags order.
+func (t transferTemplate) args() []string {
+	var args []string
+	for _, name := range templateFlags {
+		if value, ok := t.Flags[name]; ok {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	return args
+}
+
+// loadTemplates reads the saved templates in dir. A missing file means there are none.
+func loadTemplates(dir string) ([]transferTemplate, error) {
+	path := filepath.Join(dir, templatesFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read templates: %v", err)
+	}
+	var templates []transferTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	for i, t := range templates {
+		if t.Name == "" {
+			return nil, fmt.Errorf("%w: %s: template %d has no name", ErrInvalidArgument, path, i+1)
+		}
+		for name := range t.Flags {
+			if !slices.Contains(templateFlags, name) {
+				return nil, fmt.Errorf("%w: %s: template %q sets --%s, which templates can't", ErrInvalidArgument, path, t.Name, name)
+			}
+		}
+	}
+	return templates, nil
+}
+
+// saveTemplates writes templates to dir, replacing the saved ones.
+func saveTemplates(dir string, templates []transferTemplate) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("can't create data directory: %v", err)
+	}
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, templatesFile), append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("can't save templates: %v", err)
+	}
+	return nil
+}
+
+func runTemplate(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("%w: usage: template save|list|delete [NAME] [flags]", ErrInvalidArgument)
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "save":
+		return runTemplateSave(args[1:])
+	case "list":
+		fs := newFlagSet("template list")
+		parseFlags(fs, args[1:])
+		return listTemplates()
+	case "delete":
+		return runTemplateDelete(args[1:])
+	}
+	return usage
+}
+
+// runTemplateSave saves the transfer flags given after the template's name as a template, replacing one of the
+// same name with --force.
+func runTemplateSave(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%w: usage: template save NAME --receiver ADDRESS [transfer flags]", ErrInvalidArgument)
+	}
+	name := args[0]
+	fs := newFlagSet("template save")
+	for _, flagName := range templateFlags {
+		if fs.Lookup(flagName) == nil {
+			usage := "Saved as the transfer's --" + flagName
+			if f := flag.CommandLine.Lookup(flagName); f != nil {
+				usage = f.Usage
+			}
+			fs.String(flagName, "", usage)
+		}
+	}
+	force := fs.Bool("force", false, "Replace the template if one of this name exists")
+	parseFlags(fs, args[1:])
+
+	t := transferTemplate{Name: name, Flags: map[string]string{}}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil || !slices.Contains(templateFlags, f.Name) {
+			return
+		}
+		value := f.Value.String()
+		// Check the value the way the transfer will parse it.
+		if setErr := flag.CommandLine.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%w: invalid --%s: %v", ErrInvalidArgument, f.Name, setErr)
+		}
+		t.Flags[f.Name] = value
+	})
+	if err != nil {
+		return err
+	}
+	if t.Flags["receiver"] == "" && t.Flags["receiver-token-account"] == "" {
+		return fmt.Errorf("%w: --receiver or --receiver-token-account is required", ErrInvalidArgument)
+	}
+
+	templates, err := loadTemplates(dataDir)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(templates, func(saved transferTemplate) bool { return saved.Name == name })
+	switch {
+	case i < 0:
+		templates = append(templates, t)
+	case *force:
+		templates[i] = t
+	default:
+		return fmt.Errorf("%w: template %q exists, pass --force to replace it", ErrInvalidArgument, name)
+	}
+	if err := saveTemplates(dataDir, templates); err != nil {
+		return err
+	}
+	slog.Info("saved template", "name", name, "flags", strings.Join(t.args(), " "))
+	return nil
+}
+
+func listTemplates() error {
+	templates, err := loadTemplates(dataDir)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(templates, func(a, b transferTemplate) int { return strings.Compare(a.Name, b.Name) })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFLAGS")
+	for _, t := range templates {
+		fmt.Fprintf(w, "%s\t%s\n", t.Name, strings.Join(t.args(), " "))
+	}
+	return w.Flush()
+}
+
+func runTemplateDelete(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%w: usage: template delete NAME", ErrInvalidArgument)
+	}
+	name := args[0]
+	fs := newFlagSet("template delete")
+	parseFlags(fs, args[1:])
+
+	templates, err := loadTemplates(dataDir)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(templates, func(t transferTemplate) bool { return t.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: no template named %q in %s", ErrInvalidArgument, name, filepath.Join(dataDir, templatesFile))
+	}
+	if err := saveTemplates(dataDir, slices.Delete(templates, i, i+1)); err != nil {
+		return err
+	}
+	slog.Info("deleted template", "name", name)
+	return nil
+}
+
+// runRunTemplate performs the transfer saved as the named template. Transfer flags given after the name, such as
+// --amount, are applied on top of the template's, so the template's are only used where none is given.
+func runRunTemplate(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%w: usage: run-template NAME [transfer flags]", ErrInvalidArgument)
+	}
+	name := args[0]
+	// --data-dir may be among the flags, so they are parsed before the template is looked up.
+	parseFlags(flag.CommandLine, args[1:])
+	if flag.CommandLine.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", ErrInvalidArgument, flag.CommandLine.Arg(0))
+	}
+	templates, err := loadTemplates(dataDir)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(templates, func(t transferTemplate) bool { return t.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: no template named %q in %s", ErrInvalidArgument, name, filepath.Join(dataDir, templatesFile))
+	}
+	// The template's values take precedence over the environment's, but not over the command line.
+	given := map[string]bool{}
+	flag.CommandLine.Visit(func(f *flag.Flag) { given[f.Name] = !envFlags[f.Name] })
+	for _, flagName := range templateFlags {
+		value, ok := templates[i].Flags[flagName]
+		if !ok || given[flagName] {
+			continue
+		}
+		if err := flag.CommandLine.Set(flagName, value); err != nil {
+			return fmt.Errorf("%w: template %q: invalid --%s: %v", ErrInvalidArgument, name, flagName, err)
+		}
+	}
+	slog.Debug("running template", "name", name, "flags", strings.Join(templates[i].args(), " "))
+	return run(ctx)
+}
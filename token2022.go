@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	solanago "github.com/gagliardetto/solana-go"
+	ata "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Token-2022 extension types that affect transfers.
+const (
+	defaultAccountStateExtension = 6
+	memoTransferExtension        = 8
+	transferHookExtension        = 14
+)
+
+// defaultTransferMemo is attached to transfers into token accounts that require incoming transfers to carry a
+// memo, when --memo isn't set.
+const defaultTransferMemo = "token-transfer"
+
+// executeDiscriminator identifies the transfer-hook interface's Execute instruction, both in instruction data and
+// as the TLV entry of the extra-account-metas account listing the accounts Execute needs.
+var executeDiscriminator = func() []byte {
+	sum := sha256.Sum256([]byte("spl-transfer-hook-interface:execute"))
+	return sum[:8]
+}()
+
+// transferMint is what building a transfer needs to know about the mint: which token program owns it and, for
+// Token-2022 mints, its decimals and transfer-hook program.
+type transferMint struct {
+	Address  solanago.PublicKey
+	Program  solanago.PublicKey
+	Decimals uint8
+	// Hook is the transfer-hook program, which Token-2022 invokes on every transfer; zero if there is none.
+	Hook solanago.PublicKey
+	// NonTransferable is set for Token-2022 mints whose tokens can't be transferred at all.
+	NonTransferable bool
+	// DefaultFrozen is set for Token-2022 mints whose new token accounts start frozen, so they can't receive until
+	// the freeze authority thaws them.
+	DefaultFrozen bool
+	// FreezeAuthority is the mint's freeze authority; zero if there is none.
+	FreezeAuthority solanago.PublicKey
+	// Programmable is set for Metaplex programmable NFTs, which are transferred through Token Metadata, checked
+	// against RuleSet if it isn't zero.
+	Programmable bool
+	RuleSet      solanago.PublicKey
+}
+
+// loadTransferMint fetches the mint at address.
+func loadTransferMint(ctx context.Context, client RPCClient, address solanago.PublicKey) (transferMint, error) {
+	res, err := GetAccountInfo(ctx, client, address, rpc.CommitmentFinalized)
+	if err != nil {
+		return transferMint{}, fmt.Errorf("can't get mint account: %w", classifyRPCError(err))
+	}
+	m := transferMint{Address: address, Program: res.Value.Owner}
+	data := res.Value.Data.GetBinary()
+	var mint token.Mint
+	// Token-2022 mints start with the same layout; extensions follow it.
+	if err := bin.NewBorshDecoder(data).Decode(&mint); err != nil {
+		return transferMint{}, fmt.Errorf("can't decode mint: %v", err)
+	}
+	m.Decimals = mint.Decimals
+	if mint.FreezeAuthority != nil {
+		m.FreezeAuthority = *mint.FreezeAuthority
+	}
+	if !m.Program.Equals(solanago.Token2022ProgramID) {
+		// Programmable NFTs are SPL Token mints of a single indivisible token.
+		if mint.Decimals == 0 && mint.Supply == 1 {
+			if m.Programmable, m.RuleSet, err = loadProgrammableConfig(ctx, client, address); err != nil {
+				return transferMint{}, err
+			}
+		}
+		return m, nil
+	}
+	for _, ext := range token2022Extensions(data) {
+		switch {
+		case ext.Type == transferHookExtension && len(ext.Value) >= 64:
+			// TransferHook: authority (32 bytes), then program ID (32 bytes).
+			m.Hook = solanago.PublicKeyFromBytes(ext.Value[32:64])
+		case ext.Type == nonTransferableExtension:
+			m.NonTransferable = true
+		case ext.Type == defaultAccountStateExtension && len(ext.Value) >= 1:
+			m.DefaultFrozen = token.AccountState(ext.Value[0]) == token.Frozen
+		}
+	}
+	return m, nil
+}
+
+// tokenAccount returns owner's associated token account for the mint, which is derived from the token program
+// owning the mint.
+func (m transferMint) tokenAccount(owner solanago.PublicKey) (solanago.PublicKey, error) {
+	if !m.isToken2022() {
+		address, _, err := solanago.FindAssociatedTokenAddress(owner, m.Address)
+		return address, err
+	}
+	address, _, err := solanago.FindProgramAddress([][]byte{owner[:], solanago.Token2022ProgramID[:], m.Address[:]}, solanago.SPLAssociatedTokenAccountProgramID)
+	return address, err
+}
+
+// accountSize returns the size of a new associated token account of the mint, which its rent depends on.
+// Token-2022 accounts carry the account type and the immutable-owner extension, plus the transfer-hook extension
+// if the mint has a hook.
+func (m transferMint) accountSize() uint64 {
+	if !m.isToken2022() {
+		return tokenAccountSize
+	}
+	// Account type byte, then a 4 byte type and length header per extension.
+	size := uint64(tokenAccountSize + 1 + 4)
+	if !m.Hook.IsZero() {
+		size += 4 + 1
+	}
+	return size
+}
+
+func (m transferMint) isToken2022() bool {
+	return m.Program.Equals(solanago.Token2022ProgramID)
+}
+
+// createAccountInstruction creates owner's associated token account, paid for by payer.
+func (m transferMint) createAccountInstruction(payer, owner solanago.PublicKey) (solanago.Instruction, error) {
+	if !m.isToken2022() {
+		return ata.NewCreateInstruction(payer, owner, m.Address).Build(), nil
+	}
+	address, err := m.tokenAccount(owner)
+	if err != nil {
+		return nil, err
+	}
+	// The associated token account program's Create has no data, and takes the token program as an account.
+	return solanago.NewInstruction(solanago.SPLAssociatedTokenAccountProgramID, solanago.AccountMetaSlice{
+		solanago.NewAccountMeta(payer, true, true),
+		solanago.NewAccountMeta(address, true, false),
+		solanago.NewAccountMeta(owner, false, false),
+		solanago.NewAccountMeta(m.Address, false, false),
+		solanago.NewAccountMeta(solanago.SystemProgramID, false, false),
+		solanago.NewAccountMeta(solanago.Token2022ProgramID, false, false),
+	}, nil), nil
+}
+
+// transferInstruction moves amount from source to destination, authorized by owner. Token-2022 transfers use
+// TransferChecked, followed by hookAccounts, the accounts the transfer-hook program needs (see
+// transferHookAccounts).
+func (m transferMint) transferInstruction(source, destination, owner solanago.PublicKey, amount uint64, hookAccounts []*solanago.AccountMeta) (solanago.Instruction, error) {
+	if !m.isToken2022() {
+		return token.NewTransferInstruction(amount, source, destination, owner, nil).Build(), nil
+	}
+	return token2022Instruction(token.NewTransferCheckedInstruction(amount, m.Decimals, source, m.Address, destination, owner, nil).Build(), hookAccounts...)
+}
+
+// closeAccountInstruction closes an empty token account of the mint, authorized by owner, sending its rent to
+// destination.
+func (m transferMint) closeAccountInstruction(account, destination, owner solanago.PublicKey) (solanago.Instruction, error) {
+	instruction := token.NewCloseAccountInstruction(account, destination, owner, nil).Build()
+	if !m.isToken2022() {
+		return instruction, nil
+	}
+	return token2022Instruction(instruction)
+}
+
+// canThaw reports whether one of keys is the mint's freeze authority.
+func (m transferMint) canThaw(keys ...solanago.PublicKey) bool {
+	for _, key := range keys {
+		if !m.FreezeAuthority.IsZero() && m.FreezeAuthority.Equals(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// thawInstruction thaws a token account of a Token-2022 mint, signed by the freeze authority.
+func (m transferMint) thawInstruction(account solanago.PublicKey) (solanago.Instruction, error) {
+	return token2022Instruction(token.NewThawAccountInstruction(account, m.Address, m.FreezeAuthority, nil).Build())
+}
+
+// token2022Instruction turns an instruction built by the token package, which targets the legacy program, into
+// the same instruction for Token-2022 with extra accounts appended. The instruction layouts are shared.
+func token2022Instruction(instruction *token.Instruction, extra ...*solanago.AccountMeta) (solanago.Instruction, error) {
+	data, err := instruction.Data()
+	if err != nil {
+		return nil, err
+	}
+	accounts := append(solanago.AccountMetaSlice(instruction.Accounts()), extra...)
+	return solanago.NewInstruction(solanago.Token2022ProgramID, accounts, data), nil
+}
+
+// requiresMemo reports whether a token account's data has the memo-transfer extension with incoming memos required.
+func requiresMemo(data []byte) bool {
+	for _, ext := range token2022Extensions(data) {
+		if ext.Type == memoTransferExtension && len(ext.Value) >= 1 && ext.Value[0] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// memoInstruction returns a memo instruction without signers.
+func memoInstruction(memo string) solanago.Instruction {
+	return solanago.NewInstruction(solanago.MemoProgramID, solanago.AccountMetaSlice{}, []byte(memo))
+}
+
+// transferHookAccounts resolves the accounts the mint's transfer-hook program needs, as listed in its
+// extra-account-metas account, and returns them followed by the hook program and that account, in the order
+// Token-2022 expects them after the TransferChecked accounts.
+func (m transferMint) transferHookAccounts(ctx context.Context, client RPCClient, source, destination, owner solanago.PublicKey, amount uint64) ([]*solanago.AccountMeta, error) {
+	validation, _, err := solanago.FindProgramAddress([][]byte{[]byte("extra-account-metas"), m.Address[:]}, m.Hook)
+	if err != nil {
+		return nil, err
+	}
+	res, err := GetAccountInfo(ctx, client, validation, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("can't get transfer hook accounts from %s: %w", validation, classifyRPCError(err))
+	}
+	metas, err := parseExtraAccountMetas(res.Value.Data.GetBinary())
+	if err != nil {
+		return nil, fmt.Errorf("invalid extra-account-metas account %s: %v", validation, err)
+	}
+
+	// Seeds refer to the accounts and data of the hook's Execute instruction.
+	accounts := []*solanago.AccountMeta{
+		solanago.NewAccountMeta(source, false, false),
+		solanago.NewAccountMeta(m.Address, false, false),
+		solanago.NewAccountMeta(destination, false, false),
+		solanago.NewAccountMeta(owner, false, false),
+		solanago.NewAccountMeta(validation, false, false),
+	}
+	data := binary.LittleEndian.AppendUint64(append([]byte{}, executeDiscriminator...), amount)
+	r := &seedResolver{ctx: ctx, client: client, accounts: accounts, data: data}
+	for i, meta := range metas {
+		address, err := r.resolve(meta, m.Hook)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve transfer hook account %d: %v", i, err)
+		}
+		accounts = append(accounts, solanago.NewAccountMeta(address, meta.IsWritable, meta.IsSigner))
+		r.accounts = accounts
+	}
+	extra := accounts[5:]
+	return append(extra,
+		solanago.NewAccountMeta(m.Hook, false, false),
+		solanago.NewAccountMeta(validation, false, false),
+	), nil
+}
+
+// extraAccountMeta is one entry of an extra-account-metas account: either a fixed address or a PDA derived from
+// seeds, packed into Config.
+type extraAccountMeta struct {
+	Discriminator uint8
+	Config        [32]byte
+	IsSigner      bool
+	IsWritable    bool
+}
+
+// parseExtraAccountMetas finds the Execute entry of an extra-account-metas account: a TLV entry (8-byte
+// discriminator, u32 length) holding a u32 count followed by 35-byte metas.
+func parseExtraAccountMetas(data []byte) ([]extraAccountMeta, error) {
+	for off := 0; off+12 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[off+8:]))
+		value := data[off+12 : min(off+12+length, len(data))]
+		if string(data[off:off+8]) != string(executeDiscriminator) {
+			off += 12 + length
+			continue
+		}
+		if len(value) < 4 {
+			return nil, errors.New("truncated entry")
+		}
+		count := int(binary.LittleEndian.Uint32(value))
+		if len(value) < 4+35*count {
+			return nil, errors.New("truncated entry")
+		}
+		metas := make([]extraAccountMeta, count)
+		for i := range metas {
+			b := value[4+35*i:]
+			metas[i] = extraAccountMeta{Discriminator: b[0], IsSigner: b[33] != 0, IsWritable: b[34] != 0}
+			copy(metas[i].Config[:], b[1:33])
+		}
+		return metas, nil
+	}
+	return nil, errors.New("no Execute entry")
+}
+
+// seedResolver derives extra account addresses from seeds referring to the Execute instruction's data and
+// accounts, including the extra accounts resolved before.
+type seedResolver struct {
+	ctx      context.Context
+	client   RPCClient
+	accounts []*solanago.AccountMeta
+	data     []byte
+}
+
+// resolve returns the address meta describes. Discriminator 0 is a fixed address, 1 a PDA of the hook program,
+// and 128+i a PDA of the program at account index i.
+func (r *seedResolver) resolve(meta extraAccountMeta, hook solanago.PublicKey) (solanago.PublicKey, error) {
+	switch {
+	case meta.Discriminator == 0:
+		return solanago.PublicKeyFromBytes(meta.Config[:]), nil
+	case meta.Discriminator == 1:
+		return r.pda(meta.Config, hook)
+	case meta.Discriminator >= 128:
+		program, err := r.account(int(meta.Discriminator - 128))
+		if err != nil {
+			return solanago.PublicKey{}, err
+		}
+		return r.pda(meta.Config, program)
+	}
+	return solanago.PublicKey{}, fmt.Errorf("unsupported account type %d", meta.Discriminator)
+}
+
+// pda unpacks the seeds in config and derives the address from them under program. Each seed is a type byte
+// followed by its arguments; type 0 ends the list.
+func (r *seedResolver) pda(config [32]byte, program solanago.PublicKey) (solanago.PublicKey, error) {
+	var seeds [][]byte
+	b := config[:]
+	for len(b) > 0 && b[0] != 0 {
+		var seed []byte
+		var n int
+		switch b[0] {
+		case 1: // literal: length, bytes
+			if len(b) < 2 || len(b) < 2+int(b[1]) {
+				return solanago.PublicKey{}, errors.New("truncated literal seed")
+			}
+			seed, n = b[2:2+int(b[1])], 2+int(b[1])
+		case 2: // instruction data: offset, length
+			if len(b) < 3 || int(b[1])+int(b[2]) > len(r.data) {
+				return solanago.PublicKey{}, errors.New("instruction data seed out of range")
+			}
+			seed, n = r.data[b[1]:int(b[1])+int(b[2])], 3
+		case 3: // account key: account index
+			if len(b) < 2 {
+				return solanago.PublicKey{}, errors.New("truncated account key seed")
+			}
+			key, err := r.account(int(b[1]))
+			if err != nil {
+				return solanago.PublicKey{}, err
+			}
+			seed, n = key[:], 2
+		case 4: // account data: account index, offset, length
+			if len(b) < 4 {
+				return solanago.PublicKey{}, errors.New("truncated account data seed")
+			}
+			data, err := r.accountData(int(b[1]))
+			if err != nil {
+				return solanago.PublicKey{}, err
+			}
+			if int(b[2])+int(b[3]) > len(data) {
+				return solanago.PublicKey{}, errors.New("account data seed out of range")
+			}
+			seed, n = data[b[2]:int(b[2])+int(b[3])], 4
+		default:
+			return solanago.PublicKey{}, fmt.Errorf("unsupported seed type %d", b[0])
+		}
+		seeds = append(seeds, seed)
+		b = b[n:]
+	}
+	address, _, err := solanago.FindProgramAddress(seeds, program)
+	return address, err
+}
+
+// account returns the key of the Execute account at index.
+func (r *seedResolver) account(index int) (solanago.PublicKey, error) {
+	if index >= len(r.accounts) {
+		return solanago.PublicKey{}, fmt.Errorf("account index %d out of range", index)
+	}
+	return r.accounts[index].PublicKey, nil
+}
+
+// accountData fetches the data of the Execute account at index.
+func (r *seedResolver) accountData(index int) ([]byte, error) {
+	key, err := r.account(index)
+	if err != nil {
+		return nil, err
+	}
+	res, err := GetAccountInfo(r.ctx, r.client, key, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("can't get account %s: %w", key, classifyRPCError(err))
+	}
+	return res.Value.Data.GetBinary(), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// tokenError explains one of the SPL token program's custom errors.
+type tokenError struct {
+	message string
+	// hint suggests a fix.
+	hint string
+	// class is the error class the failure belongs to, if any.
+	class error
+}
+
+// tokenErrors are the SPL Token program's custom error codes, shared by Token-2022, that transfers run into.
+var tokenErrors = map[uint64]tokenError{
+	0x0:  {"the account wouldn't be rent exempt", "fund the payer with more SOL", ErrInsufficientFunds},
+	0x1:  {"the source token account doesn't hold enough tokens", "check the sender's balance with `balance`", ErrInsufficientFunds},
+	0x2:  {"the token account belongs to a different mint", "check --token, or that --receiver-token-account holds this token", ErrInvalidRecipient},
+	0x3:  {"the accounts belong to different mints", "check --token, or that --receiver-token-account holds this token", ErrInvalidRecipient},
+	0x4:  {"the signer doesn't own the token account", "sign with the wallet holding the tokens, or as a delegate approved with `approve`", nil},
+	0x5:  {"the mint's supply is fixed", "the mint authority was removed, so no more tokens can be minted", nil},
+	0x6:  {"the account is already in use", "", nil},
+	0x9:  {"the token account doesn't exist or isn't initialized", "create the receiver's associated token account, or check --receiver-token-account", ErrInvalidRecipient},
+	0x11: {"the token account is frozen", "ask the mint's freeze authority to thaw it (see `mint-info`)", nil},
+	0x12: {"the amount's decimals don't match the mint", "check --token; amounts are converted with the mint's decimals", ErrInvalidArgument},
+}
+
+// programFailure matches the log line of a program failing with a custom error.
+var programFailure = regexp.MustCompile(`Program (\w+) failed: custom program error: 0x([0-9a-fA-F]+)`)
+
+// decodeTokenError returns the explanation of the SPL token program error in a failed transaction's logs, if it
+// failed with one.
+func decodeTokenError(logs []string) (tokenError, bool) {
+	for _, line := range logs {
+		m := programFailure.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		program, err := solanago.PublicKeyFromBase58(m[1])
+		if err != nil || !(program.Equals(solanago.TokenProgramID) || program.Equals(solanago.Token2022ProgramID)) {
+			return tokenError{}, false
+		}
+		code, err := strconv.ParseUint(m[2], 16, 64)
+		if err != nil {
+			return tokenError{}, false
+		}
+		e, ok := tokenErrors[code]
+		return e, ok
+	}
+	return tokenError{}, false
+}
+
+// String returns the explanation followed by the hint.
+func (e tokenError) String() string {
+	if e.hint == "" {
+		return e.message
+	}
+	return fmt.Sprintf("%s; %s", e.message, e.hint)
+}
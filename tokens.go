@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+)
+
+// tokenRegistryFile holds the user's own symbol to mint mappings, in the data directory.
+const tokenRegistryFile = "tokens.json"
+
+// builtinTokens maps network and symbol to mint address for well-known SPL tokens.
+var builtinTokens = map[string]map[string]string{
+	"mainnet": {
+		"USDC": "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v",
+		"USDT": "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB",
+		"WSOL": "So11111111111111111111111111111111111111112",
+		"BONK": "DezXAZ8z7PnrnRJjz3wXBoRgixCa6xjnB7YaB1pPB263",
+		"MSOL": "mSoLzYCxHdYgdzU16g5QSh3i5K3z3KZK7ytfqcJm7So",
+	},
+	"devnet": {
+		"USDC": "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU",
+		"WSOL": "So11111111111111111111111111111111111111112",
+	},
+}
+
+func init() {
+	commands["tokens"] = runTokens
+}
+
+// registryEntry is a token known by symbol on the current network.
+type registryEntry struct {
+	Symbol string
+	Mint   string
+	Source string // "builtin" or "user"
+}
+
+// loadTokenRegistry returns the tokens known on network: the built-in list, overridden and extended by the
+// user's tokens.json, which has the same shape as builtinTokens.
+func loadTokenRegistry(dir, network string) (map[string]registryEntry, error) {
+	registry := map[string]registryEntry{}
+	for symbol, mint := range builtinTokens[network] {
+		registry[symbol] = registryEntry{Symbol: symbol, Mint: mint, Source: "builtin"}
+	}
+
+	path := filepath.Join(dir, tokenRegistryFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read token registry: %v", err)
+	}
+	var user map[string]map[string]string
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	for symbol, mint := range user[network] {
+		symbol = strings.ToUpper(symbol)
+		registry[symbol] = registryEntry{Symbol: symbol, Mint: mint, Source: "user"}
+	}
+	return registry, nil
+}
+
+// lookupToken resolves --token: a mint address is used as is, anything else is looked up by symbol in the
+// registry for the current network.
+func lookupToken(name string) (solanago.PublicKey, error) {
+	if mint, err := solanago.PublicKeyFromBase58(name); err == nil {
+		return mint, nil
+	}
+	registry, err := loadTokenRegistry(dataDir, clusterKind())
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	entry, ok := registry[strings.ToUpper(name)]
+	if !ok {
+		return solanago.PublicKey{}, fmt.Errorf("%w: unknown token %q on %s, see `tokens list`", ErrInvalidArgument, name, network)
+	}
+	mint, err := solanago.PublicKeyFromBase58(entry.Mint)
+	if err != nil {
+		return solanago.PublicKey{}, fmt.Errorf("%w: invalid mint for %s in token registry: %v", ErrInvalidArgument, entry.Symbol, err)
+	}
+	return mint, nil
+}
+
+func runTokens(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%w: usage: tokens list [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("tokens list")
+	parseFlags(fs, args[1:])
+
+	registry, err := loadTokenRegistry(dataDir, clusterKind())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tMINT\tSOURCE")
+	for _, symbol := range slices.Sorted(maps.Keys(registry)) {
+		entry := registry[symbol]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Symbol, entry.Mint, entry.Source)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing exports spans for the transfer pipeline and every RPC request using the OTLP/HTTP JSON encoding, so any
+// OpenTelemetry collector can receive them. It is configured with the standard OTEL_* environment variables and
+// disabled unless an OTLP endpoint is set.
+
+// Span kinds and status codes as defined by OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// tracer is nil when tracing is disabled.
+var tracer *otlpExporter
+
+type spanContextKey struct{}
+
+// span is a timed operation. A nil *span is a no-op, so call sites don't need to check whether tracing is enabled.
+type span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	attrs    map[string]any
+}
+
+// startSpan starts a span as a child of the span in ctx, if any, and returns a context carrying it.
+func startSpan(ctx context.Context, name string, kind int, attrs ...any) (context.Context, *span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	s := &span{name: name, kind: kind, start: time.Now(), attrs: map[string]any{}}
+	if parent, ok := ctx.Value(spanContextKey{}).(*span); ok && parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	s.SetAttrs(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// spanFromContext returns the span carried by ctx, or nil.
+func spanFromContext(ctx context.Context) *span {
+	s, _ := ctx.Value(spanContextKey{}).(*span)
+	return s
+}
+
+// SetAttrs sets attributes from alternating keys and values, like slog.
+func (s *span) SetAttrs(kv ...any) {
+	if s == nil {
+		return
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		s.attrs[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+}
+
+// End finishes the span, marking it as failed if err is non-nil, and queues it for export.
+func (s *span) End(err error) {
+	if s == nil {
+		return
+	}
+	tracer.add(s, time.Now(), err)
+}
+
+// traceparent returns the W3C Trace Context header value for s.
+func (s *span) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// setupTracing enables span export when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT is
+// set. Only the http/json protocol is supported.
+func setupTracing() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return
+	}
+	protocol := cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"), os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"), "http/json")
+	if protocol != "http/json" {
+		slog.Warn("tracing disabled: only the http/json OTLP protocol is supported", "protocol", protocol)
+		return
+	}
+
+	headers := map[string]string{}
+	for _, pair := range strings.Split(cmp.Or(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS"), os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")), ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	tracer = &otlpExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), "token-transfer"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	go tracer.loop(5 * time.Second)
+	slog.Debug("tracing enabled", "endpoint", endpoint)
+}
+
+// shutdownTracing exports any spans not sent yet.
+func shutdownTracing() {
+	if tracer != nil {
+		tracer.flush()
+	}
+}
+
+// otlpExporter batches finished spans and posts them to an OTLP/HTTP endpoint.
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []otlpSpan
+}
+
+// maxPendingSpans bounds memory use when the collector is unreachable; further spans are dropped.
+const maxPendingSpans = 2048
+
+func (e *otlpExporter) add(s *span, end time.Time, err error) {
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	if s.parentID != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		out.Status = &otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) < maxPendingSpans {
+		e.pending = append(e.pending, out)
+	}
+}
+
+func (e *otlpExporter) loop(interval time.Duration) {
+	for range time.Tick(interval) {
+		e.flush()
+	}
+}
+
+func (e *otlpExporter) flush() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": e.service}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "github.com/csknk/token-transfer"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		slog.Warn("can't encode spans", "error", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("can't export spans", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	// Not through newRPCClient's transport, so exporting doesn't produce spans of its own.
+	resp, err := e.client.Do(req)
+	if err != nil {
+		slog.Warn("can't export spans", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("can't export spans", "status", resp.StatusCode)
+	}
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case uint64:
+			value = map[string]any{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: k, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// tuiLogFile receives the logs while the TUI owns the terminal, in the data directory.
+	tuiLogFile = "tui.log"
+	// tuiEstimateDelay is how long the form has to be left alone before the fee estimate is refreshed.
+	tuiEstimateDelay = 400 * time.Millisecond
+	// tuiStatusInterval is how often the status pane rereads the transfer being sent.
+	tuiStatusInterval = 500 * time.Millisecond
+	// tuiRecentReceivers is how many past receivers the address book lists after the named wallets.
+	tuiRecentReceivers = 10
+)
+
+// Focusable parts of the TUI, in tab order.
+const (
+	tuiFocusContacts = iota
+	tuiFocusReceiver
+	tuiFocusToken
+	tuiFocusAmount
+	tuiFocusCount
+)
+
+func init() {
+	commands["tui"] = runTUI
+}
+
+// tuiContact is an address book entry.
+type tuiContact struct {
+	Name    string
+	Address solanago.PublicKey
+}
+
+// tuiPlan is a transfer filled in on the form, checked and waiting for confirmation.
+type tuiPlan struct {
+	Receiver solanago.PublicKey
+	Mint     transferMint
+	Amount   uint64 // raw base units
+	Label    string
+}
+
+type (
+	tuiBalancesMsg struct {
+		lines []string
+		err   error
+	}
+	tuiEstimateTickMsg struct{ seq int }
+	tuiEstimateMsg     struct {
+		seq  int
+		cost CostBreakdown
+		err  error
+	}
+	tuiPlanMsg struct {
+		plan tuiPlan
+		err  error
+	}
+	tuiStatusTickMsg struct{}
+	tuiSentMsg       struct {
+		sig solanago.Signature
+		err error
+	}
+)
+
+// tuiModel is the state of the TUI.
+type tuiModel struct {
+	ctx     context.Context
+	clients Clients
+	signer  Signer
+	store   *Store
+	policy  *Policy
+	opts    TransferOptions
+
+	focus    int
+	contacts []tuiContact
+	cursor   int
+	fields   [tuiFocusCount]string
+
+	balances    []string
+	balancesErr error
+
+	estimateSeq int
+	estimate    *CostBreakdown
+	estimateErr error
+
+	plan     *tuiPlan
+	sending  string // id of the transfer being sent
+	status   []string
+	statusOK bool
+}
+
+func runTUI(ctx context.Context, args []string) error {
+	fs := newFlagSet("tui")
+	registerTxFlags(fs)
+	parseFlags(fs, args)
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%w: tui needs a terminal", ErrInvalidArgument)
+	}
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+	store, err := OpenStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("can't open store: %w", err)
+	}
+	defer store.Close()
+	policy, err := loadPolicy(dataDir)
+	if err != nil {
+		return err
+	}
+	opts := TransferOptions{PreInstructions: priorityFeeInstructions(), NoFundRecipient: noFundRecipient}
+	if err := txFormatOptions(ctx, clients.Read, signer.PublicKey(), &opts); err != nil {
+		return err
+	}
+	if opts.FeePayer, err = loadFeePayer(); err != nil {
+		return err
+	}
+
+	// The TUI owns the terminal, so logs go to a file until it exits.
+	logPath := filepath.Join(dataDir, tuiLogFile)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't open log file: %v", err)
+	}
+	defer logFile.Close()
+	setupLogging(logFile, verbose, quiet)
+	defer setupLogging(os.Stderr, verbose, quiet)
+
+	m := &tuiModel{
+		ctx:      ctx,
+		clients:  clients,
+		signer:   signer,
+		store:    store,
+		policy:   policy,
+		opts:     opts,
+		focus:    tuiFocusReceiver,
+		contacts: addressBook(store),
+	}
+	m.fields[tuiFocusToken] = tokenName
+	if _, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run(); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("tui: %v", err)
+	}
+	return nil
+}
+
+// addressBook returns the named wallets and the most recent receivers of confirmed transfers.
+func addressBook(store *Store) []tuiContact {
+	var contacts []tuiContact
+	wallets, err := loadWallets(dataDir)
+	if err != nil {
+		slog.Warn("can't load wallets", "error", err)
+	}
+	for _, w := range wallets {
+		// Keys in secret stores would have to be fetched; list wallets whose address is known locally.
+		if w.PublicKey == "" && strings.Contains(w.Signer, "://") {
+			continue
+		}
+		address, err := walletAddress(w)
+		if err != nil {
+			continue
+		}
+		contacts = append(contacts, tuiContact{Name: w.Name, Address: address})
+	}
+
+	records := store.TransfersSince(time.Time{})
+	slices.SortFunc(records, func(a, b transferRecord) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	seen := map[string]bool{}
+	for _, record := range records {
+		if record.Status != transferConfirmed || seen[record.Receiver] || len(seen) == tuiRecentReceivers {
+			continue
+		}
+		seen[record.Receiver] = true
+		address, err := solanago.PublicKeyFromBase58(record.Receiver)
+		if err != nil {
+			continue
+		}
+		contacts = append(contacts, tuiContact{Name: "sent " + record.CreatedAt.Format("2006-01-02"), Address: address})
+	}
+	return contacts
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return m.loadBalances
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return m, m.key(msg)
+	case tuiBalancesMsg:
+		m.balances, m.balancesErr = msg.lines, msg.err
+	case tuiEstimateTickMsg:
+		if msg.seq == m.estimateSeq {
+			return m, m.runEstimate(msg.seq)
+		}
+	case tuiEstimateMsg:
+		if msg.seq == m.estimateSeq {
+			m.estimate, m.estimateErr = &msg.cost, msg.err
+		}
+	case tuiPlanMsg:
+		if msg.err != nil {
+			m.setStatus(false, msg.err.Error())
+			break
+		}
+		m.plan = &msg.plan
+		m.setStatus(true, fmt.Sprintf("Send %s %s to %s? Transfers can't be reversed. [y/n]", formatUIAmount(msg.plan.Amount, msg.plan.Mint.Decimals), msg.plan.Label, msg.plan.Receiver))
+	case tuiStatusTickMsg:
+		if m.sending == "" {
+			break
+		}
+		if record, ok := m.store.Transfer(m.sending); ok && record.Signature != "" {
+			m.setStatus(true, fmt.Sprintf("Sending %s %s: %s", record.Amount, record.Mint, record.Status), "Signature: "+record.Signature)
+		}
+		return m, tea.Tick(tuiStatusInterval, func(time.Time) tea.Msg { return tuiStatusTickMsg{} })
+	case tuiSentMsg:
+		id := m.sending
+		m.sending = ""
+		if msg.err != nil {
+			m.setStatus(false, "Transfer failed: "+msg.err.Error())
+			break
+		}
+		lines := []string{"Confirmed: " + msg.sig.String()}
+		if link := explorerTxURL(msg.sig.String()); link != "" {
+			lines = append(lines, link)
+		}
+		if record, ok := m.store.Transfer(id); ok && record.Received != "" && record.Received != record.Amount {
+			lines = append(lines, fmt.Sprintf("The receiver got %s of the %s sent", record.Received, record.Amount))
+		}
+		m.setStatus(true, lines...)
+		m.fields[tuiFocusAmount] = ""
+		return m, m.loadBalances
+	}
+	return m, nil
+}
+
+// key handles a key press.
+func (m *tuiModel) key(msg tea.KeyMsg) tea.Cmd {
+	key := msg.String()
+	if key == "ctrl+c" {
+		return tea.Quit
+	}
+	if m.plan != nil {
+		// Waiting for the transfer to be confirmed.
+		plan := *m.plan
+		m.plan = nil
+		if key != "y" {
+			m.setStatus(false, "Transfer cancelled")
+			return nil
+		}
+		return m.send(plan)
+	}
+	switch key {
+	case "esc":
+		return tea.Quit
+	case "tab":
+		m.focus = (m.focus + 1) % tuiFocusCount
+	case "shift+tab":
+		m.focus = (m.focus + tuiFocusCount - 1) % tuiFocusCount
+	case "ctrl+r":
+		return m.loadBalances
+	case "up":
+		if m.focus == tuiFocusContacts {
+			m.cursor = max(m.cursor-1, 0)
+		}
+	case "down":
+		if m.focus == tuiFocusContacts {
+			m.cursor = min(m.cursor+1, max(len(m.contacts)-1, 0))
+		}
+	case "enter":
+		switch {
+		case m.focus == tuiFocusContacts && len(m.contacts) > 0:
+			m.fields[tuiFocusReceiver] = m.contacts[m.cursor].Address.String()
+			m.focus = tuiFocusAmount
+			return m.formChanged()
+		case m.focus != tuiFocusContacts && m.sending == "":
+			m.setStatus(true, "Checking the transfer...")
+			return m.checkPlan
+		}
+	case "backspace":
+		if field := m.fields[m.focus]; m.focus != tuiFocusContacts && field != "" {
+			m.fields[m.focus] = field[:len(field)-1]
+			return m.formChanged()
+		}
+	default:
+		if msg.Type == tea.KeyRunes && m.focus != tuiFocusContacts {
+			m.fields[m.focus] += string(msg.Runes)
+			return m.formChanged()
+		}
+	}
+	return nil
+}
+
+// formChanged schedules a fee estimate for when the form has been left alone for tuiEstimateDelay.
+func (m *tuiModel) formChanged() tea.Cmd {
+	m.estimateSeq++
+	seq := m.estimateSeq
+	return tea.Tick(tuiEstimateDelay, func(time.Time) tea.Msg { return tuiEstimateTickMsg{seq: seq} })
+}
+
+func (m *tuiModel) setStatus(ok bool, lines ...string) {
+	m.status, m.statusOK = lines, ok
+}
+
+// loadBalances reads the signer's SOL and token balances.
+func (m *tuiModel) loadBalances() tea.Msg {
+	owner := m.signer.PublicKey()
+	sol, err := m.clients.Read.GetBalance(m.ctx, owner, rpc.CommitmentConfirmed)
+	if err != nil {
+		return tuiBalancesMsg{err: classifyRPCError(err)}
+	}
+	lines := []string{fmt.Sprintf("%-12s %s", "SOL", formatUIAmount(sol.Value, solDecimals))}
+	balances, err := allBalances(m.ctx, m.clients.Read, owner)
+	if err != nil {
+		return tuiBalancesMsg{lines: lines, err: err}
+	}
+	for _, b := range balances {
+		lines = append(lines, fmt.Sprintf("%-12s %s", tokenLabel(m.ctx, m.clients.Read, b.Mint), formatUIAmount(b.Amount, b.Decimals)))
+	}
+	return tuiBalancesMsg{lines: lines}
+}
+
+// formTransfer parses the form into a receiver, mint and raw amount.
+func (m *tuiModel) formTransfer() (solanago.PublicKey, transferMint, uint64, error) {
+	receiver, err := solanago.PublicKeyFromBase58(strings.TrimSpace(m.fields[tuiFocusReceiver]))
+	if err != nil {
+		return solanago.PublicKey{}, transferMint{}, 0, fmt.Errorf("%w: invalid receiver", ErrInvalidRecipient)
+	}
+	var mintAddress solanago.PublicKey
+	if name := strings.TrimSpace(m.fields[tuiFocusToken]); name != "" {
+		mintAddress, err = lookupToken(name)
+	} else {
+		mintAddress, err = programMintAddress()
+	}
+	if err != nil {
+		return solanago.PublicKey{}, transferMint{}, 0, err
+	}
+	tm, err := loadTransferMint(m.ctx, m.clients.Read, mintAddress)
+	if err != nil {
+		return solanago.PublicKey{}, transferMint{}, 0, err
+	}
+	raw, err := parseUIAmount(strings.TrimSpace(m.fields[tuiFocusAmount]), tm.Decimals)
+	if err != nil {
+		return solanago.PublicKey{}, transferMint{}, 0, err
+	}
+	if raw == 0 {
+		return solanago.PublicKey{}, transferMint{}, 0, fmt.Errorf("%w: amount must be positive", ErrInvalidArgument)
+	}
+	return receiver, tm, raw, nil
+}
+
+// runEstimate returns a command estimating the cost of the transfer on the form.
+func (m *tuiModel) runEstimate(seq int) tea.Cmd {
+	return func() tea.Msg {
+		receiver, tm, raw, err := m.formTransfer()
+		if err != nil {
+			return tuiEstimateMsg{seq: seq, err: err}
+		}
+		opts := m.opts
+		opts.Mint = tm.Address
+		p, err := PrepareTransfer(m.ctx, m.clients.Read, m.signer.PublicKey(), receiver, raw, opts)
+		if err != nil {
+			return tuiEstimateMsg{seq: seq, err: err}
+		}
+		cost, err := EstimateTransferCost(m.ctx, m.clients.Read, p)
+		return tuiEstimateMsg{seq: seq, cost: cost, err: err}
+	}
+}
+
+// checkPlan checks the transfer on the form the way a plain transfer is checked before asking for confirmation.
+func (m *tuiModel) checkPlan() tea.Msg {
+	receiver, tm, raw, err := m.formTransfer()
+	if err != nil {
+		return tuiPlanMsg{err: err}
+	}
+	if err := checkReceiverOwner(receiver); err != nil {
+		return tuiPlanMsg{err: err}
+	}
+	if err := checkTokenTransfer(m.ctx, m.clients.Read, tm, m.signer.PublicKey(), m.opts.payer(m.signer.PublicKey()), receiver); err != nil {
+		return tuiPlanMsg{err: err}
+	}
+	if err := enforcePolicy(m.policy, m.store, tm.Address, tm.Decimals, receiver, raw, 0); err != nil {
+		return tuiPlanMsg{err: err}
+	}
+	return tuiPlanMsg{plan: tuiPlan{Receiver: receiver, Mint: tm, Amount: raw, Label: tokenLabel(m.ctx, m.clients.Read, tm.Address)}}
+}
+
+// send records the confirmed plan and starts sending it; the status pane follows its record until it's done.
+func (m *tuiModel) send(plan tuiPlan) tea.Cmd {
+	uiAmount := formatUIAmount(plan.Amount, plan.Mint.Decimals)
+	record := newTransferRecord("", m.signer.PublicKey(), plan.Receiver, plan.Mint.Address, uiAmount)
+	needs, err := m.policy.NeedsApproval(plan.Mint.Address, plan.Mint.Decimals, plan.Amount)
+	if err != nil {
+		m.setStatus(false, err.Error())
+		return nil
+	}
+	if needs {
+		record.Status = transferAwaitingApproval
+	}
+	if err := m.store.PutTransfer(record); err != nil {
+		m.setStatus(false, "Can't record transfer: "+err.Error())
+		return nil
+	}
+	if needs {
+		m.setStatus(true, fmt.Sprintf("Transfer %s needs approval, see `token-transfer approvals list`", record.ID))
+		return nil
+	}
+	m.sending = record.ID
+	m.setStatus(true, "Signing and sending...")
+	opts := m.opts
+	opts.Mint = plan.Mint.Address
+	return tea.Batch(
+		func() tea.Msg {
+			sig, err := sendRecorded(m.ctx, m.store, m.clients, m.signer, record.ID, plan.Receiver, plan.Amount, opts)
+			return tuiSentMsg{sig: sig, err: err}
+		},
+		tea.Tick(tuiStatusInterval, func(time.Time) tea.Msg { return tuiStatusTickMsg{} }),
+	)
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "token-transfer  %s  signer %s\n\n", network, m.signer.PublicKey())
+
+	b.WriteString("Balances (ctrl+r to refresh)\n")
+	for _, line := range m.balances {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	if m.balancesErr != nil {
+		fmt.Fprintf(&b, "  can't load balances: %v\n", m.balancesErr)
+	}
+
+	b.WriteString("\nAddress book\n")
+	if len(m.contacts) == 0 {
+		b.WriteString("  no named wallets or past receivers\n")
+	}
+	for i, c := range m.contacts {
+		marker := "  "
+		if m.focus == tuiFocusContacts && i == m.cursor {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "%s%-16s %s\n", marker, c.Name, c.Address)
+	}
+
+	b.WriteString("\nTransfer\n")
+	for _, field := range []struct {
+		focus int
+		label string
+	}{{tuiFocusReceiver, "Receiver"}, {tuiFocusToken, "Token"}, {tuiFocusAmount, "Amount"}} {
+		marker, cursor := "  ", ""
+		if m.focus == field.focus {
+			marker, cursor = "> ", "_"
+		}
+		fmt.Fprintf(&b, "%s%-9s %s%s\n", marker, field.label+":", m.fields[field.focus], cursor)
+	}
+	switch {
+	case m.estimateErr != nil:
+		fmt.Fprintf(&b, "  Cost:     %v\n", m.estimateErr)
+	case m.estimate != nil:
+		fmt.Fprintf(&b, "  Cost:     %s SOL (fee %s, priority fee %s, rent %s)\n", formatUIAmount(m.estimate.Total(), solDecimals),
+			formatUIAmount(m.estimate.BaseFee, solDecimals), formatUIAmount(m.estimate.PriorityFee, solDecimals), formatUIAmount(m.estimate.Rent, solDecimals))
+	}
+
+	b.WriteString("\nStatus\n")
+	for _, line := range m.status {
+		if !m.statusOK {
+			line = "error: " + line
+		}
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	b.WriteString("\ntab: next  enter: pick receiver / send  esc: quit\n")
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// verifyReceived checks how much the receiver's balance actually grew in the confirmed transfer sig and records it
+// on the transfer id. It can fall short of the amount sent when the mint charges a transfer fee or a transfer hook
+// moves tokens, which is logged as a warning. The transfer has landed either way, so a failed check only warns.
+func verifyReceived(ctx context.Context, client RPCClient, store *Store, id string, sig solanago.Signature, receiver, mintAddress solanago.PublicKey, amount uint64) {
+	received, err := receivedAmount(ctx, client, sig, receiver, mintAddress)
+	if err != nil {
+		slog.Warn("can't verify the receiver's balance change", "signature", sig, "error", err)
+		return
+	}
+	mint, err := GetMint(ctx, client, mintAddress, rpc.CommitmentConfirmed)
+	if err != nil {
+		slog.Warn("can't verify the receiver's balance change", "signature", sig, "error", classifyRPCError(err))
+		return
+	}
+	decimals := mint.Decimals
+	if received != amount {
+		slog.Warn("receiver's balance changed by a different amount than was sent, e.g. because of a transfer fee or hook",
+			"sent", formatUIAmount(amount, decimals), "received", formatUIAmount(received, decimals), "signature", sig)
+	} else {
+		slog.Debug("verified receiver's balance change", "received", formatUIAmount(received, decimals))
+	}
+	err = store.UpdateTransfer(id, func(record *transferRecord) {
+		record.Received = formatUIAmount(received, decimals)
+	})
+	if err != nil {
+		slog.Error("can't record received amount", "id", id, "error", err)
+	}
+}
+
+// receivedAmount returns how many raw tokens of mint the accounts owned by owner gained in transaction sig, from
+// the transaction's pre and post token balances.
+func receivedAmount(ctx context.Context, client RPCClient, sig solanago.Signature, owner, mint solanago.PublicKey) (uint64, error) {
+	maxVersion := uint64(0)
+	tx, err := client.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return 0, classifyRPCError(err)
+	}
+	if tx.Meta == nil {
+		return 0, errors.New("transaction has no metadata")
+	}
+	pre := ownerTokenBalance(tx.Meta.PreTokenBalances, owner, mint)
+	post := ownerTokenBalance(tx.Meta.PostTokenBalances, owner, mint)
+	if post < pre {
+		return 0, nil
+	}
+	return post - pre, nil
+}
+
+// ownerTokenBalance sums the raw balances of owner's accounts of mint.
+func ownerTokenBalance(balances []rpc.TokenBalance, owner, mint solanago.PublicKey) uint64 {
+	var total uint64
+	for _, balance := range balances {
+		if balance.Owner == nil || !balance.Owner.Equals(owner) || !balance.Mint.Equals(mint) || balance.UiTokenAmount == nil {
+			continue
+		}
+		amount, err := strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+		if err == nil {
+			total += amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// walletsFile holds the named wallets in the data directory.
+const walletsFile = "wallets.json"
+
+// walletName selects a named wallet from walletsFile as the signer.
+var walletName string
+
+func init() {
+	commands["wallets"] = runWallets
+}
+
+// walletConfig is a named wallet as configured in walletsFile.
+type walletConfig struct {
+	Name string `json:"name"`
+	// Signer is where the wallet's key is loaded from, in any form --signer accepts.
+	Signer string `json:"signer"`
+	// PublicKey is optional. It lets `wallets list` show wallets whose key lives in a secret store without
+	// fetching it; keypair files are read for their public key instead.
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// loadWallets reads the named wallets in dir. A missing file means there are none.
+func loadWallets(dir string) ([]walletConfig, error) {
+	path := filepath.Join(dir, walletsFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read wallets: %v", err)
+	}
+	var wallets []walletConfig
+	if err := json.Unmarshal(data, &wallets); err != nil {
+		return nil, fmt.Errorf("%w: can't parse %s: %v", ErrInvalidArgument, path, err)
+	}
+	names := map[string]bool{}
+	for i, w := range wallets {
+		if w.Name == "" || w.Signer == "" {
+			return nil, fmt.Errorf("%w: %s: wallet %d needs a name and a signer", ErrInvalidArgument, path, i+1)
+		}
+		if names[w.Name] {
+			return nil, fmt.Errorf("%w: %s: duplicate wallet %q", ErrInvalidArgument, path, w.Name)
+		}
+		names[w.Name] = true
+	}
+	return wallets, nil
+}
+
+// signerSource returns where the signer is loaded from: the wallet named by --from, --signer, the key in
+// envKeypair, or signerKeyPath.
+func signerSource() (string, error) {
+	if walletName == "" {
+		if signerURI != "" {
+			return signerURI, nil
+		}
+		if os.Getenv(envKeypair) != "" {
+			return "env://" + envKeypair, nil
+		}
+		return signerKeyPath, nil
+	}
+	if signerURI != "" {
+		return "", fmt.Errorf("%w: --from and --signer are mutually exclusive", ErrInvalidArgument)
+	}
+	wallets, err := loadWallets(dataDir)
+	if err != nil {
+		return "", err
+	}
+	for _, w := range wallets {
+		if w.Name == walletName {
+			return w.Signer, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no wallet named %q in %s", ErrInvalidArgument, walletName, filepath.Join(dataDir, walletsFile))
+}
+
+// walletAddress returns a wallet's public key without unlocking it where possible: the configured public key, or
+// the one stored in its keypair file. Keys in secret stores have to be fetched.
+func walletAddress(w walletConfig) (solanago.PublicKey, error) {
+	if w.PublicKey != "" {
+		return solanago.PublicKeyFromBase58(w.PublicKey)
+	}
+	path := w.Signer
+	if scheme, ref, ok := strings.Cut(path, "://"); ok {
+		if scheme != "file" {
+			signer, err := loadSignerFrom(w.Signer)
+			if err != nil {
+				return solanago.PublicKey{}, err
+			}
+			return signer.PublicKey(), nil
+		}
+		path = ref
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	if isEncryptedKeypair(data) {
+		var k encryptedKeypair
+		if err := json.Unmarshal(data, &k); err != nil {
+			return solanago.PublicKey{}, fmt.Errorf("invalid keystore %s: %v", path, err)
+		}
+		return solanago.PublicKeyFromBase58(k.PublicKey)
+	}
+	key, err := parseKeypair(data, path)
+	if err != nil {
+		return solanago.PublicKey{}, err
+	}
+	return key.PublicKey(), nil
+}
+
+func runWallets(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("%w: usage: wallets list [flags]", ErrInvalidArgument)
+	}
+	fs := newFlagSet("wallets list")
+	parseFlags(fs, args[1:])
+
+	wallets, err := loadWallets(dataDir)
+	if err != nil {
+		return err
+	}
+	if len(wallets) == 0 {
+		return fmt.Errorf("%w: no wallets configured in %s", ErrInvalidArgument, filepath.Join(dataDir, walletsFile))
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	mintAddress, _, err := resolveMint(ctx, client)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tADDRESS\tSOL\t%s\n", tokenLabel(ctx, client, mintAddress))
+	for _, wallet := range wallets {
+		address, err := walletAddress(wallet)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t(%v)\t-\t-\n", wallet.Name, err)
+			continue
+		}
+		sol, token := "-", "-"
+		if balance, err := client.GetBalance(ctx, address, rpc.CommitmentConfirmed); err == nil {
+			sol = formatUIAmount(balance.Value, 9)
+		}
+		if b, err := mintBalance(ctx, client, address); err == nil {
+			token = formatUIAmount(b.Amount, b.Decimals)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", wallet.Name, address, sol, token)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+func init() {
+	commands["watch"] = runWatch
+}
+
+// watchEvent is one change to a watched token account. Balance events carry the new balance and the change;
+// transaction events the signature of a transaction that touched the account.
+type watchEvent struct {
+	Type      string `json:"type"` // "balance" or "transaction"
+	Slot      uint64 `json:"slot"`
+	Account   string `json:"account"`
+	Balance   string `json:"balance,omitempty"`
+	Change    string `json:"change,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runWatch(ctx context.Context, args []string) error {
+	fs := newFlagSet("watch")
+	ownerFlag := fs.String("owner", "", "Base58 wallet to watch (defaults to the signer's public key)")
+	fs.StringVar(&tokenName, "mint", "", "Mint address or token symbol; same as --token")
+	jsonOutput := fs.Bool("json", false, "Print one JSON object per event")
+	parseFlags(fs, args)
+
+	owner, err := ownerKey(*ownerFlag)
+	if err != nil {
+		return err
+	}
+	client, err := newReadClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	start, err := mintBalance(ctx, client, owner)
+	if err != nil {
+		return err
+	}
+	endpoint, err := wsEndpoint()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("watching token account", "owner", owner, "account", start.Account, "balance", formatUIAmount(start.Amount, start.Decimals))
+	events := make(chan watchEvent)
+	errc := make(chan error, 1)
+	go func() { errc <- WatchTokenAccount(ctx, endpoint, start, events) }()
+
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		select {
+		case event := <-events:
+			if *jsonOutput {
+				if err := enc.Encode(event); err != nil {
+					return err
+				}
+				continue
+			}
+			switch event.Type {
+			case "balance":
+				fmt.Printf("slot %d: balance %s (%s)\n", event.Slot, event.Balance, event.Change)
+			case "transaction":
+				if event.Error != "" {
+					fmt.Printf("slot %d: transaction %s failed: %s\n", event.Slot, event.Signature, event.Error)
+				} else {
+					fmt.Printf("slot %d: transaction %s\n", event.Slot, event.Signature)
+				}
+			}
+		case err := <-errc:
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// WatchTokenAccount subscribes over the websocket endpoint to the token account in start and sends an event to
+// events for every balance change (relative to start and then to the previous change) and every transaction that
+// mentions the account, until ctx is cancelled or the subscription fails.
+func WatchTokenAccount(ctx context.Context, endpoint string, start tokenBalance, events chan<- watchEvent) error {
+	conn, err := ws.Connect(ctx, endpoint)
+	if err != nil {
+		return fmt.Errorf("%w: can't connect to %s: %v", ErrRPCUnavailable, endpoint, err)
+	}
+	defer conn.Close()
+
+	accountSub, err := conn.AccountSubscribe(start.Account, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("%w: can't subscribe to account: %v", ErrRPCUnavailable, err)
+	}
+	defer accountSub.Unsubscribe()
+	logsSub, err := conn.LogsSubscribeMentions(start.Account, rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("%w: can't subscribe to logs: %v", ErrRPCUnavailable, err)
+	}
+	defer logsSub.Unsubscribe()
+
+	errc := make(chan error, 2)
+	go func() {
+		balance := start.Amount
+		for {
+			res, err := accountSub.Recv(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			var account token.Account
+			if err := bin.NewBorshDecoder(res.Value.Data.GetBinary()).Decode(&account); err != nil {
+				slog.Warn("can't decode token account update", "slot", res.Context.Slot, "error", err)
+				continue
+			}
+			if account.Amount == balance {
+				continue
+			}
+			change := "+" + formatUIAmount(account.Amount-balance, start.Decimals)
+			if account.Amount < balance {
+				change = "-" + formatUIAmount(balance-account.Amount, start.Decimals)
+			}
+			balance = account.Amount
+			event := watchEvent{
+				Type:    "balance",
+				Slot:    res.Context.Slot,
+				Account: start.Account.String(),
+				Balance: formatUIAmount(balance, start.Decimals),
+				Change:  change,
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			res, err := logsSub.Recv(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			event := watchEvent{
+				Type:      "transaction",
+				Slot:      res.Context.Slot,
+				Account:   start.Account.String(),
+				Signature: res.Value.Signature.String(),
+			}
+			if res.Value.Err != nil {
+				event.Error = fmt.Sprint(res.Value.Err)
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errc:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: subscription closed: %v", ErrRPCUnavailable, err)
+	}
+}
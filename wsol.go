@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	solanago "github.com/gagliardetto/solana-go"
+	ata "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+)
+
+func init() {
+	commands["wrap"] = runWrap
+	commands["unwrap"] = runUnwrap
+}
+
+func runWrap(ctx context.Context, args []string) error {
+	fs := newFlagSet("wrap")
+	amountFlag := fs.String("amount", "", "Amount of SOL to wrap, e.g. 1.5 (required)")
+	parseFlags(fs, args)
+
+	if *amountFlag == "" {
+		return fmt.Errorf("%w: --amount flag is required", ErrInvalidArgument)
+	}
+	lamports, err := parseUIAmount(*amountFlag, solDecimals)
+	if err != nil {
+		return err
+	}
+	if lamports == 0 {
+		return fmt.Errorf("%w: --amount must be positive", ErrInvalidArgument)
+	}
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	sig, err := WrapSOL(ctx, clients, signer, lamports)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+func runUnwrap(ctx context.Context, args []string) error {
+	fs := newFlagSet("unwrap")
+	parseFlags(fs, args)
+
+	signer, err := loadSigner()
+	if err != nil {
+		return err
+	}
+	clients, err := connect()
+	if err != nil {
+		return err
+	}
+	defer clients.Close()
+
+	sig, err := UnwrapSOL(ctx, clients, signer)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sig)
+	return nil
+}
+
+// WrapSOL moves lamports into the signer's wrapped SOL (wSOL) token account, creating it if needed, and syncs
+// the account's token balance with its lamports.
+func WrapSOL(ctx context.Context, clients Clients, signer Signer, lamports uint64) (solanago.Signature, error) {
+	owner := signer.PublicKey()
+	wsolAta, _, err := solanago.FindAssociatedTokenAddress(owner, solanago.WrappedSol)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't get wSOL ATA: %v", err)
+	}
+
+	var instructions []solanago.Instruction
+	exists, err := accountExists(ctx, clients.Read, wsolAta)
+	if err != nil {
+		return solanago.Signature{}, err
+	}
+	if !exists {
+		slog.Debug("wSOL ATA does not exist, creating it", "ata", wsolAta)
+		instructions = append(instructions, ata.NewCreateInstruction(owner, owner, solanago.WrappedSol).Build())
+	}
+	instructions = append(instructions,
+		system.NewTransferInstruction(lamports, owner, wsolAta).Build(),
+		token.NewSyncNativeInstruction(wsolAta).Build(),
+	)
+
+	slog.Info("wrapping SOL", "amount", formatUIAmount(lamports, solDecimals), "ata", wsolAta)
+	return sendInstructions(ctx, clients, signer, instructions...)
+}
+
+// UnwrapSOL closes the signer's wSOL token account, returning all of its lamports (the wrapped balance plus
+// the account's rent) to the signer as native SOL.
+func UnwrapSOL(ctx context.Context, clients Clients, signer Signer) (solanago.Signature, error) {
+	owner := signer.PublicKey()
+	wsolAta, _, err := solanago.FindAssociatedTokenAddress(owner, solanago.WrappedSol)
+	if err != nil {
+		return solanago.Signature{}, fmt.Errorf("can't get wSOL ATA: %v", err)
+	}
+	account, err := getTokenAccount(ctx, clients.Read, wsolAta)
+	if err != nil {
+		return solanago.Signature{}, err
+	}
+	if account == nil {
+		return solanago.Signature{}, fmt.Errorf("%w: no wSOL account to unwrap", ErrInvalidArgument)
+	}
+
+	slog.Info("unwrapping SOL", "amount", formatUIAmount(account.Amount, solDecimals), "ata", wsolAta)
+	return sendInstructions(ctx, clients, signer, token.NewCloseAccountInstruction(wsolAta, owner, owner, nil).Build())
+}